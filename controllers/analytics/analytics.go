@@ -0,0 +1,194 @@
+package analytics
+
+import (
+	"database/sql"
+	"hackaton-jam-back/controllers/utils"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+type AnalyticsInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token    string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+		Interval string `json:"interval,omitempty" enum:"hour,day,week" default:"day" doc:"Шаг графика регистраций"`
+	}
+}
+
+type RegistrationPoint struct {
+	Date   time.Time `json:"date" doc:"Начало интервала"`
+	Joined int       `json:"joined" example:"12" doc:"Зарегистрировались"`
+	Exited int       `json:"exited" example:"1" doc:"Вышли"`
+	Total  int       `json:"total" example:"40" doc:"Участников на конец интервала"`
+}
+
+type CountEntry struct {
+	Name  string `json:"name" example:"Go" doc:"Значение"`
+	Count int    `json:"count" example:"7" doc:"Количество участников"`
+}
+
+type TeamsStats struct {
+	Total       int     `json:"total" example:"10" doc:"Всего команд"`
+	Complete    int     `json:"complete" example:"7" doc:"Команды, подходящие под требования к размеру"`
+	Incomplete  int     `json:"incomplete" example:"3" doc:"Команды, не подходящие под требования"`
+	AverageSize float64 `json:"average_size" example:"3.5" doc:"Средний размер команды"`
+	Pending     int     `json:"pending_invites" example:"2" doc:"Неотвеченных приглашений"`
+}
+
+type SubmissionsStats struct {
+	Submitted int     `json:"submitted" example:"8" doc:"Команд, сдавших проект"`
+	Rate      float64 `json:"rate" example:"0.8" doc:"Доля команд, сдавших проект"`
+}
+
+type AnalyticsOutput struct {
+	Body struct {
+		Registered    int                  `json:"registered" example:"40" doc:"Сейчас зарегистрировано"`
+		Exited        int                  `json:"exited" example:"3" doc:"Всего выходов из мероприятия"`
+		Solo          int                  `json:"solo" example:"5" doc:"Участников без команды"`
+		Registrations []*RegistrationPoint `json:"registrations" doc:"Регистрации и выходы по времени"`
+		Teams         TeamsStats           `json:"teams" doc:"Сбор команд"`
+		Submissions   SubmissionsStats     `json:"submissions" doc:"Сдача проектов"`
+		Skills        []*CountEntry        `json:"skills" doc:"Навыки участников"`
+		Locations     []*CountEntry        `json:"locations" doc:"Откуда участники"`
+	}
+}
+
+func GetEventAnalytics(input *AnalyticsInput, db *sql.DB) (*AnalyticsOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	interval := input.Body.Interval
+	if interval == "" {
+		interval = "day"
+	}
+
+	result := new(AnalyticsOutput)
+
+	err = db.QueryRow(
+		"SELECT "+
+			"(SELECT COUNT(DISTINCT member_email) FROM event_members WHERE event_uri = $1), "+
+			"(SELECT COUNT(*) FROM event_exits WHERE event_uri = $1), "+
+			"(SELECT COUNT(DISTINCT event_members.member_email) FROM event_members WHERE event_members.event_uri = $1 AND NOT EXISTS ("+
+			"SELECT 1 FROM teams_members JOIN teams ON teams.id = teams_members.team_id "+
+			"WHERE teams.event_uri = $1 AND teams_members.member_email = event_members.member_email AND teams_members.pending = false))",
+		input.Urid,
+	).Scan(&result.Body.Registered, &result.Body.Exited, &result.Body.Solo)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	if result.Body.Registrations, err = getRegistrations(input.Urid, interval, db); err != nil {
+		return nil, err
+	}
+
+	err = db.QueryRow(
+		"WITH team_sizes AS ("+
+			"SELECT teams.id, COUNT(teams_members.member_email) FILTER (WHERE teams_members.pending = false) AS size, "+
+			"COUNT(teams_members.member_email) FILTER (WHERE teams_members.pending = true) AS pending "+
+			"FROM teams LEFT JOIN teams_members ON teams_members.team_id = teams.id "+
+			"WHERE teams.event_uri = $1 GROUP BY teams.id) "+
+			"SELECT COUNT(team_sizes.id), "+
+			"COUNT(team_sizes.id) FILTER (WHERE "+utils.TeamSizeCondition("team_sizes.size")+"), "+
+			"COALESCE(AVG(team_sizes.size), 0), "+
+			"COALESCE(SUM(team_sizes.pending), 0), "+
+			"COUNT(submissions.id) "+
+			"FROM events LEFT JOIN team_sizes ON true "+
+			"LEFT JOIN submissions ON submissions.team_id = team_sizes.id "+
+			"WHERE events.urid = $1",
+		input.Urid,
+	).Scan(
+		&result.Body.Teams.Total,
+		&result.Body.Teams.Complete,
+		&result.Body.Teams.AverageSize,
+		&result.Body.Teams.Pending,
+		&result.Body.Submissions.Submitted,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	result.Body.Teams.Incomplete = result.Body.Teams.Total - result.Body.Teams.Complete
+	if result.Body.Teams.Total > 0 {
+		result.Body.Submissions.Rate = float64(result.Body.Submissions.Submitted) / float64(result.Body.Teams.Total)
+	}
+
+	result.Body.Skills, err = getCounts(
+		"SELECT skills.skill, COUNT(DISTINCT skills.user_email) AS count FROM skills "+
+			"JOIN event_members ON event_members.member_email = skills.user_email "+
+			"WHERE event_members.event_uri = $1 GROUP BY skills.skill ORDER BY count DESC, skills.skill",
+		input.Urid, db,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Body.Locations, err = getCounts(
+		"SELECT COALESCE(NULLIF(TRIM(users.loc), ''), 'Не указано') AS location, COUNT(DISTINCT users.email) AS count FROM users "+
+			"JOIN event_members ON event_members.member_email = users.email "+
+			"WHERE event_members.event_uri = $1 GROUP BY location ORDER BY count DESC, location",
+		input.Urid, db,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Вышедшие участники тоже когда-то регистрировались, поэтому их вход учитывается
+func getRegistrations(urid string, interval string, db *sql.DB) ([]*RegistrationPoint, error) {
+	rows, err := db.Query(
+		"SELECT period, SUM(joined), SUM(exited), SUM(SUM(joined) - SUM(exited)) OVER (ORDER BY period) FROM ("+
+			"SELECT date_trunc($2, joined_at) AS period, 1 AS joined, 0 AS exited FROM event_members WHERE event_uri = $1 "+
+			"UNION ALL SELECT date_trunc($2, joined_at), 1, 0 FROM event_exits WHERE event_uri = $1 "+
+			"UNION ALL SELECT date_trunc($2, exited_at), 0, 1 FROM event_exits WHERE event_uri = $1"+
+			") AS registrations GROUP BY period ORDER BY period",
+		urid, interval,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	var points []*RegistrationPoint
+	for rows.Next() {
+		point := new(RegistrationPoint)
+		if err := rows.Scan(&point.Date, &point.Joined, &point.Exited, &point.Total); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		points = append(points, point)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return points, nil
+}
+
+func getCounts(query string, urid string, db *sql.DB) ([]*CountEntry, error) {
+	rows, err := db.Query(query, urid)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	var entries []*CountEntry
+	for rows.Next() {
+		entry := new(CountEntry)
+		if err := rows.Scan(&entry.Name, &entry.Count); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		entries = append(entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,209 @@
+package announcements
+
+import (
+	"database/sql"
+	"hackaton-jam-back/controllers/mailer"
+	"hackaton-jam-back/controllers/utils"
+	"log"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/lib/pq"
+)
+
+// Кому отправляется объявление
+const (
+	TargetAll          = "all"
+	TargetTrack        = "track"
+	TargetLeaders      = "leaders"
+	TargetNoSubmission = "no_submission"
+)
+
+// Получатели для каждой аудитории. $1 - мероприятие, $2 - трек (только для track)
+var targetQueries = map[string]string{
+	TargetAll: "SELECT member_email FROM event_members WHERE event_uri = $1",
+	TargetTrack: "SELECT DISTINCT teams_members.member_email FROM teams " +
+		"JOIN teams_members ON teams_members.team_id = teams.id AND teams_members.pending = false " +
+		"WHERE teams.event_uri = $1 AND teams.track = $2",
+	TargetLeaders: "SELECT DISTINCT teamleader FROM teams WHERE event_uri = $1",
+	TargetNoSubmission: "SELECT DISTINCT teams_members.member_email FROM teams " +
+		"JOIN teams_members ON teams_members.team_id = teams.id AND teams_members.pending = false " +
+		"WHERE teams.event_uri = $1 " +
+		"AND NOT EXISTS (SELECT 1 FROM submissions WHERE submissions.team_id = teams.id)",
+}
+
+type Announcement struct {
+	Id         int64     `json:"id" example:"1" doc:"Идентификатор объявления"`
+	Author     string    `json:"author" example:"thatmaidguy@ya.ru" doc:"Кто отправил"`
+	Target     string    `json:"target" example:"all" doc:"Аудитория (all, track, leaders, no_submission)"`
+	Track      string    `json:"track,omitempty" example:"Мобильная разработка" doc:"Трек (для аудитории track)"`
+	Text       string    `json:"text" doc:"Текст объявления"`
+	Recipients int       `json:"recipients" example:"40" doc:"Сколько человек получили"`
+	Emailed    bool      `json:"emailed" doc:"Отправлено ли на почту"`
+	CreatedAt  time.Time `json:"created_at" doc:"Время отправки"`
+}
+
+type AnnouncementSendInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+
+		Target string `json:"target" enum:"all,track,leaders,no_submission" example:"all" doc:"Аудитория: все участники, команды трека, тимлиды или команды без сданных проектов"`
+		Track  string `json:"track,omitempty" example:"Мобильная разработка" doc:"Трек (для аудитории track)"`
+		Text   string `json:"text" minLength:"1" doc:"Текст объявления"`
+	}
+}
+
+type AnnouncementsEventInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type AnnouncementsOutput struct {
+	Body struct {
+		Announcements []*Announcement `json:"announcements" doc:"Отправленные объявления (последние сверху)"`
+	}
+}
+
+func SendAnnouncement(input *AnnouncementSendInput, db *sql.DB) (*AnnouncementsOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	query, ok := targetQueries[input.Body.Target]
+	if !ok {
+		return nil, huma.Error422UnprocessableEntity("Неизвестная аудитория")
+	}
+	args := []any{input.Urid}
+	if input.Body.Target == TargetTrack {
+		if input.Body.Track == "" {
+			return nil, huma.Error422UnprocessableEntity("Нужно указать трек")
+		}
+		args = append(args, input.Body.Track)
+	} else {
+		input.Body.Track = ""
+	}
+
+	recipients, err := getRecipients(query, args, db)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(
+		"INSERT INTO notifications (\"user\", type, \"from\", event_uri, \"text\") "+
+			"SELECT unnest($1::varchar[]), 6, $2, $3, $4",
+		pq.Array(recipients), user.Email, input.Urid, input.Body.Text,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	emailed := mailer.Enabled() && len(recipients) > 0
+
+	_, err = db.Exec(
+		"INSERT INTO event_announcements (event_uri, author, target, track, \"text\", recipients, emailed) "+
+			"VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		input.Urid, user.Email, input.Body.Target, input.Body.Track, input.Body.Text, len(recipients), emailed,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	if emailed {
+		var eventName string
+		if err := db.QueryRow("SELECT name FROM events WHERE urid = $1", input.Urid).Scan(&eventName); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+
+		// Письма уходят в фоне, чтобы не держать запрос
+		go sendEmails(recipients, "Объявление: "+eventName, input.Body.Text)
+	}
+
+	return getAnnouncements(input.Urid, db)
+}
+
+func GetAnnouncements(input *AnnouncementsEventInput, db *sql.DB) (*AnnouncementsOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	return getAnnouncements(input.Urid, db)
+}
+
+func getRecipients(query string, args []any, db *sql.DB) ([]string, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	var recipients []string
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		recipients = append(recipients, email)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return recipients, nil
+}
+
+func getAnnouncements(urid string, db *sql.DB) (*AnnouncementsOutput, error) {
+	rows, err := db.Query(
+		"SELECT id, author, target, track, \"text\", recipients, emailed, created_at "+
+			"FROM event_announcements WHERE event_uri = $1 ORDER BY created_at DESC", urid,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	result := new(AnnouncementsOutput)
+
+	for rows.Next() {
+		announcement := new(Announcement)
+		if err := rows.Scan(
+			&announcement.Id,
+			&announcement.Author,
+			&announcement.Target,
+			&announcement.Track,
+			&announcement.Text,
+			&announcement.Recipients,
+			&announcement.Emailed,
+			&announcement.CreatedAt,
+		); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+
+		result.Body.Announcements = append(result.Body.Announcements, announcement)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
+
+func sendEmails(recipients []string, subject string, text string) {
+	for _, email := range recipients {
+		if err := mailer.Send(email, subject, text); err != nil {
+			log.Println("Не удалось отправить письмо " + email + ": " + err.Error())
+		}
+	}
+}
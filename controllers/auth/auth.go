@@ -4,6 +4,8 @@ import (
 	"crypto/md5"
 	"database/sql"
 	"encoding/hex"
+	"hackaton-jam-back/controllers/invitations"
+	"hackaton-jam-back/controllers/mailer"
 	"hackaton-jam-back/controllers/utils"
 	"log"
 	"strconv"
@@ -82,6 +84,20 @@ func Register(input *RegisterInput, db *sql.DB) (*LoginResponseOutput, error) {
 		return nil, huma.Error422UnprocessableEntity(err.Error())
 	}
 
+	// Если организатор заранее пригласил этот e-mail, записываем на мероприятия только после
+	// подтверждения почты, поэтому сразу отправляем письмо со ссылкой
+	if perm == 0 && mailer.Enabled() {
+		invited, err := invitations.HasPendingInvitations(input.Body.Email, db)
+		if err != nil {
+			log.Println(err.Error())
+		}
+		if invited {
+			if err := sendVerification(input.Body.Email, db); err != nil {
+				log.Println(err.Error())
+			}
+		}
+	}
+
 	// Сразу входим
 	logindata := new(LoginInput)
 	logindata.Body.Email = input.Body.Email
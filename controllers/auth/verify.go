@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"hackaton-jam-back/controllers/invitations"
+	"hackaton-jam-back/controllers/mailer"
+	"hackaton-jam-back/controllers/utils"
+	"log"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+// Сколько живет ссылка подтверждения почты
+const VerificationTTL = 24 * time.Hour
+
+type VerifyEmailInput struct {
+	Code string `path:"code" maxLength:"64" example:"3f2a9c0d4e5b6a7f8c9d0e1f" doc:"Код из письма"`
+}
+
+type VerificationOutput struct {
+	Body struct {
+		Email    string `json:"email" example:"thatmaidguy@ya.ru" doc:"E-mail пользователя"`
+		Verified bool   `json:"verified" doc:"Подтверждена ли почта"`
+		Sent     bool   `json:"sent,omitempty" doc:"Отправлено ли письмо со ссылкой"`
+	}
+}
+
+// Отправляет письмо со ссылкой подтверждения. Повторный запрос заменяет старую ссылку
+func SendVerification(input *utils.JustAccessTokenInput, db *sql.DB) (*VerificationOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+
+	result := new(VerificationOutput)
+	result.Body.Email = user.Email
+
+	if err := db.QueryRow("SELECT email_verified FROM users WHERE email = $1", user.Email).Scan(&result.Body.Verified); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if result.Body.Verified {
+		return result, nil
+	}
+
+	if !mailer.Enabled() {
+		return nil, huma.Error422UnprocessableEntity("Отправка писем не настроена")
+	}
+
+	if err := sendVerification(user.Email, db); err != nil {
+		return nil, err
+	}
+
+	result.Body.Sent = true
+	return result, nil
+}
+
+// Подтверждение почты заодно записывает на мероприятия, куда этот e-mail пригласили заранее:
+// до подтверждения нельзя быть уверенным, что адрес принадлежит пользователю
+func VerifyEmail(input *VerifyEmailInput, db *sql.DB) (*VerificationOutput, error) {
+	result := new(VerificationOutput)
+
+	var perms int
+	if err := db.QueryRow(
+		"WITH used AS (DELETE FROM email_verifications WHERE code = $1 AND expires_at > now() RETURNING email) "+
+			"UPDATE users SET email_verified = true WHERE email = (SELECT email FROM used) RETURNING email, perms",
+		input.Code,
+	).Scan(&result.Body.Email, &perms); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, huma.Error404NotFound("Ссылка недействительна или устарела")
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	if perms == 0 {
+		if err := invitations.AcceptInvitations(result.Body.Email, db); err != nil {
+			log.Println(err.Error())
+		}
+	}
+
+	result.Body.Verified = true
+	return result, nil
+}
+
+// Создает новую ссылку подтверждения (старая перестает работать) и отправляет письмо в фоне
+func sendVerification(email string, db *sql.DB) error {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return huma.Error500InternalServerError(err.Error())
+	}
+	code := hex.EncodeToString(buf)
+
+	_, err := db.Exec(
+		"INSERT INTO email_verifications (email, code, expires_at) VALUES ($1, $2, $3) "+
+			"ON CONFLICT (email) DO UPDATE SET code = EXCLUDED.code, expires_at = EXCLUDED.expires_at",
+		email, code, time.Now().Add(VerificationTTL),
+	)
+	if err != nil {
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	link := os.Getenv("PUBLIC_URL") + "/api/verify/" + url.PathEscape(code)
+	text := "Чтобы подтвердить почту, перейдите по ссылке:\n" + link + "\n\nСсылка действует сутки."
+
+	// Письмо уходит в фоне, чтобы не держать запрос
+	go func() {
+		if err := mailer.Send(email, "Подтверждение почты", text); err != nil {
+			log.Println("Не удалось отправить письмо " + email + ": " + err.Error())
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,420 @@
+package cases
+
+import (
+	"database/sql"
+	"hackaton-jam-back/controllers/utils"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/lib/pq"
+)
+
+type Case struct {
+	Id             int64                  `json:"id" example:"1" doc:"Идентификатор кейса"`
+	EventUri       string                 `json:"event_urid" example:"example_events" doc:"Ссылка на мероприятие"`
+	Partner        string                 `json:"partner" example:"Супер-банк" doc:"Партнер, предложивший кейс"`
+	PartnerLogoUrl string                 `json:"partner_logo_url,omitempty" doc:"Логотип партнера"`
+	Title          string                 `json:"title" example:"Чат-бот для банка" doc:"Название кейса"`
+	Description    string                 `json:"desc" doc:"Описание задачи"`
+	Attachments    []string               `json:"attachments" doc:"Ссылки на материалы кейса"`
+	Capacity       int                    `json:"capacity" example:"5" doc:"Сколько команд может взять кейс (0 - без ограничений)"`
+	Taken          int                    `json:"taken" example:"3" doc:"Сколько команд уже выбрали кейс"`
+	Prize          string                 `json:"prize,omitempty" example:"100 000 рублей" doc:"Приз от партнера"`
+	Judges         []*utils.UserShortInfo `json:"judges" doc:"Жюри партнера"`
+}
+
+type CasesInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+}
+
+type CaseAddInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body CaseFields
+}
+
+type CaseEditInput struct {
+	Id   int64 `path:"id" example:"1" doc:"Идентификатор кейса"`
+	Body CaseFields
+}
+
+type CaseFields struct {
+	Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+
+	Partner        string   `json:"partner" maxLength:"255" example:"Супер-банк" doc:"Партнер, предложивший кейс"`
+	PartnerLogoUrl string   `json:"partner_logo_url,omitempty" maxLength:"255" doc:"Логотип партнера"`
+	Title          string   `json:"title" maxLength:"255" example:"Чат-бот для банка" doc:"Название кейса"`
+	Description    string   `json:"desc,omitempty" doc:"Описание задачи"`
+	Attachments    []string `json:"attachments,omitempty" maxItems:"20" doc:"Ссылки на материалы кейса"`
+	Capacity       int      `json:"capacity,omitempty" minimum:"0" example:"5" doc:"Сколько команд может взять кейс (0 - без ограничений)"`
+	Prize          string   `json:"prize,omitempty" maxLength:"255" example:"100 000 рублей" doc:"Приз от партнера"`
+}
+
+type CaseDelInput struct {
+	Id   int64 `path:"id" example:"1" doc:"Идентификатор кейса"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type CaseJudgesInput struct {
+	Id   int64 `path:"id" example:"1" doc:"Идентификатор кейса"`
+	Body struct {
+		Token  string   `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+		Judges []string `json:"judges" doc:"E-mail членов жюри партнера"`
+	}
+}
+
+type CaseDeadlineInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token    string    `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+		Deadline time.Time `json:"deadline,omitempty" doc:"До какого момента команды могут выбрать или сменить кейс (если не указан - в любое время)"`
+	}
+}
+
+type CasesOutput struct {
+	Body struct {
+		Deadline *time.Time `json:"deadline,omitempty" doc:"До какого момента команды могут выбрать или сменить кейс"`
+		Cases    []*Case    `json:"cases" doc:"Кейсы партнеров"`
+	}
+}
+
+type CaseOutput struct {
+	Body Case
+}
+
+func GetCases(input *CasesInput, db *sql.DB) (*CasesOutput, error) {
+	return getCases(input.Urid, db)
+}
+
+func AddCase(input *CaseAddInput, db *sql.DB) (*CaseOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	if err := checkFields(&input.Body); err != nil {
+		return nil, err
+	}
+
+	var id int64
+	if err := db.QueryRow(
+		"INSERT INTO event_cases (event_uri, partner, partner_logo_url, title, \"desc\", attachments, capacity, prize) "+
+			"VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, NULLIF($8, '')) RETURNING id",
+		input.Urid, input.Body.Partner, input.Body.PartnerLogoUrl, input.Body.Title, input.Body.Description,
+		pq.Array(cleanLinks(input.Body.Attachments)), input.Body.Capacity, input.Body.Prize,
+	).Scan(&id); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return getCaseOutput(id, db)
+}
+
+func EditCase(input *CaseEditInput, db *sql.DB) (*CaseOutput, error) {
+	current, err := checkCaseOrganizator(input.Body.Token, input.Id, db)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkFields(&input.Body); err != nil {
+		return nil, err
+	}
+	if input.Body.Capacity > 0 && input.Body.Capacity < current.Taken {
+		return nil, huma.Error422UnprocessableEntity("Кейс уже выбрали больше команд, чем новое ограничение")
+	}
+
+	_, err = db.Exec(
+		"UPDATE event_cases SET partner = $2, partner_logo_url = NULLIF($3, ''), title = $4, \"desc\" = $5, "+
+			"attachments = $6, capacity = $7, prize = NULLIF($8, '') WHERE id = $1",
+		input.Id, input.Body.Partner, input.Body.PartnerLogoUrl, input.Body.Title, input.Body.Description,
+		pq.Array(cleanLinks(input.Body.Attachments)), input.Body.Capacity, input.Body.Prize,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return getCaseOutput(input.Id, db)
+}
+
+// Удалить можно только кейс, который еще никто не выбрал и по которому нет итогов
+func DelCase(input *CaseDelInput, db *sql.DB) (*CasesOutput, error) {
+	current, err := checkCaseOrganizator(input.Body.Token, input.Id, db)
+	if err != nil {
+		return nil, err
+	}
+
+	if current.Taken > 0 {
+		return nil, huma.Error422UnprocessableEntity("Этот кейс уже выбрали команды")
+	}
+
+	var hasResults bool
+	if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM event_results WHERE case_id = $1)", input.Id).Scan(&hasResults); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if hasResults {
+		return nil, huma.Error422UnprocessableEntity("По этому кейсу уже есть итоги")
+	}
+
+	_, err = db.Exec("DELETE FROM case_judges WHERE case_id = $1", input.Id)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	_, err = db.Exec("DELETE FROM event_cases WHERE id = $1", input.Id)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return getCases(current.EventUri, db)
+}
+
+func AddCaseJudges(input *CaseJudgesInput, db *sql.DB) (*CaseOutput, error) {
+	if _, err := checkCaseOrganizator(input.Body.Token, input.Id, db); err != nil {
+		return nil, err
+	}
+
+	for _, email := range input.Body.Judges {
+		if _, err := utils.GetUserUsernameByEmail(email, db); err != nil {
+			return nil, huma.Error422UnprocessableEntity("Пользователь " + email + " не найден")
+		}
+
+		_, err := db.Exec(
+			"INSERT INTO case_judges (case_id, judge_email) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+			input.Id, email,
+		)
+		if err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+	}
+
+	return getCaseOutput(input.Id, db)
+}
+
+// Оценки снятого члена жюри остаются, но он больше не может их менять
+func DelCaseJudges(input *CaseJudgesInput, db *sql.DB) (*CaseOutput, error) {
+	if _, err := checkCaseOrganizator(input.Body.Token, input.Id, db); err != nil {
+		return nil, err
+	}
+
+	_, err := db.Exec("DELETE FROM case_judges WHERE case_id = $1 AND judge_email = ANY($2)", input.Id, pq.Array(input.Body.Judges))
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return getCaseOutput(input.Id, db)
+}
+
+func SetCaseDeadline(input *CaseDeadlineInput, db *sql.DB) (*CasesOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	var deadline sql.NullTime
+	if !input.Body.Deadline.IsZero() {
+		deadline = sql.NullTime{Time: input.Body.Deadline, Valid: true}
+	}
+
+	res, err := db.Exec("UPDATE events SET case_deadline = $2 WHERE urid = $1", input.Urid, deadline)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, huma.Error403Forbidden("Этого события нет XP")
+	}
+
+	return getCases(input.Urid, db)
+}
+
+func checkFields(fields *CaseFields) error {
+	fields.Partner = strings.TrimSpace(fields.Partner)
+	fields.Title = strings.TrimSpace(fields.Title)
+	if fields.Partner == "" {
+		return huma.Error422UnprocessableEntity("Нужно указать партнера")
+	}
+	if fields.Title == "" {
+		return huma.Error422UnprocessableEntity("Название кейса не должно быть пустым")
+	}
+
+	links := append([]string{fields.PartnerLogoUrl}, fields.Attachments...)
+	for _, link := range links {
+		if link == "" {
+			continue
+		}
+		if _, err := url.ParseRequestURI(link); err != nil {
+			return huma.Error422UnprocessableEntity("Неверная ссылка: " + link)
+		}
+	}
+
+	return nil
+}
+
+func cleanLinks(links []string) []string {
+	result := []string{}
+	for _, link := range links {
+		if link = strings.TrimSpace(link); link != "" {
+			result = append(result, link)
+		}
+	}
+	return result
+}
+
+// Возвращает кейс, если пользователь организатор его события
+func checkCaseOrganizator(token string, id int64, db *sql.DB) (*Case, error) {
+	user, err := utils.GetUserEmailByToken(token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+
+	current, err := getCase(id, db)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := utils.CheckEventOrganizator(user, current.EventUri, db); err != nil {
+		return nil, err
+	}
+
+	return current, nil
+}
+
+const caseColumns = "event_cases.id, event_cases.event_uri, event_cases.partner, event_cases.partner_logo_url, " +
+	"event_cases.title, event_cases.\"desc\", event_cases.attachments, event_cases.capacity, " +
+	"(SELECT COUNT(*) FROM teams WHERE teams.case_id = event_cases.id), event_cases.prize"
+
+func scanCase(row interface{ Scan(dest ...any) error }) (*Case, error) {
+	item := new(Case)
+	var logo, prize sql.NullString
+	if err := row.Scan(
+		&item.Id,
+		&item.EventUri,
+		&item.Partner,
+		&logo,
+		&item.Title,
+		&item.Description,
+		pq.Array(&item.Attachments),
+		&item.Capacity,
+		&item.Taken,
+		&prize,
+	); err != nil {
+		return nil, err
+	}
+	item.PartnerLogoUrl = logo.String
+	item.Prize = prize.String
+
+	return item, nil
+}
+
+func getCase(id int64, db *sql.DB) (*Case, error) {
+	item, err := scanCase(db.QueryRow("SELECT "+caseColumns+" FROM event_cases WHERE id = $1", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, huma.Error404NotFound("Такого кейса нет")
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	if item.Judges, err = getCaseJudges(id, db); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+func getCaseOutput(id int64, db *sql.DB) (*CaseOutput, error) {
+	item, err := getCase(id, db)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CaseOutput{Body: *item}, nil
+}
+
+func getCaseJudges(id int64, db *sql.DB) ([]*utils.UserShortInfo, error) {
+	rows, err := db.Query("SELECT judge_email FROM case_judges WHERE case_id = $1 ORDER BY judge_email", id)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	judges := []*utils.UserShortInfo{}
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+
+		judge, err := utils.GetUserShortInfo(email, db)
+		if err != nil {
+			return nil, err
+		}
+		judges = append(judges, judge)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return judges, nil
+}
+
+func getDeadline(urid string, db *sql.DB) (*time.Time, error) {
+	var deadline sql.NullTime
+	if err := db.QueryRow("SELECT case_deadline FROM events WHERE urid = $1", urid).Scan(&deadline); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, huma.Error404NotFound("Этого события нет XP")
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if !deadline.Valid {
+		return nil, nil
+	}
+
+	return &deadline.Time, nil
+}
+
+func getCases(urid string, db *sql.DB) (*CasesOutput, error) {
+	deadline, err := getDeadline(urid, db)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT "+caseColumns+" FROM event_cases WHERE event_uri = $1 ORDER BY event_cases.id", urid)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	result := new(CasesOutput)
+	result.Body.Deadline = deadline
+	result.Body.Cases = []*Case{}
+
+	for rows.Next() {
+		item, err := scanCase(rows)
+		if err != nil {
+			rows.Close()
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		result.Body.Cases = append(result.Body.Cases, item)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	for _, item := range result.Body.Cases {
+		if item.Judges, err = getCaseJudges(item.Id, db); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,97 @@
+package cases
+
+import (
+	"database/sql"
+	"hackaton-jam-back/controllers/teams"
+	"hackaton-jam-back/controllers/utils"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+type TeamCaseInput struct {
+	Id   int64 `path:"id" example:"0" doc:"Идентификатор команды"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+
+		CaseId int64 `json:"case_id" example:"1" doc:"Кейс партнера (0 - отказаться от кейса)"`
+	}
+}
+
+// Тимлид выбирает кейс, пока не прошел срок выбора и на кейсе есть места
+func ChooseCase(input *TeamCaseInput, db *sql.DB) (*teams.TeamInfoOutput, error) {
+	// Проверяем, что пользователь тимлид
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil || user.Perms != 0 {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+
+	var eventUri, teamleader string
+	var current sql.NullInt64
+	if err := db.QueryRow("SELECT event_uri, teamleader, case_id FROM teams WHERE id = $1", input.Id).Scan(&eventUri, &teamleader, &current); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, huma.Error403Forbidden("Этой команды нет")
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if teamleader != user.Email {
+		return nil, huma.Error403Forbidden("Вы не тимлид команды")
+	}
+
+	if current.Int64 == input.Body.CaseId {
+		return teams.GetTeamInfo(input.Id, db)
+	}
+
+	deadline, err := getDeadline(eventUri, db)
+	if err != nil {
+		return nil, err
+	}
+	if deadline != nil && time.Now().After(*deadline) {
+		return nil, huma.Error422UnprocessableEntity("Срок выбора кейса уже прошел")
+	}
+
+	if input.Body.CaseId == 0 {
+		if _, err := db.Exec("UPDATE teams SET case_id = NULL WHERE id = $1", input.Id); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		return teams.GetTeamInfo(input.Id, db)
+	}
+
+	chosen, err := getCase(input.Body.CaseId, db)
+	if err != nil {
+		return nil, err
+	}
+	if chosen.EventUri != eventUri {
+		return nil, huma.Error422UnprocessableEntity("Этот кейс из другого события")
+	}
+
+	// Строка кейса блокируется до конца транзакции: команды, которые выбирают этот же кейс,
+	// ждут своей очереди и считают места уже с учетом этой записи
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer tx.Rollback()
+
+	var capacity, taken int
+	if err := tx.QueryRow("SELECT capacity FROM event_cases WHERE id = $1 FOR UPDATE", input.Body.CaseId).Scan(&capacity); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if err := tx.QueryRow(
+		"SELECT COUNT(*) FROM teams WHERE case_id = $1 AND id <> $2", input.Body.CaseId, input.Id,
+	).Scan(&taken); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if capacity > 0 && taken >= capacity {
+		return nil, huma.Error422UnprocessableEntity("На этом кейсе не осталось мест")
+	}
+
+	if _, err := tx.Exec("UPDATE teams SET case_id = $2 WHERE id = $1", input.Id, input.Body.CaseId); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return teams.GetTeamInfo(input.Id, db)
+}
@@ -0,0 +1,434 @@
+package certificates
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"hackaton-jam-back/controllers/utils"
+	"strings"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/lib/pq"
+)
+
+// Типы сертификатов
+const (
+	TypeParticipation = "participation"
+	TypeWinner        = "winner"
+	TypeJury          = "jury"
+	TypeMentor        = "mentor"
+)
+
+// Шаблоны по умолчанию, если организатор не настроил свои
+var defaultTemplates = map[string]Template{
+	TypeParticipation: {
+		Type:  TypeParticipation,
+		Title: "Сертификат участника",
+		Text:  "Настоящим подтверждается, что {name} принял(а) участие в мероприятии «{event}», проходившем с {start} по {end}.",
+	},
+	TypeWinner: {
+		Type:  TypeWinner,
+		Title: "Диплом победителя",
+		Text:  "Настоящим подтверждается, что {name} стал(а) победителем мероприятия «{event}» ({details}), проходившего с {start} по {end}.",
+	},
+	TypeJury: {
+		Type:  TypeJury,
+		Title: "Сертификат члена жюри",
+		Text:  "Настоящим подтверждается, что {name} входил(а) в состав жюри мероприятия «{event}», проходившего с {start} по {end}.",
+	},
+	TypeMentor: {
+		Type:  TypeMentor,
+		Title: "Сертификат ментора",
+		Text:  "Настоящим подтверждается, что {name} выступал(а) ментором на мероприятии «{event}», проходившем с {start} по {end}.",
+	},
+}
+
+type Template struct {
+	Type   string `json:"type" example:"participation" doc:"Тип сертификата (participation, winner, jury, mentor)"`
+	Title  string `json:"title" example:"Сертификат участника" doc:"Заголовок"`
+	Text   string `json:"text" doc:"Текст сертификата. Подстановки: {name}, {event}, {start}, {end}, {details}, {code}"`
+	Signer string `json:"signer" example:"Организатор Организаторов" doc:"Подпись"`
+}
+
+type Certificate struct {
+	Code      string    `json:"code" example:"3F9A0C12B7E45D80" doc:"Код проверки"`
+	Type      string    `json:"type" example:"participation" doc:"Тип сертификата"`
+	FullName  string    `json:"full_name" example:"Иванов Иван Иванович" doc:"ФИО получателя"`
+	Email     string    `json:"email" example:"thatmaidguy@ya.ru" doc:"E-mail получателя"`
+	EventUri  string    `json:"event_urid" example:"example_events" doc:"Ссылка на мероприятие"`
+	EventName string    `json:"event_name" example:"Example GameJam" doc:"Название мероприятия"`
+	StartTime time.Time `json:"start_time" doc:"Начало проведения"`
+	EndTime   time.Time `json:"end_time" doc:"Конец проведения"`
+	Details   string    `json:"details,omitempty" example:"1 место" doc:"Подробности (место, номинация)"`
+	IssuedAt  time.Time `json:"issued_at" doc:"Дата выдачи"`
+}
+
+type TemplateSetInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+
+		Type   string `json:"type" enum:"participation,winner,jury,mentor" example:"participation" doc:"Тип сертификата"`
+		Title  string `json:"title" example:"Сертификат участника" doc:"Заголовок"`
+		Text   string `json:"text" doc:"Текст сертификата. Подстановки: {name}, {event}, {start}, {end}, {details}, {code}"`
+		Signer string `json:"signer,omitempty" example:"Организатор Организаторов" doc:"Подпись"`
+	}
+}
+
+type CertificatesEventInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type CertificatesIssueInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token  string   `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+		Type   string   `json:"type" enum:"participation,winner,jury,mentor" example:"participation" doc:"Тип сертификата"`
+		Emails []string `json:"emails,omitempty" doc:"Кому выдать (если пусто - всем, кому положено)"`
+	}
+}
+
+type TemplatesOutput struct {
+	Body struct {
+		Templates []*Template `json:"templates" doc:"Шаблоны сертификатов (с учетом шаблонов по умолчанию)"`
+	}
+}
+
+type CertificatesOutput struct {
+	Body struct {
+		Certificates []*Certificate `json:"certificates" doc:"Сертификаты"`
+	}
+}
+
+type CertificateVerifyOutput struct {
+	Body struct {
+		Valid       bool         `json:"valid" doc:"Подлинный ли сертификат"`
+		Certificate *Certificate `json:"certificate,omitempty" doc:"Данные сертификата"`
+	}
+}
+
+type CertificatePdfOutput struct {
+	ContentType        string `header:"Content-Type"`
+	ContentDisposition string `header:"Content-Disposition"`
+	Body               []byte
+}
+
+const certificateColumns = "certificates.code, certificates.type, users.first_name, users.last_name, users.middle_name, " +
+	"certificates.user_email, certificates.event_uri, events.name, events.start_time, events.end_time, " +
+	"certificates.details, certificates.issued_at"
+
+const certificateJoins = "FROM certificates INNER JOIN users ON certificates.user_email = users.email " +
+	"INNER JOIN events ON certificates.event_uri = events.urid "
+
+func GetTemplates(input *CertificatesEventInput, db *sql.DB) (*TemplatesOutput, error) {
+	if _, err := checkOrganizator(input.Body.Token, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	result := new(TemplatesOutput)
+	for _, t := range []string{TypeParticipation, TypeWinner, TypeJury, TypeMentor} {
+		template, err := getTemplate(input.Urid, t, db)
+		if err != nil {
+			return nil, err
+		}
+		result.Body.Templates = append(result.Body.Templates, template)
+	}
+
+	return result, nil
+}
+
+func SetTemplate(input *TemplateSetInput, db *sql.DB) (*TemplatesOutput, error) {
+	if input.Body.Title == "" || input.Body.Text == "" {
+		return nil, huma.Error422UnprocessableEntity("Заголовок и текст не должны быть пустыми")
+	}
+
+	if _, err := checkOrganizator(input.Body.Token, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	_, err := db.Exec(
+		"INSERT INTO certificate_templates (event_uri, type, title, text, signer) VALUES ($1, $2, $3, $4, $5) "+
+			"ON CONFLICT (event_uri, type) DO UPDATE SET title = EXCLUDED.title, text = EXCLUDED.text, signer = EXCLUDED.signer",
+		input.Urid, input.Body.Type, input.Body.Title, input.Body.Text, input.Body.Signer,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	eventInput := new(CertificatesEventInput)
+	eventInput.Urid = input.Urid
+	eventInput.Body.Token = input.Body.Token
+
+	return GetTemplates(eventInput, db)
+}
+
+func IssueCertificates(input *CertificatesIssueInput, db *sql.DB) (*CertificatesOutput, error) {
+	if _, err := checkOrganizator(input.Body.Token, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	recipients, err := getRecipients(input.Urid, input.Body.Type, input.Body.Emails, db)
+	if err != nil {
+		return nil, err
+	}
+
+	for email, details := range recipients {
+		code, err := generateCode()
+		if err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+
+		// Повторная выдача обновляет подробности, но не меняет код
+		_, err = db.Exec(
+			"INSERT INTO certificates (code, event_uri, user_email, type, details) VALUES ($1, $2, $3, $4, $5) "+
+				"ON CONFLICT (event_uri, user_email, type) DO UPDATE SET details = EXCLUDED.details",
+			code, input.Urid, email, input.Body.Type, details,
+		)
+		if err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+	}
+
+	return queryCertificates(
+		"WHERE certificates.event_uri = $1 AND certificates.type = $2 ORDER BY users.last_name, users.first_name",
+		db, input.Urid, input.Body.Type,
+	)
+}
+
+func GetEventCertificates(input *CertificatesEventInput, db *sql.DB) (*CertificatesOutput, error) {
+	if _, err := checkOrganizator(input.Body.Token, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	return queryCertificates(
+		"WHERE certificates.event_uri = $1 ORDER BY certificates.type, users.last_name, users.first_name",
+		db, input.Urid,
+	)
+}
+
+func GetMyCertificates(input *utils.JustAccessTokenInput, db *sql.DB) (*CertificatesOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+
+	return queryCertificates(
+		"WHERE certificates.user_email = $1 ORDER BY certificates.issued_at DESC",
+		db, user.Email,
+	)
+}
+
+func VerifyCertificate(code string, db *sql.DB) (*CertificateVerifyOutput, error) {
+	result := new(CertificateVerifyOutput)
+
+	certificate, err := getCertificate(code, db)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return result, nil
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	result.Body.Valid = true
+	result.Body.Certificate = certificate
+
+	return result, nil
+}
+
+func GetCertificatePdf(code string, db *sql.DB) (*CertificatePdfOutput, error) {
+	certificate, err := getCertificate(code, db)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, huma.Error404NotFound("Сертификат не найден")
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	template, err := getTemplate(certificate.EventUri, certificate.Type, db)
+	if err != nil {
+		return nil, err
+	}
+
+	pdf, err := renderPdf(certificate, template)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("Не удалось сформировать PDF: " + err.Error())
+	}
+
+	return &CertificatePdfOutput{
+		ContentType:        "application/pdf",
+		ContentDisposition: "inline; filename=\"certificate-" + certificate.Code + ".pdf\"",
+		Body:               pdf,
+	}, nil
+}
+
+// Возвращает e-mail получателей и подробности для сертификата
+func getRecipients(urid string, certType string, emails []string, db *sql.DB) (map[string]string, error) {
+	var query string
+	switch certType {
+	case TypeParticipation:
+		query = "SELECT member_email AS email, '' AS details FROM event_members WHERE event_uri = $1"
+	case TypeWinner:
+		query = "SELECT DISTINCT ON (teams_members.member_email) teams_members.member_email AS email, " +
+			"CASE WHEN event_results.place IS NOT NULL THEN event_results.place || ' место' ELSE event_results.nomination END AS details " +
+			"FROM event_results INNER JOIN teams_members ON teams_members.team_id = event_results.team_id " +
+			"INNER JOIN events ON event_results.event_uri = events.urid " +
+			"WHERE event_results.event_uri = $1 AND teams_members.pending = false AND events.results_published = true " +
+			"ORDER BY teams_members.member_email, event_results.place NULLS LAST"
+	case TypeJury:
+		query = "SELECT judge_email AS email, '' AS details FROM event_judges WHERE event_uri = $1"
+	case TypeMentor:
+		query = "SELECT mentor_email AS email, '' AS details FROM event_mentors WHERE event_uri = $1"
+	default:
+		return nil, huma.Error422UnprocessableEntity("Неизвестный тип сертификата")
+	}
+
+	// Если e-mail указаны - выдаем только им
+	query = "SELECT email, details FROM (" + query + ") AS eligible WHERE cardinality($2::varchar[]) = 0 OR email = ANY($2)"
+
+	if emails == nil {
+		emails = []string{}
+	}
+
+	rows, err := db.Query(query, urid, pq.Array(emails))
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	result := make(map[string]string)
+
+	for rows.Next() {
+		var email string
+		var details sql.NullString
+		if err := rows.Scan(&email, &details); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		result[email] = details.String
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	if len(result) == 0 {
+		return nil, huma.Error422UnprocessableEntity("Некому выдавать сертификаты этого типа")
+	}
+
+	return result, nil
+}
+
+func getTemplate(urid string, certType string, db *sql.DB) (*Template, error) {
+	template := new(Template)
+	template.Type = certType
+
+	var signer sql.NullString
+	err := db.QueryRow(
+		"SELECT title, text, signer FROM certificate_templates WHERE event_uri = $1 AND type = $2", urid, certType,
+	).Scan(&template.Title, &template.Text, &signer)
+	if err == sql.ErrNoRows {
+		def := defaultTemplates[certType]
+		return &def, nil
+	}
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	template.Signer = signer.String
+
+	return template, nil
+}
+
+func getCertificate(code string, db *sql.DB) (*Certificate, error) {
+	row := db.QueryRow("SELECT "+certificateColumns+" "+certificateJoins+"WHERE certificates.code = $1", strings.ToUpper(code))
+	return scanCertificate(row)
+}
+
+func queryCertificates(where string, db *sql.DB, args ...any) (*CertificatesOutput, error) {
+	rows, err := db.Query("SELECT "+certificateColumns+" "+certificateJoins+where, args...)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	result := new(CertificatesOutput)
+
+	for rows.Next() {
+		certificate, err := scanCertificate(rows)
+		if err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		result.Body.Certificates = append(result.Body.Certificates, certificate)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
+
+func scanCertificate(row interface{ Scan(dest ...any) error }) (*Certificate, error) {
+	certificate := new(Certificate)
+
+	var firstName, lastName string
+	var middleName, details sql.NullString
+	if err := row.Scan(
+		&certificate.Code,
+		&certificate.Type,
+		&firstName,
+		&lastName,
+		&middleName,
+		&certificate.Email,
+		&certificate.EventUri,
+		&certificate.EventName,
+		&certificate.StartTime,
+		&certificate.EndTime,
+		&details,
+		&certificate.IssuedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	certificate.FullName = strings.TrimSpace(lastName + " " + firstName + " " + middleName.String)
+	certificate.Details = details.String
+
+	return certificate, nil
+}
+
+func checkOrganizator(token string, urid string, db *sql.DB) (*utils.UserEmail, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, urid, db); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func generateCode() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return strings.ToUpper(hex.EncodeToString(buf)), nil
+}
+
+func formatDate(t time.Time) string {
+	return t.Format("02.01.2006")
+}
+
+func fillTemplate(text string, certificate *Certificate) string {
+	return strings.NewReplacer(
+		"{name}", certificate.FullName,
+		"{event}", certificate.EventName,
+		"{start}", formatDate(certificate.StartTime),
+		"{end}", formatDate(certificate.EndTime),
+		"{details}", certificate.Details,
+		"{code}", certificate.Code,
+	).Replace(text)
+}
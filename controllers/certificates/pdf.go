@@ -0,0 +1,94 @@
+package certificates
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// Шрифт нужен с кириллицей, стандартные шрифты PDF ее не умеют.
+// Путь задается CERT_FONT_PATH, по умолчанию - DejaVu из образа (пакет font-dejavu)
+const defaultFontPath = "/usr/share/fonts/dejavu/DejaVuSans.ttf"
+
+var (
+	fontOnce sync.Once
+	fontData []byte
+	fontErr  error
+)
+
+// Шрифт читается один раз на весь процесс
+func loadFont() ([]byte, error) {
+	fontOnce.Do(func() {
+		path := os.Getenv("CERT_FONT_PATH")
+		if path == "" {
+			path = defaultFontPath
+		}
+		if fontData, fontErr = os.ReadFile(path); fontErr != nil {
+			fontErr = errors.New("не удалось загрузить шрифт для сертификатов (CERT_FONT_PATH): " + fontErr.Error())
+		}
+	})
+	return fontData, fontErr
+}
+
+func renderPdf(certificate *Certificate, template *Template) ([]byte, error) {
+	font, err := loadFont()
+	if err != nil {
+		return nil, err
+	}
+
+	pdf := fpdf.New("L", "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes("main", "", font)
+	pdf.SetTitle(template.Title, true)
+	pdf.SetMargins(25, 25, 25)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.AddPage()
+
+	width, height := pdf.GetPageSize()
+
+	// Рамка
+	pdf.SetLineWidth(1.5)
+	pdf.Rect(10, 10, width-20, height-20, "D")
+	pdf.SetLineWidth(0.5)
+	pdf.Rect(14, 14, width-28, height-28, "D")
+
+	// Заголовок
+	pdf.SetFont("main", "", 32)
+	pdf.SetY(40)
+	pdf.CellFormat(0, 16, template.Title, "", 1, "C", false, 0, "")
+
+	// Имя получателя
+	pdf.SetFont("main", "", 24)
+	pdf.SetY(70)
+	pdf.CellFormat(0, 12, certificate.FullName, "", 1, "C", false, 0, "")
+
+	// Текст
+	pdf.SetFont("main", "", 14)
+	pdf.SetY(95)
+	pdf.MultiCell(0, 8, fillTemplate(template.Text, certificate), "", "C", false)
+
+	// Подпись
+	if template.Signer != "" {
+		pdf.SetFont("main", "", 12)
+		pdf.SetXY(width/2, height-60)
+		pdf.CellFormat(width/2-25, 8, "_____________________ "+template.Signer, "", 1, "R", false, 0, "")
+	}
+
+	// Код проверки
+	pdf.SetFont("main", "", 10)
+	pdf.SetXY(25, height-40)
+	pdf.CellFormat(0, 6, "Дата выдачи: "+formatDate(certificate.IssuedAt), "", 1, "L", false, 0, "")
+	pdf.SetX(25)
+	pdf.CellFormat(0, 6, "Код проверки: "+certificate.Code, "", 1, "L", false, 0, "")
+	pdf.SetX(25)
+	pdf.CellFormat(0, 6, "Проверить подлинность: "+os.Getenv("PUBLIC_URL")+"/api/certificate/"+certificate.Code, "", 1, "L", false, 0, "")
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
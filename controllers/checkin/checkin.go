@@ -0,0 +1,436 @@
+package checkin
+
+import (
+	"database/sql"
+	"hackaton-jam-back/controllers/utils"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+type CheckinEventInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type CheckinScanInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен организатора или волонтера"`
+		Code  string `json:"code" doc:"Отсканированный код участника"`
+	}
+}
+
+type CheckinQrInput struct {
+	Code   string `path:"code" doc:"Код отметки"`
+	Format string `query:"format" enum:"png,svg" default:"png" doc:"Формат картинки"`
+	Size   int    `query:"size" minimum:"64" maximum:"1024" default:"256" doc:"Размер картинки в пикселях"`
+}
+
+type VolunteersAddDelInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token      string   `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+		Volunteers []string `json:"volunteers" doc:"E-mail волонтеров"`
+	}
+}
+
+type CheckinCodeOutput struct {
+	Body struct {
+		Code        string     `json:"code" doc:"Код отметки (его нужно показать на входе)"`
+		QrPng       string     `json:"qr_png" doc:"Ссылка на QR-код в PNG"`
+		QrSvg       string     `json:"qr_svg" doc:"Ссылка на QR-код в SVG"`
+		CheckedIn   bool       `json:"checked_in" doc:"Отмечен ли участник"`
+		CheckedInAt *time.Time `json:"checked_in_at,omitempty" doc:"Время отметки"`
+	}
+}
+
+type CheckinQrOutput struct {
+	ContentType  string `header:"Content-Type"`
+	CacheControl string `header:"Cache-Control"`
+	Body         []byte
+}
+
+type CheckinScanOutput struct {
+	Body struct {
+		Duplicate   bool                 `json:"duplicate" doc:"Участник уже был отмечен раньше"`
+		Member      *utils.UserShortInfo `json:"member" doc:"Участник"`
+		TeamId      int64                `json:"team_id,omitempty" example:"2" doc:"Команда участника"`
+		TeamName    string               `json:"team_name,omitempty" example:"Супер-команда" doc:"Название команды"`
+		CheckedInAt time.Time            `json:"checked_in_at" doc:"Время отметки (при повторе - время первой отметки)"`
+		CheckedInBy string               `json:"checked_in_by" example:"thatmaidguy@ya.ru" doc:"Кто отметил"`
+	}
+}
+
+type TeamAttendance struct {
+	TeamId    int64  `json:"team_id" example:"2" doc:"Идентификатор команды"`
+	TeamName  string `json:"team_name" example:"Супер-команда" doc:"Название команды"`
+	Members   int    `json:"members" example:"4" doc:"Участников в команде"`
+	CheckedIn int    `json:"checked_in" example:"3" doc:"Из них пришли"`
+}
+
+type CheckinEntry struct {
+	Email       string    `json:"email" example:"example@mail.ru" doc:"E-mail участника"`
+	Username    string    `json:"username" example:"ThatMaidGuy" doc:"Никнейм участника"`
+	CheckedInAt time.Time `json:"checked_in_at" doc:"Время отметки"`
+	CheckedInBy string    `json:"checked_in_by" example:"thatmaidguy@ya.ru" doc:"Кто отметил"`
+}
+
+type CheckinStatsOutput struct {
+	Body struct {
+		Registered       int               `json:"registered" example:"40" doc:"Зарегистрировано участников"`
+		CheckedIn        int               `json:"checked_in" example:"31" doc:"Пришли"`
+		NoTeamRegistered int               `json:"no_team_registered" example:"5" doc:"Участников без команды"`
+		NoTeamCheckedIn  int               `json:"no_team_checked_in" example:"2" doc:"Из них пришли"`
+		Teams            []*TeamAttendance `json:"teams" doc:"Посещаемость по командам"`
+		Checkins         []*CheckinEntry   `json:"checkins" doc:"Отметки (последние сверху)"`
+	}
+}
+
+type VolunteersOutput struct {
+	Body struct {
+		Volunteers []*utils.UserShortInfo `json:"volunteers" doc:"Волонтеры, которые могут отмечать участников"`
+	}
+}
+
+func GetMyCheckinCode(input *CheckinEventInput, db *sql.DB) (*CheckinCodeOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+
+	if err := checkIrlEvent(input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	// Токен создается при первом запросе кода и живет, пока участник записан
+	token, err := newToken()
+	if err != nil {
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+	if err := db.QueryRow(
+		"UPDATE event_members SET checkin_token = COALESCE(checkin_token, $3) WHERE event_uri = $1 AND member_email = $2 RETURNING checkin_token",
+		input.Urid, user.Email, token,
+	).Scan(&token); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, huma.Error403Forbidden("Ты не участвуешь в этом мероприятии")
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	code, err := makeCode(token)
+	if err != nil {
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+
+	result := new(CheckinCodeOutput)
+	result.Body.Code = code
+
+	qrUrl := os.Getenv("PUBLIC_URL") + "/api/checkin/" + url.PathEscape(code) + "/qr"
+	result.Body.QrPng = qrUrl + "?format=png"
+	result.Body.QrSvg = qrUrl + "?format=svg"
+
+	var checkedInAt time.Time
+	err = db.QueryRow("SELECT checked_in_at FROM event_checkins WHERE event_uri = $1 AND member_email = $2", input.Urid, user.Email).Scan(&checkedInAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if err == nil {
+		result.Body.CheckedIn = true
+		result.Body.CheckedInAt = &checkedInAt
+	}
+
+	return result, nil
+}
+
+// Картинку отдаем только для подписанных кодов, произвольный текст не рисуем
+func GetCheckinQr(input *CheckinQrInput) (*CheckinQrOutput, error) {
+	if _, err := parseCode(input.Code); err != nil {
+		if err == errBadCode {
+			return nil, huma.Error404NotFound("Код недействительный")
+		}
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+
+	result := &CheckinQrOutput{CacheControl: "private, max-age=86400"}
+
+	var err error
+	if input.Format == "svg" {
+		result.ContentType = "image/svg+xml"
+		result.Body, err = renderSvg(input.Code, input.Size)
+	} else {
+		result.ContentType = "image/png"
+		result.Body, err = renderPng(input.Code, input.Size)
+	}
+	if err != nil {
+		return nil, huma.Error500InternalServerError("Не удалось сформировать QR-код: " + err.Error())
+	}
+
+	return result, nil
+}
+
+func ScanCheckin(input *CheckinScanInput, db *sql.DB) (*CheckinScanOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := checkScanner(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	token, err := parseCode(input.Body.Code)
+	if err != nil {
+		if err == errBadCode {
+			return nil, huma.Error422UnprocessableEntity("Код недействительный")
+		}
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+
+	// Участник мог выйти из мероприятия после получения кода, тогда токена уже нет
+	var urid, email string
+	if err := db.QueryRow("SELECT event_uri, member_email FROM event_members WHERE checkin_token = $1", token).Scan(&urid, &email); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, huma.Error422UnprocessableEntity("Участник больше не зарегистрирован на мероприятие")
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if urid != input.Urid {
+		return nil, huma.Error422UnprocessableEntity("Код от другого мероприятия")
+	}
+
+	result := new(CheckinScanOutput)
+
+	err = db.QueryRow(
+		"INSERT INTO event_checkins (event_uri, member_email, checked_in_by) VALUES ($1, $2, $3) "+
+			"ON CONFLICT DO NOTHING RETURNING checked_in_at, checked_in_by",
+		urid, email, user.Email,
+	).Scan(&result.Body.CheckedInAt, &result.Body.CheckedInBy)
+	if err == sql.ErrNoRows {
+		// Повторное сканирование - отдаем первую отметку
+		result.Body.Duplicate = true
+		err = db.QueryRow(
+			"SELECT checked_in_at, checked_in_by FROM event_checkins WHERE event_uri = $1 AND member_email = $2",
+			urid, email,
+		).Scan(&result.Body.CheckedInAt, &result.Body.CheckedInBy)
+	}
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	result.Body.Member, err = utils.GetUserShortInfo(email, db)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	err = db.QueryRow(
+		"SELECT teams.id, teams.name FROM teams JOIN teams_members ON teams_members.team_id = teams.id "+
+			"WHERE teams.event_uri = $1 AND teams_members.member_email = $2 AND teams_members.pending = false",
+		urid, email,
+	).Scan(&result.Body.TeamId, &result.Body.TeamName)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
+
+func GetCheckinStats(input *CheckinEventInput, db *sql.DB) (*CheckinStatsOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := checkScanner(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	result := new(CheckinStatsOutput)
+
+	// Отметки ушедших участников не считаем
+	err = db.QueryRow(
+		"SELECT COUNT(*), COUNT(event_checkins.member_email), "+
+			"COUNT(*) FILTER (WHERE team.team_id IS NULL), "+
+			"COUNT(event_checkins.member_email) FILTER (WHERE team.team_id IS NULL) "+
+			"FROM event_members "+
+			"LEFT JOIN event_checkins ON event_checkins.event_uri = event_members.event_uri AND event_checkins.member_email = event_members.member_email "+
+			"LEFT JOIN LATERAL (SELECT teams_members.team_id FROM teams_members JOIN teams ON teams.id = teams_members.team_id "+
+			"WHERE teams.event_uri = event_members.event_uri AND teams_members.member_email = event_members.member_email AND teams_members.pending = false LIMIT 1) AS team ON true "+
+			"WHERE event_members.event_uri = $1",
+		input.Urid,
+	).Scan(&result.Body.Registered, &result.Body.CheckedIn, &result.Body.NoTeamRegistered, &result.Body.NoTeamCheckedIn)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	rows, err := db.Query(
+		"SELECT teams.id, teams.name, COUNT(teams_members.member_email), COUNT(event_checkins.member_email) "+
+			"FROM teams "+
+			"LEFT JOIN teams_members ON teams_members.team_id = teams.id AND teams_members.pending = false "+
+			"LEFT JOIN event_checkins ON event_checkins.event_uri = teams.event_uri AND event_checkins.member_email = teams_members.member_email "+
+			"WHERE teams.event_uri = $1 GROUP BY teams.id ORDER BY teams.name",
+		input.Urid,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		team := new(TeamAttendance)
+		if err := rows.Scan(&team.TeamId, &team.TeamName, &team.Members, &team.CheckedIn); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		result.Body.Teams = append(result.Body.Teams, team)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	checkins, err := db.Query(
+		"SELECT event_checkins.member_email, users.username, event_checkins.checked_in_at, event_checkins.checked_in_by "+
+			"FROM event_checkins JOIN users ON users.email = event_checkins.member_email "+
+			"WHERE event_checkins.event_uri = $1 ORDER BY event_checkins.checked_in_at DESC",
+		input.Urid,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer checkins.Close()
+
+	for checkins.Next() {
+		entry := new(CheckinEntry)
+		if err := checkins.Scan(&entry.Email, &entry.Username, &entry.CheckedInAt, &entry.CheckedInBy); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		result.Body.Checkins = append(result.Body.Checkins, entry)
+	}
+	if err = checkins.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
+
+func GetVolunteers(input *CheckinEventInput, db *sql.DB) (*VolunteersOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	return getVolunteers(input.Urid, db)
+}
+
+func AddVolunteers(input *VolunteersAddDelInput, db *sql.DB) (*VolunteersOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	for _, email := range input.Body.Volunteers {
+		if _, err := utils.GetUserUsernameByEmail(email, db); err != nil {
+			return nil, huma.Error422UnprocessableEntity("Пользователь " + email + " не найден")
+		}
+
+		_, err = db.Exec(
+			"INSERT INTO event_volunteers (event_uri, volunteer_email) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+			input.Urid, email,
+		)
+		if err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+	}
+
+	return getVolunteers(input.Urid, db)
+}
+
+func DelVolunteers(input *VolunteersAddDelInput, db *sql.DB) (*VolunteersOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	for _, email := range input.Body.Volunteers {
+		_, err = db.Exec("DELETE FROM event_volunteers WHERE event_uri = $1 AND volunteer_email = $2", input.Urid, email)
+		if err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+	}
+
+	return getVolunteers(input.Urid, db)
+}
+
+func getVolunteers(urid string, db *sql.DB) (*VolunteersOutput, error) {
+	rows, err := db.Query("SELECT volunteer_email FROM event_volunteers WHERE event_uri = $1", urid)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	result := new(VolunteersOutput)
+
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+
+		volunteer, err := utils.GetUserShortInfo(email, db)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Body.Volunteers = append(result.Body.Volunteers, volunteer)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
+
+func checkIrlEvent(urid string, db *sql.DB) error {
+	var isIrl bool
+	if err := db.QueryRow("SELECT is_irl FROM events WHERE urid = $1", urid).Scan(&isIrl); err != nil {
+		if err == sql.ErrNoRows {
+			return huma.Error404NotFound("Такого мероприятия нет")
+		}
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+	if !isIrl {
+		return huma.Error422UnprocessableEntity("Отметка на входе есть только у очных мероприятий")
+	}
+
+	return nil
+}
+
+// Отмечать могут организаторы мероприятия и его волонтеры
+func checkScanner(user *utils.UserEmail, urid string, db *sql.DB) error {
+	if err := checkIrlEvent(urid, db); err != nil {
+		return err
+	}
+
+	var volunteer string
+	err := db.QueryRow("SELECT volunteer_email FROM event_volunteers WHERE event_uri = $1 AND volunteer_email = $2", urid, user.Email).Scan(&volunteer)
+	if err == nil {
+		return nil
+	}
+	if err != sql.ErrNoRows {
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return utils.CheckEventOrganizator(user, urid, db)
+}
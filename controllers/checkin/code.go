@@ -0,0 +1,115 @@
+package checkin
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// Код отметки: токен записи на мероприятие + "." + base64(подпись).
+// Токен случайный и хранится в event_members, поэтому по коду нельзя узнать ни e-mail, ни мероприятие.
+// Подпись - HMAC-SHA256 на секрете CHECKIN_SECRET, обрезанный до 16 байт: с ней QR рисуется только
+// для выданных кодов без похода в базу
+const (
+	tokenSize     = 16
+	signatureSize = 16
+)
+
+var errBadCode = errors.New("код недействительный")
+
+func getSecret() ([]byte, error) {
+	secret := os.Getenv("CHECKIN_SECRET")
+	if secret == "" {
+		return nil, errors.New("не задан CHECKIN_SECRET")
+	}
+	return []byte(secret), nil
+}
+
+func sign(payload []byte, secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return mac.Sum(nil)[:signatureSize]
+}
+
+func newToken() (string, error) {
+	buf := make([]byte, tokenSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func makeCode(token string) (string, error) {
+	secret, err := getSecret()
+	if err != nil {
+		return "", err
+	}
+
+	return token + "." + base64.RawURLEncoding.EncodeToString(sign([]byte(token), secret)), nil
+}
+
+// Возвращает токен записи из кода
+func parseCode(code string) (string, error) {
+	secret, err := getSecret()
+	if err != nil {
+		return "", err
+	}
+
+	token, encodedSignature, found := strings.Cut(code, ".")
+	if !found || token == "" {
+		return "", errBadCode
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return "", errBadCode
+	}
+
+	if !hmac.Equal(signature, sign([]byte(token), secret)) {
+		return "", errBadCode
+	}
+
+	return token, nil
+}
+
+func renderPng(code string, size int) ([]byte, error) {
+	return qrcode.Encode(code, qrcode.Medium, size)
+}
+
+// SVG собирается вручную из матрицы, по квадрату на каждый модуль
+func renderSvg(code string, size int) ([]byte, error) {
+	qr, err := qrcode.New(code, qrcode.Medium)
+	if err != nil {
+		return nil, err
+	}
+
+	bitmap := qr.Bitmap()
+	modules := len(bitmap)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf,
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		size, size, modules, modules,
+	)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#ffffff"/>`, modules, modules)
+	buf.WriteString(`<path fill="#000000" d="`)
+	for y, row := range bitmap {
+		for x, black := range row {
+			if black {
+				fmt.Fprintf(&buf, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+
+	return buf.Bytes(), nil
+}
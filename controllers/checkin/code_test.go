@@ -0,0 +1,90 @@
+package checkin
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCodeRoundTrip(t *testing.T) {
+	t.Setenv("CHECKIN_SECRET", "secret")
+
+	for i := 0; i < 5; i++ {
+		token, err := newToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(token) != tokenSize*2 {
+			t.Fatalf("длина токена %d, ожидалось %d", len(token), tokenSize*2)
+		}
+
+		code, err := makeCode(token)
+		if err != nil {
+			t.Fatalf("makeCode: %v", err)
+		}
+		if strings.Contains(code, "@") {
+			t.Errorf("в коде виден e-mail: %q", code)
+		}
+
+		parsed, err := parseCode(code)
+		if err != nil {
+			t.Fatalf("parseCode(%q): %v", code, err)
+		}
+		if parsed != token {
+			t.Errorf("parseCode = %q, ожидалось %q", parsed, token)
+		}
+	}
+}
+
+func TestParseCode(t *testing.T) {
+	t.Setenv("CHECKIN_SECRET", "secret")
+
+	token := "00112233445566778899aabbccddeeff"
+	code, err := makeCode(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, signature, _ := strings.Cut(code, ".")
+
+	tests := []struct {
+		name string
+		code string
+	}{
+		{"пустой", ""},
+		{"без подписи", token},
+		{"без токена", "." + signature},
+		{"подпись не base64", token + ".***"},
+		{"чужой токен", "ffeeddccbbaa99887766554433221100." + signature},
+		{"обрезанная подпись", token + "." + signature[:10]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseCode(tt.code); err != errBadCode {
+				t.Errorf("parseCode(%q) = %v, ожидалось errBadCode", tt.code, err)
+			}
+		})
+	}
+}
+
+func TestCodeSecret(t *testing.T) {
+	t.Setenv("CHECKIN_SECRET", "secret")
+	code, err := makeCode("00112233445566778899aabbccddeeff")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Код, подписанный другим секретом, не принимается
+	t.Setenv("CHECKIN_SECRET", "another")
+	if _, err := parseCode(code); err != errBadCode {
+		t.Errorf("parseCode с другим секретом = %v, ожидалось errBadCode", err)
+	}
+
+	// Без секрета коды не выдаются и не проверяются
+	t.Setenv("CHECKIN_SECRET", "")
+	if _, err := makeCode("00112233445566778899aabbccddeeff"); err == nil {
+		t.Error("makeCode без CHECKIN_SECRET выдал код")
+	}
+	if _, err := parseCode(code); err == nil || err == errBadCode {
+		t.Errorf("parseCode без CHECKIN_SECRET = %v, ожидалась ошибка настройки", err)
+	}
+}
@@ -2,20 +2,52 @@ package events
 
 import (
 	"database/sql"
+	"hackaton-jam-back/controllers/feedback"
+	"hackaton-jam-back/controllers/moderation"
+	"hackaton-jam-back/controllers/organizations"
+	"hackaton-jam-back/controllers/qa"
+	"hackaton-jam-back/controllers/results"
 	"hackaton-jam-back/controllers/utils"
 	"log"
+	"strconv"
 	"time"
 
 	"github.com/danielgtaylor/huma/v2"
+	"github.com/lib/pq"
 )
 
+type EventsListInput struct {
+	Count  int    `query:"count" default:"20" minimum:"1" maximum:"100" example:"20" doc:"Количество событий на страницу"`
+	Cursor string `query:"cursor" doc:"Курсор следующей страницы из прошлого ответа (пусто - первая страница)"`
+	Page   int    `query:"page" default:"-1" minimum:"-1" deprecated:"true" doc:"Номер страницы с нуля (-1 - не задан). Устарело: оставлено на один релиз для старых клиентов, используйте cursor"`
+	Sort   string `query:"sort" enum:"created,start,popularity,name" default:"created" doc:"Сортировка: новые, по дате начала, по числу участников или по названию"`
+	City   string `query:"city" example:"Екатеринбург" doc:"Только мероприятия в этом городе (из справочника городов)"`
+	utils.ViewerInput
+	utils.LocaleInput
+}
+
 type GetEventsOutput struct {
+	Vary string `header:"Vary"`
 	Body struct {
-		Count  int         `json:"count" doc:"Количество мероприятий всего"`
-		Events []EventType `json:"events" doc:"Список мероприятий"`
+		Count      int         `json:"count,omitempty" doc:"Количество мероприятий всего. Устарело: считается только при листании по page"`
+		Events     []EventType `json:"events" doc:"Список мероприятий"`
+		NextCursor string      `json:"next_cursor,omitempty" doc:"Курсор следующей страницы (пусто - это последняя)"`
 	}
 }
 
+type eventSort struct {
+	expr string // Выражение для сортировки
+	cast string // Тип значения в курсоре
+	desc bool
+}
+
+var eventSorts = map[string]eventSort{
+	"created":    {expr: "events.id", cast: "bigint", desc: true},
+	"start":      {expr: "events.start_time", cast: "timestamptz", desc: false},
+	"popularity": {expr: "(SELECT COUNT(*) FROM event_members WHERE event_members.event_uri = events.urid)", cast: "bigint", desc: true},
+	"name":       {expr: "lower(events.name)", cast: "text", desc: false},
+}
+
 type EventType struct {
 	Urid      string    `json:"urid" example:"example_events" doc:"Ссылка на мероприятие"`
 	Name      string    `json:"name" example:"Example GameJam" doc:"Название мероприятия"`
@@ -24,67 +56,177 @@ type EventType struct {
 	Location  string    `json:"location" example:"Свердловская область, г. Екатеринбург" doc:"Место проведения"`
 	Icon      string    `json:"icon" doc:"Превью мероприятия"`
 	IsIrl     bool      `json:"is_irl" doc:"Очное ли мероприятие?"`
+	City      string    `json:"city,omitempty" example:"Екатеринбург" doc:"Город проведения"`
 	Tags      []string  `json:"tags" doc:"Тэги события"`
+	Locale    string    `json:"locale" example:"ru" doc:"Язык названия"`
 }
 
-func GetEventCount(db *sql.DB) (int, error) {
-	row := db.QueryRow("SELECT COUNT(*) AS total_records FROM events")
-	var result int
-
-	err := row.Scan(&result)
+// Постраничный вывод по курсору: следующая страница начинается строго после последней
+// выданной записи, поэтому новые мероприятия не сдвигают страницы
+func GetAllEvents(input *EventsListInput, db *sql.DB) (*GetEventsOutput, error) {
+	sortName := input.Sort
+	if sortName == "" {
+		sortName = "created"
+	}
+	sort, ok := eventSorts[sortName]
+	if !ok {
+		return nil, huma.Error422UnprocessableEntity("Неизвестная сортировка")
+	}
+	page, err := utils.NewPage(input.Cursor, sortName, input.Count)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	return result, nil
-}
+	// Токен необязательный: с ним видны еще и закрытые мероприятия, где пользователь участник или организатор
+	var viewer string
+	var isAdmin bool
+	if input.Token != "" {
+		user, err := utils.GetUserEmailByToken(input.Token, db)
+		if err != nil {
+			return nil, err
+		}
+		viewer, isAdmin = user.Email, user.Perms == 10
+	}
 
-func GetAllEvents(count int, page int, db *sql.DB) (*GetEventsOutput, error) {
-	rows, err := db.Query("SELECT urid, id, name, start_time, end_time, location FROM events ORDER BY id DESC LIMIT $1 OFFSET $2", count, page*count)
+	direction, compare := "ASC", ">"
+	if sort.desc {
+		direction, compare = "DESC", "<"
+	}
+
+	where := " WHERE is_draft = false AND moderation_status = 'approved' AND " + visibleEventCondition
+	args := []any{viewer, isAdmin}
+	if input.City != "" {
+		args = append(args, input.City)
+		where += " AND lower(events.city) = lower($" + strconv.Itoa(len(args)) + ")"
+	}
+
+	// Полный подсчет нужен только старым клиентам, которые листают по номеру страницы
+	result := new(GetEventsOutput)
+	legacy := page.After == nil && input.Page >= 0
+	if legacy {
+		if err := db.QueryRow("SELECT COUNT(*) FROM events"+where, args...).Scan(&result.Body.Count); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+	}
+
+	query := "SELECT urid, id, name, start_time, end_time, location, icon, is_irl, city, default_locale, (" + sort.expr + ")::text FROM events" + where
+	if page.After != nil {
+		args = append(args, page.After.Value, page.After.Key)
+		query += " AND (" + sort.expr + ", events.id) " + compare +
+			" ($" + strconv.Itoa(len(args)-1) + "::" + sort.cast + ", $" + strconv.Itoa(len(args)) + "::bigint)"
+	}
+	args = append(args, page.Limit())
+	query += " ORDER BY " + sort.expr + " " + direction + ", events.id " + direction + " LIMIT $" + strconv.Itoa(len(args))
+
+	// Старые клиенты листают по номеру страницы. Если передан курсор, он важнее
+	if legacy && input.Page > 0 {
+		args = append(args, input.Page*page.Size)
+		query += " OFFSET $" + strconv.Itoa(len(args))
+	}
+
+	rows, err := db.Query(query, args...)
 	if err != nil {
-		return nil, huma.Error422UnprocessableEntity("Проблемки с вызовом SQL")
+		return nil, huma.Error422UnprocessableEntity(err.Error())
 	}
 	defer rows.Close()
 
-	var events []EventType
-
 	for rows.Next() {
-
-		var id int
+		var id int64
+		var value string
+		var location, icon, city sql.NullString
 		var event EventType
-		if err := rows.Scan(&event.Urid, &id, &event.Name, &event.StartTime, &event.EndTime, &event.Location); err != nil {
+		if err := rows.Scan(&event.Urid, &id, &event.Name, &event.StartTime, &event.EndTime, &location, &icon, &event.IsIrl, &city, &event.Locale, &value); err != nil {
 			return nil, huma.Error422UnprocessableEntity(err.Error())
 		}
+		event.Location = location.String
+		event.Icon = icon.String
+		event.City = city.String
+
+		if !page.Add(value, strconv.FormatInt(id, 10)) {
+			break
+		}
 
 		event.Tags, err = getEventTags(event.Urid, db)
 		if err != nil {
 			return nil, huma.Error422UnprocessableEntity(err.Error())
 		}
 
-		events = append(events, event)
+		result.Body.Events = append(result.Body.Events, event)
 	}
 	if err = rows.Err(); err != nil {
 		return nil, huma.Error422UnprocessableEntity(err.Error())
 	}
+	result.Body.NextCursor = page.NextCursor()
 
-	event_count, err := GetEventCount(db)
-	if err != nil {
-		return nil, huma.Error422UnprocessableEntity(err.Error())
+	cards := make([]*EventType, len(result.Body.Events))
+	for i := range result.Body.Events {
+		cards[i] = &result.Body.Events[i]
 	}
-
-	result := new(GetEventsOutput)
-	result.Body.Events = events
-	result.Body.Count = event_count
+	if err := localizeEventTypes(cards, utils.PreferredLocales(input.LocaleInput), db); err != nil {
+		return nil, err
+	}
+	result.Vary = utils.VaryLanguage
 
 	return result, nil
 }
 
-func GetFullEventInfo(urid string, db *sql.DB) (*FullEventOutput, error) {
-	return getFullEventInfo(urid, db)
+// Закрытое мероприятие видно только своим или по действующему приглашению,
+// а не прошедшее модерацию - только своим. Черновик для всех, кроме организаторов, как будто не существует.
+// Тексты отдаются на языке из locale, если есть перевод
+func GetFullEventInfo(urid string, token string, invite string, locale utils.LocaleInput, db *sql.DB) (*FullEventOutput, error) {
+	event, err := getFullEventInfo(urid, db)
+	if err != nil {
+		return nil, err
+	}
+	if err := localizeEvent(event, utils.PreferredLocales(locale), db); err != nil {
+		return nil, err
+	}
+	event.Vary = utils.VaryLanguage
+
+	approved := event.Body.ModerationStatus == moderation.StatusApproved
+	if !event.Body.IsDraft && approved && event.Body.Visibility != VisibilityPrivate {
+		return event, nil
+	}
+
+	var user *utils.UserEmail
+	if token != "" {
+		if user, err = utils.GetUserEmailByToken(token, db); err != nil {
+			return nil, err
+		}
+	}
+	if event.Body.IsDraft {
+		if user == nil || utils.CheckEventOrganizator(user, urid, db) != nil {
+			return nil, huma.Error404NotFound("Этого события нет XP")
+		}
+		return event, nil
+	}
+
+	visible, err := utils.CanSeeEvent(user, urid, db)
+	if err != nil {
+		return nil, err
+	}
+	if !visible && !approved {
+		return nil, huma.Error403Forbidden("Мероприятие на модерации")
+	}
+	if !visible {
+		if visible, err = isInviteValid(invite, urid, db); err != nil {
+			return nil, err
+		}
+	}
+	if !visible {
+		return nil, huma.Error403Forbidden("Это закрытое мероприятие")
+	}
+
+	return event, nil
 }
 
 func getFullEventInfo(urid string, db *sql.DB) (*FullEventOutput, error) {
-	row := db.QueryRow("SELECT * FROM events WHERE urid = $1", urid)
+	row := db.QueryRow(
+		"SELECT urid, id, name, start_time, end_time, prize, \"location\", \"desc\", requirements, "+
+			"icon, is_irl, team_requirements_type, team_requirements_value, submission_deadline, is_draft, series_urid, season, "+
+			"visibility, access_code IS NOT NULL, allowed_domains, moderation_status, moderation_reason, city, latitude, longitude, "+
+			"default_locale, ARRAY(SELECT locale FROM event_translations WHERE event_translations.event_uri = events.urid ORDER BY locale) "+
+			"FROM events WHERE urid = $1", urid)
 	event := new(FullEventOutput)
 	event.Body.Icon = "https://i.imgur.com/b0zqmkj.jpeg"
 
@@ -95,6 +237,14 @@ func getFullEventInfo(urid string, db *sql.DB) (*FullEventOutput, error) {
 	var requirements sql.NullString
 	var icon sql.NullString
 	var desc sql.NullString
+	var submissionDeadline sql.NullTime
+	var series sql.NullString
+	var season sql.NullString
+	var moderationReason sql.NullString
+	var city sql.NullString
+	var latitude sql.NullFloat64
+	var longitude sql.NullFloat64
+	var translations []string
 
 	err := row.Scan(
 		&event.Body.Urid,
@@ -110,6 +260,20 @@ func getFullEventInfo(urid string, db *sql.DB) (*FullEventOutput, error) {
 		&event.Body.IsIrl,
 		&event.Body.TeamRequirementsType,
 		&event.Body.TeamRequirementsValue,
+		&submissionDeadline,
+		&event.Body.IsDraft,
+		&series,
+		&season,
+		&event.Body.Visibility,
+		&event.Body.HasAccessCode,
+		pq.Array(&event.Body.AllowedDomains),
+		&event.Body.ModerationStatus,
+		&moderationReason,
+		&city,
+		&latitude,
+		&longitude,
+		&event.Body.Locale,
+		pq.Array(&translations),
 	)
 	if err != nil {
 		log.Println(err.Error())
@@ -121,6 +285,17 @@ func getFullEventInfo(urid string, db *sql.DB) (*FullEventOutput, error) {
 	event.Body.Requirements = requirements.String
 	event.Body.Icon = icon.String
 	event.Body.Description = desc.String
+	event.Body.Series = series.String
+	event.Body.Season = season.String
+	event.Body.ModerationReason = moderationReason.String
+	event.Body.City = city.String
+	if latitude.Valid && longitude.Valid {
+		event.Body.Latitude, event.Body.Longitude = &latitude.Float64, &longitude.Float64
+	}
+	if submissionDeadline.Valid {
+		event.Body.SubmissionDeadline = &submissionDeadline.Time
+	}
+	event.Body.Locales = append([]string{event.Body.Locale}, translations...)
 
 	event.Body.Tags, err = getEventTags(urid, db)
 	if err != nil {
@@ -137,6 +312,36 @@ func getFullEventInfo(urid string, db *sql.DB) (*FullEventOutput, error) {
 		return nil, err
 	}
 
+	event.Body.Results, err = results.GetPublishedResults(urid, db)
+	if err != nil {
+		return nil, err
+	}
+
+	event.Body.Rating, err = feedback.GetEventRating(urid, db)
+	if err != nil {
+		return nil, err
+	}
+
+	event.Body.Faq, err = qa.GetFaq(urid, db)
+	if err != nil {
+		return nil, err
+	}
+
+	event.Body.Agenda, err = getEventAgenda(urid, db)
+	if err != nil {
+		return nil, err
+	}
+
+	event.Body.RegistrationForm, err = getRegistrationForm(urid, db)
+	if err != nil {
+		return nil, err
+	}
+
+	event.Body.Organization, err = organizations.GetEventOrganization(urid, db)
+	if err != nil {
+		return nil, err
+	}
+
 	return event, nil
 }
 
@@ -163,6 +368,10 @@ func getEventOrganizators(urid string, db *sql.DB) ([]*Organizators, error) {
 
 		org.Email = user.Email
 		org.Username = user.Username
+		org.Rating, err = feedback.GetOrganizerRating(user.Email, db)
+		if err != nil {
+			return nil, err
+		}
 
 		result = append(result, org)
 	}
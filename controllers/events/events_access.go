@@ -0,0 +1,343 @@
+package events
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"hackaton-jam-back/controllers/utils"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/lib/pq"
+)
+
+// Видимость мероприятия
+const (
+	VisibilityPublic   = "public"   // Видно всем
+	VisibilityUnlisted = "unlisted" // Не видно в списках, открывается по ссылке
+	VisibilityPrivate  = "private"  // Видно только участникам, организаторам и по приглашению
+)
+
+// Условие для списков мероприятий: $1 - e-mail смотрящего (или пусто), $2 - админ ли он
+const visibleEventCondition = "(events.visibility = 'public' OR $2::bool " +
+	"OR EXISTS (SELECT 1 FROM event_members WHERE event_members.event_uri = events.urid AND event_members.member_email = $1) " +
+	"OR EXISTS (SELECT 1 FROM event_orgs WHERE event_orgs.event_uri = events.urid AND event_orgs.organizator_email = $1) " +
+	"OR EXISTS (SELECT 1 FROM organization_members WHERE organization_members.org_urid = events.org_urid AND organization_members.member_email = $1))"
+
+type EventAccessInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type EventAccessEditInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+
+		Visibility     string   `json:"visibility" enum:"public,unlisted,private" example:"private" doc:"Видимость: всем, по ссылке или только приглашенным"`
+		AccessCode     string   `json:"access_code,omitempty" maxLength:"64" example:"URFU2026" doc:"Код для записи (пусто - без кода)"`
+		AllowedDomains []string `json:"allowed_domains,omitempty" example:"[\"urfu.ru\"]" doc:"Домены e-mail, с которых можно записаться (пусто - любые)"`
+	}
+}
+
+type EventAccessOutput struct {
+	Body struct {
+		Urid           string   `json:"urid" example:"example_events" doc:"Ссылка на мероприятие"`
+		Visibility     string   `json:"visibility" example:"private" doc:"Видимость"`
+		AccessCode     string   `json:"access_code" example:"URFU2026" doc:"Код для записи"`
+		AllowedDomains []string `json:"allowed_domains" doc:"Разрешенные домены e-mail"`
+	}
+}
+
+type InviteLinkCreateInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+
+		MaxUses   int       `json:"max_uses,omitempty" minimum:"0" example:"30" doc:"Сколько раз можно использовать (0 - без ограничения)"`
+		ExpiresAt time.Time `json:"expires_at,omitempty" doc:"До какого времени действует (если не указано - бессрочно)"`
+	}
+}
+
+type InviteLinkDeleteInput struct {
+	Urid   string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Invite string `path:"invite" example:"9c1f0e2a7b3d4c5e" doc:"Код приглашения"`
+	Body   struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type InviteLink struct {
+	Invite    string     `json:"invite" example:"9c1f0e2a7b3d4c5e" doc:"Код приглашения"`
+	Url       string     `json:"url" example:"http://localhost/event/example_events?invite=9c1f0e2a7b3d4c5e" doc:"Ссылка-приглашение"`
+	CreatedBy string     `json:"created_by" example:"thatmaidguy@ya.ru" doc:"Кто создал"`
+	MaxUses   int        `json:"max_uses" example:"30" doc:"Ограничение использований (0 - нет)"`
+	Uses      int        `json:"uses" example:"12" doc:"Сколько раз использовали"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" doc:"До какого времени действует"`
+	CreatedAt time.Time  `json:"created_at" doc:"Время создания"`
+}
+
+type InviteLinksOutput struct {
+	Body struct {
+		Links []*InviteLink `json:"links" doc:"Ссылки-приглашения мероприятия"`
+	}
+}
+
+func GetEventAccess(input *EventAccessInput, db *sql.DB) (*EventAccessOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	return getEventAccess(input.Urid, db)
+}
+
+func EditEventAccess(input *EventAccessEditInput, db *sql.DB) (*EventAccessOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	domains := []string{}
+	for _, domain := range input.Body.AllowedDomains {
+		domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@"))
+		if domain == "" {
+			continue
+		}
+		if strings.ContainsAny(domain, "@ /") || !strings.Contains(domain, ".") {
+			return nil, huma.Error422UnprocessableEntity("Некорректный домен: " + domain)
+		}
+		domains = append(domains, domain)
+	}
+
+	var accessCode sql.NullString
+	if code := strings.TrimSpace(input.Body.AccessCode); code != "" {
+		accessCode = sql.NullString{String: code, Valid: true}
+	}
+
+	_, err = db.Exec(
+		"UPDATE events SET visibility = $2, access_code = $3, allowed_domains = $4 WHERE urid = $1",
+		input.Urid, input.Body.Visibility, accessCode, pq.Array(domains),
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return getEventAccess(input.Urid, db)
+}
+
+func CreateInviteLink(input *InviteLinkCreateInput, db *sql.DB) (*InviteLinksOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+
+	var maxUses sql.NullInt64
+	if input.Body.MaxUses > 0 {
+		maxUses = sql.NullInt64{Int64: int64(input.Body.MaxUses), Valid: true}
+	}
+	var expiresAt sql.NullTime
+	if !input.Body.ExpiresAt.IsZero() {
+		expiresAt = sql.NullTime{Time: input.Body.ExpiresAt, Valid: true}
+	}
+
+	_, err = db.Exec(
+		"INSERT INTO event_invite_links (invite, event_uri, created_by, max_uses, expires_at) VALUES ($1, $2, $3, $4, $5)",
+		hex.EncodeToString(buf), input.Urid, user.Email, maxUses, expiresAt,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return getInviteLinks(input.Urid, db)
+}
+
+func GetInviteLinks(input *EventAccessInput, db *sql.DB) (*InviteLinksOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	return getInviteLinks(input.Urid, db)
+}
+
+func DelInviteLink(input *InviteLinkDeleteInput, db *sql.DB) (*InviteLinksOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	if _, err := db.Exec("DELETE FROM event_invite_links WHERE invite = $1 AND event_uri = $2", input.Invite, input.Urid); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return getInviteLinks(input.Urid, db)
+}
+
+// Проверяет код приглашения без его использования
+func isInviteValid(invite string, urid string, db *sql.DB) (bool, error) {
+	if invite == "" {
+		return false, nil
+	}
+
+	var valid bool
+	err := db.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM event_invite_links WHERE invite = $1 AND event_uri = $2 "+
+			"AND (max_uses IS NULL OR uses < max_uses) AND (expires_at IS NULL OR expires_at > now()))",
+		invite, urid,
+	).Scan(&valid)
+	if err != nil {
+		return false, huma.Error422UnprocessableEntity(err.Error())
+	}
+	return valid, nil
+}
+
+// Проверка при записи на мероприятие. Приглашение заменяет и код, и ограничение по доменам.
+// Возвращает true, если записаться можно только по приглашению (его нужно будет засчитать)
+func checkJoinAccess(user *utils.UserEmail, urid string, accessCode string, invite string, db *sql.DB) (bool, error) {
+	var visibility string
+	var code sql.NullString
+	var domains []string
+	if err := db.QueryRow(
+		"SELECT visibility, access_code, allowed_domains FROM events WHERE urid = $1", urid,
+	).Scan(&visibility, &code, pq.Array(&domains)); err != nil {
+		return false, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	invited, err := isInviteValid(invite, urid, db)
+	if err != nil {
+		return false, err
+	}
+	if invite != "" && !invited {
+		return false, huma.Error403Forbidden("Приглашение недействительно или закончилось")
+	}
+	if invited {
+		return true, nil
+	}
+
+	// Сравнение за постоянное время, чтобы код нельзя было подобрать по времени ответа
+	if code.Valid && subtle.ConstantTimeCompare([]byte(code.String), []byte(accessCode)) != 1 {
+		return false, huma.Error403Forbidden("Неверный код доступа")
+	}
+
+	if len(domains) > 0 {
+		_, domain, _ := strings.Cut(strings.ToLower(user.Email), "@")
+		allowed := false
+		for _, d := range domains {
+			if domain == d {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false, huma.Error403Forbidden("Записаться можно только с e-mail на " + strings.Join(domains, ", "))
+		}
+	}
+
+	// Закрытое мероприятие без кода и доменов - только по приглашению
+	if visibility == VisibilityPrivate && !code.Valid && len(domains) == 0 {
+		return false, huma.Error403Forbidden("Записаться можно только по приглашению")
+	}
+
+	return false, nil
+}
+
+// Засчитывает использование приглашения, если его еще можно использовать
+func useInvite(invite string, urid string, db *sql.DB) error {
+	var used string
+	err := db.QueryRow(
+		"UPDATE event_invite_links SET uses = uses + 1 WHERE invite = $1 AND event_uri = $2 "+
+			"AND (max_uses IS NULL OR uses < max_uses) AND (expires_at IS NULL OR expires_at > now()) RETURNING invite",
+		invite, urid,
+	).Scan(&used)
+	if err == sql.ErrNoRows {
+		return huma.Error403Forbidden("Приглашение недействительно или закончилось")
+	}
+	if err != nil {
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+	return nil
+}
+
+func getEventAccess(urid string, db *sql.DB) (*EventAccessOutput, error) {
+	result := new(EventAccessOutput)
+	result.Body.Urid = urid
+
+	var code sql.NullString
+	if err := db.QueryRow(
+		"SELECT visibility, access_code, allowed_domains FROM events WHERE urid = $1", urid,
+	).Scan(&result.Body.Visibility, &code, pq.Array(&result.Body.AllowedDomains)); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, huma.Error403Forbidden("Этого события нет XP")
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	result.Body.AccessCode = code.String
+
+	return result, nil
+}
+
+func getInviteLinks(urid string, db *sql.DB) (*InviteLinksOutput, error) {
+	rows, err := db.Query(
+		"SELECT invite, created_by, max_uses, uses, expires_at, created_at FROM event_invite_links "+
+			"WHERE event_uri = $1 ORDER BY created_at DESC", urid,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	result := new(InviteLinksOutput)
+
+	for rows.Next() {
+		link := new(InviteLink)
+		var maxUses sql.NullInt64
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&link.Invite, &link.CreatedBy, &maxUses, &link.Uses, &expiresAt, &link.CreatedAt); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		link.MaxUses = int(maxUses.Int64)
+		if expiresAt.Valid {
+			link.ExpiresAt = &expiresAt.Time
+		}
+		link.Url = os.Getenv("PUBLIC_URL") + "/event/" + url.PathEscape(urid) + "?invite=" + link.Invite
+
+		result.Body.Links = append(result.Body.Links, link)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
@@ -0,0 +1,317 @@
+package events
+
+import (
+	"database/sql"
+	"hackaton-jam-back/controllers/moderation"
+	"hackaton-jam-back/controllers/utils"
+	"strings"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+type AgendaItem struct {
+	Id          int64      `json:"id" example:"1" doc:"Идентификатор пункта"`
+	Title       string     `json:"title" example:"Открытие" doc:"Название пункта"`
+	Description string     `json:"desc,omitempty" doc:"Описание"`
+	Place       string     `json:"place,omitempty" example:"Главный зал" doc:"Где проходит"`
+	StartsAt    time.Time  `json:"starts_at" doc:"Начало"`
+	EndsAt      *time.Time `json:"ends_at,omitempty" doc:"Окончание"`
+
+	Translations []AgendaItemTranslation `json:"translations,omitempty" doc:"Переводы пункта (только для организаторов)"`
+}
+
+type AgendaItemTranslation struct {
+	Locale      string `json:"locale" pattern:"^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})?$" example:"en" doc:"Язык перевода"`
+	Title       string `json:"title,omitempty" maxLength:"255" example:"Opening" doc:"Название пункта (пусто - как на основном языке)"`
+	Description string `json:"desc,omitempty" doc:"Описание (пусто - как на основном языке)"`
+	Place       string `json:"place,omitempty" maxLength:"255" example:"Main hall" doc:"Где проходит (пусто - как на основном языке)"`
+}
+
+type AgendaItemDraft struct {
+	Title       string     `json:"title" minLength:"1" maxLength:"255" example:"Открытие" doc:"Название пункта"`
+	Description string     `json:"desc,omitempty" doc:"Описание"`
+	Place       string     `json:"place,omitempty" maxLength:"255" example:"Главный зал" doc:"Где проходит"`
+	StartsAt    time.Time  `json:"starts_at" doc:"Начало"`
+	EndsAt      *time.Time `json:"ends_at,omitempty" doc:"Окончание (необязательно)"`
+
+	Translations []AgendaItemTranslation `json:"translations,omitempty" maxItems:"20" doc:"Переводы пункта на другие языки"`
+}
+
+type AgendaInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type AgendaEditInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token  string            `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+		Agenda []AgendaItemDraft `json:"agenda" maxItems:"100" doc:"Программа мероприятия целиком (пусто - убрать программу)"`
+	}
+}
+
+type AgendaOutput struct {
+	Body struct {
+		Agenda []*AgendaItem `json:"agenda" doc:"Программа мероприятия по времени"`
+	}
+}
+
+// Программа вместе с переводами, чтобы организатор мог ее отредактировать
+func GetAgenda(input *AgendaInput, db *sql.DB) (*AgendaOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	return getAgendaOutput(input.Urid, db)
+}
+
+// Программа заменяется целиком вместе с переводами. После изменения мероприятие снова уходит на модерацию
+func EditAgenda(input *AgendaEditInput, db *sql.DB) (*AgendaOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	defaultLocale, err := getDefaultLocale(input.Urid, db)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range input.Body.Agenda {
+		if strings.TrimSpace(item.Title) == "" {
+			return nil, huma.Error422UnprocessableEntity("Название пункта программы не должно быть пустым")
+		}
+		if item.StartsAt.IsZero() {
+			return nil, huma.Error422UnprocessableEntity("Нужно указать начало пункта программы: " + item.Title)
+		}
+		if item.EndsAt != nil && item.EndsAt.Before(item.StartsAt) {
+			return nil, huma.Error422UnprocessableEntity("Пункт программы заканчивается раньше, чем начинается: " + item.Title)
+		}
+		if err := checkAgendaTranslations(item, defaultLocale); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM event_agenda WHERE event_uri = $1", input.Urid); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	for _, item := range input.Body.Agenda {
+		if err := insertAgendaItem(input.Urid, item, tx); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	if err := moderation.Requeue(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	return getAgendaOutput(input.Urid, db)
+}
+
+func checkAgendaTranslations(item AgendaItemDraft, defaultLocale string) error {
+	locales := make(map[string]bool)
+	for _, translation := range item.Translations {
+		locale := strings.ToLower(translation.Locale)
+		if locale == defaultLocale {
+			return huma.Error422UnprocessableEntity("Это основной язык мероприятия, тексты на нем задаются в самом пункте: " + item.Title)
+		}
+		if locales[locale] {
+			return huma.Error422UnprocessableEntity("Перевод на " + locale + " указан дважды: " + item.Title)
+		}
+		if translation.Title == "" && translation.Description == "" && translation.Place == "" {
+			return huma.Error422UnprocessableEntity("Перевод не должен быть пустым: " + item.Title)
+		}
+		locales[locale] = true
+	}
+
+	return nil
+}
+
+func insertAgendaItem(urid string, item AgendaItemDraft, tx *sql.Tx) error {
+	var endsAt sql.NullTime
+	if item.EndsAt != nil {
+		endsAt = sql.NullTime{Time: *item.EndsAt, Valid: true}
+	}
+
+	var id int64
+	err := tx.QueryRow(
+		"INSERT INTO event_agenda (event_uri, title, \"desc\", place, starts_at, ends_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
+		urid, item.Title, item.Description, item.Place, item.StartsAt, endsAt,
+	).Scan(&id)
+	if err != nil {
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	for _, translation := range item.Translations {
+		_, err = tx.Exec(
+			"INSERT INTO event_agenda_translations (item_id, locale, title, \"desc\", place) "+
+				"VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''))",
+			id, strings.ToLower(translation.Locale), translation.Title, translation.Description, translation.Place,
+		)
+		if err != nil {
+			return huma.Error422UnprocessableEntity(err.Error())
+		}
+	}
+
+	return nil
+}
+
+func getAgendaOutput(urid string, db *sql.DB) (*AgendaOutput, error) {
+	agenda, err := getEventAgenda(urid, db)
+	if err != nil {
+		return nil, err
+	}
+	if err := loadAgendaTranslations(urid, agenda, db); err != nil {
+		return nil, err
+	}
+
+	result := new(AgendaOutput)
+	result.Body.Agenda = agenda
+	return result, nil
+}
+
+// Подставляет перевод в пункты программы. Незаполненные поля остаются на основном языке
+func localizeAgenda(urid string, agenda []*AgendaItem, locale string, db *sql.DB) error {
+	if len(agenda) == 0 {
+		return nil
+	}
+
+	rows, err := db.Query(
+		"SELECT event_agenda_translations.item_id, event_agenda_translations.title, event_agenda_translations.\"desc\", event_agenda_translations.place "+
+			"FROM event_agenda_translations INNER JOIN event_agenda ON event_agenda.id = event_agenda_translations.item_id "+
+			"WHERE event_agenda.event_uri = $1 AND event_agenda_translations.locale = $2",
+		urid, locale,
+	)
+	if err != nil {
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	items := make(map[int64]*AgendaItem, len(agenda))
+	for _, item := range agenda {
+		items[item.Id] = item
+	}
+
+	for rows.Next() {
+		var id int64
+		var title, desc, place sql.NullString
+		if err := rows.Scan(&id, &title, &desc, &place); err != nil {
+			return huma.Error422UnprocessableEntity(err.Error())
+		}
+
+		item, ok := items[id]
+		if !ok {
+			continue
+		}
+		if title.String != "" {
+			item.Title = title.String
+		}
+		if desc.String != "" {
+			item.Description = desc.String
+		}
+		if place.String != "" {
+			item.Place = place.String
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return nil
+}
+
+// Все переводы пунктов программы одним запросом (для организаторов и шаблонов)
+func loadAgendaTranslations(urid string, agenda []*AgendaItem, db *sql.DB) error {
+	if len(agenda) == 0 {
+		return nil
+	}
+
+	rows, err := db.Query(
+		"SELECT event_agenda_translations.item_id, event_agenda_translations.locale, "+
+			"event_agenda_translations.title, event_agenda_translations.\"desc\", event_agenda_translations.place "+
+			"FROM event_agenda_translations INNER JOIN event_agenda ON event_agenda.id = event_agenda_translations.item_id "+
+			"WHERE event_agenda.event_uri = $1 ORDER BY event_agenda_translations.locale",
+		urid,
+	)
+	if err != nil {
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	items := make(map[int64]*AgendaItem, len(agenda))
+	for _, item := range agenda {
+		items[item.Id] = item
+	}
+
+	for rows.Next() {
+		var id int64
+		var translation AgendaItemTranslation
+		var title, desc, place sql.NullString
+		if err := rows.Scan(&id, &translation.Locale, &title, &desc, &place); err != nil {
+			return huma.Error422UnprocessableEntity(err.Error())
+		}
+		translation.Title = title.String
+		translation.Description = desc.String
+		translation.Place = place.String
+
+		if item, ok := items[id]; ok {
+			item.Translations = append(item.Translations, translation)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return nil
+}
+
+func getEventAgenda(urid string, db *sql.DB) ([]*AgendaItem, error) {
+	rows, err := db.Query(
+		"SELECT id, title, \"desc\", place, starts_at, ends_at FROM event_agenda WHERE event_uri = $1 ORDER BY starts_at, id", urid,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	agenda := []*AgendaItem{}
+
+	for rows.Next() {
+		item := new(AgendaItem)
+		var endsAt sql.NullTime
+		if err := rows.Scan(&item.Id, &item.Title, &item.Description, &item.Place, &item.StartsAt, &endsAt); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		if endsAt.Valid {
+			item.EndsAt = &endsAt.Time
+		}
+
+		agenda = append(agenda, item)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return agenda, nil
+}
@@ -0,0 +1,26 @@
+package events
+
+import "testing"
+
+func TestCheckAgendaTranslations(t *testing.T) {
+	tests := []struct {
+		name         string
+		translations []AgendaItemTranslation
+		ok           bool
+	}{
+		{"без переводов", nil, true},
+		{"два языка", []AgendaItemTranslation{{Locale: "en", Title: "Opening"}, {Locale: "pt-BR", Place: "Salão"}}, true},
+		{"основной язык", []AgendaItemTranslation{{Locale: "RU", Title: "Открытие"}}, false},
+		{"язык дважды", []AgendaItemTranslation{{Locale: "en", Title: "Opening"}, {Locale: "EN", Title: "Start"}}, false},
+		{"пустой перевод", []AgendaItemTranslation{{Locale: "en"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := AgendaItemDraft{Title: "Открытие", Translations: tt.translations}
+			if err := checkAgendaTranslations(item, "ru"); (err == nil) != tt.ok {
+				t.Errorf("ошибка %v, ожидалось принятие: %v", err, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package events
+
+import (
+	"database/sql"
+	"errors"
+	"hackaton-jam-back/controllers/geo"
+	"hackaton-jam-back/controllers/utils"
+	"strconv"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+type EventGeoInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+
+		Latitude  *float64 `json:"latitude,omitempty" minimum:"-90" maximum:"90" example:"56.8389" doc:"Широта (без координат - определить по месту проведения)"`
+		Longitude *float64 `json:"longitude,omitempty" minimum:"-180" maximum:"180" example:"60.6057" doc:"Долгота"`
+		City      string   `json:"city,omitempty" example:"Екатеринбург" doc:"Город (пусто - определить по месту проведения)"`
+	}
+}
+
+type EventGeoOutput struct {
+	Body struct {
+		Urid      string   `json:"urid" example:"example_events" doc:"Ссылка на мероприятие"`
+		City      string   `json:"city,omitempty" example:"Екатеринбург" doc:"Город"`
+		Latitude  *float64 `json:"latitude,omitempty" example:"56.8389" doc:"Широта"`
+		Longitude *float64 `json:"longitude,omitempty" example:"60.6057" doc:"Долгота"`
+	}
+}
+
+type NearbyEventsInput struct {
+	Latitude  float64 `query:"latitude" minimum:"-90" maximum:"90" example:"56.8389" doc:"Широта точки поиска"`
+	Longitude float64 `query:"longitude" minimum:"-180" maximum:"180" example:"60.6057" doc:"Долгота точки поиска"`
+	Radius    float64 `query:"radius" default:"50" minimum:"1" maximum:"1000" example:"50" doc:"Радиус поиска в километрах"`
+	Count     int     `query:"count" default:"20" minimum:"1" maximum:"100" example:"20" doc:"Количество событий на страницу"`
+	Cursor    string  `query:"cursor" doc:"Курсор следующей страницы из прошлого ответа (пусто - первая страница)"`
+	utils.ViewerInput
+	utils.LocaleInput
+}
+
+type NearbyEvent struct {
+	EventType
+	Distance float64 `json:"distance" example:"12.5" doc:"Расстояние до мероприятия в километрах"`
+}
+
+type NearbyEventsOutput struct {
+	Vary string `header:"Vary"`
+	Body struct {
+		Events     []NearbyEvent `json:"events" doc:"Мероприятия (ближайшие сверху)"`
+		NextCursor string        `json:"next_cursor,omitempty" doc:"Курсор следующей страницы (пусто - это последняя)"`
+	}
+}
+
+type EventCity struct {
+	City   string `json:"city" example:"Екатеринбург" doc:"Город"`
+	Events int    `json:"events" example:"3" doc:"Предстоящих очных мероприятий"`
+}
+
+type EventCitiesOutput struct {
+	Body struct {
+		Cities []*EventCity `json:"cities" doc:"Города с очными мероприятиями (сначала те, где их больше)"`
+	}
+}
+
+// Ищет очные мероприятия, которые еще не закончились, в радиусе от точки
+func GetNearbyEvents(input *NearbyEventsInput, db *sql.DB) (*NearbyEventsOutput, error) {
+	page, err := utils.NewPage(input.Cursor, "distance", input.Count)
+	if err != nil {
+		return nil, err
+	}
+	radius := input.Radius
+	if radius <= 0 {
+		radius = 50
+	}
+
+	var viewer string
+	var isAdmin bool
+	if input.Token != "" {
+		user, err := utils.GetUserEmailByToken(input.Token, db)
+		if err != nil {
+			return nil, err
+		}
+		viewer, isAdmin = user.Email, user.Perms == 10
+	}
+
+	minLat, maxLat, minLon, maxLon := geo.BoundingBox(input.Latitude, input.Longitude, radius)
+	distance := geo.DistanceSQL("$3", "$4")
+
+	query := "SELECT * FROM (SELECT urid, id, name, start_time, end_time, location, icon, is_irl, city, default_locale, " + distance + " AS distance FROM events " +
+		"WHERE is_draft = false AND moderation_status = 'approved' AND is_irl = true AND end_time > now() AND " + visibleEventCondition + " " +
+		"AND latitude BETWEEN $6 AND $7 AND longitude BETWEEN $8 AND $9) AS nearby WHERE distance <= $5"
+	args := []any{viewer, isAdmin, input.Latitude, input.Longitude, radius, minLat, maxLat, minLon, maxLon, page.Limit()}
+	if page.After != nil {
+		query += " AND (distance, id) > ($11::double precision, $12::bigint)"
+		args = append(args, page.After.Value, page.After.Key)
+	}
+	query += " ORDER BY distance, id LIMIT $10"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	result := new(NearbyEventsOutput)
+
+	for rows.Next() {
+		var id int64
+		var location, icon, city sql.NullString
+		var event NearbyEvent
+		if err := rows.Scan(
+			&event.Urid, &id, &event.Name, &event.StartTime, &event.EndTime, &location, &icon, &event.IsIrl, &city, &event.Locale, &event.Distance,
+		); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		event.Location = location.String
+		event.Icon = icon.String
+		event.City = city.String
+
+		if !page.Add(strconv.FormatFloat(event.Distance, 'g', -1, 64), strconv.FormatInt(id, 10)) {
+			break
+		}
+
+		event.Tags, err = getEventTags(event.Urid, db)
+		if err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+
+		result.Body.Events = append(result.Body.Events, event)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	result.Body.NextCursor = page.NextCursor()
+
+	cards := make([]*EventType, len(result.Body.Events))
+	for i := range result.Body.Events {
+		cards[i] = &result.Body.Events[i].EventType
+	}
+	if err := localizeEventTypes(cards, utils.PreferredLocales(input.LocaleInput), db); err != nil {
+		return nil, err
+	}
+	result.Vary = utils.VaryLanguage
+
+	return result, nil
+}
+
+// Справочник для фильтра по городу: только открытые предстоящие очные мероприятия
+func GetEventCities(db *sql.DB) (*EventCitiesOutput, error) {
+	rows, err := db.Query(
+		"SELECT city, COUNT(*) FROM events " +
+			"WHERE city IS NOT NULL AND is_irl = true AND is_draft = false AND moderation_status = 'approved' " +
+			"AND visibility = 'public' AND end_time > now() " +
+			"GROUP BY city ORDER BY COUNT(*) DESC, city",
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	result := new(EventCitiesOutput)
+	result.Body.Cities = []*EventCity{}
+
+	for rows.Next() {
+		city := new(EventCity)
+		if err := rows.Scan(&city.City, &city.Events); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		result.Body.Cities = append(result.Body.Cities, city)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
+
+// Ставит координаты вручную (например, точный адрес площадки) или заново определяет их по месту проведения
+func EditEventGeo(input *EventGeoInput, db *sql.DB, geocoder geo.Geocoder) (*EventGeoOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	if (input.Body.Latitude == nil) != (input.Body.Longitude == nil) {
+		return nil, huma.Error422UnprocessableEntity("Нужно указать и широту, и долготу")
+	}
+
+	var location sql.NullString
+	if err := db.QueryRow("SELECT location FROM events WHERE urid = $1", input.Urid).Scan(&location); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	place, err := geocoder.Geocode(location.String)
+	if err != nil && !errors.Is(err, geo.ErrNotFound) {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	result := new(EventGeoOutput)
+	result.Body.Urid = input.Urid
+	if place != nil {
+		result.Body.City = place.City
+		result.Body.Latitude, result.Body.Longitude = &place.Latitude, &place.Longitude
+	}
+	if input.Body.Latitude != nil {
+		result.Body.Latitude, result.Body.Longitude = input.Body.Latitude, input.Body.Longitude
+	}
+	if input.Body.City != "" {
+		result.Body.City = input.Body.City
+	}
+	if place == nil && input.Body.Latitude == nil && input.Body.City == "" {
+		return nil, huma.Error422UnprocessableEntity("Не удалось определить город по месту проведения, укажите его вручную")
+	}
+
+	if err := setEventPlace(input.Urid, result.Body.City, result.Body.Latitude, result.Body.Longitude, db); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Запросы выполняются и в транзакции, и без нее
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// Определяет город и координаты по месту проведения. Не найдено - значит не указано
+func geocodeEvent(urid string, location string, geocoder geo.Geocoder, db execer) error {
+	place, err := geocoder.Geocode(location)
+	if errors.Is(err, geo.ErrNotFound) {
+		return setEventPlace(urid, "", nil, nil, db)
+	}
+	if err != nil {
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+	return setEventPlace(urid, place.City, &place.Latitude, &place.Longitude, db)
+}
+
+func setEventPlace(urid string, city string, latitude *float64, longitude *float64, db execer) error {
+	var eventCity sql.NullString
+	if city != "" {
+		eventCity = sql.NullString{String: city, Valid: true}
+	}
+
+	_, err := db.Exec("UPDATE events SET city = $2, latitude = $3, longitude = $4 WHERE urid = $1", urid, eventCity, latitude, longitude)
+	if err != nil {
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,277 @@
+package events
+
+import (
+	"database/sql"
+	"hackaton-jam-back/controllers/moderation"
+	"hackaton-jam-back/controllers/utils"
+	"strings"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+// Основной язык мероприятий, если организатор не указал другой
+const DefaultLocale = "ru"
+
+type EventTranslation struct {
+	Locale       string    `json:"locale" example:"en" doc:"Язык перевода"`
+	Name         string    `json:"name,omitempty" example:"Example GameJam" doc:"Название мероприятия"`
+	Description  string    `json:"desc,omitempty" doc:"Описание мероприятия"`
+	Requirements string    `json:"requirements,omitempty" doc:"Необходимые навыки для мероприятия"`
+	Prize        string    `json:"prize,omitempty" doc:"Призы мероприятия"`
+	UpdatedAt    time.Time `json:"updated_at" doc:"Когда перевод меняли последний раз"`
+}
+
+type EventTranslationsInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type EventTranslationSaveInput struct {
+	Urid   string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Locale string `path:"locale" pattern:"^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})?$" example:"en" doc:"Язык перевода (например en или pt-BR)"`
+	Body   struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+
+		Name         string `json:"name,omitempty" example:"Example GameJam" doc:"Название мероприятия (пусто - как на основном языке)"`
+		Description  string `json:"desc,omitempty" doc:"Описание мероприятия (пусто - как на основном языке)"`
+		Requirements string `json:"requirements,omitempty" doc:"Необходимые навыки (пусто - как на основном языке)"`
+		Prize        string `json:"prize,omitempty" doc:"Призы мероприятия (пусто - как на основном языке)"`
+	}
+}
+
+type EventTranslationDelInput struct {
+	Urid   string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Locale string `path:"locale" pattern:"^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})?$" example:"en" doc:"Язык перевода"`
+	Body   struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type EventLocaleInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+
+		DefaultLocale string `json:"default_locale" pattern:"^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})?$" example:"ru" doc:"Язык, на котором написаны тексты самого мероприятия"`
+	}
+}
+
+type EventTranslationsOutput struct {
+	Body struct {
+		DefaultLocale string              `json:"default_locale" example:"ru" doc:"Основной язык мероприятия"`
+		Translations  []*EventTranslation `json:"translations" doc:"Переводы"`
+	}
+}
+
+func GetEventTranslations(input *EventTranslationsInput, db *sql.DB) (*EventTranslationsOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	return getEventTranslations(input.Urid, db)
+}
+
+func SaveEventTranslation(input *EventTranslationSaveInput, db *sql.DB) (*EventTranslationsOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	if input.Body.Name == "" && input.Body.Description == "" && input.Body.Requirements == "" && input.Body.Prize == "" {
+		return nil, huma.Error422UnprocessableEntity("Перевод не должен быть пустым")
+	}
+
+	locale := strings.ToLower(input.Locale)
+	defaultLocale, err := getDefaultLocale(input.Urid, db)
+	if err != nil {
+		return nil, err
+	}
+	if locale == defaultLocale {
+		return nil, huma.Error422UnprocessableEntity("Это основной язык мероприятия, тексты на нем меняются в самом мероприятии")
+	}
+
+	_, err = db.Exec(
+		"INSERT INTO event_translations (event_uri, locale, name, \"desc\", requirements, prize) "+
+			"VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, '')) "+
+			"ON CONFLICT (event_uri, locale) DO UPDATE SET "+
+			"name = EXCLUDED.name, \"desc\" = EXCLUDED.\"desc\", requirements = EXCLUDED.requirements, "+
+			"prize = EXCLUDED.prize, updated_at = now()",
+		input.Urid, locale, input.Body.Name, input.Body.Description, input.Body.Requirements, input.Body.Prize,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	// Перевод участники видят так же, как основной текст, - снова на модерацию
+	if err := moderation.Requeue(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	return getEventTranslations(input.Urid, db)
+}
+
+func DelEventTranslation(input *EventTranslationDelInput, db *sql.DB) (*EventTranslationsOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec("DELETE FROM event_translations WHERE event_uri = $1 AND locale = $2", input.Urid, strings.ToLower(input.Locale))
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return getEventTranslations(input.Urid, db)
+}
+
+// Меняет язык, на котором написаны тексты самого мероприятия
+func SetEventLocale(input *EventLocaleInput, db *sql.DB) (*EventTranslationsOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	locale := strings.ToLower(input.Body.DefaultLocale)
+
+	// Иначе на один язык будет два разных текста
+	res, err := db.Exec(
+		"UPDATE events SET default_locale = $2 WHERE urid = $1 "+
+			"AND NOT EXISTS (SELECT 1 FROM event_translations WHERE event_uri = $1 AND locale = $2) "+
+			"AND NOT EXISTS (SELECT 1 FROM event_agenda_translations INNER JOIN event_agenda ON event_agenda.id = event_agenda_translations.item_id "+
+			"WHERE event_agenda.event_uri = $1 AND event_agenda_translations.locale = $2)",
+		input.Urid, locale,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, huma.Error422UnprocessableEntity("На этот язык уже есть перевод, сначала удалите его")
+	}
+
+	return getEventTranslations(input.Urid, db)
+}
+
+// Подставляет перевод в полную информацию о мероприятии и его программу. Незаполненные поля
+// перевода остаются на основном языке
+func localizeEvent(event *FullEventOutput, preferred []string, db *sql.DB) error {
+	locale := utils.MatchLocale(preferred, event.Body.Locales, event.Body.Locale)
+	if locale == event.Body.Locale {
+		return nil
+	}
+
+	var name, desc, requirements, prize sql.NullString
+	err := db.QueryRow(
+		"SELECT name, \"desc\", requirements, prize FROM event_translations WHERE event_uri = $1 AND locale = $2",
+		event.Body.Urid, locale,
+	).Scan(&name, &desc, &requirements, &prize)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	if name.String != "" {
+		event.Body.Name = name.String
+	}
+	if desc.String != "" {
+		event.Body.Description = desc.String
+	}
+	if requirements.String != "" {
+		event.Body.Requirements = requirements.String
+	}
+	if prize.String != "" {
+		event.Body.Prize = prize.String
+	}
+	event.Body.Locale = locale
+
+	return localizeAgenda(event.Body.Urid, event.Body.Agenda, locale, db)
+}
+
+// То же для карточек страницы списка, где из текстов есть только название.
+// Переводы грузятся одним запросом на всю страницу
+func localizeEventTypes(events []*EventType, preferred []string, db *sql.DB) error {
+	urids := make([]string, len(events))
+	for i, event := range events {
+		urids[i] = event.Urid
+	}
+
+	translations, err := utils.GetNameTranslations(urids, preferred, db)
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		event.Name, event.Locale = translations.Localize(event.Urid, event.Name, event.Locale, preferred)
+	}
+
+	return nil
+}
+
+func getDefaultLocale(urid string, db *sql.DB) (string, error) {
+	var locale string
+	if err := db.QueryRow("SELECT default_locale FROM events WHERE urid = $1", urid).Scan(&locale); err != nil {
+		if err == sql.ErrNoRows {
+			return "", huma.Error404NotFound("Такого мероприятия нет")
+		}
+		return "", huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return locale, nil
+}
+
+func getEventTranslations(urid string, db *sql.DB) (*EventTranslationsOutput, error) {
+	result := new(EventTranslationsOutput)
+
+	var err error
+	result.Body.DefaultLocale, err = getDefaultLocale(urid, db)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(
+		"SELECT locale, name, \"desc\", requirements, prize, updated_at FROM event_translations WHERE event_uri = $1 ORDER BY locale", urid,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		translation := new(EventTranslation)
+		var name, desc, requirements, prize sql.NullString
+		if err := rows.Scan(&translation.Locale, &name, &desc, &requirements, &prize, &translation.UpdatedAt); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		translation.Name = name.String
+		translation.Description = desc.String
+		translation.Requirements = requirements.String
+		translation.Prize = prize.String
+
+		result.Body.Translations = append(result.Body.Translations, translation)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
@@ -2,6 +2,12 @@ package events
 
 import (
 	"database/sql"
+	"hackaton-jam-back/controllers/feedback"
+	"hackaton-jam-back/controllers/geo"
+	"hackaton-jam-back/controllers/moderation"
+	"hackaton-jam-back/controllers/organizations"
+	"hackaton-jam-back/controllers/qa"
+	"hackaton-jam-back/controllers/results"
 	"hackaton-jam-back/controllers/utils"
 	"log"
 	"reflect"
@@ -25,9 +31,13 @@ type EventCreationInput struct {
 		TeamRequirementsType  int       `json:"team_requirements_type" doc:"Тип равенства требования к количеству сокомандников (0 - ==, 1 - <=, 2 - <, 3 - =>, 4 >)"`
 		TeamRequirementsValue int       `json:"team_requirements_value" doc:"Количество сокомандников"`
 
-		Description  string `json:"desc,omitempty" doc:"Описание мероприятия"`
-		Prize        string `json:"prize,omitempty" doc:"Призы мероприятия"`
-		Requirements string `json:"requirements,omitempty" doc:"Необходимые навыки для мероприятия"`
+		Description        string    `json:"desc,omitempty" doc:"Описание мероприятия"`
+		Prize              string    `json:"prize,omitempty" doc:"Призы мероприятия"`
+		Requirements       string    `json:"requirements,omitempty" doc:"Необходимые навыки для мероприятия"`
+		SubmissionDeadline time.Time `json:"submission_deadline,omitempty" doc:"Крайний срок сдачи проектов"`
+		IsDraft            bool      `json:"is_draft,omitempty" doc:"Создать как черновик"`
+		Organization       string    `json:"organization,omitempty" maxLength:"30" example:"urfu" doc:"Организация-владелец (нужно в ней состоять)"`
+		DefaultLocale      string    `json:"default_locale,omitempty" pattern:"^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})?$" example:"ru" doc:"Язык текстов мероприятия (по умолчанию ru)"`
 	}
 }
 
@@ -61,38 +71,63 @@ type EventEditInput struct {
 }
 
 type Organizators struct {
-	Email    string `json:"email" doc:"E-mail организатора"`
-	Username string `json:"username" doc:"Имя пользователя организатора"`
+	Email    string           `json:"email" doc:"E-mail организатора"`
+	Username string           `json:"username" doc:"Имя пользователя организатора"`
+	Rating   *feedback.Rating `json:"rating,omitempty" doc:"Оценка организатора по анкетам всех его мероприятий"`
 }
 
 type FullEventOutput struct {
+	Vary string `header:"Vary"`
 	Body struct {
-		Urid                  string    `json:"urid" example:"example_events" doc:"Ссылка на мероприятие"`
-		Name                  string    `json:"name" example:"Example GameJam" doc:"Название мероприятия"`
-		StartTime             time.Time `json:"start_time" doc:"Начало проведения"`
-		EndTime               time.Time `json:"end_time" doc:"Конец проведения"`
-		Location              string    `json:"location" example:"Свердловская область, г. Екатеринбург" doc:"Место проведения"`
-		Description           string    `json:"desc" doc:"Описание мероприятия"`
-		Prize                 string    `json:"prize" doc:"Призы мероприятия"`
-		Requirements          string    `json:"requirements" doc:"Необходимые навыки для мероприятия"`
-		Partners              []string  `json:"partners" doc:"Партнеры мероприятия"`
-		Icon                  string    `json:"icon" doc:"Превью мероприятия"`
-		IsIrl                 bool      `json:"is_irl" doc:"Очное ли мероприятие?"`
-		TeamRequirementsType  int       `json:"team_requirements_type" doc:"Тип равенства требования к количеству сокомандников (0 - ==, 1 - <=, 2 - <, 3 - =>, 4 >)"`
-		TeamRequirementsValue int       `json:"team_requirements_value" doc:"Количество сокомандников"`
-
-		Tags         []string        `json:"tags" doc:"Тэги события"`
-		Organizators []*Organizators `json:"organisators" doc:"Список организаторов"`
+		Urid                  string     `json:"urid" example:"example_events" doc:"Ссылка на мероприятие"`
+		Name                  string     `json:"name" example:"Example GameJam" doc:"Название мероприятия"`
+		StartTime             time.Time  `json:"start_time" doc:"Начало проведения"`
+		EndTime               time.Time  `json:"end_time" doc:"Конец проведения"`
+		Location              string     `json:"location" example:"Свердловская область, г. Екатеринбург" doc:"Место проведения"`
+		City                  string     `json:"city,omitempty" example:"Екатеринбург" doc:"Город проведения (определяется по месту проведения)"`
+		Latitude              *float64   `json:"latitude,omitempty" example:"56.8389" doc:"Широта места проведения"`
+		Longitude             *float64   `json:"longitude,omitempty" example:"60.6057" doc:"Долгота места проведения"`
+		Description           string     `json:"desc" doc:"Описание мероприятия"`
+		Prize                 string     `json:"prize" doc:"Призы мероприятия"`
+		Requirements          string     `json:"requirements" doc:"Необходимые навыки для мероприятия"`
+		Partners              []string   `json:"partners" doc:"Партнеры мероприятия"`
+		Icon                  string     `json:"icon" doc:"Превью мероприятия"`
+		IsIrl                 bool       `json:"is_irl" doc:"Очное ли мероприятие?"`
+		TeamRequirementsType  int        `json:"team_requirements_type" doc:"Тип равенства требования к количеству сокомандников (0 - ==, 1 - <=, 2 - <, 3 - =>, 4 >)"`
+		TeamRequirementsValue int        `json:"team_requirements_value" doc:"Количество сокомандников"`
+		SubmissionDeadline    *time.Time `json:"submission_deadline,omitempty" doc:"Крайний срок сдачи проектов"`
+		IsDraft               bool       `json:"is_draft" doc:"Черновик (не показывается в списке событий)"`
+		Series                string     `json:"series,omitempty" example:"autumn_jams" doc:"Серия, в которую входит мероприятие"`
+		Season                string     `json:"season,omitempty" example:"2026" doc:"Сезон серии"`
+		Visibility            string     `json:"visibility" example:"public" doc:"Видимость (public, unlisted, private)"`
+		ModerationStatus      string     `json:"moderation_status" example:"approved" doc:"Статус модерации (pending, approved, rejected)"`
+		ModerationReason      string     `json:"moderation_reason,omitempty" doc:"Причина отклонения модератором"`
+		HasAccessCode         bool       `json:"has_access_code" doc:"Нужен ли код для записи"`
+		AllowedDomains        []string   `json:"allowed_domains" doc:"Домены e-mail, с которых можно записаться (пусто - любые)"`
+		Locale                string     `json:"locale" example:"ru" doc:"Язык текстов в ответе"`
+		Locales               []string   `json:"locales" doc:"Языки, на которых есть тексты (первый - основной)"`
+
+		Rating       *feedback.Rating `json:"rating,omitempty" doc:"Оценка мероприятия участниками (если ответов достаточно)"`
+		Faq          []*qa.FaqItem    `json:"faq,omitempty" doc:"Частые вопросы с ответами организаторов"`
+		Agenda       []*AgendaItem    `json:"agenda" doc:"Программа мероприятия по времени"`
+		Tags         []string         `json:"tags" doc:"Тэги события"`
+		Organizators []*Organizators  `json:"organisators" doc:"Список организаторов"`
+
+		Results []*results.EventResult `json:"results,omitempty" doc:"Итоги мероприятия (после публикации)"`
+
+		RegistrationForm []*RegistrationField `json:"registration_form" doc:"Поля анкеты, которую заполняют при записи"`
+
+		Organization *organizations.OrganizationShortInfo `json:"organization,omitempty" doc:"Организация, которой принадлежит мероприятие"`
 	}
 }
 
 type DeleteEventOutput struct {
 	Body struct {
-		Errors []string `json:"errors" doc:"Список ошибок"`
+		Errors []string `json:"errors" doc:"Список ошибок (при ошибке удаление отменяется целиком)"`
 	}
 }
 
-func CreateEvent(input *EventCreationInput, db *sql.DB) (*FullEventOutput, error) {
+func CreateEvent(input *EventCreationInput, db *sql.DB, geocoder geo.Geocoder) (*FullEventOutput, error) {
 	// Проверить можем ли создать меро?
 	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
 	if err != nil {
@@ -102,16 +137,41 @@ func CreateEvent(input *EventCreationInput, db *sql.DB) (*FullEventOutput, error
 		return nil, huma.Error403Forbidden("Нет прав")
 	}
 
+	var orgUrid sql.NullString
+	if input.Body.Organization != "" {
+		if err := organizations.CheckMember(user, input.Body.Organization, db); err != nil {
+			return nil, err
+		}
+		orgUrid = sql.NullString{String: input.Body.Organization, Valid: true}
+	}
+
+	defaultLocale := DefaultLocale
+	if input.Body.DefaultLocale != "" {
+		defaultLocale = strings.ToLower(input.Body.DefaultLocale)
+	}
+
+	var submissionDeadline sql.NullTime
+	if !input.Body.SubmissionDeadline.IsZero() {
+		submissionDeadline = sql.NullTime{Time: input.Body.SubmissionDeadline, Valid: true}
+	}
+
+	// Без модерации публикуют только админы и доверенные организаторы
+	status, err := moderation.InitialStatus(user, db)
+	if err != nil {
+		return nil, err
+	}
+
 	// Запись в базу
 	_, err = db.Query("INSERT INTO events ("+
 		"urid, name, start_time, end_time, prize, \"location\", \"desc\", requirements, "+
-		"icon, is_irl, team_requirements_type, team_requirements_value) "+
-		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)",
+		"icon, is_irl, team_requirements_type, team_requirements_value, submission_deadline, is_draft, moderation_status, org_urid, default_locale) "+
+		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)",
 
 		input.Body.Urid, input.Body.Name, input.Body.StartTime, input.Body.EndTime,
 		input.Body.Prize, input.Body.Location, input.Body.Description,
 		input.Body.Requirements, input.Body.Icon, input.Body.IsIrl,
 		input.Body.TeamRequirementsType, input.Body.TeamRequirementsValue,
+		submissionDeadline, input.Body.IsDraft, status, orgUrid, defaultLocale,
 	)
 	if err != nil {
 		return nil, huma.Error422UnprocessableEntity(err.Error())
@@ -122,26 +182,31 @@ func CreateEvent(input *EventCreationInput, db *sql.DB) (*FullEventOutput, error
 		return nil, huma.Error422UnprocessableEntity(err.Error())
 	}
 
+	if err := geocodeEvent(input.Body.Urid, input.Body.Location, geocoder, db); err != nil {
+		return nil, err
+	}
+
 	return getFullEventInfo(input.Body.Urid, db)
 }
 
-func EditEvent(input *EventEditInput, db *sql.DB) (*FullEventOutput, error) {
+func EditEvent(input *EventEditInput, db *sql.DB, geocoder geo.Geocoder) (*FullEventOutput, error) {
 	// Проверить наша ли меро?
 	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
 	if err != nil {
 		return nil, huma.Error403Forbidden("Пользователь не найден")
 	}
 
-	if user.Perms != 10 {
-		var organizator_email string
-		if err := db.QueryRow("SELECT organizator_email FROM event_orgs WHERE organizator_email = $1 AND event_uri = $2", user.Email, input.Urid).Scan(&organizator_email); err != nil {
-			if err == sql.ErrNoRows {
-				return nil, huma.Error403Forbidden("Это не твое мероприятие :/")
-			}
-			return nil, huma.Error422UnprocessableEntity(err.Error())
-		}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
 	}
 
+	// Поля и координаты нового места сохраняются вместе, иначе место и город могут разойтись
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer tx.Rollback()
+
 	val := reflect.ValueOf(input.Body)
 	t := val.Type()
 	for i := 0; i < t.NumField(); i++ {
@@ -156,8 +221,30 @@ func EditEvent(input *EventEditInput, db *sql.DB) (*FullEventOutput, error) {
 			continue
 		}
 
-		db.QueryRow("UPDATE events SET "+strings.Split(column_name.Tag.Get("json"), ",")[0]+" = $2 WHERE urid = $1", user.Email, fvalue.String()).Scan()
-		//                                                                        дебилизм ^                                  идиотизм ^
+		// Имя колонки берется из тега структуры, а не от клиента, поэтому его можно подставить в запрос.
+		// Кавычки нужны для desc и location
+		column := strings.Split(column_name.Tag.Get("json"), ",")[0]
+		if _, err := tx.Exec("UPDATE events SET \""+column+"\" = $2 WHERE urid = $1", input.Urid, fvalue.Interface()); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+	}
+
+	// Новое место - заново определяем город и координаты
+	if input.Body.Location != "" {
+		if err := geocodeEvent(input.Urid, input.Body.Location, geocoder, tx); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	// Поменялось то, что видят участники, - снова на модерацию
+	if input.Body.Name != "" || input.Body.Description != "" || input.Body.Icon != "" ||
+		input.Body.Prize != "" || input.Body.Requirements != "" || input.Body.Location != "" {
+		if err := moderation.Requeue(user, input.Urid, db); err != nil {
+			return nil, err
+		}
 	}
 
 	return getFullEventInfo(input.Urid, db)
@@ -169,44 +256,18 @@ func DeleteEvent(input *EventDeleteInput, db *sql.DB) (*DeleteEventOutput, error
 	if err != nil {
 		return nil, huma.Error403Forbidden("Пользователь не найден")
 	}
-	if user.Perms != 10 {
-		var organizator_email string
-		if err := db.QueryRow("SELECT organizator_email FROM event_orgs WHERE organizator_email = $1 AND event_uri = $2", user.Email, input.Urid).Scan(&organizator_email); err != nil {
-			if err == sql.ErrNoRows {
-				return nil, huma.Error403Forbidden("Это не твое мероприятие :/")
-			}
-			return nil, huma.Error422UnprocessableEntity(err.Error())
-		}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
 	}
 
-	result := new(DeleteEventOutput)
-
-	// Удалить все
-	_, err = db.Query("DELETE FROM event_orgs WHERE event_uri=$1", input.Urid)
-	if err != nil {
-		result.Body.Errors = append(result.Body.Errors, err.Error())
-	}
-	_, err = db.Query("DELETE FROM event_blog WHERE event_uri=$1", input.Urid)
-	if err != nil {
-		result.Body.Errors = append(result.Body.Errors, err.Error())
-	}
-	_, err = db.Query("DELETE FROM event_members WHERE event_uri=$1", input.Urid)
-	if err != nil {
-		result.Body.Errors = append(result.Body.Errors, err.Error())
-	}
-	_, err = db.Query("DELETE FROM event_tags WHERE event_uri=$1", input.Urid)
-	if err != nil {
-		result.Body.Errors = append(result.Body.Errors, err.Error())
-	}
-	_, err = db.Query("DELETE FROM event_partners WHERE event_uri=$1", input.Urid)
-	if err != nil {
-		result.Body.Errors = append(result.Body.Errors, err.Error())
-	}
-	_, err = db.Query("DELETE FROM events WHERE urid=$1", input.Urid)
-	if err != nil {
-		result.Body.Errors = append(result.Body.Errors, err.Error())
+	// Данные мероприятия удаляются каскадом по внешним ключам (sql/hjam.sql), одним запросом.
+	// Журнал изменений итогов (event_results_audit) на события не ссылается и остается архивом
+	if _, err := db.Exec("DELETE FROM events WHERE urid = $1", input.Urid); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
 	}
 
+	result := new(DeleteEventOutput)
+	result.Body.Errors = []string{}
 	return result, nil
 }
 
@@ -230,14 +291,8 @@ func AddEventTags(input *EventTagAddDelInput, db *sql.DB) (*FullEventOutput, err
 		return nil, huma.Error403Forbidden("Пользователь не найден")
 	}
 
-	if user.Perms != 10 {
-		var organizator_email string
-		if err := db.QueryRow("SELECT organizator_email FROM event_orgs WHERE organizator_email = $1 AND event_uri = $2", user.Email, input.Urid).Scan(&organizator_email); err != nil {
-			if err == sql.ErrNoRows {
-				return nil, huma.Error403Forbidden("Это не твое мероприятие :/")
-			}
-			return nil, huma.Error422UnprocessableEntity(err.Error())
-		}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
 	}
 
 	for _, tag := range input.Body.Tags {
@@ -262,14 +317,8 @@ func DelEventTags(input *EventTagAddDelInput, db *sql.DB) (*FullEventOutput, err
 		return nil, huma.Error403Forbidden("Пользователь не найден")
 	}
 
-	if user.Perms != 10 {
-		var organizator_email string
-		if err := db.QueryRow("SELECT organizator_email FROM event_orgs WHERE organizator_email = $1 AND event_uri = $2", user.Email, input.Urid).Scan(&organizator_email); err != nil {
-			if err == sql.ErrNoRows {
-				return nil, huma.Error403Forbidden("Это не твое мероприятие :/")
-			}
-			return nil, huma.Error422UnprocessableEntity(err.Error())
-		}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
 	}
 
 	for _, tag := range input.Body.Tags {
@@ -310,14 +359,8 @@ func AddEventPartners(input *EventPartnersAddDelInput, db *sql.DB) (*FullEventOu
 		return nil, huma.Error403Forbidden("Пользователь не найден")
 	}
 
-	if user.Perms != 10 {
-		var organizator_email string
-		if err := db.QueryRow("SELECT organizator_email FROM event_orgs WHERE organizator_email = $1 AND event_uri = $2", user.Email, input.Urid).Scan(&organizator_email); err != nil {
-			if err == sql.ErrNoRows {
-				return nil, huma.Error403Forbidden("Это не твое мероприятие :/")
-			}
-			return nil, huma.Error422UnprocessableEntity(err.Error())
-		}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
 	}
 
 	for _, logo := range input.Body.Partners {
@@ -334,14 +377,8 @@ func DelEventPartners(input *EventPartnersAddDelInput, db *sql.DB) (*FullEventOu
 		return nil, huma.Error403Forbidden("Пользователь не найден")
 	}
 
-	if user.Perms != 10 {
-		var organizator_email string
-		if err := db.QueryRow("SELECT organizator_email FROM event_orgs WHERE organizator_email = $1 AND event_uri = $2", user.Email, input.Urid).Scan(&organizator_email); err != nil {
-			if err == sql.ErrNoRows {
-				return nil, huma.Error403Forbidden("Это не твое мероприятие :/")
-			}
-			return nil, huma.Error422UnprocessableEntity(err.Error())
-		}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
 	}
 
 	for _, logo := range input.Body.Partners {
@@ -0,0 +1,281 @@
+package events
+
+import (
+	"database/sql"
+	"hackaton-jam-back/controllers/utils"
+	"slices"
+	"strings"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/lib/pq"
+)
+
+// Типы полей анкеты при записи
+const (
+	FieldText   = "text"   // Свободный ответ
+	FieldChoice = "choice" // Один вариант из списка
+)
+
+const maxAnswerLength = 2000
+
+type RegistrationField struct {
+	Id       int64    `json:"id" example:"1" doc:"Идентификатор поля"`
+	Label    string   `json:"label" example:"Размер футболки" doc:"Вопрос"`
+	Type     string   `json:"type" example:"choice" doc:"Тип поля (text - свободный ответ, choice - вариант из списка)"`
+	Options  []string `json:"options,omitempty" doc:"Варианты ответа (для choice)"`
+	Required bool     `json:"required" doc:"Обязательное поле"`
+}
+
+type RegistrationFieldDraft struct {
+	Label    string   `json:"label" minLength:"1" maxLength:"500" example:"Размер футболки" doc:"Вопрос"`
+	Type     string   `json:"type" enum:"text,choice" example:"choice" doc:"Тип поля (text - свободный ответ, choice - вариант из списка)"`
+	Options  []string `json:"options,omitempty" maxItems:"30" doc:"Варианты ответа (для choice)"`
+	Required bool     `json:"required,omitempty" doc:"Обязательное поле"`
+}
+
+type RegistrationAnswer struct {
+	FieldId int64  `json:"field_id" example:"1" doc:"Идентификатор поля"`
+	Value   string `json:"value" example:"M" doc:"Ответ"`
+}
+
+type RegistrationFormEditInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token  string                   `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+		Fields []RegistrationFieldDraft `json:"fields" maxItems:"30" doc:"Поля анкеты по порядку (пусто - запись без анкеты)"`
+	}
+}
+
+type RegistrationAnswersInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type RegistrationFormOutput struct {
+	Body struct {
+		Fields []*RegistrationField `json:"fields" doc:"Поля анкеты при записи"`
+	}
+}
+
+type MemberRegistration struct {
+	Email   string               `json:"email" example:"thatmaidguy@ya.ru" doc:"E-mail участника"`
+	Answers []RegistrationAnswer `json:"answers" doc:"Ответы участника"`
+}
+
+type RegistrationAnswersOutput struct {
+	Body struct {
+		Fields  []*RegistrationField  `json:"fields" doc:"Поля анкеты при записи"`
+		Members []*MemberRegistration `json:"members" doc:"Ответы участников"`
+	}
+}
+
+// Поля можно менять, пока никто не записался по анкете
+func EditRegistrationForm(input *RegistrationFormEditInput, db *sql.DB) (*RegistrationFormOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	for _, field := range input.Body.Fields {
+		if err := checkRegistrationField(field); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer tx.Rollback()
+
+	var answered bool
+	if err := tx.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM registration_answers INNER JOIN registration_fields ON registration_fields.id = registration_answers.field_id "+
+			"WHERE registration_fields.event_uri = $1)", input.Urid,
+	).Scan(&answered); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if answered {
+		return nil, huma.Error409Conflict("По анкете уже записались, поля менять нельзя")
+	}
+
+	if _, err := tx.Exec("DELETE FROM registration_fields WHERE event_uri = $1", input.Urid); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	for position, field := range input.Body.Fields {
+		if err := insertRegistrationField(input.Urid, field, position, tx); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	result := new(RegistrationFormOutput)
+	if result.Body.Fields, err = getRegistrationForm(input.Urid, db); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Ответы видят только организаторы
+func GetRegistrationAnswers(input *RegistrationAnswersInput, db *sql.DB) (*RegistrationAnswersOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	result := new(RegistrationAnswersOutput)
+	if result.Body.Fields, err = getRegistrationForm(input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(
+		"SELECT registration_answers.member_email, registration_answers.field_id, registration_answers.value "+
+			"FROM registration_answers INNER JOIN registration_fields ON registration_fields.id = registration_answers.field_id "+
+			"WHERE registration_fields.event_uri = $1 ORDER BY registration_answers.member_email, registration_fields.position",
+		input.Urid,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	result.Body.Members = []*MemberRegistration{}
+
+	var member *MemberRegistration
+	for rows.Next() {
+		var email string
+		var answer RegistrationAnswer
+		if err := rows.Scan(&email, &answer.FieldId, &answer.Value); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		if member == nil || member.Email != email {
+			member = &MemberRegistration{Email: email}
+			result.Body.Members = append(result.Body.Members, member)
+		}
+		member.Answers = append(member.Answers, answer)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
+
+func checkRegistrationField(field RegistrationFieldDraft) error {
+	if strings.TrimSpace(field.Label) == "" {
+		return huma.Error422UnprocessableEntity("Вопрос анкеты не должен быть пустым")
+	}
+	switch field.Type {
+	case FieldText:
+		if len(field.Options) > 0 {
+			return huma.Error422UnprocessableEntity("Варианты ответа бывают только у полей choice: " + field.Label)
+		}
+	case FieldChoice:
+		if len(field.Options) < 2 {
+			return huma.Error422UnprocessableEntity("Нужно хотя бы два варианта ответа: " + field.Label)
+		}
+	default:
+		return huma.Error422UnprocessableEntity("Неизвестный тип поля")
+	}
+
+	return nil
+}
+
+func insertRegistrationField(urid string, field RegistrationFieldDraft, position int, tx *sql.Tx) error {
+	options := field.Options
+	if options == nil {
+		options = []string{}
+	}
+
+	_, err := tx.Exec(
+		"INSERT INTO registration_fields (event_uri, label, type, options, required, position) VALUES ($1, $2, $3, $4, $5, $6)",
+		urid, field.Label, field.Type, pq.Array(options), field.Required, position,
+	)
+	if err != nil {
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return nil
+}
+
+// Проверяет ответы при записи: все обязательные поля заполнены, варианты из списка
+func checkRegistrationAnswers(fields []*RegistrationField, answers []RegistrationAnswer) ([]RegistrationAnswer, error) {
+	var checked []RegistrationAnswer
+	answered := make(map[int64]bool)
+
+	for _, answer := range answers {
+		var field *RegistrationField
+		for _, f := range fields {
+			if f.Id == answer.FieldId {
+				field = f
+				break
+			}
+		}
+		if field == nil {
+			return nil, huma.Error422UnprocessableEntity("Такого поля нет в анкете")
+		}
+		if answered[field.Id] {
+			return nil, huma.Error422UnprocessableEntity("На вопрос можно ответить только один раз: " + field.Label)
+		}
+
+		answer.Value = strings.TrimSpace(answer.Value)
+		if answer.Value == "" {
+			continue
+		}
+		if len([]rune(answer.Value)) > maxAnswerLength {
+			return nil, huma.Error422UnprocessableEntity("Слишком длинный ответ: " + field.Label)
+		}
+		if field.Type == FieldChoice && !slices.Contains(field.Options, answer.Value) {
+			return nil, huma.Error422UnprocessableEntity("Такого варианта нет: " + field.Label)
+		}
+
+		answered[field.Id] = true
+		checked = append(checked, answer)
+	}
+
+	for _, field := range fields {
+		if field.Required && !answered[field.Id] {
+			return nil, huma.Error422UnprocessableEntity("Нужно ответить на вопрос: " + field.Label)
+		}
+	}
+
+	return checked, nil
+}
+
+func getRegistrationForm(urid string, db *sql.DB) ([]*RegistrationField, error) {
+	rows, err := db.Query(
+		"SELECT id, label, type, options, required FROM registration_fields WHERE event_uri = $1 ORDER BY position, id", urid,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	fields := []*RegistrationField{}
+
+	for rows.Next() {
+		field := new(RegistrationField)
+		if err := rows.Scan(&field.Id, &field.Label, &field.Type, pq.Array(&field.Options), &field.Required); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+
+		fields = append(fields, field)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return fields, nil
+}
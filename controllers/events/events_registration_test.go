@@ -0,0 +1,48 @@
+package events
+
+import "testing"
+
+func TestCheckRegistrationAnswers(t *testing.T) {
+	fields := []*RegistrationField{
+		{Id: 1, Label: "Размер футболки", Type: FieldChoice, Options: []string{"S", "M", "L"}, Required: true},
+		{Id: 2, Label: "Аллергии", Type: FieldText},
+	}
+
+	tests := []struct {
+		name    string
+		answers []RegistrationAnswer
+		ok      bool
+		saved   int
+	}{
+		{"только обязательное", []RegistrationAnswer{{1, "M"}}, true, 1},
+		{"все поля", []RegistrationAnswer{{1, "L"}, {2, " орехи "}}, true, 2},
+		{"пустой необязательный", []RegistrationAnswer{{1, "S"}, {2, "  "}}, true, 1},
+		{"без обязательного", []RegistrationAnswer{{2, "нет"}}, false, 0},
+		{"пустой обязательный", []RegistrationAnswer{{1, ""}}, false, 0},
+		{"чужой вариант", []RegistrationAnswer{{1, "XXL"}}, false, 0},
+		{"чужое поле", []RegistrationAnswer{{1, "M"}, {3, "?"}}, false, 0},
+		{"повтор", []RegistrationAnswer{{1, "M"}, {1, "L"}}, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved, err := checkRegistrationAnswers(fields, tt.answers)
+			if (err == nil) != tt.ok {
+				t.Fatalf("ошибка %v, ожидалось принятие: %v", err, tt.ok)
+			}
+			if len(saved) != tt.saved {
+				t.Errorf("сохраняется %d ответов, ожидалось %d", len(saved), tt.saved)
+			}
+			for _, answer := range saved {
+				if answer.Value == "" || answer.Value[0] == ' ' {
+					t.Errorf("ответ не очищен: %q", answer.Value)
+				}
+			}
+		})
+	}
+
+	// Без анкеты записываются без ответов
+	if saved, err := checkRegistrationAnswers(nil, nil); err != nil || len(saved) != 0 {
+		t.Errorf("пустая анкета: %v, %v", saved, err)
+	}
+}
@@ -0,0 +1,568 @@
+package events
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hackaton-jam-back/controllers/moderation"
+	"hackaton-jam-back/controllers/series"
+	"hackaton-jam-back/controllers/utils"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/lib/pq"
+)
+
+// Все, что переносится из события в копию или шаблон.
+// Даты хранятся относительно начала события, чтобы их можно было сдвинуть
+type EventBlueprint struct {
+	Name                  string   `json:"name"`
+	Location              string   `json:"location"`
+	City                  string   `json:"city,omitempty"`
+	Latitude              *float64 `json:"latitude,omitempty"`
+	Longitude             *float64 `json:"longitude,omitempty"`
+	Icon                  string   `json:"icon"`
+	IsIrl                 bool     `json:"is_irl"`
+	TeamRequirementsType  int      `json:"team_requirements_type"`
+	TeamRequirementsValue int      `json:"team_requirements_value"`
+	Description           string   `json:"desc"`
+	Prize                 string   `json:"prize"`
+	Requirements          string   `json:"requirements"`
+	Series                string   `json:"series,omitempty"`
+	Visibility            string   `json:"visibility,omitempty"`
+	AllowedDomains        []string `json:"allowed_domains,omitempty"`
+
+	DurationSeconds           int64  `json:"duration_seconds"`
+	SubmissionDeadlineSeconds *int64 `json:"submission_deadline_seconds,omitempty"`
+
+	Tags             []string                 `json:"tags"`
+	Partners         []string                 `json:"partners"`
+	Criteria         []*BlueprintCriterion    `json:"criteria"`
+	Agenda           []*BlueprintAgendaItem   `json:"agenda,omitempty"`
+	RegistrationForm []RegistrationFieldDraft `json:"registration_form,omitempty"`
+}
+
+type BlueprintCriterion struct {
+	Name        string  `json:"name"`
+	Description string  `json:"desc"`
+	Weight      float64 `json:"weight"`
+	MinScore    int     `json:"min_score"`
+	MaxScore    int     `json:"max_score"`
+}
+
+// Время пункта программы тоже относительно начала события
+type BlueprintAgendaItem struct {
+	Title        string                  `json:"title"`
+	Description  string                  `json:"desc"`
+	Place        string                  `json:"place"`
+	StartSeconds int64                   `json:"start_seconds"`
+	EndSeconds   *int64                  `json:"end_seconds,omitempty"`
+	Translations []AgendaItemTranslation `json:"translations,omitempty"`
+}
+
+type EventDuplicateInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на копируемое мероприятие"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+
+		NewUrid   string    `json:"new_urid" maxLength:"30" example:"example_events_2" doc:"Ссылка на новое мероприятие"`
+		Name      string    `json:"name,omitempty" example:"Example GameJam #2" doc:"Название (если не указано - как у исходного)"`
+		StartTime time.Time `json:"start_time" doc:"Начало проведения нового мероприятия (остальные даты сдвигаются)"`
+	}
+}
+
+type EventTemplateSaveInput struct {
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+
+		FromUrid string `json:"from_urid" maxLength:"30" example:"example_events" doc:"Из какого мероприятия сделать шаблон"`
+		Name     string `json:"name" example:"Осенний джем" doc:"Название шаблона"`
+	}
+}
+
+type EventTemplateDelInput struct {
+	Id   int64 `path:"id" example:"1" doc:"Идентификатор шаблона"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type EventFromTemplateInput struct {
+	Id   int64 `path:"id" example:"1" doc:"Идентификатор шаблона"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+
+		Urid      string    `json:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие (Поменять потом нельзя!!!)"`
+		Name      string    `json:"name,omitempty" example:"Example GameJam" doc:"Название (если не указано - как в шаблоне)"`
+		StartTime time.Time `json:"start_time" doc:"Начало проведения"`
+	}
+}
+
+type EventPublishInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type EventTemplate struct {
+	Id        int64           `json:"id" example:"1" doc:"Идентификатор шаблона"`
+	Name      string          `json:"name" example:"Осенний джем" doc:"Название шаблона"`
+	Owner     string          `json:"owner" example:"thatmaidguy@ya.ru" doc:"Владелец шаблона"`
+	CreatedAt time.Time       `json:"created_at" doc:"Время создания"`
+	Event     *EventBlueprint `json:"event" doc:"Содержимое шаблона"`
+}
+
+type EventTemplatesOutput struct {
+	Body struct {
+		Templates []*EventTemplate `json:"templates" doc:"Шаблоны мероприятий"`
+	}
+}
+
+func DuplicateEvent(input *EventDuplicateInput, db *sql.DB) (*FullEventOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	blueprint, err := getEventBlueprint(input.Urid, db)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := moderation.InitialStatus(user, db)
+	if err != nil {
+		return nil, err
+	}
+
+	// Копия со всеми тегами, критериями, программой, анкетой и переводами создается целиком или никак
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer tx.Rollback()
+
+	if err := createEventFromBlueprint(blueprint, input.Body.NewUrid, input.Body.Name, input.Body.StartTime, user, status, tx); err != nil {
+		return nil, err
+	}
+
+	// Копия остается у той же организации и на том же языке
+	_, err = tx.Exec(
+		"UPDATE events SET org_urid = source.org_urid, default_locale = source.default_locale "+
+			"FROM events source WHERE events.urid = $1 AND source.urid = $2",
+		input.Body.NewUrid, input.Urid,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	// Переводы тоже копируем, кроме названия, если копию назвали по-новому
+	_, err = tx.Exec(
+		"INSERT INTO event_translations (event_uri, locale, name, \"desc\", requirements, prize) "+
+			"SELECT $1, locale, CASE WHEN $3::text = '' THEN name END, \"desc\", requirements, prize "+
+			"FROM event_translations WHERE event_uri = $2",
+		input.Body.NewUrid, input.Urid, input.Body.Name,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return getFullEventInfo(input.Body.NewUrid, db)
+}
+
+func PublishDraftEvent(input *EventPublishInput, db *sql.DB) (*FullEventOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	var wasDraft bool
+	if err := db.QueryRow("SELECT is_draft FROM events WHERE urid = $1", input.Urid).Scan(&wasDraft); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	_, err = db.Exec("UPDATE events SET is_draft = false WHERE urid = $1", input.Urid)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	if wasDraft {
+		// Опубликованный черновик встает в очередь модерации
+		if err := moderation.Requeue(user, input.Urid, db); err != nil {
+			return nil, err
+		}
+
+		// Новый выпуск серии - сообщаем подписчикам (если уже одобрен)
+		if err := series.NotifyNewEdition(input.Urid, user.Email, db); err != nil {
+			return nil, err
+		}
+	}
+
+	return getFullEventInfo(input.Urid, db)
+}
+
+func GetEventTemplates(input *utils.JustAccessTokenInput, db *sql.DB) (*EventTemplatesOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if user.Perms < 1 {
+		return nil, huma.Error403Forbidden("Нет прав")
+	}
+
+	return getEventTemplates(user.Email, db)
+}
+
+func SaveEventTemplate(input *EventTemplateSaveInput, db *sql.DB) (*EventTemplatesOutput, error) {
+	if input.Body.Name == "" {
+		return nil, huma.Error422UnprocessableEntity("Название шаблона не должно быть пустым")
+	}
+
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Body.FromUrid, db); err != nil {
+		return nil, err
+	}
+
+	blueprint, err := getEventBlueprint(input.Body.FromUrid, db)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(blueprint)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	_, err = db.Exec(
+		"INSERT INTO event_templates (owner_email, name, payload) VALUES ($1, $2, $3)",
+		user.Email, input.Body.Name, payload,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return getEventTemplates(user.Email, db)
+}
+
+func DelEventTemplate(input *EventTemplateDelInput, db *sql.DB) (*EventTemplatesOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+
+	if _, err := getEventTemplate(input.Id, user, db); err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec("DELETE FROM event_templates WHERE id = $1", input.Id)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return getEventTemplates(user.Email, db)
+}
+
+func CreateEventFromTemplate(input *EventFromTemplateInput, db *sql.DB) (*FullEventOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if user.Perms < 1 {
+		return nil, huma.Error403Forbidden("Нет прав")
+	}
+
+	template, err := getEventTemplate(input.Id, user, db)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := moderation.InitialStatus(user, db)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer tx.Rollback()
+
+	if err := createEventFromBlueprint(template.Event, input.Body.Urid, input.Body.Name, input.Body.StartTime, user, status, tx); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return getFullEventInfo(input.Body.Urid, db)
+}
+
+func getEventBlueprint(urid string, db *sql.DB) (*EventBlueprint, error) {
+	event, err := getFullEventInfo(urid, db)
+	if err != nil {
+		return nil, err
+	}
+
+	blueprint := &EventBlueprint{
+		Name:                  event.Body.Name,
+		Location:              event.Body.Location,
+		City:                  event.Body.City,
+		Latitude:              event.Body.Latitude,
+		Longitude:             event.Body.Longitude,
+		Icon:                  event.Body.Icon,
+		IsIrl:                 event.Body.IsIrl,
+		TeamRequirementsType:  event.Body.TeamRequirementsType,
+		TeamRequirementsValue: event.Body.TeamRequirementsValue,
+		Description:           event.Body.Description,
+		Prize:                 event.Body.Prize,
+		Requirements:          event.Body.Requirements,
+		Series:                event.Body.Series,
+		Visibility:            event.Body.Visibility,
+		AllowedDomains:        event.Body.AllowedDomains,
+		DurationSeconds:       int64(event.Body.EndTime.Sub(event.Body.StartTime).Seconds()),
+		Tags:                  event.Body.Tags,
+		Partners:              event.Body.Partners,
+	}
+	if event.Body.SubmissionDeadline != nil {
+		offset := int64(event.Body.SubmissionDeadline.Sub(event.Body.StartTime).Seconds())
+		blueprint.SubmissionDeadlineSeconds = &offset
+	}
+
+	if err := loadAgendaTranslations(urid, event.Body.Agenda, db); err != nil {
+		return nil, err
+	}
+	for _, item := range event.Body.Agenda {
+		agendaItem := &BlueprintAgendaItem{
+			Title:        item.Title,
+			Description:  item.Description,
+			Place:        item.Place,
+			StartSeconds: int64(item.StartsAt.Sub(event.Body.StartTime).Seconds()),
+			Translations: item.Translations,
+		}
+		if item.EndsAt != nil {
+			offset := int64(item.EndsAt.Sub(event.Body.StartTime).Seconds())
+			agendaItem.EndSeconds = &offset
+		}
+		blueprint.Agenda = append(blueprint.Agenda, agendaItem)
+	}
+
+	for _, field := range event.Body.RegistrationForm {
+		blueprint.RegistrationForm = append(blueprint.RegistrationForm, RegistrationFieldDraft{
+			Label:    field.Label,
+			Type:     field.Type,
+			Options:  field.Options,
+			Required: field.Required,
+		})
+	}
+
+	rows, err := db.Query(
+		"SELECT name, \"desc\", weight, min_score, max_score FROM judging_criteria WHERE event_uri = $1 ORDER BY id", urid,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		criterion := new(BlueprintCriterion)
+		var desc sql.NullString
+		if err := rows.Scan(&criterion.Name, &desc, &criterion.Weight, &criterion.MinScore, &criterion.MaxScore); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		criterion.Description = desc.String
+
+		blueprint.Criteria = append(blueprint.Criteria, criterion)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return blueprint, nil
+}
+
+// Создает черновик события по заготовке, сдвигая все даты (и программу) к новому началу.
+// Все вставки идут в транзакции вызывающего, чтобы не остался черновик без тегов, критериев или анкеты
+func createEventFromBlueprint(blueprint *EventBlueprint, urid string, name string, start time.Time, organizator *utils.UserEmail, status string, tx *sql.Tx) error {
+	if urid == "" {
+		return huma.Error422UnprocessableEntity("Ссылка на мероприятие не должна быть пустой")
+	}
+	if start.IsZero() {
+		return huma.Error422UnprocessableEntity("Нужно указать начало проведения")
+	}
+	if name == "" {
+		name = blueprint.Name
+	}
+
+	end := start.Add(time.Duration(blueprint.DurationSeconds) * time.Second)
+
+	var seriesUrid sql.NullString
+	if blueprint.Series != "" {
+		seriesUrid = sql.NullString{String: blueprint.Series, Valid: true}
+	}
+
+	// Код доступа не копируется, его организатор задает заново
+	visibility := blueprint.Visibility
+	if visibility == "" {
+		visibility = VisibilityPublic
+	}
+	domains := blueprint.AllowedDomains
+	if domains == nil {
+		domains = []string{}
+	}
+
+	var city sql.NullString
+	if blueprint.City != "" {
+		city = sql.NullString{String: blueprint.City, Valid: true}
+	}
+
+	var submissionDeadline sql.NullTime
+	if blueprint.SubmissionDeadlineSeconds != nil {
+		submissionDeadline = sql.NullTime{Time: start.Add(time.Duration(*blueprint.SubmissionDeadlineSeconds) * time.Second), Valid: true}
+	}
+
+	_, err := tx.Exec("INSERT INTO events ("+
+		"urid, name, start_time, end_time, prize, \"location\", \"desc\", requirements, "+
+		"icon, is_irl, team_requirements_type, team_requirements_value, submission_deadline, series_urid, "+
+		"visibility, allowed_domains, moderation_status, city, latitude, longitude, is_draft) "+
+		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, true)",
+
+		urid, name, start, end,
+		blueprint.Prize, blueprint.Location, blueprint.Description,
+		blueprint.Requirements, blueprint.Icon, blueprint.IsIrl,
+		blueprint.TeamRequirementsType, blueprint.TeamRequirementsValue,
+		submissionDeadline, seriesUrid,
+		visibility, pq.Array(domains), status,
+		city, blueprint.Latitude, blueprint.Longitude,
+	)
+	if err != nil {
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	_, err = tx.Exec("INSERT INTO event_orgs (event_uri, organizator_email) VALUES ($1, $2)", urid, organizator.Email)
+	if err != nil {
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	for _, tag := range blueprint.Tags {
+		_, err = tx.Exec("INSERT INTO event_tags (event_uri, tag) VALUES ($1, $2)", urid, tag)
+		if err != nil {
+			return huma.Error422UnprocessableEntity(err.Error())
+		}
+	}
+
+	for _, logo := range blueprint.Partners {
+		_, err = tx.Exec("INSERT INTO event_partners (event_uri, logo_url) VALUES ($1, $2)", urid, logo)
+		if err != nil {
+			return huma.Error422UnprocessableEntity(err.Error())
+		}
+	}
+
+	for _, criterion := range blueprint.Criteria {
+		_, err = tx.Exec(
+			"INSERT INTO judging_criteria (event_uri, name, \"desc\", weight, min_score, max_score) VALUES ($1, $2, $3, $4, $5, $6)",
+			urid, criterion.Name, criterion.Description, criterion.Weight, criterion.MinScore, criterion.MaxScore,
+		)
+		if err != nil {
+			return huma.Error422UnprocessableEntity(err.Error())
+		}
+	}
+
+	for _, item := range blueprint.Agenda {
+		draft := AgendaItemDraft{
+			Title:       item.Title,
+			Description: item.Description,
+			Place:       item.Place,
+			StartsAt:    start.Add(time.Duration(item.StartSeconds) * time.Second),
+
+			Translations: item.Translations,
+		}
+		if item.EndSeconds != nil {
+			endsAt := start.Add(time.Duration(*item.EndSeconds) * time.Second)
+			draft.EndsAt = &endsAt
+		}
+		if err := insertAgendaItem(urid, draft, tx); err != nil {
+			return err
+		}
+	}
+
+	for position, field := range blueprint.RegistrationForm {
+		if err := insertRegistrationField(urid, field, position, tx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getEventTemplates(owner string, db *sql.DB) (*EventTemplatesOutput, error) {
+	rows, err := db.Query(
+		"SELECT id, name, owner_email, created_at, payload FROM event_templates WHERE owner_email = $1 ORDER BY id DESC", owner,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	result := new(EventTemplatesOutput)
+
+	for rows.Next() {
+		template, err := scanEventTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		result.Body.Templates = append(result.Body.Templates, template)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
+
+// Шаблоном может пользоваться только его владелец (или админ)
+func getEventTemplate(id int64, user *utils.UserEmail, db *sql.DB) (*EventTemplate, error) {
+	row := db.QueryRow("SELECT id, name, owner_email, created_at, payload FROM event_templates WHERE id = $1", id)
+
+	template, err := scanEventTemplate(row)
+	if err != nil {
+		return nil, err
+	}
+	if template.Owner != user.Email && user.Perms != 10 {
+		return nil, huma.Error403Forbidden("Это не твой шаблон :/")
+	}
+
+	return template, nil
+}
+
+func scanEventTemplate(row interface{ Scan(dest ...any) error }) (*EventTemplate, error) {
+	template := new(EventTemplate)
+
+	var payload []byte
+	if err := row.Scan(&template.Id, &template.Name, &template.Owner, &template.CreatedAt, &payload); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, huma.Error404NotFound("Такого шаблона нет")
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	template.Event = new(EventBlueprint)
+	if err := json.Unmarshal(payload, template.Event); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return template, nil
+}
@@ -2,10 +2,15 @@ package events
 
 import (
 	"database/sql"
+	"hackaton-jam-back/controllers/moderation"
 	"hackaton-jam-back/controllers/utils"
+	"hackaton-jam-back/controllers/webhooks"
+	"log"
 	"strconv"
+	"time"
 
 	"github.com/danielgtaylor/huma/v2"
+	"github.com/lib/pq"
 )
 
 type EventJoinExitInput struct {
@@ -15,6 +20,18 @@ type EventJoinExitInput struct {
 	}
 }
 
+type EventJoinInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+
+		AccessCode string `json:"access_code,omitempty" example:"URFU2026" doc:"Код доступа (если мероприятие с кодом)"`
+		Invite     string `json:"invite,omitempty" example:"9c1f0e2a7b3d4c5e" doc:"Код из ссылки-приглашения"`
+
+		Answers []RegistrationAnswer `json:"answers,omitempty" doc:"Ответы на анкету при записи (если организаторы ее задали)"`
+	}
+}
+
 type EventJoinExitOutput struct {
 	Success bool `json:"success" example:"true" doc:"Успех выполнения"`
 }
@@ -22,23 +39,28 @@ type EventJoinExitOutput struct {
 type EventSearchUsers struct {
 	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
 	Body struct {
-		SkillsToSearch []string `json:"skills_to_search" doc:"Токен пользователя"`
+		Token          string   `json:"access_token,omitempty" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя (необязательно, для закрытых мероприятий)"`
+		SkillsToSearch []string `json:"skills_to_search" doc:"Искомые навыки"`
+		Cursor         string   `json:"cursor,omitempty" doc:"Курсор следующей страницы из прошлого ответа (пусто - первая страница)"`
+		Count          int      `json:"count,omitempty" minimum:"0" maximum:"100" example:"20" doc:"Количество участников на страницу"`
 	}
 }
 
 type EventSearchUsersOutput struct {
 	Body struct {
-		Users []*utils.UserShortInfo `json:"users" doc:"Таблица подходящих пользователей по критериям (если нет - выводит всех)"`
+		Users      []*utils.UserShortInfo `json:"users" doc:"Таблица подходящих пользователей по критериям (если нет - выводит всех)"`
+		NextCursor string                 `json:"next_cursor,omitempty" doc:"Курсор следующей страницы (пусто - это последняя)"`
 	}
 }
 
 type UserEventsOutput struct {
+	Vary string `header:"Vary"`
 	Body struct {
 		Events []EventType `json:"event" doc:"Лист с событиями"`
 	}
 }
 
-func JoinEvent(input *EventJoinExitInput, db *sql.DB) (*EventJoinExitOutput, error) {
+func JoinEvent(input *EventJoinInput, db *sql.DB) (*EventJoinExitOutput, error) {
 	if err := isEventExists(input.Urid, db); err != nil {
 		return nil, err
 	}
@@ -52,7 +74,77 @@ func JoinEvent(input *EventJoinExitInput, db *sql.DB) (*EventJoinExitOutput, err
 		return nil, huma.Error403Forbidden("Участвовать можно только обычным пользователям")
 	}
 
-	db.QueryRow("INSERT INTO event_members (event_uri, member_email) VALUES ($1, $2)", input.Urid, user.Email).Scan()
+	var isDraft bool
+	var moderationStatus string
+	if err := db.QueryRow("SELECT is_draft, moderation_status FROM events WHERE urid = $1", input.Urid).Scan(&isDraft, &moderationStatus); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if isDraft {
+		return nil, huma.Error403Forbidden("Событие еще не опубликовано")
+	}
+	if moderationStatus != moderation.StatusApproved {
+		return nil, huma.Error403Forbidden("Событие еще не прошло модерацию")
+	}
+
+	// Повторная запись ничего не меняет и не тратит приглашение
+	var member string
+	err = db.QueryRow("SELECT member_email FROM event_members WHERE event_uri = $1 AND member_email = $2", input.Urid, user.Email).Scan(&member)
+	if err == nil {
+		return &EventJoinExitOutput{Success: true}, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	// Код доступа, домены и приглашения
+	byInvite, err := checkJoinAccess(user, input.Urid, input.Body.AccessCode, input.Body.Invite, db)
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := getRegistrationForm(input.Urid, db)
+	if err != nil {
+		return nil, err
+	}
+	answers, err := checkRegistrationAnswers(fields, input.Body.Answers)
+	if err != nil {
+		return nil, err
+	}
+
+	if byInvite {
+		if err := useInvite(input.Body.Invite, input.Urid, db); err != nil {
+			return nil, err
+		}
+	}
+
+	// Участник записывается вместе с анкетой или никак
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("INSERT INTO event_members (event_uri, member_email) VALUES ($1, $2)", input.Urid, user.Email)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	for _, answer := range answers {
+		_, err = tx.Exec(
+			"INSERT INTO registration_answers (field_id, member_email, value) VALUES ($1, $2, $3)",
+			answer.FieldId, user.Email, answer.Value,
+		)
+		if err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	joined := webhooks.MemberData{Email: user.Email, Username: user.Username, Source: "join"}
+	if err := webhooks.Enqueue(input.Urid, webhooks.TypeMemberJoined, joined, db); err != nil {
+		log.Println("Вебхуки: " + err.Error())
+	}
 
 	return &EventJoinExitOutput{Success: true}, nil
 }
@@ -71,119 +163,111 @@ func ExitEvent(input *EventJoinExitInput, db *sql.DB) (*EventJoinExitOutput, err
 		return nil, huma.Error403Forbidden("Участвовать можно только обычным пользователям")
 	}
 
-	// Удаляем
-	db.QueryRow("DELETE FROM event_members WHERE event_uri = $1 AND member_email = $2", input.Urid, user.Email).Scan()
+	// Удаляем, запоминая выход для аналитики
+	db.QueryRow(
+		"WITH exited AS (DELETE FROM event_members WHERE event_uri = $1 AND member_email = $2 RETURNING event_uri, member_email, joined_at) "+
+			"INSERT INTO event_exits (event_uri, member_email, joined_at) SELECT event_uri, member_email, joined_at FROM exited",
+		input.Urid, user.Email).Scan()
+
+	// Анкета при повторной записи заполняется заново
+	db.QueryRow(
+		"DELETE FROM registration_answers WHERE member_email = $2 "+
+			"AND field_id IN (SELECT id FROM registration_fields WHERE event_uri = $1)",
+		input.Urid, user.Email).Scan()
 
 	return &EventJoinExitOutput{Success: true}, nil
 }
 
-func GetAllJoinedEvents(email string, db *sql.DB) (*UserEventsOutput, error) {
+// Названия отдаются на языке из locale, если есть перевод
+func GetAllJoinedEvents(email string, locale utils.LocaleInput, db *sql.DB) (*UserEventsOutput, error) {
 	rows, err := db.Query(
-		"SELECT events.id, events.urid, events.name, events.start_time, events.end_time, events.location, event_members.member_email"+
-			"FROM event_members INNER JOIN events ON event_members.event_uri=events.urid"+
+		"SELECT events.urid, events.name, events.start_time, events.end_time, events.location, events.icon, events.is_irl, events.city, events.default_locale "+
+			"FROM event_members INNER JOIN events ON event_members.event_uri = events.urid "+
 			"WHERE event_members.member_email = $1 ORDER BY events.id DESC", email,
 	)
 	if err != nil {
-		return nil, huma.Error422UnprocessableEntity("Проблемки с вызовом SQL")
+		return nil, huma.Error422UnprocessableEntity(err.Error())
 	}
 	defer rows.Close()
 
 	result := new(UserEventsOutput)
 
 	for rows.Next() {
-		var id int
-		var member string
+		var location, icon, city sql.NullString
 		var event EventType
-		if err := rows.Scan(&id, &event.Urid, &id, &event.Name, &event.StartTime, &event.EndTime, &event.Location, &member); err != nil {
+		if err := rows.Scan(&event.Urid, &event.Name, &event.StartTime, &event.EndTime, &location, &icon, &event.IsIrl, &city, &event.Locale); err != nil {
 			return nil, huma.Error422UnprocessableEntity(err.Error())
 		}
+		event.Location = location.String
+		event.Icon = icon.String
+		event.City = city.String
+
 		result.Body.Events = append(result.Body.Events, event)
 	}
 	if err = rows.Err(); err != nil {
 		return nil, huma.Error422UnprocessableEntity(err.Error())
 	}
 
+	cards := make([]*EventType, len(result.Body.Events))
+	for i := range result.Body.Events {
+		cards[i] = &result.Body.Events[i]
+	}
+	if err := localizeEventTypes(cards, utils.PreferredLocales(locale), db); err != nil {
+		return nil, err
+	}
+	result.Vary = utils.VaryLanguage
+
 	return result, nil
 }
 
+// Участники идут в порядке вступления, страницы выдаются по курсору
 func GetAllEventMembers(input *EventSearchUsers, db *sql.DB) (*EventSearchUsersOutput, error) {
-	// Проверяем событие на наличие
-	if err := isEventExists(input.Urid, db); err != nil {
+	// Проверяем событие на наличие и доступ к закрытому
+	if err := utils.CheckEventVisible(input.Body.Token, input.Urid, db); err != nil {
 		return nil, err
 	}
 
-	var rows *sql.Rows
-
-	if len(input.Body.SkillsToSearch) > 0 {
-		queryPiece := "("
-
-		var args []any
-		args = append(args, input.Urid)
-
-		for i, v := range input.Body.SkillsToSearch {
-			if i != 0 {
-				queryPiece += " AND "
-			}
-			queryPiece += "skills.skill=$" + strconv.Itoa(i+2)
-			args = append(args, v)
-		}
-		queryPiece += ")"
-
-		rows, err := db.Query(
-			"SELECT event_members.member_email, COUNT(*) as count "+
-				"FROM event_members INNER JOIN events ON event_members.event_uri=events.urid "+
-				"JOIN skills ON event_members.member_email=skills.user_email "+
-				"WHERE event_members.event_uri=$1 AND ("+queryPiece+") "+
-				"GROUP BY event_members.member_email", args...,
-		)
-		if err != nil {
-			return nil, huma.Error422UnprocessableEntity(err.Error())
-		}
-
-		result := new(EventSearchUsersOutput)
-
-		for rows.Next() {
-			var member_email string
-			var count int
-			if err := rows.Scan(&member_email, &count); err != nil {
-				return nil, huma.Error422UnprocessableEntity(err.Error())
-			}
-			if count == len(input.Body.SkillsToSearch) {
-				continue
-			}
-
-			user, err := utils.GetUserShortInfo(member_email, db)
-			if err != nil {
-				return nil, err
-			}
-
-			result.Body.Users = append(result.Body.Users, user)
-		}
-		if err := rows.Err(); err != nil {
-			return nil, huma.Error422UnprocessableEntity(err.Error())
-		}
+	page, err := utils.NewPage(input.Body.Cursor, "joined", input.Body.Count)
+	if err != nil {
+		return nil, err
+	}
 
-		return result, nil
+	query := "SELECT event_members.member_email, event_members.joined_at FROM event_members WHERE event_members.event_uri = $1"
+	args := []any{input.Urid, page.Limit()}
 
+	// Нужны участники, у которых есть все искомые навыки
+	if len(input.Body.SkillsToSearch) > 0 {
+		args = append(args, pq.Array(input.Body.SkillsToSearch))
+		query += " AND (SELECT COUNT(DISTINCT skills.skill) FROM skills WHERE skills.user_email = event_members.member_email " +
+			"AND skills.skill = ANY($" + strconv.Itoa(len(args)) + ")) = " + strconv.Itoa(len(input.Body.SkillsToSearch))
+	}
+	if page.After != nil {
+		args = append(args, page.After.Value, page.After.Key)
+		query += " AND (event_members.joined_at, event_members.member_email) > ($" + strconv.Itoa(len(args)-1) +
+			"::timestamptz, $" + strconv.Itoa(len(args)) + ")"
 	}
+	query += " ORDER BY event_members.joined_at, event_members.member_email LIMIT $2"
 
-	rows, err := db.Query(
-		"SELECT event_members.member_email FROM event_members "+
-			"WHERE event_members.event_uri=$1", input.Urid,
-	)
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		return nil, huma.Error422UnprocessableEntity(err.Error())
 	}
+	defer rows.Close()
 
 	result := new(EventSearchUsersOutput)
 
 	for rows.Next() {
-		var member_email string
-		if err := rows.Scan(&member_email); err != nil {
+		var memberEmail string
+		var joinedAt time.Time
+		if err := rows.Scan(&memberEmail, &joinedAt); err != nil {
 			return nil, huma.Error422UnprocessableEntity(err.Error())
 		}
 
-		user, err := utils.GetUserShortInfo(member_email, db)
+		if !page.Add(joinedAt.Format(time.RFC3339Nano), memberEmail) {
+			break
+		}
+
+		user, err := utils.GetUserShortInfo(memberEmail, db)
 		if err != nil {
 			return nil, err
 		}
@@ -193,6 +277,7 @@ func GetAllEventMembers(input *EventSearchUsers, db *sql.DB) (*EventSearchUsersO
 	if err := rows.Err(); err != nil {
 		return nil, huma.Error422UnprocessableEntity(err.Error())
 	}
+	result.Body.NextCursor = page.NextCursor()
 
 	return result, nil
 }
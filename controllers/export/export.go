@@ -0,0 +1,156 @@
+package export
+
+import (
+	"database/sql"
+	"hackaton-jam-back/controllers/utils"
+	"log"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+type ExportInput struct {
+	Urid   string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Kind   string `path:"kind" enum:"members,teams,results" example:"members" doc:"Что выгрузить: участников, команды или итоги"`
+	Format string `query:"format" enum:"csv,xlsx" default:"csv" doc:"Формат файла"`
+	Body   struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type exportKind struct {
+	sheet  string
+	header []string
+	query  string
+}
+
+// Все колонки приводятся к тексту прямо в SQL
+var exportKinds = map[string]exportKind{
+	"members": {
+		sheet: "Участники",
+		header: []string{
+			"E-mail", "Никнейм", "Фамилия", "Имя", "Отчество", "Место жительства", "Место работы",
+			"Занятость", "Навыки", "Контакты", "Команда", "Роль", "Дата регистрации",
+		},
+		query: "SELECT users.email, users.username, users.last_name, users.first_name, COALESCE(users.middle_name, ''), " +
+			"COALESCE(users.loc, ''), COALESCE(users.work_place, ''), COALESCE(users.work_time, ''), " +
+			"COALESCE((SELECT string_agg(skill, ', ' ORDER BY skill) FROM skills WHERE skills.user_email = users.email), ''), " +
+			"COALESCE((SELECT string_agg(contact_link, ', ') FROM contacts WHERE contacts.user_email = users.email), ''), " +
+			"COALESCE(team.name, ''), COALESCE(team.role, ''), " +
+			"to_char(event_members.joined_at, 'YYYY-MM-DD HH24:MI') " +
+			"FROM event_members JOIN users ON users.email = event_members.member_email " +
+			"LEFT JOIN LATERAL (SELECT teams.name, teams_members.role FROM teams " +
+			"JOIN teams_members ON teams_members.team_id = teams.id AND teams_members.pending = false " +
+			"WHERE teams.event_uri = event_members.event_uri AND teams_members.member_email = users.email LIMIT 1) AS team ON true " +
+			"WHERE event_members.event_uri = $1 ORDER BY users.last_name, users.first_name",
+	},
+	"teams": {
+		sheet: "Команды",
+		header: []string{
+			"ID команды", "Команда", "Трек", "Тимлид", "E-mail участника", "Никнейм", "ФИО", "Роль", "Статус", "Проект сдан",
+		},
+		query: "SELECT teams.id::text, teams.name, teams.track, teams.teamleader, " +
+			"users.email, users.username, users.last_name || ' ' || users.first_name, teams_members.role, " +
+			"CASE WHEN teams_members.pending THEN 'Приглашен' ELSE 'В команде' END, " +
+			"CASE WHEN submissions.id IS NULL THEN 'Нет' ELSE 'Да' END " +
+			"FROM teams LEFT JOIN teams_members ON teams_members.team_id = teams.id " +
+			"LEFT JOIN users ON users.email = teams_members.member_email " +
+			"LEFT JOIN submissions ON submissions.team_id = teams.id " +
+			"WHERE teams.event_uri = $1 ORDER BY teams.name, teams_members.pending, users.last_name",
+	},
+	"results": {
+		sheet:  "Итоги",
+		header: []string{"Трек", "Место", "Номинация", "Приз", "Команда", "Участники"},
+		query: "SELECT event_results.track, COALESCE(event_results.place::text, ''), COALESCE(event_results.nomination, ''), " +
+			"COALESCE(event_results.prize, ''), teams.name, " +
+			"COALESCE((SELECT string_agg(users.last_name || ' ' || users.first_name || ' <' || users.email || '>', ', ') " +
+			"FROM teams_members JOIN users ON users.email = teams_members.member_email " +
+			"WHERE teams_members.team_id = teams.id AND teams_members.pending = false), '') " +
+			"FROM event_results JOIN teams ON teams.id = event_results.team_id " +
+			"WHERE event_results.event_uri = $1 " +
+			"ORDER BY event_results.track, event_results.place NULLS LAST, event_results.nomination",
+	},
+}
+
+func ExportEvent(input *ExportInput, db *sql.DB) (*huma.StreamResponse, error) {
+	// Выгрузка только для организаторов и админов
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	kind, ok := exportKinds[input.Kind]
+	if !ok {
+		return nil, huma.Error422UnprocessableEntity("Неизвестный тип выгрузки")
+	}
+
+	// Запрос выполняем заранее, чтобы ошибка вернулась нормальным ответом,
+	// а строки читаем уже во время отправки
+	rows, err := db.Query(kind.query, input.Urid)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	filename := input.Urid + "-" + input.Kind + "." + input.Format
+	contentType := "text/csv; charset=utf-8"
+	if input.Format == "xlsx" {
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+
+	return &huma.StreamResponse{
+		Body: func(ctx huma.Context) {
+			defer rows.Close()
+
+			ctx.SetHeader("Content-Type", contentType)
+			ctx.SetHeader("Content-Disposition", "attachment; filename=\""+filename+"\"")
+
+			var table tableWriter
+			var err error
+			if input.Format == "xlsx" {
+				table, err = newXlsxTable(ctx.BodyWriter(), kind.sheet)
+			} else {
+				table, err = newCsvTable(ctx.BodyWriter())
+			}
+			if err != nil {
+				log.Println("Выгрузка " + filename + ": " + err.Error())
+				return
+			}
+
+			if err := writeRows(table, kind, rows); err != nil {
+				log.Println("Выгрузка " + filename + ": " + err.Error())
+			}
+			if err := table.Close(); err != nil {
+				log.Println("Выгрузка " + filename + ": " + err.Error())
+			}
+		},
+	}, nil
+}
+
+func writeRows(table tableWriter, kind exportKind, rows *sql.Rows) error {
+	if err := table.WriteRow(kind.header); err != nil {
+		return err
+	}
+
+	values := make([]sql.NullString, len(kind.header))
+	dest := make([]any, len(kind.header))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	row := make([]string, len(kind.header))
+
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		for i, value := range values {
+			row[i] = value.String
+		}
+		if err := table.WriteRow(row); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,158 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Построчная запись таблицы, чтобы не держать выгрузку в памяти
+type tableWriter interface {
+	WriteRow(row []string) error
+	Close() error
+}
+
+type csvTable struct {
+	writer *csv.Writer
+}
+
+func newCsvTable(w io.Writer) (*csvTable, error) {
+	// BOM, чтобы Excel понял кириллицу
+	if _, err := w.Write([]byte("\xEF\xBB\xBF")); err != nil {
+		return nil, err
+	}
+	return &csvTable{writer: csv.NewWriter(w)}, nil
+}
+
+func (t *csvTable) WriteRow(row []string) error {
+	escaped := make([]string, len(row))
+	for i, cell := range row {
+		escaped[i] = escapeCsvCell(cell)
+	}
+	return t.writer.Write(escaped)
+}
+
+// Excel и LibreOffice считают формулой ячейку, которая начинается с одного из этих символов.
+// Данные пишут сами участники, поэтому такие ячейки экранируются апострофом.
+// В XLSX ячейки хранятся как строки, там это не нужно
+func escapeCsvCell(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+func (t *csvTable) Close() error {
+	t.writer.Flush()
+	return t.writer.Error()
+}
+
+// Минимальный XLSX: один лист, строки хранятся прямо в ячейках (inlineStr)
+type xlsxTable struct {
+	archive *zip.Writer
+	sheet   io.Writer
+	rows    int
+}
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+</Types>`
+
+const xlsxRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+
+const xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+</Relationships>`
+
+const xlsxWorkbookStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="`
+
+const xlsxWorkbookEnd = `" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`
+
+const xlsxSheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+
+const xlsxSheetEnd = `</sheetData></worksheet>`
+
+func newXlsxTable(w io.Writer, sheetName string) (*xlsxTable, error) {
+	archive := zip.NewWriter(w)
+
+	files := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRels},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/workbook.xml", xlsxWorkbookStart + escapeXml(sheetName) + xlsxWorkbookEnd},
+	}
+	for _, file := range files {
+		f, err := archive.Create(file.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, file.content); err != nil {
+			return nil, err
+		}
+	}
+
+	// Лист пишется последним, поэтому в него можно писать построчно
+	sheet, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(sheet, xlsxSheetStart); err != nil {
+		return nil, err
+	}
+
+	return &xlsxTable{archive: archive, sheet: sheet}, nil
+}
+
+func (t *xlsxTable) WriteRow(row []string) error {
+	t.rows++
+	line := `<row r="` + strconv.Itoa(t.rows) + `">`
+	for i, value := range row {
+		line += `<c r="` + columnName(i) + strconv.Itoa(t.rows) + `" t="inlineStr"><is><t xml:space="preserve">` +
+			escapeXml(value) + `</t></is></c>`
+	}
+	line += `</row>`
+
+	_, err := io.WriteString(t.sheet, line)
+	return err
+}
+
+func (t *xlsxTable) Close() error {
+	if _, err := io.WriteString(t.sheet, xlsxSheetEnd); err != nil {
+		return err
+	}
+	return t.archive.Close()
+}
+
+// 0 -> A, 25 -> Z, 26 -> AA
+func columnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
+
+func escapeXml(value string) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(value))
+	return sb.String()
+}
@@ -0,0 +1,73 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEscapeCsvCell(t *testing.T) {
+	tests := []struct {
+		cell string
+		want string
+	}{
+		{"", ""},
+		{"Супер-команда", "Супер-команда"},
+		{"=HYPERLINK(\"http://evil\")", "'=HYPERLINK(\"http://evil\")"},
+		{"+79001234567", "'+79001234567"},
+		{"-1+1", "'-1+1"},
+		{"@SUM(A1:A2)", "'@SUM(A1:A2)"},
+		{"\t=1", "'\t=1"},
+		{"\r=1", "'\r=1"},
+		{"a=1", "a=1"},
+		{"'=1", "'=1"},
+	}
+
+	for _, tt := range tests {
+		if got := escapeCsvCell(tt.cell); got != tt.want {
+			t.Errorf("escapeCsvCell(%q) = %q, ожидалось %q", tt.cell, got, tt.want)
+		}
+	}
+}
+
+func TestCsvTable(t *testing.T) {
+	var buf bytes.Buffer
+	table, err := newCsvTable(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := table.WriteRow([]string{"Команда", "Итог"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.WriteRow([]string{"=1+1", "10"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "\xEF\xBB\xBFКоманда,Итог\n'=1+1,10\n"
+	if buf.String() != want {
+		t.Errorf("CSV:\n%q\nожидалось:\n%q", buf.String(), want)
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	tests := []struct {
+		index int
+		want  string
+	}{
+		{0, "A"},
+		{25, "Z"},
+		{26, "AA"},
+		{51, "AZ"},
+		{52, "BA"},
+		{701, "ZZ"},
+		{702, "AAA"},
+	}
+
+	for _, tt := range tests {
+		if got := columnName(tt.index); got != tt.want {
+			t.Errorf("columnName(%d) = %q, ожидалось %q", tt.index, got, tt.want)
+		}
+	}
+}
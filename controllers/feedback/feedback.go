@@ -0,0 +1,569 @@
+package feedback
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"hackaton-jam-back/controllers/utils"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/lib/pq"
+)
+
+// Типы вопросов анкеты
+const (
+	QuestionRating = "rating" // Оценка от 1 до 5
+	QuestionText   = "text"   // Свободный ответ
+)
+
+const (
+	// Сколько анкета открыта после окончания мероприятия
+	SurveyWindow = 30 * 24 * time.Hour
+
+	// Меньше ответов не показываем, чтобы по ним нельзя было узнать автора
+	MinAnonymousResponses = 3
+
+	maxTextLength = 2000
+)
+
+// Вопросы по умолчанию, если организатор не задал свои
+var defaultQuestions = []QuestionDraft{
+	{Text: "Общее впечатление от мероприятия", Type: QuestionRating, Required: true},
+	{Text: "Организация и поддержка участников", Type: QuestionRating, Required: true},
+	{Text: "Что понравилось и что стоит улучшить?", Type: QuestionText},
+}
+
+type Question struct {
+	Id       int64  `json:"id" example:"1" doc:"Идентификатор вопроса"`
+	Text     string `json:"text" example:"Общее впечатление от мероприятия" doc:"Текст вопроса"`
+	Type     string `json:"type" example:"rating" doc:"Тип вопроса (rating - оценка от 1 до 5, text - свободный ответ)"`
+	Required bool   `json:"required" doc:"Обязательный вопрос"`
+}
+
+type QuestionDraft struct {
+	Text     string `json:"text" minLength:"1" maxLength:"500" example:"Общее впечатление от мероприятия" doc:"Текст вопроса"`
+	Type     string `json:"type" enum:"rating,text" example:"rating" doc:"Тип вопроса (rating - оценка от 1 до 5, text - свободный ответ)"`
+	Required bool   `json:"required,omitempty" doc:"Обязательный вопрос"`
+}
+
+type Answer struct {
+	QuestionId int64  `json:"question_id" example:"1" doc:"Идентификатор вопроса"`
+	Rating     int    `json:"rating,omitempty" minimum:"0" maximum:"5" example:"5" doc:"Оценка (для вопросов rating)"`
+	Text       string `json:"text,omitempty" doc:"Ответ (для вопросов text)"`
+}
+
+type Rating struct {
+	Average   float64 `json:"average" example:"4.6" doc:"Средняя оценка от 1 до 5"`
+	Responses int     `json:"responses" example:"25" doc:"Сколько человек оценили"`
+}
+
+type SurveyInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type SurveySubmitInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token   string   `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+		Answers []Answer `json:"answers" minItems:"1" doc:"Ответы на вопросы"`
+	}
+}
+
+type QuestionsEditInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token     string          `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+		Questions []QuestionDraft `json:"questions" minItems:"1" maxItems:"30" doc:"Вопросы анкеты по порядку"`
+	}
+}
+
+type SurveyOutput struct {
+	Body struct {
+		Urid      string      `json:"urid" example:"example_events" doc:"Ссылка на мероприятие"`
+		OpensAt   time.Time   `json:"opens_at" doc:"Когда анкета откроется (окончание мероприятия)"`
+		ClosesAt  time.Time   `json:"closes_at" doc:"Когда анкета закроется"`
+		IsOpen    bool        `json:"is_open" doc:"Можно ли сейчас ответить"`
+		CanAnswer bool        `json:"can_answer" doc:"Может ли пользователь ответить (участник и еще не отвечал)"`
+		Answered  bool        `json:"answered" doc:"Пользователь уже ответил"`
+		Questions []*Question `json:"questions" doc:"Вопросы анкеты"`
+	}
+}
+
+type QuestionResult struct {
+	Question
+	Answers      int      `json:"answers" example:"20" doc:"Сколько ответили на вопрос"`
+	Average      float64  `json:"average,omitempty" example:"4.5" doc:"Средняя оценка (для вопросов rating)"`
+	Distribution []int    `json:"distribution,omitempty" doc:"Сколько поставили 1, 2, 3, 4 и 5 (для вопросов rating)"`
+	Texts        []string `json:"texts,omitempty" doc:"Ответы без авторов (для вопросов text)"`
+}
+
+type SurveyResultsOutput struct {
+	Body struct {
+		Urid      string            `json:"urid" example:"example_events" doc:"Ссылка на мероприятие"`
+		Members   int               `json:"members" example:"40" doc:"Участников мероприятия"`
+		Responses int               `json:"responses" example:"25" doc:"Сколько заполнили анкету"`
+		Hidden    bool              `json:"hidden" doc:"Ответов слишком мало, чтобы показать их анонимно"`
+		Rating    *Rating           `json:"rating,omitempty" doc:"Общая оценка мероприятия"`
+		Questions []*QuestionResult `json:"questions" doc:"Сводка по вопросам"`
+	}
+}
+
+type QuestionsOutput struct {
+	Body struct {
+		Questions []*Question `json:"questions" doc:"Вопросы анкеты"`
+	}
+}
+
+func GetSurvey(input *SurveyInput, db *sql.DB) (*SurveyOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+
+	result := new(SurveyOutput)
+	result.Body.Urid = input.Urid
+
+	var isMember bool
+	if err := db.QueryRow(
+		"SELECT end_time, EXISTS (SELECT 1 FROM event_members WHERE event_uri = $1 AND member_email = $2), "+
+			"EXISTS (SELECT 1 FROM feedback_respondents WHERE event_uri = $1 AND member_email = $2) "+
+			"FROM events WHERE urid = $1",
+		input.Urid, user.Email,
+	).Scan(&result.Body.OpensAt, &isMember, &result.Body.Answered); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, huma.Error404NotFound("Этого события нет XP")
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	// Анкету видят участники и организаторы
+	if !isMember {
+		if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+			return nil, err
+		}
+	}
+
+	result.Body.ClosesAt = result.Body.OpensAt.Add(SurveyWindow)
+	now := time.Now()
+	result.Body.IsOpen = !now.Before(result.Body.OpensAt) && now.Before(result.Body.ClosesAt)
+	result.Body.CanAnswer = result.Body.IsOpen && isMember && !result.Body.Answered
+
+	if result.Body.Questions, err = getQuestions(input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Ответ сохраняется без автора: отдельно помечаем только то, что участник уже ответил
+func SubmitSurvey(input *SurveySubmitInput, db *sql.DB) (*SurveyOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+
+	var endTime time.Time
+	var isMember, answered bool
+	if err := db.QueryRow(
+		"SELECT end_time, EXISTS (SELECT 1 FROM event_members WHERE event_uri = $1 AND member_email = $2), "+
+			"EXISTS (SELECT 1 FROM feedback_respondents WHERE event_uri = $1 AND member_email = $2) "+
+			"FROM events WHERE urid = $1",
+		input.Urid, user.Email,
+	).Scan(&endTime, &isMember, &answered); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, huma.Error404NotFound("Этого события нет XP")
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	if !isMember {
+		return nil, huma.Error403Forbidden("Анкету заполняют только участники мероприятия")
+	}
+	if time.Now().Before(endTime) {
+		return nil, huma.Error403Forbidden("Анкета откроется после окончания мероприятия")
+	}
+	if time.Now().After(endTime.Add(SurveyWindow)) {
+		return nil, huma.Error403Forbidden("Анкета уже закрыта")
+	}
+	if answered {
+		return nil, huma.Error409Conflict("Вы уже заполнили анкету")
+	}
+
+	if err := ensureQuestions(input.Urid, db); err != nil {
+		return nil, err
+	}
+	questions, err := getQuestions(input.Urid, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var questionIds []int64
+	var ratings []sql.NullInt64
+	var texts []sql.NullString
+	answers := make(map[int64]bool)
+
+	for _, answer := range input.Body.Answers {
+		var question *Question
+		for _, q := range questions {
+			if q.Id == answer.QuestionId {
+				question = q
+				break
+			}
+		}
+		if question == nil {
+			return nil, huma.Error422UnprocessableEntity("Такого вопроса нет в анкете")
+		}
+		if answers[question.Id] {
+			return nil, huma.Error422UnprocessableEntity("На вопрос можно ответить только один раз: " + question.Text)
+		}
+
+		var rating sql.NullInt64
+		var text sql.NullString
+		switch question.Type {
+		case QuestionRating:
+			if answer.Rating < 1 || answer.Rating > 5 {
+				return nil, huma.Error422UnprocessableEntity("Оценка должна быть от 1 до 5: " + question.Text)
+			}
+			rating = sql.NullInt64{Int64: int64(answer.Rating), Valid: true}
+		case QuestionText:
+			answer.Text = strings.TrimSpace(answer.Text)
+			if answer.Text == "" {
+				continue
+			}
+			if len([]rune(answer.Text)) > maxTextLength {
+				return nil, huma.Error422UnprocessableEntity("Слишком длинный ответ: " + question.Text)
+			}
+			text = sql.NullString{String: answer.Text, Valid: true}
+		}
+
+		answers[question.Id] = true
+		questionIds = append(questionIds, question.Id)
+		ratings = append(ratings, rating)
+		texts = append(texts, text)
+	}
+
+	for _, question := range questions {
+		if question.Required && !answers[question.Id] {
+			return nil, huma.Error422UnprocessableEntity("Нужно ответить на вопрос: " + question.Text)
+		}
+	}
+	if len(questionIds) == 0 {
+		return nil, huma.Error422UnprocessableEntity("Анкета пустая")
+	}
+
+	responseId, err := newResponseId()
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	// Одним запросом: при повторной отправке участник уже отмечен, и ответы не вставятся
+	res, err := db.Exec(
+		"WITH respondent AS (INSERT INTO feedback_respondents (event_uri, member_email) VALUES ($1, $2) "+
+			"ON CONFLICT DO NOTHING RETURNING event_uri) "+
+			"INSERT INTO feedback_answers (event_uri, response_id, question_id, rating, \"text\") "+
+			"SELECT respondent.event_uri, $3, answers.question_id, answers.rating, answers.answer_text "+
+			"FROM respondent, unnest($4::bigint[], $5::int[], $6::text[]) AS answers (question_id, rating, answer_text)",
+		input.Urid, user.Email, responseId, pq.Array(questionIds), pq.Array(ratings), pq.Array(texts),
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, huma.Error409Conflict("Вы уже заполнили анкету")
+	}
+
+	survey := &SurveyInput{Urid: input.Urid}
+	survey.Body.Token = input.Body.Token
+	return GetSurvey(survey, db)
+}
+
+// Вопросы можно менять, пока никто не ответил
+func EditQuestions(input *QuestionsEditInput, db *sql.DB) (*QuestionsOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	var responses int
+	if err := db.QueryRow("SELECT COUNT(*) FROM feedback_respondents WHERE event_uri = $1", input.Urid).Scan(&responses); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if responses > 0 {
+		return nil, huma.Error409Conflict("Анкету уже начали заполнять, вопросы менять нельзя")
+	}
+
+	for _, question := range input.Body.Questions {
+		if question.Type != QuestionRating && question.Type != QuestionText {
+			return nil, huma.Error422UnprocessableEntity("Неизвестный тип вопроса")
+		}
+		if strings.TrimSpace(question.Text) == "" {
+			return nil, huma.Error422UnprocessableEntity("Текст вопроса не должен быть пустым")
+		}
+	}
+
+	if _, err := db.Exec("DELETE FROM feedback_questions WHERE event_uri = $1", input.Urid); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if err := insertQuestions(input.Urid, input.Body.Questions, db); err != nil {
+		return nil, err
+	}
+
+	result := new(QuestionsOutput)
+	if result.Body.Questions, err = getQuestions(input.Urid, db); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Сводка для организаторов. Авторы ответов не хранятся, а при малом числе ответов
+// не показываем и сами ответы
+func GetSurveyResults(input *SurveyInput, db *sql.DB) (*SurveyResultsOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	result := new(SurveyResultsOutput)
+	result.Body.Urid = input.Urid
+	result.Body.Questions = []*QuestionResult{}
+
+	if err := db.QueryRow(
+		"SELECT (SELECT COUNT(*) FROM event_members WHERE event_uri = $1), "+
+			"(SELECT COUNT(*) FROM feedback_respondents WHERE event_uri = $1)",
+		input.Urid,
+	).Scan(&result.Body.Members, &result.Body.Responses); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	if result.Body.Responses < MinAnonymousResponses {
+		result.Body.Hidden = true
+		return result, nil
+	}
+
+	if result.Body.Rating, err = GetEventRating(input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(
+		"SELECT feedback_questions.id, feedback_questions.\"text\", feedback_questions.type, feedback_questions.required, "+
+			"COUNT(feedback_answers.question_id), COALESCE(AVG(feedback_answers.rating), 0), "+
+			"COUNT(*) FILTER (WHERE feedback_answers.rating = 1), COUNT(*) FILTER (WHERE feedback_answers.rating = 2), "+
+			"COUNT(*) FILTER (WHERE feedback_answers.rating = 3), COUNT(*) FILTER (WHERE feedback_answers.rating = 4), "+
+			"COUNT(*) FILTER (WHERE feedback_answers.rating = 5) "+
+			"FROM feedback_questions LEFT JOIN feedback_answers ON feedback_answers.question_id = feedback_questions.id "+
+			"WHERE feedback_questions.event_uri = $1 GROUP BY feedback_questions.id ORDER BY feedback_questions.position, feedback_questions.id",
+		input.Urid,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	byId := make(map[int64]*QuestionResult)
+	for rows.Next() {
+		question := new(QuestionResult)
+		distribution := make([]int, 5)
+		if err := rows.Scan(
+			&question.Id, &question.Text, &question.Type, &question.Required, &question.Answers, &question.Average,
+			&distribution[0], &distribution[1], &distribution[2], &distribution[3], &distribution[4],
+		); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		if question.Type == QuestionRating {
+			question.Distribution = distribution
+		} else {
+			question.Average = 0
+		}
+
+		byId[question.Id] = question
+		result.Body.Questions = append(result.Body.Questions, question)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	// Сортировка по тексту, а не по времени, чтобы порядок не выдавал авторов
+	textRows, err := db.Query(
+		"SELECT question_id, \"text\" FROM feedback_answers WHERE event_uri = $1 AND \"text\" IS NOT NULL ORDER BY question_id, \"text\"",
+		input.Urid,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer textRows.Close()
+
+	for textRows.Next() {
+		var questionId int64
+		var text string
+		if err := textRows.Scan(&questionId, &text); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		if question, ok := byId[questionId]; ok {
+			question.Texts = append(question.Texts, text)
+		}
+	}
+	if err = textRows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
+
+// Средняя оценка мероприятия по всем вопросам-оценкам (nil, если оценок мало)
+func GetEventRating(urid string, db *sql.DB) (*Rating, error) {
+	return getRating("event_uri = $1", urid, db)
+}
+
+// Средняя оценка по всем мероприятиям организатора (nil, если оценок мало)
+func GetOrganizerRating(email string, db *sql.DB) (*Rating, error) {
+	return getRating("event_uri IN (SELECT event_uri FROM event_orgs WHERE organizator_email = $1)", email, db)
+}
+
+// Открывает анкеты закончившихся мероприятий и оповещает участников (9)
+func OpenFinishedSurveys(db *sql.DB) error {
+	rows, err := db.Query(
+		"UPDATE events SET feedback_opened_at = now() "+
+			"WHERE feedback_opened_at IS NULL AND is_draft = false AND end_time <= now() AND end_time > $1 RETURNING urid",
+		time.Now().Add(-SurveyWindow),
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var opened []string
+	for rows.Next() {
+		var urid string
+		if err := rows.Scan(&urid); err != nil {
+			return err
+		}
+		opened = append(opened, urid)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, urid := range opened {
+		if err := ensureQuestions(urid, db); err != nil {
+			return err
+		}
+
+		// Уведомление приходит от имени организатора
+		_, err := db.Exec(
+			"INSERT INTO notifications (\"user\", type, \"from\", event_uri) "+
+				"SELECT event_members.member_email, 9, orgs.organizator_email, $1 FROM event_members, "+
+				"(SELECT organizator_email FROM event_orgs WHERE event_uri = $1 ORDER BY organizator_email LIMIT 1) AS orgs "+
+				"WHERE event_members.event_uri = $1",
+			urid,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Раз в interval проверяет, не пора ли открыть анкеты
+func RunScheduler(db *sql.DB, interval time.Duration) {
+	for {
+		if err := OpenFinishedSurveys(db); err != nil {
+			log.Printf("Анкеты обратной связи: %v", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+func getRating(condition string, arg string, db *sql.DB) (*Rating, error) {
+	rating := new(Rating)
+	if err := db.QueryRow(
+		"SELECT COUNT(DISTINCT response_id), COALESCE(ROUND(AVG(rating)::numeric, 2), 0)::float8 FROM feedback_answers "+
+			"WHERE rating IS NOT NULL AND "+condition,
+		arg,
+	).Scan(&rating.Responses, &rating.Average); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if rating.Responses < MinAnonymousResponses {
+		return nil, nil
+	}
+	return rating, nil
+}
+
+func getQuestions(urid string, db *sql.DB) ([]*Question, error) {
+	rows, err := db.Query(
+		"SELECT id, \"text\", type, required FROM feedback_questions WHERE event_uri = $1 ORDER BY position, id", urid,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	result := []*Question{}
+	for rows.Next() {
+		question := new(Question)
+		if err := rows.Scan(&question.Id, &question.Text, &question.Type, &question.Required); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		result = append(result, question)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
+
+// Ставит вопросы по умолчанию, если организатор не задал свои
+func ensureQuestions(urid string, db *sql.DB) error {
+	var exists bool
+	if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM feedback_questions WHERE event_uri = $1)", urid).Scan(&exists); err != nil {
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+	if exists {
+		return nil
+	}
+	return insertQuestions(urid, defaultQuestions, db)
+}
+
+func insertQuestions(urid string, questions []QuestionDraft, db *sql.DB) error {
+	var texts, types []string
+	var required []bool
+	for _, question := range questions {
+		texts = append(texts, strings.TrimSpace(question.Text))
+		types = append(types, question.Type)
+		required = append(required, question.Required)
+	}
+
+	_, err := db.Exec(
+		"INSERT INTO feedback_questions (event_uri, \"text\", type, required, position) "+
+			"SELECT $1, questions.text, questions.type, questions.required, questions.position "+
+			"FROM unnest($2::varchar[], $3::varchar[], $4::bool[]) WITH ORDINALITY AS questions (text, type, required, position)",
+		urid, pq.Array(texts), pq.Array(types), pq.Array(required),
+	)
+	if err != nil {
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+	return nil
+}
+
+func newResponseId() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,55 @@
+package geo
+
+// Встроенный справочник: крупные города и места, где часто проходят хакатоны
+var builtinPlaces = []Place{
+	{City: "Москва", Region: "Москва", Latitude: 55.7558, Longitude: 37.6173},
+	{City: "Санкт-Петербург", Region: "Санкт-Петербург", Latitude: 59.9343, Longitude: 30.3351},
+	{City: "Новосибирск", Region: "Новосибирская область", Latitude: 55.0084, Longitude: 82.9357},
+	{City: "Екатеринбург", Region: "Свердловская область", Latitude: 56.8389, Longitude: 60.6057},
+	{City: "Нижний Тагил", Region: "Свердловская область", Latitude: 57.9194, Longitude: 59.9650},
+	{City: "Каменск-Уральский", Region: "Свердловская область", Latitude: 56.4149, Longitude: 61.9189},
+	{City: "Первоуральск", Region: "Свердловская область", Latitude: 56.9080, Longitude: 59.9428},
+	{City: "Верхняя Пышма", Region: "Свердловская область", Latitude: 56.9758, Longitude: 60.5650},
+	{City: "Казань", Region: "Республика Татарстан", Latitude: 55.7961, Longitude: 49.1064},
+	{City: "Иннополис", Region: "Республика Татарстан", Latitude: 55.7521, Longitude: 48.7447},
+	{City: "Нижний Новгород", Region: "Нижегородская область", Latitude: 56.3269, Longitude: 44.0059},
+	{City: "Челябинск", Region: "Челябинская область", Latitude: 55.1644, Longitude: 61.4368},
+	{City: "Магнитогорск", Region: "Челябинская область", Latitude: 53.4186, Longitude: 58.9794},
+	{City: "Самара", Region: "Самарская область", Latitude: 53.1959, Longitude: 50.1008},
+	{City: "Тольятти", Region: "Самарская область", Latitude: 53.5303, Longitude: 49.3461},
+	{City: "Омск", Region: "Омская область", Latitude: 54.9885, Longitude: 73.3242},
+	{City: "Ростов-на-Дону", Region: "Ростовская область", Latitude: 47.2357, Longitude: 39.7015},
+	{City: "Уфа", Region: "Республика Башкортостан", Latitude: 54.7388, Longitude: 55.9721},
+	{City: "Красноярск", Region: "Красноярский край", Latitude: 56.0153, Longitude: 92.8932},
+	{City: "Воронеж", Region: "Воронежская область", Latitude: 51.6720, Longitude: 39.1843},
+	{City: "Пермь", Region: "Пермский край", Latitude: 58.0105, Longitude: 56.2502},
+	{City: "Волгоград", Region: "Волгоградская область", Latitude: 48.7080, Longitude: 44.5133},
+	{City: "Краснодар", Region: "Краснодарский край", Latitude: 45.0355, Longitude: 38.9753},
+	{City: "Сочи", Region: "Краснодарский край", Latitude: 43.5855, Longitude: 39.7231},
+	{City: "Саратов", Region: "Саратовская область", Latitude: 51.5331, Longitude: 46.0342},
+	{City: "Тюмень", Region: "Тюменская область", Latitude: 57.1522, Longitude: 65.5272},
+	{City: "Ижевск", Region: "Удмуртская Республика", Latitude: 56.8526, Longitude: 53.2045},
+	{City: "Барнаул", Region: "Алтайский край", Latitude: 53.3548, Longitude: 83.7698},
+	{City: "Ульяновск", Region: "Ульяновская область", Latitude: 54.3142, Longitude: 48.4031},
+	{City: "Иркутск", Region: "Иркутская область", Latitude: 52.2870, Longitude: 104.3050},
+	{City: "Хабаровск", Region: "Хабаровский край", Latitude: 48.4802, Longitude: 135.0719},
+	{City: "Владивосток", Region: "Приморский край", Latitude: 43.1155, Longitude: 131.8855},
+	{City: "Ярославль", Region: "Ярославская область", Latitude: 57.6261, Longitude: 39.8845},
+	{City: "Махачкала", Region: "Республика Дагестан", Latitude: 42.9849, Longitude: 47.5047},
+	{City: "Томск", Region: "Томская область", Latitude: 56.4846, Longitude: 84.9476},
+	{City: "Оренбург", Region: "Оренбургская область", Latitude: 51.7682, Longitude: 55.0970},
+	{City: "Кемерово", Region: "Кемеровская область", Latitude: 55.3547, Longitude: 86.0873},
+	{City: "Рязань", Region: "Рязанская область", Latitude: 54.6269, Longitude: 39.6916},
+	{City: "Астрахань", Region: "Астраханская область", Latitude: 46.3479, Longitude: 48.0336},
+	{City: "Пенза", Region: "Пензенская область", Latitude: 53.1959, Longitude: 45.0183},
+	{City: "Липецк", Region: "Липецкая область", Latitude: 52.6088, Longitude: 39.5992},
+	{City: "Калининград", Region: "Калининградская область", Latitude: 54.7104, Longitude: 20.4522},
+	{City: "Тула", Region: "Тульская область", Latitude: 54.1931, Longitude: 37.6173},
+	{City: "Киров", Region: "Кировская область", Latitude: 58.6036, Longitude: 49.6680},
+	{City: "Курган", Region: "Курганская область", Latitude: 55.4410, Longitude: 65.3411},
+	{City: "Сургут", Region: "Ханты-Мансийский автономный округ", Latitude: 61.2540, Longitude: 73.3962},
+	{City: "Якутск", Region: "Республика Саха (Якутия)", Latitude: 62.0355, Longitude: 129.6755},
+	{City: "Мурманск", Region: "Мурманская область", Latitude: 68.9585, Longitude: 33.0827},
+	{City: "Архангельск", Region: "Архангельская область", Latitude: 64.5393, Longitude: 40.5187},
+	{City: "Петрозаводск", Region: "Республика Карелия", Latitude: 61.7849, Longitude: 34.3469},
+}
@@ -0,0 +1,64 @@
+package geo
+
+import (
+	"errors"
+	"math"
+	"os"
+)
+
+var ErrNotFound = errors.New("место не найдено")
+
+const earthRadiusKm = 6371.0
+
+// Населенный пункт с координатами
+type Place struct {
+	City      string
+	Region    string
+	Latitude  float64
+	Longitude float64
+}
+
+// Определяет город и координаты по адресу в свободной форме
+type Geocoder interface {
+	Geocode(location string) (*Place, error)
+}
+
+// Справочник городов берется из файла GEOCODER_DATA (CSV: city,region,latitude,longitude),
+// а без него - встроенный
+func New() (Geocoder, error) {
+	if path := os.Getenv("GEOCODER_DATA"); path != "" {
+		return LoadStatic(path)
+	}
+	return NewStatic(builtinPlaces), nil
+}
+
+// Расстояние между точками по поверхности Земли в километрах (формула гаверсинусов)
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	dLat := radians(lat2 - lat1)
+	dLon := radians(lon2 - lon1)
+	a := math.Pow(math.Sin(dLat/2), 2) + math.Cos(radians(lat1))*math.Cos(radians(lat2))*math.Pow(math.Sin(dLon/2), 2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+// Та же формула для SQL: расстояние от точки ($lat, $lon) до колонок latitude и longitude
+func DistanceSQL(lat string, lon string) string {
+	return "(2 * 6371.0 * asin(sqrt(power(sin(radians(events.latitude - " + lat + ") / 2), 2) + " +
+		"cos(radians(" + lat + ")) * cos(radians(events.latitude)) * power(sin(radians(events.longitude - " + lon + ") / 2), 2))))"
+}
+
+// Пределы широты и долготы вокруг точки, чтобы отсечь дальние записи до точного расчета
+func BoundingBox(lat, lon, radiusKm float64) (minLat, maxLat, minLon, maxLon float64) {
+	dLat := radiusKm / earthRadiusKm * 180 / math.Pi
+	minLat, maxLat = math.Max(lat-dLat, -90), math.Min(lat+dLat, 90)
+
+	// У полюсов долгота не ограничивает
+	if maxLat >= 90 || minLat <= -90 {
+		return minLat, maxLat, -180, 180
+	}
+	dLon := dLat / math.Cos(radians(lat))
+	return minLat, maxLat, math.Max(lon-dLon, -180), math.Min(lon+dLon, 180)
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
@@ -0,0 +1,76 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lat1, lon1, lat2, lon2 float64
+		want                   float64
+	}{
+		{"одна точка", 56.8389, 60.6057, 56.8389, 60.6057, 0},
+		{"Екатеринбург - Москва", 56.8389, 60.6057, 55.7558, 37.6173, 1418},
+		{"градус по экватору", 0, 0, 0, 1, 111.19},
+		{"через 180-й меридиан", 0, 179.5, 0, -179.5, 111.19},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Distance(tt.lat1, tt.lon1, tt.lat2, tt.lon2); math.Abs(got-tt.want) > tt.want*0.01+0.01 {
+				t.Errorf("Distance = %.2f, ожидалось около %.2f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBoundingBox(t *testing.T) {
+	tests := []struct {
+		name     string
+		lat, lon float64
+		radius   float64
+		poles    bool // Долгота не ограничена
+	}{
+		{"Екатеринбург", 56.8389, 60.6057, 50, false},
+		{"экватор", 0, 0, 100, false},
+		{"у края долготы", 10, 179.9, 100, false},
+		{"у северного полюса", 89.9, 10, 50, true},
+		{"у южного полюса", -89.9, 10, 50, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			minLat, maxLat, minLon, maxLon := BoundingBox(tt.lat, tt.lon, tt.radius)
+
+			if minLat < -90 || maxLat > 90 || minLon < -180 || maxLon > 180 {
+				t.Fatalf("пределы за границами координат: %v %v %v %v", minLat, maxLat, minLon, maxLon)
+			}
+			if tt.lat < minLat || tt.lat > maxLat || tt.lon < minLon || tt.lon > maxLon {
+				t.Fatalf("центр не попал в пределы: %v %v %v %v", minLat, maxLat, minLon, maxLon)
+			}
+			if tt.poles != (minLon == -180 && maxLon == 180) {
+				t.Errorf("долгота %v..%v, ожидалось без ограничения: %v", minLon, maxLon, tt.poles)
+			}
+
+			// Точки на расстоянии радиуса к северу, югу, востоку и западу не должны отсекаться
+			dLat := tt.radius / earthRadiusKm * 180 / math.Pi * 0.999
+			dLon := dLat / math.Cos(radians(tt.lat))
+			for _, point := range [][2]float64{
+				{tt.lat + dLat, tt.lon}, {tt.lat - dLat, tt.lon},
+				{tt.lat, tt.lon + dLon}, {tt.lat, tt.lon - dLon},
+			} {
+				if point[0] < -90 || point[0] > 90 || point[1] < -180 || point[1] > 180 {
+					continue
+				}
+				if Distance(tt.lat, tt.lon, point[0], point[1]) > tt.radius {
+					continue
+				}
+				if point[0] < minLat || point[0] > maxLat || point[1] < minLon || point[1] > maxLon {
+					t.Errorf("точка %v в радиусе, но за пределами", point)
+				}
+			}
+		})
+	}
+}
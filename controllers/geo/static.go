@@ -0,0 +1,109 @@
+package geo
+
+import (
+	"encoding/csv"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Геокодер по справочнику городов: ищет в адресе название известного города
+type Static struct {
+	places map[string]*Place
+}
+
+func NewStatic(places []Place) *Static {
+	static := &Static{places: make(map[string]*Place, len(places))}
+	for i := range places {
+		static.places[normalize(places[i].City)] = &places[i]
+	}
+	return static
+}
+
+// Загружает справочник из CSV с заголовком city,region,latitude,longitude
+func LoadStatic(path string) (*Static, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) < 2 {
+		return nil, errors.New("справочник городов пуст")
+	}
+
+	var places []Place
+	for i, record := range records[1:] {
+		if len(record) < 4 {
+			return nil, errors.New("справочник городов: мало колонок в строке " + strconv.Itoa(i+2))
+		}
+		lat, err := strconv.ParseFloat(strings.TrimSpace(record[2]), 64)
+		if err != nil {
+			return nil, errors.New("справочник городов: неверная широта в строке " + strconv.Itoa(i+2))
+		}
+		lon, err := strconv.ParseFloat(strings.TrimSpace(record[3]), 64)
+		if err != nil {
+			return nil, errors.New("справочник городов: неверная долгота в строке " + strconv.Itoa(i+2))
+		}
+		places = append(places, Place{
+			City:      strings.TrimSpace(record[0]),
+			Region:    strings.TrimSpace(record[1]),
+			Latitude:  lat,
+			Longitude: lon,
+		})
+	}
+
+	return NewStatic(places), nil
+}
+
+// Из нескольких найденных названий берется самое длинное ("Нижний Новгород", а не "Новгород"),
+// а при равенстве - стоящее правее: город обычно пишут после области
+func (s *Static) Geocode(location string) (*Place, error) {
+	text := " " + normalize(location) + " "
+
+	var found *Place
+	var foundKey string
+	foundAt := -1
+	for key, place := range s.places {
+		at := strings.LastIndex(text, " "+key+" ")
+		if at < 0 {
+			continue
+		}
+		if len(key) > len(foundKey) || (len(key) == len(foundKey) && at > foundAt) {
+			found, foundKey, foundAt = place, key, at
+		}
+	}
+	if found == nil {
+		return nil, ErrNotFound
+	}
+
+	place := *found
+	return &place, nil
+}
+
+// Нижний регистр, е вместо ё, без знаков препинания и сокращений вроде "г."
+func normalize(s string) string {
+	s = strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(s, "ё", "е"), "Ё", "Е"))
+	s = strings.Map(func(r rune) rune {
+		switch r {
+		case ',', '.', ';', ':', '(', ')', '"', '«', '»':
+			return ' '
+		}
+		return r
+	}, s)
+
+	words := strings.Fields(s)
+	result := words[:0]
+	for _, word := range words {
+		if word == "г" || word == "гор" || word == "город" {
+			continue
+		}
+		result = append(result, word)
+	}
+	return strings.Join(result, " ")
+}
@@ -0,0 +1,429 @@
+package invitations
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"hackaton-jam-back/controllers/mailer"
+	"hackaton-jam-back/controllers/utils"
+	"hackaton-jam-back/controllers/webhooks"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/mail"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+const maxImportRows = 5000
+
+// Результат по строке файла
+const (
+	StatusEnrolled       = "enrolled"
+	StatusAlreadyMember  = "already_member"
+	StatusInvited        = "invited"
+	StatusAlreadyInvited = "already_invited"
+	StatusError          = "error"
+)
+
+// Пределы запроса на импорт: CSV на несколько тысяч строк с запасом
+const (
+	MaxImportBodyBytes = 5 << 20
+	ImportReadTimeout  = time.Minute
+)
+
+type ImportInput struct {
+	Urid    string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	DryRun  bool   `query:"dry_run" doc:"Только проверить файл, ничего не записывая"`
+	RawBody multipart.Form
+}
+
+type InvitationsEventInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type ImportRow struct {
+	Row     int    `json:"row" example:"2" doc:"Номер строки в файле"`
+	Email   string `json:"email" example:"student@urfu.ru" doc:"E-mail из строки"`
+	Status  string `json:"status" example:"enrolled" doc:"Результат (enrolled, already_member, invited, already_invited, error)"`
+	Message string `json:"message,omitempty" doc:"Пояснение к ошибке"`
+}
+
+type ImportOutput struct {
+	Body struct {
+		DryRun   bool         `json:"dry_run" doc:"Пробный запуск (ничего не записано)"`
+		Total    int          `json:"total" example:"120" doc:"Строк в файле"`
+		Enrolled int          `json:"enrolled" example:"80" doc:"Записано на мероприятие"`
+		Invited  int          `json:"invited" example:"30" doc:"Приглашено на платформу"`
+		Skipped  int          `json:"skipped" example:"5" doc:"Уже были записаны или приглашены"`
+		Errors   int          `json:"errors" example:"5" doc:"Строк с ошибками"`
+		Emailed  bool         `json:"emailed" doc:"Отправлены ли письма с приглашениями"`
+		Rows     []*ImportRow `json:"rows" doc:"Результат по каждой строке"`
+	}
+}
+
+type Invitation struct {
+	Email      string     `json:"email" example:"student@urfu.ru" doc:"E-mail приглашенного"`
+	InvitedBy  string     `json:"invited_by" example:"thatmaidguy@ya.ru" doc:"Кто пригласил"`
+	CreatedAt  time.Time  `json:"created_at" doc:"Время приглашения"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty" doc:"Когда зарегистрировался (если уже)"`
+}
+
+type InvitationsOutput struct {
+	Body struct {
+		Invitations []*Invitation `json:"invitations" doc:"Приглашения на мероприятие"`
+	}
+}
+
+type importRecord struct {
+	row       int
+	email     string
+	firstName string
+}
+
+// Ожидается файл в поле file и токен в поле access_token.
+// В файле нужен заголовок с колонкой email, имя (first_name) - по желанию
+func ImportParticipants(input *ImportInput, db *sql.DB) (*ImportOutput, error) {
+	user, err := utils.GetUserEmailByToken(formValue(&input.RawBody, "access_token"), db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	files := input.RawBody.File["file"]
+	if len(files) == 0 {
+		return nil, huma.Error422UnprocessableEntity("Нужно приложить CSV-файл в поле file")
+	}
+	file, err := files[0].Open()
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer file.Close()
+
+	records, err := readRecords(file)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity("Не удалось прочитать CSV: " + err.Error())
+	}
+
+	var eventName string
+	if err := db.QueryRow("SELECT name FROM events WHERE urid = $1", input.Urid).Scan(&eventName); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	result := new(ImportOutput)
+	result.Body.DryRun = input.DryRun
+	result.Body.Total = len(records)
+
+	var invited []*importRecord
+	seen := map[string]int{}
+
+	for _, record := range records {
+		row := &ImportRow{Row: record.row, Email: record.email}
+		result.Body.Rows = append(result.Body.Rows, row)
+
+		if first, ok := seen[record.email]; ok {
+			row.Status = StatusError
+			row.Message = "Этот e-mail уже был в строке " + strconv.Itoa(first)
+			result.Body.Errors++
+			continue
+		}
+		seen[record.email] = record.row
+
+		if err := validateEmail(record.email); err != nil {
+			row.Status = StatusError
+			row.Message = err.Error()
+			result.Body.Errors++
+			continue
+		}
+
+		row.Status, err = importRecordRow(record, input.Urid, user.Email, input.DryRun, db)
+		if err != nil {
+			row.Status = StatusError
+			row.Message = err.Error()
+		}
+
+		switch row.Status {
+		case StatusEnrolled:
+			result.Body.Enrolled++
+		case StatusInvited:
+			result.Body.Invited++
+			invited = append(invited, record)
+		case StatusAlreadyMember, StatusAlreadyInvited:
+			result.Body.Skipped++
+		default:
+			result.Body.Errors++
+		}
+	}
+
+	if !input.DryRun && mailer.Enabled() && len(invited) > 0 {
+		result.Body.Emailed = true
+
+		// Письма уходят в фоне, чтобы не держать запрос
+		go sendInvitations(invited, input.Urid, eventName)
+	}
+
+	return result, nil
+}
+
+func GetInvitations(input *InvitationsEventInput, db *sql.DB) (*InvitationsOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(
+		"SELECT email, invited_by, created_at, accepted_at FROM event_invitations WHERE event_uri = $1 ORDER BY created_at DESC",
+		input.Urid,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	result := new(InvitationsOutput)
+
+	for rows.Next() {
+		invitation := new(Invitation)
+		var acceptedAt sql.NullTime
+		if err := rows.Scan(&invitation.Email, &invitation.InvitedBy, &invitation.CreatedAt, &acceptedAt); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		if acceptedAt.Valid {
+			invitation.AcceptedAt = &acceptedAt.Time
+		}
+
+		result.Body.Invitations = append(result.Body.Invitations, invitation)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
+
+// Есть ли у e-mail приглашения, которые еще не приняты
+func HasPendingInvitations(email string, db *sql.DB) (bool, error) {
+	var invited bool
+	err := db.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM event_invitations WHERE email = lower($1) AND accepted_at IS NULL)", email,
+	).Scan(&invited)
+	return invited, err
+}
+
+// Записывает на мероприятия, куда пользователя пригласили до регистрации.
+// Вызывается только после подтверждения почты
+func AcceptInvitations(email string, db *sql.DB) error {
+	rows, err := db.Query(
+		"WITH accepted AS (UPDATE event_invitations SET accepted_at = now() "+
+			"WHERE email = lower($1) AND accepted_at IS NULL RETURNING event_uri) "+
+			"INSERT INTO event_members (event_uri, member_email) SELECT event_uri, $1 FROM accepted RETURNING event_uri",
+		email,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var urids []string
+	for rows.Next() {
+		var urid string
+		if err := rows.Scan(&urid); err != nil {
+			return err
+		}
+		urids = append(urids, urid)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	if len(urids) == 0 {
+		return nil
+	}
+
+	member := webhooks.MemberData{Email: email, Source: "invitation"}
+	if user, err := utils.GetUserUsernameByEmail(email, db); err == nil {
+		member.Username = user.Username
+	}
+	for _, urid := range urids {
+		if err := webhooks.Enqueue(urid, webhooks.TypeMemberJoined, member, db); err != nil {
+			log.Println("Вебхуки: " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+func importRecordRow(record *importRecord, urid string, organizator string, dryRun bool, db *sql.DB) (string, error) {
+	var email string
+	var perms int
+	err := db.QueryRow("SELECT email, perms FROM users WHERE lower(email) = $1", record.email).Scan(&email, &perms)
+	if err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+
+	// Пользователь уже есть - записываем
+	if err == nil {
+		if perms != 0 {
+			return "", errors.New("Участвовать можно только обычным пользователям")
+		}
+
+		var member string
+		err := db.QueryRow("SELECT member_email FROM event_members WHERE event_uri = $1 AND member_email = $2", urid, email).Scan(&member)
+		if err == nil {
+			return StatusAlreadyMember, nil
+		}
+		if err != sql.ErrNoRows {
+			return "", err
+		}
+
+		if !dryRun {
+			if _, err := db.Exec("INSERT INTO event_members (event_uri, member_email) VALUES ($1, $2)", urid, email); err != nil {
+				return "", err
+			}
+
+			member := webhooks.MemberData{Email: email, Source: "import"}
+			if user, err := utils.GetUserUsernameByEmail(email, db); err == nil {
+				member.Username = user.Username
+			}
+			if err := webhooks.Enqueue(urid, webhooks.TypeMemberJoined, member, db); err != nil {
+				log.Println("Вебхуки: " + err.Error())
+			}
+		}
+		return StatusEnrolled, nil
+	}
+
+	// Пользователя нет - приглашаем
+	var invitedEmail string
+	err = db.QueryRow("SELECT email FROM event_invitations WHERE event_uri = $1 AND email = $2", urid, record.email).Scan(&invitedEmail)
+	if err == nil {
+		return StatusAlreadyInvited, nil
+	}
+	if err != sql.ErrNoRows {
+		return "", err
+	}
+
+	if !dryRun {
+		_, err := db.Exec(
+			"INSERT INTO event_invitations (event_uri, email, invited_by) VALUES ($1, $2, $3)",
+			urid, record.email, organizator,
+		)
+		if err != nil {
+			return "", err
+		}
+	}
+	return StatusInvited, nil
+}
+
+func readRecords(file io.Reader) ([]*importRecord, error) {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	// Excel в русской локали сохраняет CSV через точку с запятой
+	firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		reader.Comma = ';'
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	emailColumn, nameColumn := -1, -1
+	for i, column := range header {
+		switch strings.ToLower(strings.TrimSpace(column)) {
+		case "email", "e-mail", "почта":
+			emailColumn = i
+		case "first_name", "name", "имя":
+			nameColumn = i
+		}
+	}
+	if emailColumn == -1 {
+		return nil, errors.New("в заголовке нет колонки email")
+	}
+
+	var records []*importRecord
+	for row := 2; ; row++ {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == maxImportRows {
+			return nil, errors.New("слишком много строк (максимум " + strconv.Itoa(maxImportRows) + ")")
+		}
+
+		record := &importRecord{row: row}
+		if emailColumn < len(fields) {
+			record.email = strings.ToLower(strings.TrimSpace(fields[emailColumn]))
+		}
+		if nameColumn != -1 && nameColumn < len(fields) {
+			record.firstName = strings.TrimSpace(fields[nameColumn])
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+func validateEmail(email string) error {
+	if email == "" {
+		return errors.New("Пустой e-mail")
+	}
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return errors.New("Некорректный e-mail")
+	}
+	return nil
+}
+
+func sendInvitations(records []*importRecord, urid string, eventName string) {
+	link := os.Getenv("PUBLIC_URL") + "/register?event=" + url.QueryEscape(urid)
+
+	for _, record := range records {
+		greeting := "Здравствуйте!"
+		if record.firstName != "" {
+			greeting = "Здравствуйте, " + record.firstName + "!"
+		}
+
+		text := greeting + "\n\n" +
+			"Вас пригласили на мероприятие «" + eventName + "».\n" +
+			"Зарегистрируйтесь на платформе с этим e-mail и подтвердите почту - после этого вы будете записаны на мероприятие:\n" +
+			link + "&email=" + url.QueryEscape(record.email) + "\n"
+
+		if err := mailer.Send(record.email, "Приглашение на «"+eventName+"»", text); err != nil {
+			log.Println("Не удалось отправить приглашение " + record.email + ": " + err.Error())
+		}
+	}
+}
+
+func formValue(form *multipart.Form, name string) string {
+	if values := form.Value[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
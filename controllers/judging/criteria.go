@@ -0,0 +1,146 @@
+package judging
+
+import (
+	"database/sql"
+	"hackaton-jam-back/controllers/utils"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+type Criterion struct {
+	Id          int64   `json:"id" example:"1" doc:"Идентификатор критерия"`
+	Name        string  `json:"name" example:"Геймплей" doc:"Название критерия"`
+	Description string  `json:"desc" doc:"Описание критерия"`
+	Weight      float64 `json:"weight" example:"1.5" doc:"Вес критерия"`
+	MinScore    int     `json:"min_score" example:"0" doc:"Минимальная оценка"`
+	MaxScore    int     `json:"max_score" example:"10" doc:"Максимальная оценка"`
+}
+
+type CriterionAddInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+
+		Name        string  `json:"name" example:"Геймплей" doc:"Название критерия"`
+		Description string  `json:"desc,omitempty" doc:"Описание критерия"`
+		Weight      float64 `json:"weight" example:"1.5" doc:"Вес критерия (больше нуля)"`
+		MinScore    int     `json:"min_score" example:"0" doc:"Минимальная оценка"`
+		MaxScore    int     `json:"max_score" example:"10" doc:"Максимальная оценка"`
+	}
+}
+
+type CriterionDelInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Id   int64  `path:"id" example:"1" doc:"Идентификатор критерия"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type CriteriaOutput struct {
+	Body struct {
+		Criteria []*Criterion `json:"criteria" doc:"Критерии оценивания"`
+	}
+}
+
+func GetCriteria(urid string, db *sql.DB) (*CriteriaOutput, error) {
+	criteria, err := getEventCriteria(urid, db)
+	if err != nil {
+		return nil, err
+	}
+
+	result := new(CriteriaOutput)
+	result.Body.Criteria = criteria
+
+	return result, nil
+}
+
+func AddCriterion(input *CriterionAddInput, db *sql.DB) (*CriteriaOutput, error) {
+	if input.Body.Name == "" {
+		return nil, huma.Error422UnprocessableEntity("Название критерия не должно быть пустым")
+	}
+	if input.Body.Weight <= 0 {
+		return nil, huma.Error422UnprocessableEntity("Вес критерия должен быть больше нуля")
+	}
+	if input.Body.MaxScore <= input.Body.MinScore {
+		return nil, huma.Error422UnprocessableEntity("Максимальная оценка должна быть больше минимальной")
+	}
+
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(
+		"INSERT INTO judging_criteria (event_uri, name, \"desc\", weight, min_score, max_score) VALUES ($1, $2, $3, $4, $5, $6)",
+		input.Urid, input.Body.Name, input.Body.Description, input.Body.Weight, input.Body.MinScore, input.Body.MaxScore,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return GetCriteria(input.Urid, db)
+}
+
+func DelCriterion(input *CriterionDelInput, db *sql.DB) (*CriteriaOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	var tmp int64
+	if err := db.QueryRow("SELECT id FROM judging_criteria WHERE id = $1 AND event_uri = $2", input.Id, input.Urid).Scan(&tmp); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, huma.Error404NotFound("Такого критерия нет")
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	// Сначала оценки по критерию, потом сам критерий
+	_, err = db.Exec("DELETE FROM scores WHERE criterion_id = $1", input.Id)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	_, err = db.Exec("DELETE FROM judging_criteria WHERE id = $1", input.Id)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return GetCriteria(input.Urid, db)
+}
+
+func getEventCriteria(urid string, db *sql.DB) ([]*Criterion, error) {
+	rows, err := db.Query(
+		"SELECT id, name, \"desc\", weight, min_score, max_score FROM judging_criteria WHERE event_uri = $1 ORDER BY id", urid,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	var result []*Criterion
+
+	for rows.Next() {
+		criterion := new(Criterion)
+		var desc sql.NullString
+		if err := rows.Scan(&criterion.Id, &criterion.Name, &desc, &criterion.Weight, &criterion.MinScore, &criterion.MaxScore); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		criterion.Description = desc.String
+
+		result = append(result, criterion)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
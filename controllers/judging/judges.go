@@ -0,0 +1,259 @@
+package judging
+
+import (
+	"database/sql"
+	"hackaton-jam-back/controllers/utils"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+type JudgesAddDelInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token  string   `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+		Judges []string `json:"judges" doc:"E-mail членов жюри"`
+	}
+}
+
+type JudgeAssignInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token         string  `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+		Judge         string  `json:"judge" example:"thatmaidguy@ya.ru" doc:"E-mail члена жюри"`
+		SubmissionIds []int64 `json:"submission_ids,omitempty" doc:"Работы для оценивания (если пусто - все работы события)"`
+	}
+}
+
+type JudgesOutput struct {
+	Body struct {
+		Judges []*utils.UserShortInfo `json:"judges" doc:"Члены жюри"`
+	}
+}
+
+func GetJudges(urid string, db *sql.DB) (*JudgesOutput, error) {
+	rows, err := db.Query("SELECT judge_email FROM event_judges WHERE event_uri = $1", urid)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	result := new(JudgesOutput)
+
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+
+		judge, err := utils.GetUserShortInfo(email, db)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Body.Judges = append(result.Body.Judges, judge)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
+
+func AddJudges(input *JudgesAddDelInput, db *sql.DB) (*JudgesOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	// Сначала проверяем всех, чтобы не добавить жюри наполовину
+	for _, email := range input.Body.Judges {
+		if _, err := utils.GetUserUsernameByEmail(email, db); err != nil {
+			return nil, huma.Error422UnprocessableEntity("Пользователь " + email + " не найден")
+		}
+		if err := checkJudgeLinked(email, input.Urid, db); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer tx.Rollback()
+
+	for _, email := range input.Body.Judges {
+		_, err = tx.Exec(
+			"INSERT INTO event_judges (event_uri, judge_email) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+			input.Urid, email,
+		)
+		if err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return GetJudges(input.Urid, db)
+}
+
+// В жюри попадают только связанные с мероприятием: участники, организаторы, сотрудники организации,
+// волонтеры, менторы или приглашенные на мероприятие. Остальных сначала нужно пригласить
+func checkJudgeLinked(email string, urid string, db *sql.DB) error {
+	var linked bool
+	if err := db.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM event_members WHERE event_uri = $1 AND member_email = $2) "+
+			"OR EXISTS (SELECT 1 FROM event_orgs WHERE event_uri = $1 AND organizator_email = $2) "+
+			"OR EXISTS (SELECT 1 FROM events INNER JOIN organization_members ON organization_members.org_urid = events.org_urid "+
+			"WHERE events.urid = $1 AND organization_members.member_email = $2) "+
+			"OR EXISTS (SELECT 1 FROM event_volunteers WHERE event_uri = $1 AND volunteer_email = $2) "+
+			"OR EXISTS (SELECT 1 FROM event_mentors WHERE event_uri = $1 AND mentor_email = $2) "+
+			"OR EXISTS (SELECT 1 FROM event_invitations WHERE event_uri = $1 AND email = $2)",
+		urid, email,
+	).Scan(&linked); err != nil {
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+	if !linked {
+		return huma.Error422UnprocessableEntity("Пользователь " + email + " не связан с мероприятием, сначала пригласите его")
+	}
+
+	return nil
+}
+
+func DelJudges(input *JudgesAddDelInput, db *sql.DB) (*JudgesOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	for _, email := range input.Body.Judges {
+		// Назначения снимаем, оценки оставляем
+		_, err = db.Exec(
+			"DELETE FROM judge_assignments WHERE judge_email = $1 AND submission_id IN (SELECT id FROM submissions WHERE event_uri = $2)",
+			email, input.Urid,
+		)
+		if err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+
+		_, err = db.Exec("DELETE FROM event_judges WHERE event_uri = $1 AND judge_email = $2", input.Urid, email)
+		if err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+	}
+
+	return GetJudges(input.Urid, db)
+}
+
+func AssignSubmissions(input *JudgeAssignInput, db *sql.DB) (*JudgeAssignmentsOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	if err := checkEventJudge(input.Body.Judge, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	// Работы команд, в которых состоит сам член жюри, ему не назначаются
+	if len(input.Body.SubmissionIds) == 0 {
+		_, err = db.Exec(
+			"INSERT INTO judge_assignments (submission_id, judge_email) "+
+				"SELECT id, $2 FROM submissions WHERE event_uri = $1 AND NOT "+
+				"EXISTS (SELECT 1 FROM teams_members WHERE teams_members.team_id = submissions.team_id AND teams_members.member_email = $2) "+
+				"ON CONFLICT DO NOTHING",
+			input.Urid, input.Body.Judge,
+		)
+		if err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+	}
+
+	for _, id := range input.Body.SubmissionIds {
+		var own bool
+		if err := db.QueryRow(
+			"SELECT EXISTS (SELECT 1 FROM teams_members INNER JOIN submissions ON submissions.team_id = teams_members.team_id "+
+				"WHERE submissions.id = $2 AND teams_members.member_email = $1)",
+			input.Body.Judge, id,
+		).Scan(&own); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		if own {
+			return nil, huma.Error422UnprocessableEntity("Член жюри состоит в команде этой работы")
+		}
+
+		res, err := db.Exec(
+			"INSERT INTO judge_assignments (submission_id, judge_email) "+
+				"SELECT id, $3 FROM submissions WHERE id = $1 AND event_uri = $2 ON CONFLICT DO NOTHING",
+			id, input.Urid, input.Body.Judge,
+		)
+		if err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			var tmp int64
+			if err := db.QueryRow("SELECT id FROM submissions WHERE id = $1 AND event_uri = $2", id, input.Urid).Scan(&tmp); err == sql.ErrNoRows {
+				return nil, huma.Error422UnprocessableEntity("Работа не относится к этому событию")
+			}
+		}
+	}
+
+	return getJudgeAssignments(input.Body.Judge, input.Urid, db)
+}
+
+func UnassignSubmissions(input *JudgeAssignInput, db *sql.DB) (*JudgeAssignmentsOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	if len(input.Body.SubmissionIds) == 0 {
+		_, err = db.Exec(
+			"DELETE FROM judge_assignments WHERE judge_email = $2 AND submission_id IN (SELECT id FROM submissions WHERE event_uri = $1)",
+			input.Urid, input.Body.Judge,
+		)
+		if err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+	}
+
+	for _, id := range input.Body.SubmissionIds {
+		_, err = db.Exec(
+			"DELETE FROM judge_assignments WHERE judge_email = $3 AND submission_id = $1 AND submission_id IN (SELECT id FROM submissions WHERE event_uri = $2)",
+			id, input.Urid, input.Body.Judge,
+		)
+		if err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+	}
+
+	return getJudgeAssignments(input.Body.Judge, input.Urid, db)
+}
+
+func checkEventJudge(email string, urid string, db *sql.DB) error {
+	var tmp string
+	if err := db.QueryRow("SELECT judge_email FROM event_judges WHERE event_uri = $1 AND judge_email = $2", urid, email).Scan(&tmp); err != nil {
+		if err == sql.ErrNoRows {
+			return huma.Error403Forbidden("Пользователь не входит в жюри этого события")
+		}
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return nil
+}
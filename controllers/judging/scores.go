@@ -0,0 +1,528 @@
+package judging
+
+import (
+	"database/sql"
+	"hackaton-jam-back/controllers/utils"
+	"strconv"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+type JudgeAssignment struct {
+	SubmissionId   int64  `json:"submission_id" example:"1" doc:"Идентификатор работы"`
+	EventUri       string `json:"event_urid" example:"example_events" doc:"Ссылка на мероприятие"`
+	TeamName       string `json:"team_name" example:"Супер-команда" doc:"Название команды"`
+	Title          string `json:"title" example:"Супер-игра" doc:"Название проекта"`
+	ScoredCriteria int    `json:"scored_criteria" example:"2" doc:"Сколько критериев уже оценено"`
+	TotalCriteria  int    `json:"total_criteria" example:"3" doc:"Сколько всего критериев"`
+}
+
+type JudgeAssignmentsInput struct {
+	Urid string `query:"urid" example:"example_events" doc:"Ссылка на мероприятие (если не указана - все события)"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type JudgeAssignmentsOutput struct {
+	Body struct {
+		Judge       string             `json:"judge" example:"thatmaidguy@ya.ru" doc:"E-mail члена жюри"`
+		Assignments []*JudgeAssignment `json:"assignments" doc:"Работы для оценивания"`
+	}
+}
+
+type ScoreItem struct {
+	CriterionId int64  `json:"criterion_id" example:"1" doc:"Идентификатор критерия"`
+	Score       int    `json:"score" example:"8" doc:"Оценка"`
+	Comment     string `json:"comment,omitempty" doc:"Комментарий"`
+}
+
+type JudgeScore struct {
+	Judge       string    `json:"judge" example:"thatmaidguy@ya.ru" doc:"E-mail члена жюри"`
+	CriterionId int64     `json:"criterion_id" example:"1" doc:"Идентификатор критерия"`
+	Score       int       `json:"score" example:"8" doc:"Оценка"`
+	Comment     string    `json:"comment" doc:"Комментарий"`
+	UpdatedAt   time.Time `json:"updated_at" doc:"Время оценки"`
+}
+
+type ScoresSetInput struct {
+	Id   int64 `path:"id" example:"1" doc:"Идентификатор работы"`
+	Body struct {
+		Token  string      `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+		Scores []ScoreItem `json:"scores" doc:"Оценки по критериям"`
+	}
+}
+
+type ScoresGetInput struct {
+	Id   int64 `path:"id" example:"1" doc:"Идентификатор работы"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type ScoresOutput struct {
+	Body struct {
+		SubmissionId int64         `json:"submission_id" example:"1" doc:"Идентификатор работы"`
+		Scores       []*JudgeScore `json:"scores" doc:"Оценки"`
+	}
+}
+
+type JudgingEventInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type JudgeProgress struct {
+	Judge     string `json:"judge" example:"thatmaidguy@ya.ru" doc:"E-mail члена жюри"`
+	Assigned  int    `json:"assigned" example:"10" doc:"Назначено работ"`
+	Completed int    `json:"completed" example:"7" doc:"Полностью оценено работ"`
+}
+
+type SubmissionProgress struct {
+	SubmissionId    int64  `json:"submission_id" example:"1" doc:"Идентификатор работы"`
+	TeamName        string `json:"team_name" example:"Супер-команда" doc:"Название команды"`
+	JudgesAssigned  int    `json:"judges_assigned" example:"3" doc:"Назначено членов жюри"`
+	JudgesCompleted int    `json:"judges_completed" example:"2" doc:"Оценили полностью"`
+}
+
+type JudgingProgressOutput struct {
+	Body struct {
+		TotalCriteria int                   `json:"total_criteria" example:"3" doc:"Количество критериев"`
+		Judges        []*JudgeProgress      `json:"judges" doc:"Прогресс по членам жюри"`
+		Submissions   []*SubmissionProgress `json:"submissions" doc:"Прогресс по работам"`
+	}
+}
+
+type LeaderboardEntry struct {
+	Place        int     `json:"place" example:"1" doc:"Место"`
+	SubmissionId int64   `json:"submission_id" example:"1" doc:"Идентификатор работы"`
+	TeamId       int64   `json:"team_id" example:"2" doc:"Идентификатор команды"`
+	TeamName     string  `json:"team_name" example:"Супер-команда" doc:"Название команды"`
+	Title        string  `json:"title" example:"Супер-игра" doc:"Название проекта"`
+	Score        float64 `json:"score" example:"87.5" doc:"Итоговый балл (0-100) с учетом весов критериев"`
+	Judges       int     `json:"judges" example:"3" doc:"Сколько членов жюри оценивали работу"`
+}
+
+type LeaderboardOutput struct {
+	Body struct {
+		Published   bool                `json:"published" doc:"Опубликованы ли результаты"`
+		Leaderboard []*LeaderboardEntry `json:"leaderboard" doc:"Таблица лидеров"`
+	}
+}
+
+type LeaderboardPreviewInput struct {
+	Urid   string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	CaseId int64  `query:"case_id" example:"1" doc:"Таблица лидеров кейса партнера (если не указан - общий зачет)"`
+	Body   struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type JudgingPublishInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token     string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+		Published bool   `json:"published" doc:"Опубликовать (true) или скрыть (false) результаты"`
+	}
+}
+
+func GetMyAssignments(input *JudgeAssignmentsInput, db *sql.DB) (*JudgeAssignmentsOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+
+	return getJudgeAssignments(user.Email, input.Urid, db)
+}
+
+func GetMyScores(input *ScoresGetInput, db *sql.DB) (*ScoresOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+
+	return getSubmissionScores(input.Id, user.Email, db)
+}
+
+func SetScores(input *ScoresSetInput, db *sql.DB) (*ScoresOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+
+	// Оценивать можно только назначенные работы и работы по кейсу, в жюри которого входишь
+	var eventUri string
+	var assigned, own bool
+	if err := db.QueryRow(
+		"SELECT submissions.event_uri, "+
+			"EXISTS (SELECT 1 FROM judge_assignments WHERE submission_id = submissions.id AND judge_email = $2), "+
+			"EXISTS (SELECT 1 FROM teams_members WHERE teams_members.team_id = submissions.team_id AND teams_members.member_email = $2) "+
+			"FROM submissions INNER JOIN teams ON submissions.team_id = teams.id "+
+			"WHERE submissions.id = $1 AND ("+
+			"EXISTS (SELECT 1 FROM judge_assignments WHERE submission_id = submissions.id AND judge_email = $2) "+
+			"OR teams.case_id IN (SELECT case_id FROM case_judges WHERE judge_email = $2))",
+		input.Id, user.Email).Scan(&eventUri, &assigned, &own); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, huma.Error403Forbidden("Эта работа вам не назначена")
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if own {
+		return nil, huma.Error403Forbidden("Нельзя оценивать работу своей команды")
+	}
+
+	if assigned {
+		if err := checkEventJudge(user.Email, eventUri, db); err != nil {
+			return nil, err
+		}
+	}
+
+	criteria, err := getEventCriteria(eventUri, db)
+	if err != nil {
+		return nil, err
+	}
+
+	byId := make(map[int64]*Criterion)
+	for _, c := range criteria {
+		byId[c.Id] = c
+	}
+
+	// Сначала все проверяем, потом пишем
+	for _, item := range input.Body.Scores {
+		criterion, ok := byId[item.CriterionId]
+		if !ok {
+			return nil, huma.Error422UnprocessableEntity("Критерия " + strconv.FormatInt(item.CriterionId, 10) + " нет в этом событии")
+		}
+		if item.Score < criterion.MinScore || item.Score > criterion.MaxScore {
+			return nil, huma.Error422UnprocessableEntity(
+				"Оценка по критерию \"" + criterion.Name + "\" должна быть от " +
+					strconv.Itoa(criterion.MinScore) + " до " + strconv.Itoa(criterion.MaxScore))
+		}
+	}
+
+	for _, item := range input.Body.Scores {
+		_, err = db.Exec(
+			"INSERT INTO scores (submission_id, judge_email, criterion_id, score, comment) VALUES ($1, $2, $3, $4, $5) "+
+				"ON CONFLICT (submission_id, judge_email, criterion_id) DO UPDATE SET "+
+				"score = EXCLUDED.score, comment = EXCLUDED.comment, updated_at = now()",
+			input.Id, user.Email, item.CriterionId, item.Score, item.Comment,
+		)
+		if err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+	}
+
+	return getSubmissionScores(input.Id, user.Email, db)
+}
+
+func GetAllSubmissionScores(input *ScoresGetInput, db *sql.DB) (*ScoresOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+
+	var eventUri string
+	if err := db.QueryRow("SELECT event_uri FROM submissions WHERE id = $1", input.Id).Scan(&eventUri); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, huma.Error404NotFound("Такой работы нет")
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if err := utils.CheckEventOrganizator(user, eventUri, db); err != nil {
+		return nil, err
+	}
+
+	return getSubmissionScores(input.Id, "", db)
+}
+
+func GetJudgingProgress(input *JudgingEventInput, db *sql.DB) (*JudgingProgressOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	result := new(JudgingProgressOutput)
+
+	if err := db.QueryRow("SELECT COUNT(*) FROM judging_criteria WHERE event_uri = $1", input.Urid).Scan(&result.Body.TotalCriteria); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	// Оценка засчитывается, когда член жюри оценил все критерии работы
+	const completedExpr = "(SELECT COUNT(*) FROM scores WHERE scores.submission_id = judge_assignments.submission_id " +
+		"AND scores.judge_email = judge_assignments.judge_email) >= $2 AND $2 > 0"
+
+	rows, err := db.Query(
+		"SELECT judge_assignments.judge_email, COUNT(*), COUNT(*) FILTER (WHERE "+completedExpr+") "+
+			"FROM judge_assignments INNER JOIN submissions ON judge_assignments.submission_id = submissions.id "+
+			"WHERE submissions.event_uri = $1 GROUP BY judge_assignments.judge_email ORDER BY judge_assignments.judge_email",
+		input.Urid, result.Body.TotalCriteria,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		progress := new(JudgeProgress)
+		if err := rows.Scan(&progress.Judge, &progress.Assigned, &progress.Completed); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		result.Body.Judges = append(result.Body.Judges, progress)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	submissionRows, err := db.Query(
+		"SELECT submissions.id, teams.name, COUNT(judge_assignments.judge_email), "+
+			"COUNT(judge_assignments.judge_email) FILTER (WHERE "+completedExpr+") "+
+			"FROM submissions INNER JOIN teams ON submissions.team_id = teams.id "+
+			"LEFT JOIN judge_assignments ON judge_assignments.submission_id = submissions.id "+
+			"WHERE submissions.event_uri = $1 GROUP BY submissions.id, teams.name ORDER BY submissions.id",
+		input.Urid, result.Body.TotalCriteria,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer submissionRows.Close()
+
+	for submissionRows.Next() {
+		progress := new(SubmissionProgress)
+		if err := submissionRows.Scan(&progress.SubmissionId, &progress.TeamName, &progress.JudgesAssigned, &progress.JudgesCompleted); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		result.Body.Submissions = append(result.Body.Submissions, progress)
+	}
+	if err = submissionRows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
+
+func GetPublicLeaderboard(urid string, caseId int64, token string, db *sql.DB) (*LeaderboardOutput, error) {
+	if err := utils.CheckEventVisible(token, urid, db); err != nil {
+		return nil, err
+	}
+
+	published, err := IsJudgingPublished(urid, db)
+	if err != nil {
+		return nil, err
+	}
+	if !published {
+		return nil, huma.Error403Forbidden("Результаты еще не опубликованы")
+	}
+
+	return getLeaderboardOutput(urid, caseId, published, db)
+}
+
+func GetOrganizatorLeaderboard(input *LeaderboardPreviewInput, db *sql.DB) (*LeaderboardOutput, error) {
+	// Проверить наша ли меро? Таблицу своего кейса видит и жюри партнера
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckCaseAccess(user, input.Urid, input.CaseId, db); err != nil {
+		return nil, err
+	}
+
+	published, err := IsJudgingPublished(input.Urid, db)
+	if err != nil {
+		return nil, err
+	}
+
+	return getLeaderboardOutput(input.Urid, input.CaseId, published, db)
+}
+
+func PublishJudging(input *JudgingPublishInput, db *sql.DB) (*LeaderboardOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec("UPDATE events SET judging_published = $2 WHERE urid = $1", input.Urid, input.Body.Published)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return getLeaderboardOutput(input.Urid, 0, input.Body.Published, db)
+}
+
+func IsJudgingPublished(urid string, db *sql.DB) (bool, error) {
+	var published bool
+	if err := db.QueryRow("SELECT judging_published FROM events WHERE urid = $1", urid).Scan(&published); err != nil {
+		if err == sql.ErrNoRows {
+			return false, huma.Error403Forbidden("Этого события нет XP")
+		}
+		return false, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return published, nil
+}
+
+// Считает таблицу лидеров: по каждому критерию берется средняя оценка жюри,
+// приведенная к шкале 0..1, затем взвешенная сумма делится на сумму всех весов события.
+// Оценки жюри партнеров, которые не входят в жюри события, в общий зачет не идут
+func ComputeLeaderboard(urid string, db *sql.DB) ([]*LeaderboardEntry, error) {
+	return computeLeaderboard(urid, 0, db)
+}
+
+// Таблица лидеров кейса: только команды, выбравшие кейс, и только оценки жюри партнера
+func ComputeCaseLeaderboard(urid string, caseId int64, db *sql.DB) ([]*LeaderboardEntry, error) {
+	return computeLeaderboard(urid, caseId, db)
+}
+
+func computeLeaderboard(urid string, caseId int64, db *sql.DB) ([]*LeaderboardEntry, error) {
+	scoreFilter := "(teams.case_id IS NULL " +
+		"OR scores.judge_email IN (SELECT judge_email FROM event_judges WHERE event_uri = $1) " +
+		"OR scores.judge_email NOT IN (SELECT judge_email FROM case_judges WHERE case_id = teams.case_id))"
+	teamFilter := "$2::bigint = 0"
+	if caseId != 0 {
+		scoreFilter = "scores.judge_email IN (SELECT judge_email FROM case_judges WHERE case_id = $2)"
+		teamFilter = "teams.case_id = $2"
+	}
+
+	rows, err := db.Query(
+		"WITH per_criterion AS ("+
+			"SELECT scores.submission_id, judging_criteria.weight, "+
+			"AVG((scores.score - judging_criteria.min_score)::double precision / (judging_criteria.max_score - judging_criteria.min_score)) AS norm "+
+			"FROM scores INNER JOIN judging_criteria ON scores.criterion_id = judging_criteria.id "+
+			"INNER JOIN submissions ON scores.submission_id = submissions.id "+
+			"INNER JOIN teams ON submissions.team_id = teams.id "+
+			"WHERE judging_criteria.event_uri = $1 AND "+scoreFilter+" "+
+			"GROUP BY scores.submission_id, judging_criteria.id, judging_criteria.weight"+
+			"), total_weight AS (SELECT SUM(weight) AS w FROM judging_criteria WHERE event_uri = $1) "+
+			"SELECT submissions.id, submissions.team_id, teams.name, submissions.title, "+
+			"COALESCE(SUM(per_criterion.weight * per_criterion.norm) / NULLIF((SELECT w FROM total_weight), 0) * 100, 0) AS score, "+
+			"(SELECT COUNT(DISTINCT judge_email) FROM scores WHERE scores.submission_id = submissions.id AND "+scoreFilter+") AS judges "+
+			"FROM submissions INNER JOIN teams ON submissions.team_id = teams.id "+
+			"LEFT JOIN per_criterion ON per_criterion.submission_id = submissions.id "+
+			"WHERE submissions.event_uri = $1 AND "+teamFilter+" "+
+			"GROUP BY submissions.id, submissions.team_id, teams.name, submissions.title "+
+			"ORDER BY score DESC, submissions.id",
+		urid, caseId,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	var result []*LeaderboardEntry
+
+	for rows.Next() {
+		entry := new(LeaderboardEntry)
+		if err := rows.Scan(&entry.SubmissionId, &entry.TeamId, &entry.TeamName, &entry.Title, &entry.Score, &entry.Judges); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+
+		// Одинаковый балл - одинаковое место
+		entry.Place = len(result) + 1
+		if len(result) > 0 && result[len(result)-1].Score == entry.Score {
+			entry.Place = result[len(result)-1].Place
+		}
+
+		result = append(result, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
+
+func getLeaderboardOutput(urid string, caseId int64, published bool, db *sql.DB) (*LeaderboardOutput, error) {
+	leaderboard, err := computeLeaderboard(urid, caseId, db)
+	if err != nil {
+		return nil, err
+	}
+
+	result := new(LeaderboardOutput)
+	result.Body.Published = published
+	result.Body.Leaderboard = leaderboard
+
+	return result, nil
+}
+
+func getJudgeAssignments(judge string, urid string, db *sql.DB) (*JudgeAssignmentsOutput, error) {
+	rows, err := db.Query(
+		"SELECT submissions.id, submissions.event_uri, teams.name, submissions.title, "+
+			"(SELECT COUNT(*) FROM scores WHERE scores.submission_id = submissions.id AND scores.judge_email = $1), "+
+			"(SELECT COUNT(*) FROM judging_criteria WHERE judging_criteria.event_uri = submissions.event_uri) "+
+			"FROM submissions INNER JOIN teams ON submissions.team_id = teams.id "+
+			"WHERE (submissions.id IN (SELECT submission_id FROM judge_assignments WHERE judge_email = $1) "+
+			"OR teams.case_id IN (SELECT case_id FROM case_judges WHERE judge_email = $1)) "+
+			"AND ($2 = '' OR submissions.event_uri = $2) "+
+			"ORDER BY submissions.id",
+		judge, urid,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	result := new(JudgeAssignmentsOutput)
+	result.Body.Judge = judge
+
+	for rows.Next() {
+		assignment := new(JudgeAssignment)
+		if err := rows.Scan(
+			&assignment.SubmissionId,
+			&assignment.EventUri,
+			&assignment.TeamName,
+			&assignment.Title,
+			&assignment.ScoredCriteria,
+			&assignment.TotalCriteria,
+		); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		result.Body.Assignments = append(result.Body.Assignments, assignment)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
+
+// Если judge пустой - возвращаются оценки всех членов жюри
+func getSubmissionScores(submissionId int64, judge string, db *sql.DB) (*ScoresOutput, error) {
+	rows, err := db.Query(
+		"SELECT judge_email, criterion_id, score, comment, updated_at FROM scores "+
+			"WHERE submission_id = $1 AND ($2 = '' OR judge_email = $2) ORDER BY judge_email, criterion_id",
+		submissionId, judge,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	result := new(ScoresOutput)
+	result.Body.SubmissionId = submissionId
+
+	for rows.Next() {
+		score := new(JudgeScore)
+		var comment sql.NullString
+		if err := rows.Scan(&score.Judge, &score.CriterionId, &score.Score, &comment, &score.UpdatedAt); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		score.Comment = comment.String
+
+		result.Body.Scores = append(result.Body.Scores, score)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
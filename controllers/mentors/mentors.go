@@ -0,0 +1,237 @@
+package mentors
+
+import (
+	"database/sql"
+	"hackaton-jam-back/controllers/utils"
+	"strings"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/lib/pq"
+)
+
+type Mentor struct {
+	Email     string   `json:"email" example:"mentor@ya.ru" doc:"E-mail ментора"`
+	Username  string   `json:"username" example:"thatmaidguy" doc:"Имя пользователя"`
+	Expertise []string `json:"expertise" doc:"В чем может помочь"`
+	Bio       string   `json:"bio,omitempty" doc:"О себе"`
+	FreeSlots int      `json:"free_slots" example:"3" doc:"Свободных предстоящих слотов"`
+}
+
+type Settings struct {
+	BookingLimit int `json:"booking_limit" example:"2" doc:"Сколько предстоящих встреч может быть у одной команды одновременно"`
+	CancelHours  int `json:"cancel_hours" example:"2" doc:"За сколько часов до начала команда еще может отменить встречу"`
+}
+
+type MentorsInput struct {
+	Urid      string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Expertise string `query:"expertise" example:"Go" doc:"Только менторы с этой экспертизой"`
+}
+
+type MentorAddInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+
+		Email     string   `json:"email" example:"mentor@ya.ru" doc:"E-mail ментора (должен быть зарегистрирован)"`
+		Expertise []string `json:"expertise,omitempty" maxItems:"20" doc:"В чем может помочь"`
+		Bio       string   `json:"bio,omitempty" maxLength:"1000" doc:"О себе"`
+	}
+}
+
+type MentorDelInput struct {
+	Urid  string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Email string `path:"email" example:"mentor@ya.ru" doc:"E-mail ментора"`
+	Body  struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type SettingsEditInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token        string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+		BookingLimit int    `json:"booking_limit" minimum:"1" maximum:"50" example:"2" doc:"Сколько предстоящих встреч может быть у одной команды одновременно"`
+		CancelHours  int    `json:"cancel_hours" minimum:"0" maximum:"168" example:"2" doc:"За сколько часов до начала команда еще может отменить встречу"`
+	}
+}
+
+type MentorsOutput struct {
+	Body struct {
+		Settings Settings  `json:"settings" doc:"Правила записи"`
+		Mentors  []*Mentor `json:"mentors" doc:"Менторы мероприятия"`
+	}
+}
+
+func GetMentors(input *MentorsInput, db *sql.DB) (*MentorsOutput, error) {
+	return getMentors(input.Urid, input.Expertise, db)
+}
+
+// Добавляет ментора или обновляет его экспертизу
+func AddMentor(input *MentorAddInput, db *sql.DB) (*MentorsOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	if _, err := utils.GetUserUsernameByEmail(input.Body.Email, db); err != nil {
+		return nil, huma.Error404NotFound("Пользователь не найден")
+	}
+
+	expertise := []string{}
+	seen := make(map[string]bool)
+	for _, tag := range input.Body.Expertise {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[strings.ToLower(tag)] {
+			continue
+		}
+		seen[strings.ToLower(tag)] = true
+		expertise = append(expertise, tag)
+	}
+
+	_, err = db.Exec(
+		"INSERT INTO event_mentors (event_uri, mentor_email, expertise, bio) VALUES ($1, $2, $3, $4) "+
+			"ON CONFLICT (event_uri, mentor_email) DO UPDATE SET expertise = EXCLUDED.expertise, bio = EXCLUDED.bio",
+		input.Urid, input.Body.Email, pq.Array(expertise), input.Body.Bio,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return getMentors(input.Urid, "", db)
+}
+
+// Удаляет ментора вместе с его слотами. Записавшиеся команды получают уведомление об отмене
+func DelMentor(input *MentorDelInput, db *sql.DB) (*MentorsOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT id FROM mentor_slots WHERE event_uri = $1 AND mentor_email = $2", input.Urid, input.Email)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	var slots []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		slots = append(slots, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	for _, id := range slots {
+		if err := deleteSlot(id, user.Email, db); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := db.Exec("DELETE FROM event_mentors WHERE event_uri = $1 AND mentor_email = $2", input.Urid, input.Email)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, huma.Error404NotFound("Ментор не найден")
+	}
+
+	return getMentors(input.Urid, "", db)
+}
+
+func EditSettings(input *SettingsEditInput, db *sql.DB) (*MentorsOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(
+		"UPDATE events SET mentor_booking_limit = $2, mentor_cancel_hours = $3 WHERE urid = $1",
+		input.Urid, input.Body.BookingLimit, input.Body.CancelHours,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return getMentors(input.Urid, "", db)
+}
+
+func isMentor(email string, urid string, db *sql.DB) (bool, error) {
+	var exists bool
+	if err := db.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM event_mentors WHERE event_uri = $1 AND mentor_email = $2)", urid, email,
+	).Scan(&exists); err != nil {
+		return false, huma.Error422UnprocessableEntity(err.Error())
+	}
+	return exists, nil
+}
+
+func getSettings(urid string, db *sql.DB) (*Settings, error) {
+	settings := new(Settings)
+	if err := db.QueryRow(
+		"SELECT mentor_booking_limit, mentor_cancel_hours FROM events WHERE urid = $1", urid,
+	).Scan(&settings.BookingLimit, &settings.CancelHours); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, huma.Error404NotFound("Этого события нет XP")
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	return settings, nil
+}
+
+func getMentors(urid string, expertise string, db *sql.DB) (*MentorsOutput, error) {
+	settings, err := getSettings(urid, db)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(
+		"SELECT event_mentors.mentor_email, users.username, event_mentors.expertise, event_mentors.bio, "+
+			"(SELECT COUNT(*) FROM mentor_slots WHERE mentor_slots.event_uri = event_mentors.event_uri "+
+			"AND mentor_slots.mentor_email = event_mentors.mentor_email AND mentor_slots.team_id IS NULL AND mentor_slots.start_time > now()) "+
+			"FROM event_mentors JOIN users ON users.email = event_mentors.mentor_email "+
+			"WHERE event_mentors.event_uri = $1 "+
+			"AND ($2::text = '' OR EXISTS (SELECT 1 FROM unnest(event_mentors.expertise) AS tag WHERE lower(tag) = lower($2))) "+
+			"ORDER BY users.username",
+		urid, expertise,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	result := new(MentorsOutput)
+	result.Body.Settings = *settings
+	result.Body.Mentors = []*Mentor{}
+
+	for rows.Next() {
+		mentor := new(Mentor)
+		var bio sql.NullString
+		if err := rows.Scan(&mentor.Email, &mentor.Username, pq.Array(&mentor.Expertise), &bio, &mentor.FreeSlots); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		mentor.Bio = bio.String
+
+		result.Body.Mentors = append(result.Body.Mentors, mentor)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
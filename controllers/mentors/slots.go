@@ -0,0 +1,548 @@
+package mentors
+
+import (
+	"database/sql"
+	"hackaton-jam-back/controllers/utils"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/lib/pq"
+)
+
+// За сколько до начала встречи приходит напоминание
+const ReminderBefore = 30 * time.Minute
+
+type Slot struct {
+	Id         int64     `json:"id" example:"1" doc:"Идентификатор слота"`
+	Mentor     string    `json:"mentor" example:"mentor@ya.ru" doc:"E-mail ментора"`
+	MentorName string    `json:"mentor_name" example:"thatmaidguy" doc:"Имя пользователя ментора"`
+	StartTime  time.Time `json:"start_time" doc:"Начало встречи"`
+	EndTime    time.Time `json:"end_time" doc:"Конец встречи"`
+	Location   string    `json:"location,omitempty" example:"Аудитория 101" doc:"Где проходит встреча (аудитория или ссылка)"`
+	Booked     bool      `json:"booked" doc:"Занят ли слот"`
+	TeamId     int64     `json:"team_id,omitempty" example:"2" doc:"Записавшаяся команда"`
+	TeamName   string    `json:"team_name,omitempty" example:"Супер-команда" doc:"Название записавшейся команды"`
+}
+
+type Session struct {
+	Slot
+	EventUrid string   `json:"event_urid" example:"example_events" doc:"Ссылка на мероприятие"`
+	EventName string   `json:"event_name" example:"Example GameJam" doc:"Название мероприятия"`
+	Members   []string `json:"members,omitempty" doc:"Участники записавшейся команды"`
+}
+
+type SlotsInput struct {
+	Urid      string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Mentor    string `query:"mentor" example:"mentor@ya.ru" doc:"Только слоты этого ментора"`
+	Expertise string `query:"expertise" example:"Go" doc:"Только слоты менторов с этой экспертизой"`
+	FreeOnly  bool   `query:"free_only" doc:"Только свободные"`
+}
+
+type SlotAddInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+
+		Mentor    string    `json:"mentor,omitempty" example:"mentor@ya.ru" doc:"Ментор (для организаторов; по умолчанию - сам пользователь)"`
+		StartTime time.Time `json:"start_time" doc:"Начало встречи"`
+		Duration  int       `json:"duration,omitempty" minimum:"0" maximum:"240" example:"30" doc:"Длительность в минутах (по умолчанию 30)"`
+		Location  string    `json:"location,omitempty" maxLength:"255" example:"Аудитория 101" doc:"Где проходит встреча (аудитория или ссылка)"`
+	}
+}
+
+type SlotInput struct {
+	Id   int64 `path:"id" example:"1" doc:"Идентификатор слота"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type SlotsOutput struct {
+	Body struct {
+		Slots []*Slot `json:"slots" doc:"Слоты по времени"`
+	}
+}
+
+type SlotOutput struct {
+	Body Slot
+}
+
+type SessionsOutput struct {
+	Body struct {
+		Sessions []*Session `json:"sessions" doc:"Предстоящие слоты ментора по всем мероприятиям"`
+	}
+}
+
+func GetSlots(input *SlotsInput, db *sql.DB) (*SlotsOutput, error) {
+	rows, err := db.Query(
+		"SELECT mentor_slots.id, mentor_slots.mentor_email, users.username, mentor_slots.start_time, mentor_slots.end_time, "+
+			"mentor_slots.location, mentor_slots.team_id, teams.name "+
+			"FROM mentor_slots JOIN users ON users.email = mentor_slots.mentor_email "+
+			"LEFT JOIN teams ON teams.id = mentor_slots.team_id "+
+			"WHERE mentor_slots.event_uri = $1 AND mentor_slots.end_time > now() "+
+			"AND ($2::text = '' OR mentor_slots.mentor_email = $2) "+
+			"AND ($3::text = '' OR EXISTS (SELECT 1 FROM event_mentors, unnest(event_mentors.expertise) AS tag "+
+			"WHERE event_mentors.event_uri = mentor_slots.event_uri AND event_mentors.mentor_email = mentor_slots.mentor_email "+
+			"AND lower(tag) = lower($3))) "+
+			"AND ($4 = false OR mentor_slots.team_id IS NULL) "+
+			"ORDER BY mentor_slots.start_time, mentor_slots.id",
+		input.Urid, input.Mentor, input.Expertise, input.FreeOnly,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	result := new(SlotsOutput)
+	result.Body.Slots = []*Slot{}
+
+	for rows.Next() {
+		slot := new(Slot)
+		var location, teamName sql.NullString
+		var teamId sql.NullInt64
+		if err := rows.Scan(
+			&slot.Id, &slot.Mentor, &slot.MentorName, &slot.StartTime, &slot.EndTime, &location, &teamId, &teamName,
+		); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		slot.Location = location.String
+		slot.Booked = teamId.Valid
+		slot.TeamId = teamId.Int64
+		slot.TeamName = teamName.String
+
+		result.Body.Slots = append(result.Body.Slots, slot)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
+
+// Ментор добавляет себе слот, организатор - любому ментору мероприятия
+func AddSlot(input *SlotAddInput, db *sql.DB) (*SlotsOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+
+	mentor := input.Body.Mentor
+	if mentor == "" || mentor == user.Email {
+		mentor = user.Email
+	} else if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+	ok, err := isMentor(mentor, input.Urid, db)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, huma.Error403Forbidden("Это не ментор мероприятия")
+	}
+
+	duration := input.Body.Duration
+	if duration == 0 {
+		duration = 30
+	}
+	if duration < 10 {
+		return nil, huma.Error422UnprocessableEntity("Встреча должна длиться хотя бы 10 минут")
+	}
+	start := input.Body.StartTime
+	end := start.Add(time.Duration(duration) * time.Minute)
+
+	var eventStart, eventEnd time.Time
+	if err := db.QueryRow("SELECT start_time, end_time FROM events WHERE urid = $1", input.Urid).Scan(&eventStart, &eventEnd); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if !start.After(time.Now()) {
+		return nil, huma.Error422UnprocessableEntity("Слот должен быть в будущем")
+	}
+	if start.Before(eventStart) || end.After(eventEnd) {
+		return nil, huma.Error422UnprocessableEntity("Слот должен быть во время мероприятия")
+	}
+
+	// Ментор не может быть в двух местах сразу, даже на разных мероприятиях
+	var overlaps bool
+	if err := db.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM mentor_slots WHERE mentor_email = $1 AND start_time < $3 AND end_time > $2)",
+		mentor, start, end,
+	).Scan(&overlaps); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if overlaps {
+		return nil, huma.Error409Conflict("Слот пересекается с другим слотом ментора")
+	}
+
+	_, err = db.Exec(
+		"INSERT INTO mentor_slots (event_uri, mentor_email, start_time, end_time, location) VALUES ($1, $2, $3, $4, $5)",
+		input.Urid, mentor, start, end, input.Body.Location,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return GetSlots(&SlotsInput{Urid: input.Urid, Mentor: mentor}, db)
+}
+
+// Ментор или организатор убирает слот. Если на него записана команда, ей придет уведомление об отмене
+func DelSlot(input *SlotInput, db *sql.DB) (*SlotsOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+
+	var urid, mentor string
+	if err := db.QueryRow("SELECT event_uri, mentor_email FROM mentor_slots WHERE id = $1", input.Id).Scan(&urid, &mentor); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, huma.Error404NotFound("Слот не найден")
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if mentor != user.Email {
+		if err := utils.CheckEventOrganizator(user, urid, db); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := deleteSlot(input.Id, user.Email, db); err != nil {
+		return nil, err
+	}
+
+	return GetSlots(&SlotsInput{Urid: urid, Mentor: mentor}, db)
+}
+
+// Записаться может только тимлид, и не больше лимита предстоящих встреч на команду
+func BookSlot(input *SlotInput, db *sql.DB) (*SlotOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+
+	slot, urid, err := getSlot(input.Id, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var teamId int64
+	if err := db.QueryRow("SELECT id FROM teams WHERE event_uri = $1 AND teamleader = $2", urid, user.Email).Scan(&teamId); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, huma.Error403Forbidden("Записать команду к ментору может только тимлид")
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	if slot.Booked {
+		return nil, huma.Error409Conflict("Слот уже занят")
+	}
+	if !slot.StartTime.After(time.Now()) {
+		return nil, huma.Error403Forbidden("Слот уже прошел")
+	}
+
+	settings, err := getSettings(urid, db)
+	if err != nil {
+		return nil, err
+	}
+
+	// Строка команды блокируется до конца транзакции, чтобы параллельные записи
+	// не прошли проверку лимита одновременно
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SELECT 1 FROM teams WHERE id = $1 FOR UPDATE", teamId); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	var upcoming int
+	var overlaps bool
+	if err := tx.QueryRow(
+		"SELECT COUNT(*) FILTER (WHERE start_time > now()), "+
+			"COUNT(*) FILTER (WHERE start_time < $3 AND end_time > $2) > 0 "+
+			"FROM mentor_slots WHERE team_id = $1",
+		teamId, slot.StartTime, slot.EndTime,
+	).Scan(&upcoming, &overlaps); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if upcoming >= settings.BookingLimit {
+		return nil, huma.Error403Forbidden("У команды уже " + strconv.Itoa(upcoming) + " предстоящих встреч с менторами, больше нельзя")
+	}
+	if overlaps {
+		return nil, huma.Error409Conflict("У команды уже есть встреча в это время")
+	}
+
+	// Слот могли занять, пока мы проверяли
+	res, err := tx.Exec(
+		"UPDATE mentor_slots SET team_id = $2, booked_by = $3, booked_at = now(), reminded = false "+
+			"WHERE id = $1 AND team_id IS NULL",
+		input.Id, teamId, user.Email,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, huma.Error409Conflict("Слот уже занят")
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	// Кидаем ментору уведомление о записи (10)
+	if err := notify([]string{slot.Mentor}, 10, user.Email, urid, teamId, slotText(slot), db); err != nil {
+		return nil, err
+	}
+
+	slot, _, err = getSlot(input.Id, db)
+	if err != nil {
+		return nil, err
+	}
+	return &SlotOutput{Body: *slot}, nil
+}
+
+// Тимлид может отменить запись не позже чем за cancel_hours до начала, организатор - в любое время
+func CancelBooking(input *SlotInput, db *sql.DB) (*SlotOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+
+	slot, urid, err := getSlot(input.Id, db)
+	if err != nil {
+		return nil, err
+	}
+	if !slot.Booked {
+		return nil, huma.Error409Conflict("На слот никто не записан")
+	}
+
+	var teamleader string
+	if err := db.QueryRow("SELECT teamleader FROM teams WHERE id = $1", slot.TeamId).Scan(&teamleader); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	if teamleader == user.Email {
+		settings, err := getSettings(urid, db)
+		if err != nil {
+			return nil, err
+		}
+		if time.Now().Add(time.Duration(settings.CancelHours) * time.Hour).After(slot.StartTime) {
+			return nil, huma.Error403Forbidden("Отменить встречу можно не позже чем за " + strconv.Itoa(settings.CancelHours) + " ч. до начала")
+		}
+	} else if err := utils.CheckEventOrganizator(user, urid, db); err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(
+		"UPDATE mentor_slots SET team_id = NULL, booked_by = NULL, booked_at = NULL, reminded = false WHERE id = $1",
+		input.Id,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	// Кидаем ментору уведомление об отмене (11)
+	if err := notify([]string{slot.Mentor}, 11, user.Email, urid, slot.TeamId, slotText(slot), db); err != nil {
+		return nil, err
+	}
+
+	slot, _, err = getSlot(input.Id, db)
+	if err != nil {
+		return nil, err
+	}
+	return &SlotOutput{Body: *slot}, nil
+}
+
+// Предстоящие слоты ментора: и занятые, и свободные
+func GetMentorSessions(input *utils.JustAccessTokenInput, db *sql.DB) (*SessionsOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+
+	rows, err := db.Query(
+		"SELECT mentor_slots.id, mentor_slots.start_time, mentor_slots.end_time, mentor_slots.location, "+
+			"mentor_slots.team_id, teams.name, events.urid, events.name, "+
+			"ARRAY(SELECT member_email FROM teams_members WHERE teams_members.team_id = mentor_slots.team_id AND teams_members.pending = false ORDER BY member_email) "+
+			"FROM mentor_slots JOIN events ON events.urid = mentor_slots.event_uri "+
+			"LEFT JOIN teams ON teams.id = mentor_slots.team_id "+
+			"WHERE mentor_slots.mentor_email = $1 AND mentor_slots.end_time > now() "+
+			"ORDER BY mentor_slots.start_time",
+		user.Email,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	result := new(SessionsOutput)
+	result.Body.Sessions = []*Session{}
+
+	for rows.Next() {
+		session := new(Session)
+		var location, teamName sql.NullString
+		var teamId sql.NullInt64
+		if err := rows.Scan(
+			&session.Id, &session.StartTime, &session.EndTime, &location, &teamId, &teamName,
+			&session.EventUrid, &session.EventName, pq.Array(&session.Members),
+		); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		session.Mentor = user.Email
+		session.MentorName = user.Username
+		session.Location = location.String
+		session.Booked = teamId.Valid
+		session.TeamId = teamId.Int64
+		session.TeamName = teamName.String
+
+		result.Body.Sessions = append(result.Body.Sessions, session)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
+
+// Напоминает ментору и команде о скорой встрече (12). Каждый слот - один раз
+func SendReminders(db *sql.DB) error {
+	rows, err := db.Query(
+		"UPDATE mentor_slots SET reminded = true "+
+			"WHERE team_id IS NOT NULL AND reminded = false AND start_time > now() AND start_time <= $1 "+
+			"RETURNING id",
+		time.Now().Add(ReminderBefore),
+	)
+	if err != nil {
+		return err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		slot, urid, err := getSlot(id, db)
+		if err != nil {
+			return err
+		}
+
+		var teamleader string
+		if err := db.QueryRow("SELECT teamleader FROM teams WHERE id = $1", slot.TeamId).Scan(&teamleader); err != nil {
+			return err
+		}
+		members, err := getTeamMembers(slot.TeamId, db)
+		if err != nil {
+			return err
+		}
+
+		if err := notify([]string{slot.Mentor}, 12, teamleader, urid, slot.TeamId, slotText(slot), db); err != nil {
+			return err
+		}
+		if err := notify(members, 12, slot.Mentor, urid, slot.TeamId, slotText(slot), db); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Раз в interval рассылает напоминания о встречах
+func RunReminders(db *sql.DB, interval time.Duration) {
+	for {
+		if err := SendReminders(db); err != nil {
+			log.Printf("Напоминания о встречах с менторами: %v", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+func deleteSlot(id int64, from string, db *sql.DB) error {
+	slot, urid, err := getSlot(id, db)
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.Exec("DELETE FROM mentor_slots WHERE id = $1", id); err != nil {
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	// Кидаем команде уведомление об отмене (11), если встреча еще не прошла
+	if slot.Booked && slot.StartTime.After(time.Now()) {
+		members, err := getTeamMembers(slot.TeamId, db)
+		if err != nil {
+			return huma.Error422UnprocessableEntity(err.Error())
+		}
+		if err := notify(members, 11, from, urid, slot.TeamId, slotText(slot), db); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getSlot(id int64, db *sql.DB) (*Slot, string, error) {
+	slot := new(Slot)
+	var urid string
+	var location, teamName sql.NullString
+	var teamId sql.NullInt64
+	if err := db.QueryRow(
+		"SELECT mentor_slots.id, mentor_slots.event_uri, mentor_slots.mentor_email, users.username, "+
+			"mentor_slots.start_time, mentor_slots.end_time, mentor_slots.location, mentor_slots.team_id, teams.name "+
+			"FROM mentor_slots JOIN users ON users.email = mentor_slots.mentor_email "+
+			"LEFT JOIN teams ON teams.id = mentor_slots.team_id WHERE mentor_slots.id = $1",
+		id,
+	).Scan(
+		&slot.Id, &urid, &slot.Mentor, &slot.MentorName, &slot.StartTime, &slot.EndTime, &location, &teamId, &teamName,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, "", huma.Error404NotFound("Слот не найден")
+		}
+		return nil, "", huma.Error422UnprocessableEntity(err.Error())
+	}
+	slot.Location = location.String
+	slot.Booked = teamId.Valid
+	slot.TeamId = teamId.Int64
+	slot.TeamName = teamName.String
+
+	return slot, urid, nil
+}
+
+func getTeamMembers(teamId int64, db *sql.DB) ([]string, error) {
+	var members []string
+	if err := db.QueryRow(
+		"SELECT ARRAY(SELECT member_email FROM teams_members WHERE team_id = $1 AND pending = false)", teamId,
+	).Scan(pq.Array(&members)); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func notify(recipients []string, notifyType int, from string, urid string, teamId int64, text string, db *sql.DB) error {
+	_, err := db.Exec(
+		"INSERT INTO notifications (\"user\", team_id, type, \"from\", event_uri, \"text\") "+
+			"SELECT unnest($1::varchar[]), $2, $3, $4, $5, $6",
+		pq.Array(recipients), teamId, notifyType, from, urid, text,
+	)
+	if err != nil {
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+	return nil
+}
+
+func slotText(slot *Slot) string {
+	text := "Встреча с ментором " + slot.MentorName + " " + slot.StartTime.Format("02.01.2006 15:04 MST")
+	if slot.Location != "" {
+		text += ", " + slot.Location
+	}
+	return text
+}
@@ -0,0 +1,280 @@
+package moderation
+
+import (
+	"database/sql"
+	"hackaton-jam-back/controllers/series"
+	"hackaton-jam-back/controllers/utils"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/lib/pq"
+)
+
+// Статусы модерации мероприятия
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+)
+
+type ModerationQueueInput struct {
+	Body struct {
+		Token  string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+		Status string `json:"status,omitempty" enum:"pending,rejected" default:"pending" doc:"Какие мероприятия показать"`
+	}
+}
+
+type ModerationDecisionInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token  string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+		Reason string `json:"reason,omitempty" example:"Тестовое мероприятие" doc:"Причина (обязательна при отклонении)"`
+	}
+}
+
+type TrustedOrganizerInput struct {
+	Email string `path:"email" example:"thatmaidguy@ya.ru" doc:"E-mail организатора"`
+	Body  struct {
+		Token   string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+		Trusted bool   `json:"trusted" doc:"Публиковать мероприятия организатора без модерации"`
+	}
+}
+
+type QueuedEvent struct {
+	Urid         string    `json:"urid" example:"example_events" doc:"Ссылка на мероприятие"`
+	Name         string    `json:"name" example:"Example GameJam" doc:"Название мероприятия"`
+	StartTime    time.Time `json:"start_time" doc:"Начало проведения"`
+	Organizators []string  `json:"organizators" doc:"E-mail организаторов"`
+	Status       string    `json:"status" example:"pending" doc:"Статус модерации"`
+	Reason       string    `json:"reason,omitempty" doc:"Причина отклонения"`
+	ModeratedBy  string    `json:"moderated_by,omitempty" example:"admin@ya.ru" doc:"Кто принял решение"`
+	RequestedAt  time.Time `json:"requested_at" doc:"Когда попало на модерацию"`
+}
+
+type ModerationQueueOutput struct {
+	Body struct {
+		Events []*QueuedEvent `json:"events" doc:"Мероприятия (старые сверху)"`
+	}
+}
+
+type ModerationDecisionOutput struct {
+	Body struct {
+		Urid   string `json:"urid" example:"example_events" doc:"Ссылка на мероприятие"`
+		Status string `json:"status" example:"approved" doc:"Новый статус модерации"`
+		Reason string `json:"reason,omitempty" doc:"Причина отклонения"`
+	}
+}
+
+type TrustedOrganizerOutput struct {
+	Body struct {
+		Email   string `json:"email" example:"thatmaidguy@ya.ru" doc:"E-mail организатора"`
+		Trusted bool   `json:"trusted" doc:"Доверенный организатор"`
+	}
+}
+
+// Статус нового мероприятия: админы и доверенные организаторы обходят модерацию
+func InitialStatus(user *utils.UserEmail, db *sql.DB) (string, error) {
+	trusted, err := isTrusted(user, db)
+	if err != nil {
+		return "", err
+	}
+	if trusted {
+		return StatusApproved, nil
+	}
+	return StatusPending, nil
+}
+
+// Отправляет мероприятие на повторную модерацию после существенных изменений
+func Requeue(user *utils.UserEmail, urid string, db *sql.DB) error {
+	trusted, err := isTrusted(user, db)
+	if err != nil || trusted {
+		return err
+	}
+
+	_, err = db.Exec(
+		"UPDATE events SET moderation_status = $2, moderation_reason = NULL, moderated_by = NULL, moderation_requested_at = now() "+
+			"WHERE urid = $1",
+		urid, StatusPending,
+	)
+	if err != nil {
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+	return nil
+}
+
+func GetQueue(input *ModerationQueueInput, db *sql.DB) (*ModerationQueueOutput, error) {
+	if _, err := checkAdmin(input.Body.Token, db); err != nil {
+		return nil, err
+	}
+
+	status := input.Body.Status
+	if status == "" {
+		status = StatusPending
+	}
+
+	// Черновики не модерируются, пока их не опубликуют
+	rows, err := db.Query(
+		"SELECT urid, name, start_time, moderation_status, moderation_reason, moderated_by, moderation_requested_at, "+
+			"ARRAY(SELECT organizator_email FROM event_orgs WHERE event_orgs.event_uri = events.urid) "+
+			"FROM events WHERE moderation_status = $1 AND is_draft = false ORDER BY moderation_requested_at",
+		status,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	result := new(ModerationQueueOutput)
+
+	for rows.Next() {
+		event := new(QueuedEvent)
+		var reason sql.NullString
+		var moderatedBy sql.NullString
+		if err := rows.Scan(
+			&event.Urid,
+			&event.Name,
+			&event.StartTime,
+			&event.Status,
+			&reason,
+			&moderatedBy,
+			&event.RequestedAt,
+			pq.Array(&event.Organizators),
+		); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		event.Reason = reason.String
+		event.ModeratedBy = moderatedBy.String
+
+		result.Body.Events = append(result.Body.Events, event)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
+
+func ApproveEvent(input *ModerationDecisionInput, db *sql.DB) (*ModerationDecisionOutput, error) {
+	admin, err := checkAdmin(input.Body.Token, db)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := setStatus(input.Urid, StatusApproved, "", admin.Email, db); err != nil {
+		return nil, err
+	}
+
+	// Кидаем уведомление об одобрении (8)
+	if err := notifyOrganizators(input.Urid, 8, admin.Email, "", db); err != nil {
+		return nil, err
+	}
+
+	// Подписчики серии узнают о выпуске только после одобрения
+	if err := series.NotifyNewEdition(input.Urid, admin.Email, db); err != nil {
+		return nil, err
+	}
+
+	result := new(ModerationDecisionOutput)
+	result.Body.Urid = input.Urid
+	result.Body.Status = StatusApproved
+	return result, nil
+}
+
+func RejectEvent(input *ModerationDecisionInput, db *sql.DB) (*ModerationDecisionOutput, error) {
+	if input.Body.Reason == "" {
+		return nil, huma.Error422UnprocessableEntity("Нужно указать причину отклонения")
+	}
+
+	admin, err := checkAdmin(input.Body.Token, db)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := setStatus(input.Urid, StatusRejected, input.Body.Reason, admin.Email, db); err != nil {
+		return nil, err
+	}
+
+	// Кидаем уведомление об отклонении с причиной (7)
+	if err := notifyOrganizators(input.Urid, 7, admin.Email, input.Body.Reason, db); err != nil {
+		return nil, err
+	}
+
+	result := new(ModerationDecisionOutput)
+	result.Body.Urid = input.Urid
+	result.Body.Status = StatusRejected
+	result.Body.Reason = input.Body.Reason
+	return result, nil
+}
+
+func SetTrustedOrganizer(input *TrustedOrganizerInput, db *sql.DB) (*TrustedOrganizerOutput, error) {
+	if _, err := checkAdmin(input.Body.Token, db); err != nil {
+		return nil, err
+	}
+
+	res, err := db.Exec("UPDATE users SET is_trusted = $2 WHERE email = $1 AND perms = 1", input.Email, input.Body.Trusted)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, huma.Error404NotFound("Организатор не найден")
+	}
+
+	result := new(TrustedOrganizerOutput)
+	result.Body.Email = input.Email
+	result.Body.Trusted = input.Body.Trusted
+	return result, nil
+}
+
+func setStatus(urid string, status string, reason string, admin string, db *sql.DB) error {
+	var moderationReason sql.NullString
+	if reason != "" {
+		moderationReason = sql.NullString{String: reason, Valid: true}
+	}
+
+	res, err := db.Exec(
+		"UPDATE events SET moderation_status = $2, moderation_reason = $3, moderated_by = $4 WHERE urid = $1",
+		urid, status, moderationReason, admin,
+	)
+	if err != nil {
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return huma.Error404NotFound("Этого события нет XP")
+	}
+	return nil
+}
+
+func notifyOrganizators(urid string, notifyType int, from string, text string, db *sql.DB) error {
+	_, err := db.Exec(
+		"INSERT INTO notifications (\"user\", type, \"from\", event_uri, \"text\") "+
+			"SELECT organizator_email, $2, $3, $1, $4 FROM event_orgs WHERE event_uri = $1",
+		urid, notifyType, from, text,
+	)
+	if err != nil {
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+	return nil
+}
+
+func isTrusted(user *utils.UserEmail, db *sql.DB) (bool, error) {
+	if user.Perms == 10 {
+		return true, nil
+	}
+
+	var trusted bool
+	if err := db.QueryRow("SELECT is_trusted FROM users WHERE email = $1", user.Email).Scan(&trusted); err != nil {
+		return false, huma.Error422UnprocessableEntity(err.Error())
+	}
+	return trusted, nil
+}
+
+func checkAdmin(token string, db *sql.DB) (*utils.UserEmail, error) {
+	user, err := utils.GetUserEmailByToken(token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if user.Perms != 10 {
+		return nil, huma.Error403Forbidden("Модерация доступна только администраторам")
+	}
+	return user, nil
+}
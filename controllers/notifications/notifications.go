@@ -3,25 +3,52 @@ package notifications
 import (
 	"database/sql"
 	"hackaton-jam-back/controllers/utils"
+	"strconv"
+	"time"
 
 	"github.com/danielgtaylor/huma/v2"
 )
 
 type Notify struct {
-	NotifyType int                  `json:"notify_type" example:"0" doc:"Тип уведомления (0 - приглашение в команду, 1 - отклонение приглашения, 2 - принятие приглашения, 3 - при кике с команды)"`
+	NotifyType int                  `json:"notify_type" example:"0" doc:"Тип уведомления (0 - приглашение в команду, 1 - отклонение приглашения, 2 - принятие приглашения, 3 - при кике с команды, 4 - команда награждена по итогам мероприятия, 5 - анонсирован новый выпуск серии, 6 - объявление организаторов, 7 - мероприятие отклонено модератором, 8 - мероприятие одобрено модератором, 9 - открыта анкета обратной связи, 10 - команда записалась к ментору, 11 - встреча с ментором отменена, 12 - напоминание о встрече с ментором, 13 - организаторы ответили на вопрос)"`
 	From       *utils.UserShortInfo `json:"from" doc:"От кого уведомление"`
-	TeamId     int64                `json:"team_id" doc:"Айдишник команды, чтобы принять приглашение"`
+	TeamId     int64                `json:"team_id" doc:"Айдишник команды, чтобы принять приглашение (0 - уведомление не про команду)"`
 	EventUri   string               `json:"event_urid" doc:"Ссылка на мероприятие"`
+	Text       string               `json:"text,omitempty" doc:"Текст уведомления (для объявлений, причины отклонения, встреч с менторами и ответов на вопросы)"`
+	CreatedAt  time.Time            `json:"created_at" doc:"Время уведомления"`
+}
+
+type NotificationsInput struct {
+	Body struct {
+		Token  string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+		Cursor string `json:"cursor,omitempty" doc:"Курсор следующей страницы из прошлого ответа (пусто - первая страница)"`
+		Count  int    `json:"count,omitempty" minimum:"0" maximum:"100" example:"20" doc:"Количество уведомлений на страницу"`
+	}
 }
 
 type NotificationsOutput struct {
 	Body struct {
 		Notifications []*Notify `json:"notifications" doc:"Лист "`
+		NextCursor    string    `json:"next_cursor,omitempty" doc:"Курсор следующей страницы (пусто - это последняя)"`
 	}
 }
 
-func getNotifys(email string, db *sql.DB) (*NotificationsOutput, error) {
-	rows, err := db.Query("SELECT team_id, type, from, event_uri FROM notifications WHERE user = $1", email)
+// Новые сверху, страницы выдаются по курсору
+func getNotifys(email string, cursorValue string, count int, db *sql.DB) (*NotificationsOutput, error) {
+	page, err := utils.NewPage(cursorValue, "created", count)
+	if err != nil {
+		return nil, err
+	}
+
+	query := "SELECT id, team_id, type, \"from\", event_uri, \"text\", created_at FROM notifications WHERE \"user\" = $1"
+	args := []any{email, page.Limit()}
+	if page.After != nil {
+		query += " AND (created_at, id) < ($3::timestamptz, $4::bigint)"
+		args = append(args, page.After.Value, page.After.Key)
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT $2"
+
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -31,11 +58,21 @@ func getNotifys(email string, db *sql.DB) (*NotificationsOutput, error) {
 
 	for rows.Next() {
 		notify := new(Notify)
+		var id int64
 		var e string
-		if err := rows.Scan(&notify.TeamId, &notify.NotifyType, &e, &notify.EventUri); err != nil {
+		var teamId sql.NullInt64
+		var text sql.NullString
+		if err := rows.Scan(&id, &teamId, &notify.NotifyType, &e, &notify.EventUri, &text, &notify.CreatedAt); err != nil {
 			return nil, huma.Error422UnprocessableEntity(err.Error())
 		}
 
+		if !page.Add(notify.CreatedAt.Format(time.RFC3339Nano), strconv.FormatInt(id, 10)) {
+			break
+		}
+
+		notify.TeamId = teamId.Int64
+		notify.Text = text.String
+
 		notify.From, err = utils.GetUserShortInfo(e, db)
 		if err != nil {
 			return nil, huma.Error422UnprocessableEntity(err.Error())
@@ -46,17 +83,18 @@ func getNotifys(email string, db *sql.DB) (*NotificationsOutput, error) {
 	if err = rows.Err(); err != nil {
 		return nil, huma.Error422UnprocessableEntity(err.Error())
 	}
+	result.Body.NextCursor = page.NextCursor()
 
 	return result, nil
 }
 
-func GetNotifications(input *utils.JustAccessTokenInput, db *sql.DB) (*NotificationsOutput, error) {
+func GetNotifications(input *NotificationsInput, db *sql.DB) (*NotificationsOutput, error) {
 	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
 	if err != nil {
 		return nil, huma.Error403Forbidden("Пользователь не найден")
 	}
 
-	return getNotifys(user.Email, db)
+	return getNotifys(user.Email, input.Body.Cursor, input.Body.Count, db)
 }
 
 func DeleteAllNotifications(input *utils.JustAccessTokenInput, db *sql.DB) (*NotificationsOutput, error) {
@@ -65,11 +103,12 @@ func DeleteAllNotifications(input *utils.JustAccessTokenInput, db *sql.DB) (*Not
 		return nil, huma.Error403Forbidden("Пользователь не найден")
 	}
 
-	rows, err := db.Query("DELETE FROM notifications WHERE user = $1 AND type <> 0", user.Email)
+	rows, err := db.Query("DELETE FROM notifications WHERE \"user\" = $1 AND type <> 0", user.Email)
 	if err != nil {
 		return nil, huma.Error422UnprocessableEntity(err.Error())
 	}
 	defer rows.Close()
 
-	return getNotifys(user.Email, db)
+	// Отдаем первую страницу того, что осталось
+	return getNotifys(user.Email, "", 0, db)
 }
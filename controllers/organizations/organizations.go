@@ -0,0 +1,638 @@
+package organizations
+
+import (
+	"database/sql"
+	"hackaton-jam-back/controllers/utils"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+// Роли в организации
+const (
+	RoleAdmin  = "admin"  // Управляет организацией, ее сотрудниками и всеми ее мероприятиями
+	RoleMember = "member" // Создает мероприятия от имени организации и видит ее закрытые мероприятия
+)
+
+type OrganizationShortInfo struct {
+	Urid   string `json:"urid" example:"urfu" doc:"Ссылка на организацию"`
+	Name   string `json:"name" example:"УрФУ" doc:"Название организации"`
+	Logo   string `json:"logo" doc:"Логотип организации"`
+	Events int    `json:"events,omitempty" example:"4" doc:"Количество мероприятий (в общем списке - только опубликованных)"`
+	Role   string `json:"role,omitempty" example:"admin" doc:"Моя роль в организации"`
+}
+
+type OrganizationMember struct {
+	User    *utils.UserShortInfo `json:"user" doc:"Сотрудник"`
+	Role    string               `json:"role" example:"admin" doc:"Роль (admin, member)"`
+	AddedAt time.Time            `json:"added_at" doc:"Когда добавлен"`
+}
+
+type OrganizationEvent struct {
+	Urid      string    `json:"urid" example:"example_events" doc:"Ссылка на мероприятие"`
+	Name      string    `json:"name" example:"Example GameJam" doc:"Название мероприятия"`
+	StartTime time.Time `json:"start_time" doc:"Начало проведения"`
+	EndTime   time.Time `json:"end_time" doc:"Конец проведения"`
+	Location  string    `json:"location" example:"Свердловская область, г. Екатеринбург" doc:"Место проведения"`
+	Icon      string    `json:"icon" doc:"Превью мероприятия"`
+	Locale    string    `json:"locale" example:"ru" doc:"Язык названия"`
+}
+
+type OrganizationOutput struct {
+	Vary string `header:"Vary"`
+	Body struct {
+		Urid        string    `json:"urid" example:"urfu" doc:"Ссылка на организацию"`
+		Name        string    `json:"name" example:"УрФУ" doc:"Название организации"`
+		Description string    `json:"desc" doc:"Описание организации"`
+		Logo        string    `json:"logo" doc:"Логотип организации"`
+		CreatedAt   time.Time `json:"created_at" doc:"Когда создана"`
+
+		Members  []*OrganizationMember `json:"members" doc:"Сотрудники"`
+		Upcoming []*OrganizationEvent  `json:"upcoming" doc:"Текущие и будущие мероприятия"`
+		Past     []*OrganizationEvent  `json:"past" doc:"Прошедшие мероприятия"`
+	}
+}
+
+type OrganizationsListInput struct {
+	Count  int    `query:"count" default:"20" minimum:"1" maximum:"100" example:"20" doc:"Количество организаций на страницу"`
+	Cursor string `query:"cursor" doc:"Курсор следующей страницы из прошлого ответа (пусто - первая страница)"`
+}
+
+type OrganizationsOutput struct {
+	Body struct {
+		Organizations []*OrganizationShortInfo `json:"organizations" doc:"Организации"`
+		NextCursor    string                   `json:"next_cursor,omitempty" doc:"Курсор следующей страницы (пусто - это последняя)"`
+	}
+}
+
+type OrganizationCreateInput struct {
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+
+		Urid        string `json:"urid" maxLength:"30" example:"urfu" doc:"Ссылка на организацию (Поменять потом нельзя!!!)"`
+		Name        string `json:"name" example:"УрФУ" doc:"Название организации"`
+		Description string `json:"desc,omitempty" doc:"Описание организации"`
+		Logo        string `json:"logo,omitempty" doc:"Логотип организации"`
+	}
+}
+
+type OrganizationEditInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"urfu" doc:"Ссылка на организацию"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+
+		Name        string `json:"name,omitempty" example:"УрФУ" doc:"Название организации"`
+		Description string `json:"desc,omitempty" doc:"Описание организации"`
+		Logo        string `json:"logo,omitempty" doc:"Логотип организации"`
+	}
+}
+
+type OrganizationTokenInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"urfu" doc:"Ссылка на организацию"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type OrganizationMemberInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"urfu" doc:"Ссылка на организацию"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+
+		Username string `json:"username" example:"ThatMaidGuy" doc:"Никнейм сотрудника"`
+		Role     string `json:"role" enum:"admin,member" example:"member" doc:"Роль в организации"`
+	}
+}
+
+type OrganizationMemberDelInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"urfu" doc:"Ссылка на организацию"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+
+		Username string `json:"username" example:"ThatMaidGuy" doc:"Никнейм сотрудника (можно указать себя, чтобы выйти)"`
+	}
+}
+
+type OrganizationEventInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"urfu" doc:"Ссылка на организацию"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+
+		EventUrid string `json:"event_urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	}
+}
+
+// Организации по названию, страницы выдаются по курсору
+func GetAllOrganizations(input *OrganizationsListInput, db *sql.DB) (*OrganizationsOutput, error) {
+	page, err := utils.NewPage(input.Cursor, "name", input.Count)
+	if err != nil {
+		return nil, err
+	}
+
+	query := "SELECT organizations.urid, organizations.name, organizations.logo, COUNT(events.urid) " +
+		"FROM organizations LEFT JOIN events ON events.org_urid = organizations.urid AND events.is_draft = false AND events.visibility = 'public' AND events.moderation_status = 'approved'"
+	args := []any{page.Limit()}
+	if page.After != nil {
+		query += " WHERE (organizations.name, organizations.urid) > ($2, $3)"
+		args = append(args, page.After.Value, page.After.Key)
+	}
+	query += " GROUP BY organizations.urid ORDER BY organizations.name, organizations.urid LIMIT $1"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	result := new(OrganizationsOutput)
+
+	for rows.Next() {
+		org := new(OrganizationShortInfo)
+		var logo sql.NullString
+		if err := rows.Scan(&org.Urid, &org.Name, &logo, &org.Events); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		org.Logo = logo.String
+
+		if !page.Add(org.Name, org.Urid) {
+			break
+		}
+
+		result.Body.Organizations = append(result.Body.Organizations, org)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	result.Body.NextCursor = page.NextCursor()
+
+	return result, nil
+}
+
+// Названия мероприятий отдаются на языке из locale, если есть перевод
+func GetOrganization(urid string, locale utils.LocaleInput, db *sql.DB) (*OrganizationOutput, error) {
+	result, err := getOrganization(urid, db)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*OrganizationEvent, 0, len(result.Body.Upcoming)+len(result.Body.Past))
+	events = append(append(events, result.Body.Upcoming...), result.Body.Past...)
+	urids := make([]string, len(events))
+	for i, event := range events {
+		urids[i] = event.Urid
+	}
+
+	preferred := utils.PreferredLocales(locale)
+	translations, err := utils.GetNameTranslations(urids, preferred, db)
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range events {
+		event.Name, event.Locale = translations.Localize(event.Urid, event.Name, event.Locale, preferred)
+	}
+	result.Vary = utils.VaryLanguage
+
+	return result, nil
+}
+
+func GetMyOrganizations(input *utils.JustAccessTokenInput, db *sql.DB) (*OrganizationsOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+
+	rows, err := db.Query(
+		"SELECT organizations.urid, organizations.name, organizations.logo, organization_members.role, "+
+			"(SELECT COUNT(*) FROM events WHERE events.org_urid = organizations.urid) "+
+			"FROM organizations JOIN organization_members ON organization_members.org_urid = organizations.urid "+
+			"WHERE organization_members.member_email = $1 ORDER BY organizations.name", user.Email,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	result := new(OrganizationsOutput)
+
+	for rows.Next() {
+		org := new(OrganizationShortInfo)
+		var logo sql.NullString
+		if err := rows.Scan(&org.Urid, &org.Name, &logo, &org.Role, &org.Events); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		org.Logo = logo.String
+
+		result.Body.Organizations = append(result.Body.Organizations, org)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
+
+func CreateOrganization(input *OrganizationCreateInput, db *sql.DB) (*OrganizationOutput, error) {
+	// Проверить можем ли создать организацию?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if user.Perms < 1 {
+		return nil, huma.Error403Forbidden("Нет прав")
+	}
+
+	if input.Body.Urid == "" || input.Body.Name == "" {
+		return nil, huma.Error422UnprocessableEntity("Ссылка и название организации не должны быть пустыми")
+	}
+
+	// Организация без админа никому не доступна, поэтому создается вместе с ним
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"INSERT INTO organizations (urid, name, \"desc\", logo, created_by) VALUES ($1, $2, $3, $4, $5)",
+		input.Body.Urid, input.Body.Name, input.Body.Description, input.Body.Logo, user.Email,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	// Создатель становится первым админом
+	_, err = tx.Exec(
+		"INSERT INTO organization_members (org_urid, member_email, role) VALUES ($1, $2, $3)",
+		input.Body.Urid, user.Email, RoleAdmin,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return getOrganization(input.Body.Urid, db)
+}
+
+func EditOrganization(input *OrganizationEditInput, db *sql.DB) (*OrganizationOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := checkOrgAdmin(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(
+		"UPDATE organizations SET "+
+			"name = COALESCE(NULLIF($2, ''), name), "+
+			"\"desc\" = COALESCE(NULLIF($3, ''), \"desc\"), "+
+			"logo = COALESCE(NULLIF($4, ''), logo) "+
+			"WHERE urid = $1",
+		input.Urid, input.Body.Name, input.Body.Description, input.Body.Logo,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return getOrganization(input.Urid, db)
+}
+
+func DeleteOrganization(input *OrganizationTokenInput, db *sql.DB) (*OrganizationsOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := checkOrgAdmin(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	// Иначе мероприятия снова останутся без хозяина
+	var events int
+	if err := db.QueryRow("SELECT COUNT(*) FROM events WHERE org_urid = $1", input.Urid).Scan(&events); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if events > 0 {
+		return nil, huma.Error422UnprocessableEntity("Сначала уберите мероприятия из организации")
+	}
+
+	if _, err := db.Exec("DELETE FROM organization_members WHERE org_urid = $1", input.Urid); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if _, err := db.Exec("DELETE FROM organizations WHERE urid = $1", input.Urid); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return GetAllOrganizations(&OrganizationsListInput{}, db)
+}
+
+// Добавляет сотрудника или меняет его роль
+func SetMember(input *OrganizationMemberInput, db *sql.DB) (*OrganizationOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := checkOrgAdmin(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	member, err := utils.GetUserEmailByUsername(input.Body.Username, db)
+	if err != nil {
+		return nil, huma.Error404NotFound("Пользователь не найден")
+	}
+	if member.Perms < 1 {
+		return nil, huma.Error422UnprocessableEntity("В организации могут состоять только организаторы")
+	}
+
+	// Последнего админа не разжалуешь: организация останется без управления
+	res, err := db.Exec(
+		"INSERT INTO organization_members (org_urid, member_email, role) VALUES ($1, $2, $3) "+
+			"ON CONFLICT (org_urid, member_email) DO UPDATE SET role = EXCLUDED.role "+
+			"WHERE EXCLUDED.role = 'admin' OR EXISTS (SELECT 1 FROM organization_members other "+
+			"WHERE other.org_urid = $1 AND other.member_email <> $2 AND other.role = 'admin')",
+		input.Urid, member.Email, input.Body.Role,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, huma.Error422UnprocessableEntity("Нельзя снять роль с последнего админа организации")
+	}
+
+	return getOrganization(input.Urid, db)
+}
+
+// Убирает сотрудника. Он теряет доступ к мероприятиям организации, даже тем, что создавал сам
+func DelMember(input *OrganizationMemberDelInput, db *sql.DB) (*OrganizationOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+
+	member, err := utils.GetUserEmailByUsername(input.Body.Username, db)
+	if err != nil {
+		return nil, huma.Error404NotFound("Пользователь не найден")
+	}
+
+	// Выйти можно самому, убрать другого - только админу
+	if member.Email != user.Email {
+		if err := checkOrgAdmin(user, input.Urid, db); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := getMemberRole(member.Email, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	res, err := db.Exec(
+		"DELETE FROM organization_members WHERE org_urid = $1 AND member_email = $2 "+
+			"AND (role <> 'admin' OR EXISTS (SELECT 1 FROM organization_members other "+
+			"WHERE other.org_urid = $1 AND other.member_email <> $2 AND other.role = 'admin'))",
+		input.Urid, member.Email,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, huma.Error422UnprocessableEntity("Нельзя убрать последнего админа организации")
+	}
+
+	_, err = db.Exec(
+		"DELETE FROM event_orgs WHERE organizator_email = $2 AND event_uri IN (SELECT urid FROM events WHERE org_urid = $1)",
+		input.Urid, member.Email,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return getOrganization(input.Urid, db)
+}
+
+// Передает мероприятие организации. Передать может его организатор, состоящий в организации
+func AddOrganizationEvent(input *OrganizationEventInput, db *sql.DB) (*OrganizationOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := CheckMember(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+	if err := utils.CheckEventOrganizator(user, input.Body.EventUrid, db); err != nil {
+		return nil, err
+	}
+
+	res, err := db.Exec("UPDATE events SET org_urid = $2 WHERE urid = $1", input.Body.EventUrid, input.Urid)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, huma.Error404NotFound("Такого мероприятия нет")
+	}
+
+	return getOrganization(input.Urid, db)
+}
+
+func DelOrganizationEvent(input *OrganizationEventInput, db *sql.DB) (*OrganizationOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := checkOrgAdmin(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	var orgUrid sql.NullString
+	var organizators int
+	if err := db.QueryRow(
+		"SELECT org_urid, (SELECT COUNT(*) FROM event_orgs WHERE event_orgs.event_uri = events.urid) FROM events WHERE urid = $1",
+		input.Body.EventUrid,
+	).Scan(&orgUrid, &organizators); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, huma.Error404NotFound("Такого мероприятия нет")
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if orgUrid.String != input.Urid {
+		return nil, huma.Error422UnprocessableEntity("Это мероприятие не принадлежит организации")
+	}
+
+	// Мероприятие без организаторов и без организации никто не сможет вести
+	if organizators == 0 {
+		return nil, huma.Error422UnprocessableEntity("У мероприятия не останется организаторов")
+	}
+
+	_, err = db.Exec("UPDATE events SET org_urid = NULL WHERE urid = $1 AND org_urid = $2", input.Body.EventUrid, input.Urid)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return getOrganization(input.Urid, db)
+}
+
+// Проверяет, что пользователь состоит в организации (или админ сайта)
+func CheckMember(user *utils.UserEmail, urid string, db *sql.DB) error {
+	if user.Perms == 10 {
+		return nil
+	}
+
+	_, err := getMemberRole(user.Email, urid, db)
+	if err != nil {
+		return huma.Error403Forbidden("Ты не состоишь в этой организации")
+	}
+
+	return nil
+}
+
+// Организация, которой принадлежит мероприятие (nil, если его ведут частные организаторы)
+func GetEventOrganization(eventUrid string, db *sql.DB) (*OrganizationShortInfo, error) {
+	org := new(OrganizationShortInfo)
+	var logo sql.NullString
+	err := db.QueryRow(
+		"SELECT organizations.urid, organizations.name, organizations.logo "+
+			"FROM events JOIN organizations ON organizations.urid = events.org_urid WHERE events.urid = $1",
+		eventUrid,
+	).Scan(&org.Urid, &org.Name, &logo)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	org.Logo = logo.String
+
+	return org, nil
+}
+
+func getOrganization(urid string, db *sql.DB) (*OrganizationOutput, error) {
+	result := new(OrganizationOutput)
+
+	var desc sql.NullString
+	var logo sql.NullString
+	err := db.QueryRow(
+		"SELECT urid, name, \"desc\", logo, created_at FROM organizations WHERE urid = $1", urid,
+	).Scan(
+		&result.Body.Urid,
+		&result.Body.Name,
+		&desc,
+		&logo,
+		&result.Body.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, huma.Error404NotFound("Такой организации нет")
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	result.Body.Description = desc.String
+	result.Body.Logo = logo.String
+
+	result.Body.Members, err = getMembers(urid, db)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(
+		"SELECT urid, name, start_time, end_time, \"location\", icon, default_locale, end_time < now() "+
+			"FROM events WHERE org_urid = $1 AND is_draft = false AND visibility = 'public' AND moderation_status = 'approved' ORDER BY start_time DESC", urid,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		event := new(OrganizationEvent)
+		var location sql.NullString
+		var icon sql.NullString
+		var past bool
+		if err := rows.Scan(&event.Urid, &event.Name, &event.StartTime, &event.EndTime, &location, &icon, &event.Locale, &past); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		event.Location = location.String
+		event.Icon = icon.String
+
+		if past {
+			result.Body.Past = append(result.Body.Past, event)
+		} else {
+			result.Body.Upcoming = append(result.Body.Upcoming, event)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
+
+func getMembers(urid string, db *sql.DB) ([]*OrganizationMember, error) {
+	rows, err := db.Query(
+		"SELECT member_email, role, added_at FROM organization_members WHERE org_urid = $1 "+
+			"ORDER BY role = 'admin' DESC, added_at", urid,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	var emails []string
+	var members []*OrganizationMember
+	for rows.Next() {
+		var email string
+		member := new(OrganizationMember)
+		if err := rows.Scan(&email, &member.Role, &member.AddedAt); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+
+		emails = append(emails, email)
+		members = append(members, member)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	for i, member := range members {
+		member.User, err = utils.GetUserShortInfo(emails[i], db)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return members, nil
+}
+
+func getMemberRole(email string, urid string, db *sql.DB) (string, error) {
+	var role string
+	if err := db.QueryRow(
+		"SELECT role FROM organization_members WHERE org_urid = $1 AND member_email = $2", urid, email,
+	).Scan(&role); err != nil {
+		if err == sql.ErrNoRows {
+			return "", huma.Error404NotFound("Этот пользователь не состоит в организации")
+		}
+		return "", huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return role, nil
+}
+
+func checkOrgAdmin(user *utils.UserEmail, urid string, db *sql.DB) error {
+	var exists bool
+	if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM organizations WHERE urid = $1)", urid).Scan(&exists); err != nil {
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+	if !exists {
+		return huma.Error404NotFound("Такой организации нет")
+	}
+
+	if user.Perms == 10 {
+		return nil
+	}
+
+	role, err := getMemberRole(user.Email, urid, db)
+	if err != nil || role != RoleAdmin {
+		return huma.Error403Forbidden("Ты не админ этой организации")
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package profile
 
 import (
 	"database/sql"
+	"hackaton-jam-back/controllers/results"
 	"hackaton-jam-back/controllers/utils"
 	"log"
 	"reflect"
@@ -39,6 +40,8 @@ type ProfileOutput struct {
 		WorkTime    string `json:"work_time" example:"2 месяца" doc:"Опыт работы"`
 		Location    string `json:"location" example:"Екатеринбург" doc:"Место жительства"`
 		Permissions int    `json:"permissions" example:"0" doc:"Права доступа"`
+
+		Awards []*results.UserAward `json:"awards" doc:"Награды пользователя на мероприятиях"`
 	}
 }
 
@@ -86,6 +89,11 @@ func GetProfile(username string, db *sql.DB) (*ProfileOutput, error) {
 	userdata.Body.WorkTime = workTime.String
 	userdata.Body.Location = location.String
 
+	userdata.Body.Awards, err = results.GetUserAwards(userdata.Body.Email, db)
+	if err != nil {
+		return nil, err
+	}
+
 	return userdata, nil
 }
 
@@ -0,0 +1,475 @@
+package qa
+
+import (
+	"database/sql"
+	"hackaton-jam-back/controllers/utils"
+	"strings"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+const (
+	minQuestionLength = 5
+	maxQuestionLength = 2000
+)
+
+type EventQuestion struct {
+	Id         int64      `json:"id" example:"1" doc:"Идентификатор вопроса"`
+	Author     string     `json:"author" example:"thatmaidguy" doc:"Имя пользователя автора"`
+	Text       string     `json:"text" example:"Можно ли участвовать одному?" doc:"Вопрос"`
+	Answer     string     `json:"answer,omitempty" example:"Да, команда из одного человека тоже команда" doc:"Ответ организаторов"`
+	AnsweredBy string     `json:"answered_by,omitempty" example:"thatmaidguy2" doc:"Кто ответил"`
+	AnsweredAt *time.Time `json:"answered_at,omitempty" doc:"Когда ответили"`
+	Votes      int        `json:"votes" example:"12" doc:"Сколько человек поддержали вопрос"`
+	Voted      bool       `json:"voted" doc:"Поддержал ли вопрос текущий пользователь"`
+	Mine       bool       `json:"mine" doc:"Вопрос текущего пользователя"`
+	Pinned     bool       `json:"pinned" doc:"Закреплен организаторами"`
+	InFaq      bool       `json:"in_faq" doc:"Вынесен в частые вопросы"`
+	Hidden     bool       `json:"hidden,omitempty" doc:"Скрыт модерацией (видят только организаторы)"`
+	CreatedAt  time.Time  `json:"created_at" doc:"Когда задан"`
+}
+
+type FaqItem struct {
+	Question string `json:"question" example:"Можно ли участвовать одному?" doc:"Вопрос"`
+	Answer   string `json:"answer" example:"Да, команда из одного человека тоже команда" doc:"Ответ"`
+}
+
+type EventQuestionsInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token      string `json:"access_token,omitempty" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя (необязательно)"`
+		Sort       string `json:"sort,omitempty" enum:"top,new" default:"top" doc:"Сортировка: популярные или новые (закрепленные всегда сверху)"`
+		Unanswered bool   `json:"unanswered,omitempty" doc:"Только вопросы без ответа"`
+	}
+}
+
+type QuestionAskInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+		Text  string `json:"text" example:"Можно ли участвовать одному?" doc:"Вопрос"`
+	}
+}
+
+type EventQuestionInput struct {
+	Id   int64 `path:"id" example:"1" doc:"Идентификатор вопроса"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type QuestionVoteInput struct {
+	Id   int64 `path:"id" example:"1" doc:"Идентификатор вопроса"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+		Vote  bool   `json:"vote" doc:"Поддержать (true) или снять голос (false)"`
+	}
+}
+
+type QuestionAnswerInput struct {
+	Id   int64 `path:"id" example:"1" doc:"Идентификатор вопроса"`
+	Body struct {
+		Token  string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+		Answer string `json:"answer" minLength:"1" example:"Да, команда из одного человека тоже команда" doc:"Ответ"`
+		Faq    bool   `json:"faq,omitempty" doc:"Сразу вынести в частые вопросы"`
+	}
+}
+
+type QuestionModerateInput struct {
+	Id   int64 `path:"id" example:"1" doc:"Идентификатор вопроса"`
+	Body struct {
+		Token  string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+		Pinned *bool  `json:"pinned,omitempty" doc:"Закрепить"`
+		Faq    *bool  `json:"faq,omitempty" doc:"Вынести в частые вопросы (только с ответом)"`
+		Hidden *bool  `json:"hidden,omitempty" doc:"Скрыть"`
+	}
+}
+
+type EventQuestionsOutput struct {
+	Body struct {
+		Questions []*EventQuestion `json:"questions" doc:"Вопросы мероприятия"`
+	}
+}
+
+type EventQuestionOutput struct {
+	Body EventQuestion
+}
+
+type QuestionDelOutput struct {
+	Body struct {
+		Success bool `json:"success" doc:"Успешно выполнено!"`
+	}
+}
+
+// viewer - кто смотрит: организаторы видят и скрытые вопросы
+type viewer struct {
+	email       string
+	isOrganizer bool
+}
+
+func GetQuestions(input *EventQuestionsInput, db *sql.DB) (*EventQuestionsOutput, error) {
+	view, err := getViewer(input.Body.Token, input.Urid, db)
+	if err != nil {
+		return nil, err
+	}
+
+	return getQuestions(input.Urid, view, input.Body.Sort, input.Body.Unanswered, db)
+}
+
+// Спрашивать могут участники и организаторы
+func AskQuestion(input *QuestionAskInput, db *sql.DB) (*EventQuestionOutput, error) {
+	view, err := getViewer(input.Body.Token, input.Urid, db)
+	if err != nil {
+		return nil, err
+	}
+	if view.email == "" {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if !view.isOrganizer {
+		if err := checkMember(view.email, input.Urid, db); err != nil {
+			return nil, err
+		}
+	}
+
+	text := strings.TrimSpace(input.Body.Text)
+	if length := len([]rune(text)); length < minQuestionLength || length > maxQuestionLength {
+		return nil, huma.Error422UnprocessableEntity("Вопрос должен быть от 5 до 2000 символов")
+	}
+
+	var id int64
+	if err := db.QueryRow(
+		"INSERT INTO event_questions (event_uri, author_email, \"text\") VALUES ($1, $2, $3) RETURNING id",
+		input.Urid, view.email, text,
+	).Scan(&id); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return getQuestion(id, view, db)
+}
+
+// Голосовать можно за чужие вопросы, один раз
+func VoteQuestion(input *QuestionVoteInput, db *sql.DB) (*EventQuestionOutput, error) {
+	urid, author, err := getQuestionEvent(input.Id, db)
+	if err != nil {
+		return nil, err
+	}
+	view, err := getViewer(input.Body.Token, urid, db)
+	if err != nil {
+		return nil, err
+	}
+	if view.email == "" {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if !view.isOrganizer {
+		if err := checkMember(view.email, urid, db); err != nil {
+			return nil, err
+		}
+	}
+	if author == view.email {
+		return nil, huma.Error403Forbidden("Нельзя голосовать за свой вопрос")
+	}
+
+	// Скрытые вопросы видны только организаторам
+	if _, err := getQuestion(input.Id, view, db); err != nil {
+		return nil, err
+	}
+
+	if input.Body.Vote {
+		_, err = db.Exec(
+			"INSERT INTO event_question_votes (question_id, user_email) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+			input.Id, view.email,
+		)
+	} else {
+		_, err = db.Exec("DELETE FROM event_question_votes WHERE question_id = $1 AND user_email = $2", input.Id, view.email)
+	}
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return getQuestion(input.Id, view, db)
+}
+
+// Отвечают организаторы, автору приходит уведомление (13)
+func AnswerQuestion(input *QuestionAnswerInput, db *sql.DB) (*EventQuestionOutput, error) {
+	urid, author, err := getQuestionEvent(input.Id, db)
+	if err != nil {
+		return nil, err
+	}
+	view, err := getOrganizer(input.Body.Token, urid, db)
+	if err != nil {
+		return nil, err
+	}
+
+	answer := strings.TrimSpace(input.Body.Answer)
+	if answer == "" {
+		return nil, huma.Error422UnprocessableEntity("Ответ не должен быть пустым")
+	}
+
+	_, err = db.Exec(
+		"UPDATE event_questions SET answer = $2, answered_by = $3, answered_at = now(), in_faq = in_faq OR $4 WHERE id = $1",
+		input.Id, answer, view.email, input.Body.Faq,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	if author != view.email {
+		_, err = db.Exec(
+			"INSERT INTO notifications (\"user\", type, \"from\", event_uri, \"text\") VALUES ($1, 13, $2, $3, $4)",
+			author, view.email, urid, answer,
+		)
+		if err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+	}
+
+	return getQuestion(input.Id, view, db)
+}
+
+// Закрепление, частые вопросы и скрытие - для организаторов
+func ModerateQuestion(input *QuestionModerateInput, db *sql.DB) (*EventQuestionOutput, error) {
+	urid, _, err := getQuestionEvent(input.Id, db)
+	if err != nil {
+		return nil, err
+	}
+	view, err := getOrganizer(input.Body.Token, urid, db)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Body.Faq != nil && *input.Body.Faq {
+		var answered bool
+		if err := db.QueryRow("SELECT answer IS NOT NULL FROM event_questions WHERE id = $1", input.Id).Scan(&answered); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		if !answered {
+			return nil, huma.Error422UnprocessableEntity("В частые вопросы можно вынести только вопрос с ответом")
+		}
+	}
+
+	_, err = db.Exec(
+		"UPDATE event_questions SET is_pinned = COALESCE($2, is_pinned), in_faq = COALESCE($3, in_faq), "+
+			"is_hidden = COALESCE($4, is_hidden) WHERE id = $1",
+		input.Id, input.Body.Pinned, input.Body.Faq, input.Body.Hidden,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return getQuestion(input.Id, view, db)
+}
+
+// Организатор удаляет любой вопрос, автор - свой, пока на него не ответили
+func DelQuestion(input *EventQuestionInput, db *sql.DB) (*QuestionDelOutput, error) {
+	urid, author, err := getQuestionEvent(input.Id, db)
+	if err != nil {
+		return nil, err
+	}
+	view, err := getViewer(input.Body.Token, urid, db)
+	if err != nil {
+		return nil, err
+	}
+	if view.email == "" {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+
+	if !view.isOrganizer {
+		if author != view.email {
+			return nil, huma.Error403Forbidden("Это не твой вопрос")
+		}
+		var answered bool
+		if err := db.QueryRow("SELECT answer IS NOT NULL FROM event_questions WHERE id = $1", input.Id).Scan(&answered); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		if answered {
+			return nil, huma.Error403Forbidden("На вопрос уже ответили, удалить его может только организатор")
+		}
+	}
+
+	if _, err := db.Exec("DELETE FROM event_question_votes WHERE question_id = $1", input.Id); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if _, err := db.Exec("DELETE FROM event_questions WHERE id = $1", input.Id); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	result := new(QuestionDelOutput)
+	result.Body.Success = true
+	return result, nil
+}
+
+// Частые вопросы для страницы мероприятия
+func GetFaq(urid string, db *sql.DB) ([]*FaqItem, error) {
+	rows, err := db.Query(
+		"SELECT \"text\", answer FROM event_questions "+
+			"WHERE event_uri = $1 AND in_faq = true AND is_hidden = false AND answer IS NOT NULL "+
+			"ORDER BY is_pinned DESC, id",
+		urid,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	var result []*FaqItem
+	for rows.Next() {
+		item := new(FaqItem)
+		if err := rows.Scan(&item.Question, &item.Answer); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		result = append(result, item)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
+
+func getQuestions(urid string, view *viewer, sort string, unanswered bool, db *sql.DB) (*EventQuestionsOutput, error) {
+	order := "votes DESC, event_questions.id DESC"
+	if sort == "new" {
+		order = "event_questions.id DESC"
+	}
+
+	rows, err := db.Query(
+		questionQuery+"WHERE event_questions.event_uri = $1 AND ($3::bool OR event_questions.is_hidden = false) "+
+			"AND ($4::bool = false OR event_questions.answer IS NULL) "+
+			"ORDER BY event_questions.is_pinned DESC, "+order,
+		urid, view.email, view.isOrganizer, unanswered,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	result := new(EventQuestionsOutput)
+	result.Body.Questions = []*EventQuestion{}
+
+	for rows.Next() {
+		question, err := scanQuestion(rows, view)
+		if err != nil {
+			return nil, err
+		}
+		result.Body.Questions = append(result.Body.Questions, question)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
+
+func getQuestion(id int64, view *viewer, db *sql.DB) (*EventQuestionOutput, error) {
+	row := db.QueryRow(
+		questionQuery+"WHERE event_questions.id = $1 AND ($3::bool OR event_questions.is_hidden = false)",
+		id, view.email, view.isOrganizer,
+	)
+	question, err := scanQuestion(row, view)
+	if err != nil {
+		return nil, err
+	}
+	return &EventQuestionOutput{Body: *question}, nil
+}
+
+// $2 - e-mail смотрящего, чтобы отметить его голоса и вопросы
+const questionQuery = "SELECT event_questions.id, authors.username, event_questions.author_email, event_questions.\"text\", " +
+	"event_questions.answer, answerers.username, event_questions.answered_at, " +
+	"(SELECT COUNT(*) FROM event_question_votes WHERE event_question_votes.question_id = event_questions.id) AS votes, " +
+	"EXISTS (SELECT 1 FROM event_question_votes WHERE event_question_votes.question_id = event_questions.id " +
+	"AND event_question_votes.user_email = $2), " +
+	"event_questions.is_pinned, event_questions.in_faq, event_questions.is_hidden, event_questions.created_at " +
+	"FROM event_questions JOIN users AS authors ON authors.email = event_questions.author_email " +
+	"LEFT JOIN users AS answerers ON answerers.email = event_questions.answered_by "
+
+func scanQuestion(row interface{ Scan(dest ...any) error }, view *viewer) (*EventQuestion, error) {
+	question := new(EventQuestion)
+	var authorEmail string
+	var answer, answeredBy sql.NullString
+	var answeredAt sql.NullTime
+	if err := row.Scan(
+		&question.Id, &question.Author, &authorEmail, &question.Text,
+		&answer, &answeredBy, &answeredAt,
+		&question.Votes, &question.Voted,
+		&question.Pinned, &question.InFaq, &question.Hidden, &question.CreatedAt,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, huma.Error404NotFound("Вопрос не найден")
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	question.Answer = answer.String
+	question.AnsweredBy = answeredBy.String
+	if answeredAt.Valid {
+		question.AnsweredAt = &answeredAt.Time
+	}
+	question.Mine = view.email != "" && authorEmail == view.email
+
+	return question, nil
+}
+
+// Токен необязательный. Доску закрытого мероприятия видят только свои
+func getViewer(token string, urid string, db *sql.DB) (*viewer, error) {
+	view := new(viewer)
+	if token != "" {
+		user, err := utils.GetUserEmailByToken(token, db)
+		if err != nil {
+			return nil, huma.Error403Forbidden("Пользователь не найден")
+		}
+		view.email = user.Email
+		view.isOrganizer = utils.CheckEventOrganizator(user, urid, db) == nil
+	}
+
+	var visibility string
+	var isMember bool
+	if err := db.QueryRow(
+		"SELECT visibility, EXISTS (SELECT 1 FROM event_members WHERE event_uri = $1 AND member_email = $2) FROM events WHERE urid = $1",
+		urid, view.email,
+	).Scan(&visibility, &isMember); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, huma.Error404NotFound("Этого события нет XP")
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if visibility == "private" && !isMember && !view.isOrganizer {
+		return nil, huma.Error403Forbidden("Это закрытое мероприятие")
+	}
+
+	return view, nil
+}
+
+func getOrganizer(token string, urid string, db *sql.DB) (*viewer, error) {
+	user, err := utils.GetUserEmailByToken(token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, urid, db); err != nil {
+		return nil, err
+	}
+	return &viewer{email: user.Email, isOrganizer: true}, nil
+}
+
+func checkMember(email string, urid string, db *sql.DB) error {
+	var isMember bool
+	if err := db.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM event_members WHERE event_uri = $1 AND member_email = $2)", urid, email,
+	).Scan(&isMember); err != nil {
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+	if !isMember {
+		return huma.Error403Forbidden("Вопросы задают и поддерживают только участники мероприятия")
+	}
+	return nil
+}
+
+func getQuestionEvent(id int64, db *sql.DB) (string, string, error) {
+	var urid, author string
+	if err := db.QueryRow("SELECT event_uri, author_email FROM event_questions WHERE id = $1", id).Scan(&urid, &author); err != nil {
+		if err == sql.ErrNoRows {
+			return "", "", huma.Error404NotFound("Вопрос не найден")
+		}
+		return "", "", huma.Error422UnprocessableEntity(err.Error())
+	}
+	return urid, author, nil
+}
@@ -0,0 +1,767 @@
+package results
+
+import (
+	"database/sql"
+	"fmt"
+	"hackaton-jam-back/controllers/judging"
+	"hackaton-jam-back/controllers/utils"
+	"hackaton-jam-back/controllers/voting"
+	"hackaton-jam-back/controllers/webhooks"
+	"log"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+type EventResult struct {
+	Id         int64  `json:"id" example:"1" doc:"Идентификатор результата"`
+	Track      string `json:"track" example:"" doc:"Трек (пусто - общий зачет)"`
+	CaseId     int64  `json:"case_id,omitempty" example:"1" doc:"Кейс партнера (если результат по кейсу)"`
+	CaseTitle  string `json:"case_title,omitempty" example:"Чат-бот для банка" doc:"Название кейса"`
+	TeamId     int64  `json:"team_id" example:"2" doc:"Идентификатор команды"`
+	TeamName   string `json:"team_name" example:"Супер-команда" doc:"Название команды"`
+	Place      int    `json:"place,omitempty" example:"1" doc:"Место (если есть)"`
+	Nomination string `json:"nomination,omitempty" example:"Лучший арт" doc:"Номинация (если есть)"`
+	Prize      string `json:"prize,omitempty" example:"10 000 рублей" doc:"Приз"`
+}
+
+type UserAward struct {
+	EventUri   string `json:"event_urid" example:"example_events" doc:"Ссылка на мероприятие"`
+	EventName  string `json:"event_name" example:"Example GameJam" doc:"Название мероприятия"`
+	TeamId     int64  `json:"team_id" example:"2" doc:"Идентификатор команды"`
+	TeamName   string `json:"team_name" example:"Супер-команда" doc:"Название команды"`
+	Track      string `json:"track" example:"" doc:"Трек (пусто - общий зачет)"`
+	Place      int    `json:"place,omitempty" example:"1" doc:"Место (если есть)"`
+	Nomination string `json:"nomination,omitempty" example:"Лучший арт" doc:"Номинация (если есть)"`
+	Prize      string `json:"prize,omitempty" example:"10 000 рублей" doc:"Приз"`
+}
+
+type AuditEntry struct {
+	Id        int64     `json:"id" example:"1" doc:"Идентификатор записи"`
+	Actor     string    `json:"actor" example:"thatmaidguy@ya.ru" doc:"Кто совершил действие"`
+	Action    string    `json:"action" example:"publish" doc:"Действие (add, delete, publish, unlock)"`
+	Details   string    `json:"details" doc:"Подробности"`
+	Reason    string    `json:"reason" doc:"Причина (для снятия фиксации)"`
+	CreatedAt time.Time `json:"created_at" doc:"Время действия"`
+}
+
+type ResultAddInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+
+		TeamId     int64  `json:"team_id" example:"2" doc:"Идентификатор команды"`
+		Track      string `json:"track,omitempty" example:"" doc:"Трек (пусто - общий зачет)"`
+		CaseId     int64  `json:"case_id,omitempty" example:"1" doc:"Кейс партнера (команда должна была его выбрать)"`
+		Place      int    `json:"place,omitempty" example:"1" doc:"Место"`
+		Nomination string `json:"nomination,omitempty" example:"Лучший арт" doc:"Номинация"`
+		Prize      string `json:"prize,omitempty" example:"10 000 рублей" doc:"Приз"`
+	}
+}
+
+type ResultDelInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Id   int64  `path:"id" example:"1" doc:"Идентификатор результата"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type ResultsFromLeaderboardInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token  string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+		Top    int    `json:"top" example:"3" doc:"Сколько призовых мест взять из таблицы лидеров"`
+		Track  string `json:"track,omitempty" example:"" doc:"Трек (пусто - общий зачет)"`
+		CaseId int64  `json:"case_id,omitempty" example:"1" doc:"Взять места из таблицы лидеров кейса партнера"`
+	}
+}
+
+type ResultsAudienceChoiceInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token      string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+		Nomination string `json:"nomination,omitempty" maxLength:"255" example:"Приз зрительских симпатий" doc:"Название номинации"`
+		Prize      string `json:"prize,omitempty" maxLength:"255" example:"10 000 рублей" doc:"Приз"`
+	}
+}
+
+type ResultsEventInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type ResultsPreviewInput struct {
+	Urid   string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	CaseId int64  `query:"case_id" example:"1" doc:"Только результаты по этому кейсу"`
+	Body   struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type ResultsUnlockInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token  string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+		Reason string `json:"reason" example:"Ошибка в номинации" doc:"Причина снятия фиксации"`
+	}
+}
+
+type EventResultsOutput struct {
+	Body struct {
+		Published bool           `json:"published" doc:"Опубликованы ли результаты"`
+		Locked    bool           `json:"locked" doc:"Зафиксированы ли результаты"`
+		Results   []*EventResult `json:"results" doc:"Результаты"`
+	}
+}
+
+type ResultsAuditOutput struct {
+	Body struct {
+		Entries []*AuditEntry `json:"entries" doc:"Журнал изменений результатов"`
+	}
+}
+
+func GetPublicResults(urid string, caseId int64, token string, db *sql.DB) (*EventResultsOutput, error) {
+	if err := utils.CheckEventVisible(token, urid, db); err != nil {
+		return nil, err
+	}
+
+	published, locked, err := getResultsState(urid, db)
+	if err != nil {
+		return nil, err
+	}
+	if !published {
+		return nil, huma.Error403Forbidden("Результаты еще не опубликованы")
+	}
+
+	return getResultsOutput(urid, caseId, published, locked, db)
+}
+
+func GetOrganizatorResults(input *ResultsPreviewInput, db *sql.DB) (*EventResultsOutput, error) {
+	if _, err := checkOrganizator(input.Body.Token, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	published, locked, err := getResultsState(input.Urid, db)
+	if err != nil {
+		return nil, err
+	}
+
+	return getResultsOutput(input.Urid, input.CaseId, published, locked, db)
+}
+
+func AddResult(input *ResultAddInput, db *sql.DB) (*EventResultsOutput, error) {
+	if input.Body.Place <= 0 && input.Body.Nomination == "" {
+		return nil, huma.Error422UnprocessableEntity("Нужно указать место или номинацию")
+	}
+
+	user, err := checkOrganizator(input.Body.Token, input.Urid, db)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer tx.Rollback()
+	if err := checkResultsEditable(input.Urid, tx); err != nil {
+		return nil, err
+	}
+
+	var teamName string
+	var teamCase sql.NullInt64
+	if err := db.QueryRow("SELECT name, case_id FROM teams WHERE id = $1 AND event_uri = $2", input.Body.TeamId, input.Urid).Scan(&teamName, &teamCase); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, huma.Error422UnprocessableEntity("Команда не участвует в этом событии")
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	caseTitle, err := getCaseTitle(input.Body.CaseId, input.Urid, db)
+	if err != nil {
+		return nil, err
+	}
+	if input.Body.CaseId != 0 && teamCase.Int64 != input.Body.CaseId {
+		return nil, huma.Error422UnprocessableEntity("Команда не выбирала этот кейс")
+	}
+
+	if err := insertResult(input.Urid, input.Body.TeamId, input.Body.Track, input.Body.CaseId, input.Body.Place, input.Body.Nomination, input.Body.Prize, tx); err != nil {
+		return nil, err
+	}
+
+	if err := writeAudit(input.Urid, user.Email, "add",
+		describeResult(teamName, input.Body.Track, caseTitle, input.Body.Place, input.Body.Nomination, input.Body.Prize), "", tx); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	published, locked, err := getResultsState(input.Urid, db)
+	if err != nil {
+		return nil, err
+	}
+
+	return getResultsOutput(input.Urid, 0, published, locked, db)
+}
+
+func DelResult(input *ResultDelInput, db *sql.DB) (*EventResultsOutput, error) {
+	user, err := checkOrganizator(input.Body.Token, input.Urid, db)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer tx.Rollback()
+	if err := checkResultsEditable(input.Urid, tx); err != nil {
+		return nil, err
+	}
+
+	result, err := getResult(input.Id, input.Urid, db)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec("DELETE FROM event_results WHERE id = $1", input.Id)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	if err := writeAudit(input.Urid, user.Email, "delete",
+		describeResult(result.TeamName, result.Track, result.CaseTitle, result.Place, result.Nomination, result.Prize), "", tx); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	published, locked, err := getResultsState(input.Urid, db)
+	if err != nil {
+		return nil, err
+	}
+
+	return getResultsOutput(input.Urid, 0, published, locked, db)
+}
+
+func AddResultsFromLeaderboard(input *ResultsFromLeaderboardInput, db *sql.DB) (*EventResultsOutput, error) {
+	if input.Body.Top <= 0 {
+		return nil, huma.Error422UnprocessableEntity("Количество мест должно быть больше нуля")
+	}
+
+	user, err := checkOrganizator(input.Body.Token, input.Urid, db)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer tx.Rollback()
+	if err := checkResultsEditable(input.Urid, tx); err != nil {
+		return nil, err
+	}
+
+	caseTitle, err := getCaseTitle(input.Body.CaseId, input.Urid, db)
+	if err != nil {
+		return nil, err
+	}
+
+	leaderboard, err := judging.ComputeLeaderboard(input.Urid, db)
+	if input.Body.CaseId != 0 {
+		leaderboard, err = judging.ComputeCaseLeaderboard(input.Urid, input.Body.CaseId, db)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range leaderboard {
+		if entry.Place > input.Body.Top {
+			break
+		}
+
+		// Повторный перенос таблицы лидеров добавляет только недостающие места
+		if err := insertResult(input.Urid, entry.TeamId, input.Body.Track, input.Body.CaseId, entry.Place, "", "", tx); err == errDuplicateResult {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		if err := writeAudit(input.Urid, user.Email, "add",
+			describeResult(entry.TeamName, input.Body.Track, caseTitle, entry.Place, "", ""), "", tx); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	published, locked, err := getResultsState(input.Urid, db)
+	if err != nil {
+		return nil, err
+	}
+
+	return getResultsOutput(input.Urid, 0, published, locked, db)
+}
+
+// Номинация по итогам зрительского голосования. При равенстве голосов награждаются все лидеры
+func AddAudienceChoice(input *ResultsAudienceChoiceInput, db *sql.DB) (*EventResultsOutput, error) {
+	user, err := checkOrganizator(input.Body.Token, input.Urid, db)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer tx.Rollback()
+	if err := checkResultsEditable(input.Urid, tx); err != nil {
+		return nil, err
+	}
+
+	nomination := input.Body.Nomination
+	if nomination == "" {
+		nomination = "Приз зрительских симпатий"
+	}
+
+	winners, err := voting.GetWinners(input.Urid, db)
+	if err != nil {
+		return nil, err
+	}
+	if len(winners) == 0 {
+		return nil, huma.Error422UnprocessableEntity("В голосовании нет ни одного голоса")
+	}
+
+	for _, winner := range winners {
+		if err := insertResult(input.Urid, winner.TeamId, "", 0, 0, nomination, input.Body.Prize, tx); err == errDuplicateResult {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		if err := writeAudit(input.Urid, user.Email, "add",
+			describeResult(winner.TeamName, "", "", 0, nomination, input.Body.Prize), "", tx); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	published, locked, err := getResultsState(input.Urid, db)
+	if err != nil {
+		return nil, err
+	}
+
+	return getResultsOutput(input.Urid, 0, published, locked, db)
+}
+
+func PublishResults(input *ResultsEventInput, db *sql.DB) (*EventResultsOutput, error) {
+	user, err := checkOrganizator(input.Body.Token, input.Urid, db)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer tx.Rollback()
+	if err := checkResultsEditable(input.Urid, tx); err != nil {
+		return nil, err
+	}
+
+	// Публикация сразу фиксирует результаты
+	_, err = tx.Exec("UPDATE events SET results_published = true, results_locked = true WHERE urid = $1", input.Urid)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	if err := writeAudit(input.Urid, user.Email, "publish", "", "", tx); err != nil {
+		return nil, err
+	}
+
+	// Кидаем уведомление о награждении (4) участникам команд. После снятия фиксации и повторной
+	// публикации уведомляются только за новые награды: строка помечается, когда о ней сообщили
+	_, err = tx.Exec(
+		"WITH fresh AS (UPDATE event_results SET notified = true WHERE event_uri = $1 AND notified = false RETURNING team_id) "+
+			"INSERT INTO notifications (\"user\", team_id, type, \"from\", event_uri) "+
+			"SELECT DISTINCT teams_members.member_email, fresh.team_id, 4, $2, $1 "+
+			"FROM fresh INNER JOIN teams_members ON teams_members.team_id = fresh.team_id "+
+			"WHERE teams_members.pending = false",
+		input.Urid, user.Email,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	output, err := getResultsOutput(input.Urid, 0, true, true, db)
+	if err != nil {
+		return nil, err
+	}
+	if err := webhooks.Enqueue(input.Urid, webhooks.TypeResultsPublished, output.Body, db); err != nil {
+		log.Println("Вебхуки: " + err.Error())
+	}
+
+	return output, nil
+}
+
+func UnlockResults(input *ResultsUnlockInput, db *sql.DB) (*EventResultsOutput, error) {
+	if input.Body.Reason == "" {
+		return nil, huma.Error422UnprocessableEntity("Нужно указать причину снятия фиксации")
+	}
+
+	user, err := checkOrganizator(input.Body.Token, input.Urid, db)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer tx.Rollback()
+
+	published, locked, err := lockResultsState(input.Urid, tx)
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, huma.Error422UnprocessableEntity("Результаты не зафиксированы")
+	}
+
+	_, err = tx.Exec("UPDATE events SET results_locked = false WHERE urid = $1", input.Urid)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	if err := writeAudit(input.Urid, user.Email, "unlock", "", input.Body.Reason, tx); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return getResultsOutput(input.Urid, 0, published, false, db)
+}
+
+func GetResultsAudit(input *ResultsEventInput, db *sql.DB) (*ResultsAuditOutput, error) {
+	if _, err := checkOrganizator(input.Body.Token, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(
+		"SELECT id, actor, action, details, reason, created_at FROM event_results_audit WHERE event_uri = $1 ORDER BY id DESC",
+		input.Urid,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	result := new(ResultsAuditOutput)
+
+	for rows.Next() {
+		entry := new(AuditEntry)
+		var details, reason sql.NullString
+		if err := rows.Scan(&entry.Id, &entry.Actor, &entry.Action, &details, &reason, &entry.CreatedAt); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		entry.Details = details.String
+		entry.Reason = reason.String
+
+		result.Body.Entries = append(result.Body.Entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
+
+// Результаты для страницы события (пусто, пока не опубликованы)
+func GetPublishedResults(urid string, db *sql.DB) ([]*EventResult, error) {
+	published, _, err := getResultsState(urid, db)
+	if err != nil {
+		return nil, err
+	}
+	if !published {
+		return nil, nil
+	}
+
+	return getEventResults(urid, 0, db)
+}
+
+// Награды пользователя для профиля
+func GetUserAwards(email string, db *sql.DB) ([]*UserAward, error) {
+	rows, err := db.Query(
+		"SELECT events.urid, events.name, teams.id, teams.name, event_results.track, "+
+			"event_results.place, event_results.nomination, event_results.prize "+
+			"FROM event_results INNER JOIN teams ON event_results.team_id = teams.id "+
+			"INNER JOIN events ON event_results.event_uri = events.urid "+
+			"INNER JOIN teams_members ON teams_members.team_id = teams.id "+
+			"WHERE teams_members.member_email = $1 AND teams_members.pending = false AND events.results_published = true "+
+			"ORDER BY events.start_time DESC, event_results.place NULLS LAST",
+		email,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	var result []*UserAward
+
+	for rows.Next() {
+		award := new(UserAward)
+		var place sql.NullInt64
+		var nomination, prize sql.NullString
+		if err := rows.Scan(
+			&award.EventUri,
+			&award.EventName,
+			&award.TeamId,
+			&award.TeamName,
+			&award.Track,
+			&place,
+			&nomination,
+			&prize,
+		); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		award.Place = int(place.Int64)
+		award.Nomination = nomination.String
+		award.Prize = prize.String
+
+		result = append(result, award)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
+
+func getResultsOutput(urid string, caseId int64, published bool, locked bool, db *sql.DB) (*EventResultsOutput, error) {
+	list, err := getEventResults(urid, caseId, db)
+	if err != nil {
+		return nil, err
+	}
+
+	result := new(EventResultsOutput)
+	result.Body.Published = published
+	result.Body.Locked = locked
+	result.Body.Results = list
+
+	return result, nil
+}
+
+func getEventResults(urid string, caseId int64, db *sql.DB) ([]*EventResult, error) {
+	rows, err := db.Query(
+		"SELECT "+resultColumns+" FROM event_results INNER JOIN teams ON event_results.team_id = teams.id "+
+			"LEFT JOIN event_cases ON event_results.case_id = event_cases.id "+
+			"WHERE event_results.event_uri = $1 AND ($2::bigint = 0 OR event_results.case_id = $2) "+
+			"ORDER BY event_results.track, event_results.case_id NULLS FIRST, event_results.place NULLS LAST, event_results.id",
+		urid, caseId,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	var result []*EventResult
+
+	for rows.Next() {
+		entry, err := scanResult(rows)
+		if err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		result = append(result, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
+
+func getResult(id int64, urid string, db *sql.DB) (*EventResult, error) {
+	row := db.QueryRow(
+		"SELECT "+resultColumns+" FROM event_results INNER JOIN teams ON event_results.team_id = teams.id "+
+			"LEFT JOIN event_cases ON event_results.case_id = event_cases.id "+
+			"WHERE event_results.id = $1 AND event_results.event_uri = $2",
+		id, urid,
+	)
+
+	entry, err := scanResult(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, huma.Error404NotFound("Такого результата нет")
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return entry, nil
+}
+
+const resultColumns = "event_results.id, event_results.track, event_results.case_id, event_cases.title, " +
+	"event_results.team_id, teams.name, event_results.place, event_results.nomination, event_results.prize"
+
+func scanResult(row interface{ Scan(dest ...any) error }) (*EventResult, error) {
+	entry := new(EventResult)
+	var place, caseId sql.NullInt64
+	var caseTitle, nomination, prize sql.NullString
+	if err := row.Scan(&entry.Id, &entry.Track, &caseId, &caseTitle, &entry.TeamId, &entry.TeamName, &place, &nomination, &prize); err != nil {
+		return nil, err
+	}
+	entry.CaseId = caseId.Int64
+	entry.CaseTitle = caseTitle.String
+	entry.Place = int(place.Int64)
+	entry.Nomination = nomination.String
+	entry.Prize = prize.String
+
+	return entry, nil
+}
+
+// Такая же награда у команды уже есть
+var errDuplicateResult = huma.Error422UnprocessableEntity("Эта команда уже награждена так же")
+
+func insertResult(urid string, teamId int64, track string, caseId int64, place int, nomination string, prize string, tx *sql.Tx) error {
+	var caseValue sql.NullInt64
+	if caseId != 0 {
+		caseValue = sql.NullInt64{Int64: caseId, Valid: true}
+	}
+	var placeValue sql.NullInt64
+	if place > 0 {
+		placeValue = sql.NullInt64{Int64: int64(place), Valid: true}
+	}
+	var nominationValue sql.NullString
+	if nomination != "" {
+		nominationValue = sql.NullString{String: nomination, Valid: true}
+	}
+	var prizeValue sql.NullString
+	if prize != "" {
+		prizeValue = sql.NullString{String: prize, Valid: true}
+	}
+
+	res, err := tx.Exec(
+		"INSERT INTO event_results (event_uri, track, case_id, team_id, place, nomination, prize) VALUES ($1, $2, $3, $4, $5, $6, $7) "+
+			"ON CONFLICT ON CONSTRAINT event_results_uq DO NOTHING",
+		urid, track, caseValue, teamId, placeValue, nominationValue, prizeValue,
+	)
+	if err != nil {
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errDuplicateResult
+	}
+
+	return nil
+}
+
+func getResultsState(urid string, db *sql.DB) (published bool, locked bool, err error) {
+	if err := db.QueryRow("SELECT results_published, results_locked FROM events WHERE urid = $1", urid).Scan(&published, &locked); err != nil {
+		if err == sql.ErrNoRows {
+			return false, false, huma.Error403Forbidden("Этого события нет XP")
+		}
+		return false, false, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return published, locked, nil
+}
+
+// Блокирует строку события до конца транзакции, чтобы правки не пересеклись с публикацией или снятием фиксации
+func lockResultsState(urid string, tx *sql.Tx) (published bool, locked bool, err error) {
+	if err := tx.QueryRow("SELECT results_published, results_locked FROM events WHERE urid = $1 FOR UPDATE", urid).Scan(&published, &locked); err != nil {
+		if err == sql.ErrNoRows {
+			return false, false, huma.Error403Forbidden("Этого события нет XP")
+		}
+		return false, false, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return published, locked, nil
+}
+
+func checkResultsEditable(urid string, tx *sql.Tx) error {
+	_, locked, err := lockResultsState(urid, tx)
+	if err != nil {
+		return err
+	}
+	if locked {
+		return huma.Error403Forbidden("Результаты зафиксированы, сначала снимите фиксацию с указанием причины")
+	}
+
+	return nil
+}
+
+func checkOrganizator(token string, urid string, db *sql.DB) (*utils.UserEmail, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, urid, db); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func describeResult(teamName string, track string, caseTitle string, place int, nomination string, prize string) string {
+	details := "Команда \"" + teamName + "\""
+	if track != "" {
+		details += fmt.Sprintf(", трек \"%s\"", track)
+	}
+	if caseTitle != "" {
+		details += fmt.Sprintf(", кейс \"%s\"", caseTitle)
+	}
+	if place > 0 {
+		details += fmt.Sprintf(", место %d", place)
+	}
+	if nomination != "" {
+		details += fmt.Sprintf(", номинация \"%s\"", nomination)
+	}
+	if prize != "" {
+		details += fmt.Sprintf(", приз \"%s\"", prize)
+	}
+
+	return details
+}
+
+// Пустой caseId - результат без кейса
+func getCaseTitle(caseId int64, urid string, db *sql.DB) (string, error) {
+	if caseId == 0 {
+		return "", nil
+	}
+
+	var title string
+	if err := db.QueryRow("SELECT title FROM event_cases WHERE id = $1 AND event_uri = $2", caseId, urid).Scan(&title); err != nil {
+		if err == sql.ErrNoRows {
+			return "", huma.Error422UnprocessableEntity("Такого кейса нет в этом событии")
+		}
+		return "", huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return title, nil
+}
+
+func writeAudit(urid string, actor string, action string, details string, reason string, tx *sql.Tx) error {
+	_, err := tx.Exec(
+		"INSERT INTO event_results_audit (event_uri, actor, action, details, reason) VALUES ($1, $2, $3, $4, $5)",
+		urid, actor, action, details, reason,
+	)
+	if err != nil {
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,582 @@
+package series
+
+import (
+	"database/sql"
+	"hackaton-jam-back/controllers/utils"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/lib/pq"
+)
+
+type SeriesShortInfo struct {
+	Urid     string `json:"urid" example:"autumn_jams" doc:"Ссылка на серию"`
+	Name     string `json:"name" example:"Осенние джемы" doc:"Название серии"`
+	Icon     string `json:"icon" doc:"Превью серии"`
+	Editions int    `json:"editions" example:"3" doc:"Количество опубликованных выпусков"`
+}
+
+type SeriesEdition struct {
+	Urid      string    `json:"urid" example:"example_events" doc:"Ссылка на мероприятие"`
+	Name      string    `json:"name" example:"Example GameJam" doc:"Название мероприятия"`
+	StartTime time.Time `json:"start_time" doc:"Начало проведения"`
+	EndTime   time.Time `json:"end_time" doc:"Конец проведения"`
+	Location  string    `json:"location" example:"Свердловская область, г. Екатеринбург" doc:"Место проведения"`
+	Icon      string    `json:"icon" doc:"Превью мероприятия"`
+	Season    string    `json:"season" example:"2026" doc:"Сезон"`
+	Locale    string    `json:"locale" example:"ru" doc:"Язык названия"`
+}
+
+type SeasonStanding struct {
+	Place     int                  `json:"place" example:"1" doc:"Место в сезоне"`
+	User      *utils.UserShortInfo `json:"user" doc:"Участник"`
+	Points    int                  `json:"points" example:"43" doc:"Очки"`
+	Events    int                  `json:"events" example:"2" doc:"Мероприятий с наградами"`
+	Wins      int                  `json:"wins" example:"1" doc:"Первых мест в общем зачете"`
+	BestPlace int                  `json:"best_place,omitempty" example:"1" doc:"Лучшее место в общем зачете"`
+}
+
+type SeriesOutput struct {
+	Vary string `header:"Vary"`
+	Body struct {
+		Urid             string  `json:"urid" example:"autumn_jams" doc:"Ссылка на серию"`
+		Name             string  `json:"name" example:"Осенние джемы" doc:"Название серии"`
+		Description      string  `json:"desc" doc:"Описание серии"`
+		Icon             string  `json:"icon" doc:"Превью серии"`
+		Owner            string  `json:"owner" example:"thatmaidguy@ya.ru" doc:"Владелец серии"`
+		PlacePoints      []int64 `json:"place_points" doc:"Очки за места в общем зачете (первый элемент - за 1 место)"`
+		NominationPoints int     `json:"nomination_points" example:"5" doc:"Очки за номинацию"`
+		Subscribers      int     `json:"subscribers" example:"10" doc:"Количество подписчиков"`
+
+		Seasons  []string         `json:"seasons" doc:"Сезоны серии"`
+		Upcoming []*SeriesEdition `json:"upcoming" doc:"Текущие и будущие выпуски"`
+		Past     []*SeriesEdition `json:"past" doc:"Прошедшие выпуски"`
+	}
+}
+
+type SeriesListOutput struct {
+	Body struct {
+		Series []*SeriesShortInfo `json:"series" doc:"Серии мероприятий"`
+	}
+}
+
+type SeasonLeaderboardOutput struct {
+	Body struct {
+		Season    string            `json:"season" example:"2026" doc:"Сезон (пусто - за все время)"`
+		Standings []*SeasonStanding `json:"standings" doc:"Турнирная таблица"`
+	}
+}
+
+type SeriesCreateInput struct {
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+
+		Urid             string  `json:"urid" maxLength:"30" example:"autumn_jams" doc:"Ссылка на серию (Поменять потом нельзя!!!)"`
+		Name             string  `json:"name" example:"Осенние джемы" doc:"Название серии"`
+		Description      string  `json:"desc,omitempty" doc:"Описание серии"`
+		Icon             string  `json:"icon,omitempty" doc:"Превью серии"`
+		PlacePoints      []int64 `json:"place_points,omitempty" doc:"Очки за места (по умолчанию 25, 18, 15, 12, 10, 8, 6, 4, 2, 1)"`
+		NominationPoints *int    `json:"nomination_points,omitempty" example:"5" doc:"Очки за номинацию (по умолчанию 5)"`
+	}
+}
+
+type SeriesEditInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"autumn_jams" doc:"Ссылка на серию"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+
+		Name             string  `json:"name,omitempty" example:"Осенние джемы" doc:"Название серии"`
+		Description      string  `json:"desc,omitempty" doc:"Описание серии"`
+		Icon             string  `json:"icon,omitempty" doc:"Превью серии"`
+		PlacePoints      []int64 `json:"place_points,omitempty" doc:"Очки за места (первый элемент - за 1 место)"`
+		NominationPoints *int    `json:"nomination_points,omitempty" example:"5" doc:"Очки за номинацию"`
+	}
+}
+
+type SeriesTokenInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"autumn_jams" doc:"Ссылка на серию"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type SeriesEventInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"autumn_jams" doc:"Ссылка на серию"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+
+		EventUrid string `json:"event_urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+		Season    string `json:"season,omitempty" example:"2026" doc:"Сезон (для добавления)"`
+	}
+}
+
+func GetAllSeries(db *sql.DB) (*SeriesListOutput, error) {
+	rows, err := db.Query(
+		"SELECT event_series.urid, event_series.name, event_series.icon, COUNT(events.urid) " +
+			"FROM event_series LEFT JOIN events ON events.series_urid = event_series.urid AND events.is_draft = false AND events.visibility = 'public' AND events.moderation_status = 'approved' " +
+			"GROUP BY event_series.urid ORDER BY event_series.created_at DESC",
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	result := new(SeriesListOutput)
+
+	for rows.Next() {
+		series := new(SeriesShortInfo)
+		var icon sql.NullString
+		if err := rows.Scan(&series.Urid, &series.Name, &icon, &series.Editions); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		series.Icon = icon.String
+
+		result.Body.Series = append(result.Body.Series, series)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
+
+// Названия выпусков отдаются на языке из locale, если есть перевод
+func GetSeries(urid string, locale utils.LocaleInput, db *sql.DB) (*SeriesOutput, error) {
+	result, err := getSeries(urid, db)
+	if err != nil {
+		return nil, err
+	}
+
+	editions := make([]*SeriesEdition, 0, len(result.Body.Upcoming)+len(result.Body.Past))
+	editions = append(append(editions, result.Body.Upcoming...), result.Body.Past...)
+	urids := make([]string, len(editions))
+	for i, edition := range editions {
+		urids[i] = edition.Urid
+	}
+
+	preferred := utils.PreferredLocales(locale)
+	translations, err := utils.GetNameTranslations(urids, preferred, db)
+	if err != nil {
+		return nil, err
+	}
+	for _, edition := range editions {
+		edition.Name, edition.Locale = translations.Localize(edition.Urid, edition.Name, edition.Locale, preferred)
+	}
+	result.Vary = utils.VaryLanguage
+
+	return result, nil
+}
+
+func GetMySubscriptions(input *utils.JustAccessTokenInput, db *sql.DB) (*SeriesListOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+
+	rows, err := db.Query(
+		"SELECT event_series.urid, event_series.name, event_series.icon, "+
+			"(SELECT COUNT(*) FROM events WHERE events.series_urid = event_series.urid AND events.is_draft = false AND events.visibility = 'public' AND events.moderation_status = 'approved') "+
+			"FROM event_series JOIN series_subscriptions ON series_subscriptions.series_urid = event_series.urid "+
+			"WHERE series_subscriptions.user_email = $1 ORDER BY event_series.name", user.Email,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	result := new(SeriesListOutput)
+
+	for rows.Next() {
+		series := new(SeriesShortInfo)
+		var icon sql.NullString
+		if err := rows.Scan(&series.Urid, &series.Name, &icon, &series.Editions); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		series.Icon = icon.String
+
+		result.Body.Series = append(result.Body.Series, series)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
+
+func CreateSeries(input *SeriesCreateInput, db *sql.DB) (*SeriesOutput, error) {
+	// Проверить можем ли создать серию?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if user.Perms < 1 {
+		return nil, huma.Error403Forbidden("Нет прав")
+	}
+
+	if input.Body.Urid == "" || input.Body.Name == "" {
+		return nil, huma.Error422UnprocessableEntity("Ссылка и название серии не должны быть пустыми")
+	}
+	if err := checkPoints(input.Body.PlacePoints, input.Body.NominationPoints); err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(
+		"INSERT INTO event_series (urid, name, \"desc\", icon, owner_email) VALUES ($1, $2, $3, $4, $5)",
+		input.Body.Urid, input.Body.Name, input.Body.Description, input.Body.Icon, user.Email,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	if err := updatePoints(input.Body.Urid, input.Body.PlacePoints, input.Body.NominationPoints, db); err != nil {
+		return nil, err
+	}
+
+	return getSeries(input.Body.Urid, db)
+}
+
+func EditSeries(input *SeriesEditInput, db *sql.DB) (*SeriesOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := checkSeriesOwner(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+	if err := checkPoints(input.Body.PlacePoints, input.Body.NominationPoints); err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(
+		"UPDATE event_series SET "+
+			"name = COALESCE(NULLIF($2, ''), name), "+
+			"\"desc\" = COALESCE(NULLIF($3, ''), \"desc\"), "+
+			"icon = COALESCE(NULLIF($4, ''), icon) "+
+			"WHERE urid = $1",
+		input.Urid, input.Body.Name, input.Body.Description, input.Body.Icon,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	if err := updatePoints(input.Urid, input.Body.PlacePoints, input.Body.NominationPoints, db); err != nil {
+		return nil, err
+	}
+
+	return getSeries(input.Urid, db)
+}
+
+func DeleteSeries(input *SeriesTokenInput, db *sql.DB) (*SeriesListOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := checkSeriesOwner(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	// Сами мероприятия остаются, просто выходят из серии
+	if _, err := db.Exec("UPDATE events SET series_urid = NULL, season = NULL WHERE series_urid = $1", input.Urid); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if _, err := db.Exec("DELETE FROM series_subscriptions WHERE series_urid = $1", input.Urid); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if _, err := db.Exec("DELETE FROM event_series WHERE urid = $1", input.Urid); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return GetAllSeries(db)
+}
+
+func AddSeriesEvent(input *SeriesEventInput, db *sql.DB) (*SeriesOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := checkSeriesOwner(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+	if err := utils.CheckEventOrganizator(user, input.Body.EventUrid, db); err != nil {
+		return nil, err
+	}
+
+	var season sql.NullString
+	if input.Body.Season != "" {
+		season = sql.NullString{String: input.Body.Season, Valid: true}
+	}
+
+	var previous sql.NullString
+	if err := db.QueryRow("SELECT series_urid FROM events WHERE urid = $1", input.Body.EventUrid).Scan(&previous); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, huma.Error404NotFound("Такого мероприятия нет")
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	_, err = db.Exec("UPDATE events SET series_urid = $2, season = $3 WHERE urid = $1", input.Body.EventUrid, input.Urid, season)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	// Подписчиков оповещаем только о новом выпуске, а не о смене сезона
+	if previous.String != input.Urid {
+		if err := NotifyNewEdition(input.Body.EventUrid, user.Email, db); err != nil {
+			return nil, err
+		}
+	}
+
+	return getSeries(input.Urid, db)
+}
+
+func DelSeriesEvent(input *SeriesEventInput, db *sql.DB) (*SeriesOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := checkSeriesOwner(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(
+		"UPDATE events SET series_urid = NULL, season = NULL WHERE urid = $1 AND series_urid = $2",
+		input.Body.EventUrid, input.Urid,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return getSeries(input.Urid, db)
+}
+
+func Subscribe(input *SeriesTokenInput, db *sql.DB) (*SeriesOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+
+	_, err = db.Exec(
+		"INSERT INTO series_subscriptions (series_urid, user_email) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+		input.Urid, user.Email,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return getSeries(input.Urid, db)
+}
+
+func Unsubscribe(input *SeriesTokenInput, db *sql.DB) (*SeriesOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+
+	_, err = db.Exec("DELETE FROM series_subscriptions WHERE series_urid = $1 AND user_email = $2", input.Urid, user.Email)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return getSeries(input.Urid, db)
+}
+
+// Очки считаются по опубликованным итогам: места берутся из общего зачета,
+// номинации - из любого трека. Очки получает каждый участник команды
+func GetSeasonLeaderboard(urid string, season string, db *sql.DB) (*SeasonLeaderboardOutput, error) {
+	if _, err := getSeries(urid, db); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(
+		"SELECT teams_members.member_email, "+
+			"SUM("+
+			"CASE WHEN event_results.track = '' AND event_results.place BETWEEN 1 AND cardinality(event_series.place_points) "+
+			"THEN event_series.place_points[event_results.place] ELSE 0 END + "+
+			"CASE WHEN COALESCE(event_results.nomination, '') <> '' THEN event_series.nomination_points ELSE 0 END"+
+			") AS points, "+
+			"COUNT(DISTINCT event_results.event_uri), "+
+			"COUNT(*) FILTER (WHERE event_results.track = '' AND event_results.place = 1) AS wins, "+
+			"MIN(event_results.place) FILTER (WHERE event_results.track = '') "+
+			"FROM event_results "+
+			"JOIN events ON events.urid = event_results.event_uri "+
+			"JOIN event_series ON event_series.urid = events.series_urid "+
+			"JOIN teams_members ON teams_members.team_id = event_results.team_id AND teams_members.pending = false "+
+			"WHERE event_series.urid = $1 AND events.results_published = true AND ($2::varchar = '' OR events.season = $2) "+
+			"GROUP BY teams_members.member_email "+
+			"ORDER BY points DESC, wins DESC, teams_members.member_email",
+		urid, season,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	result := new(SeasonLeaderboardOutput)
+	result.Body.Season = season
+
+	var emails []string
+	for rows.Next() {
+		standing := new(SeasonStanding)
+		var email string
+		var best sql.NullInt64
+		if err := rows.Scan(&email, &standing.Points, &standing.Events, &standing.Wins, &best); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		standing.BestPlace = int(best.Int64)
+
+		emails = append(emails, email)
+		result.Body.Standings = append(result.Body.Standings, standing)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	for i, standing := range result.Body.Standings {
+		// Одинаковые очки - одинаковое место
+		if i > 0 && result.Body.Standings[i-1].Points == standing.Points {
+			standing.Place = result.Body.Standings[i-1].Place
+		} else {
+			standing.Place = i + 1
+		}
+
+		standing.User, err = utils.GetUserShortInfo(emails[i], db)
+		if err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+	}
+
+	return result, nil
+}
+
+// Оповещает подписчиков серии о новом выпуске. Черновики, не прошедшие модерацию
+// и уже начавшиеся мероприятия не анонсируются, повторно об одном выпуске не сообщаем
+func NotifyNewEdition(eventUrid string, from string, db *sql.DB) error {
+	_, err := db.Exec(
+		"INSERT INTO notifications (\"user\", type, \"from\", event_uri) "+
+			"SELECT series_subscriptions.user_email, 5, $2, events.urid "+
+			"FROM events JOIN series_subscriptions ON series_subscriptions.series_urid = events.series_urid "+
+			"WHERE events.urid = $1 AND events.is_draft = false AND events.visibility = 'public' "+
+			"AND events.moderation_status = 'approved' AND events.start_time > now() "+
+			"AND NOT EXISTS (SELECT 1 FROM notifications WHERE notifications.\"user\" = series_subscriptions.user_email "+
+			"AND notifications.type = 5 AND notifications.event_uri = events.urid)",
+		eventUrid, from,
+	)
+	if err != nil {
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return nil
+}
+
+func getSeries(urid string, db *sql.DB) (*SeriesOutput, error) {
+	result := new(SeriesOutput)
+
+	var desc sql.NullString
+	var icon sql.NullString
+	err := db.QueryRow(
+		"SELECT urid, name, \"desc\", icon, owner_email, place_points, nomination_points, "+
+			"(SELECT COUNT(*) FROM series_subscriptions WHERE series_subscriptions.series_urid = event_series.urid) "+
+			"FROM event_series WHERE urid = $1", urid,
+	).Scan(
+		&result.Body.Urid,
+		&result.Body.Name,
+		&desc,
+		&icon,
+		&result.Body.Owner,
+		(*pq.Int64Array)(&result.Body.PlacePoints),
+		&result.Body.NominationPoints,
+		&result.Body.Subscribers,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, huma.Error404NotFound("Такой серии нет")
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	result.Body.Description = desc.String
+	result.Body.Icon = icon.String
+
+	rows, err := db.Query(
+		"SELECT urid, name, start_time, end_time, \"location\", icon, season, default_locale, end_time < now() "+
+			"FROM events WHERE series_urid = $1 AND is_draft = false AND visibility = 'public' AND moderation_status = 'approved' ORDER BY start_time DESC", urid,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	seasons := map[string]bool{}
+	for rows.Next() {
+		edition := new(SeriesEdition)
+		var location sql.NullString
+		var icon sql.NullString
+		var season sql.NullString
+		var past bool
+		if err := rows.Scan(&edition.Urid, &edition.Name, &edition.StartTime, &edition.EndTime, &location, &icon, &season, &edition.Locale, &past); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		edition.Location = location.String
+		edition.Icon = icon.String
+		edition.Season = season.String
+
+		if season.String != "" && !seasons[season.String] {
+			seasons[season.String] = true
+			result.Body.Seasons = append(result.Body.Seasons, season.String)
+		}
+
+		if past {
+			result.Body.Past = append(result.Body.Past, edition)
+		} else {
+			result.Body.Upcoming = append(result.Body.Upcoming, edition)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
+
+func updatePoints(urid string, placePoints []int64, nominationPoints *int, db *sql.DB) error {
+	if len(placePoints) > 0 {
+		if _, err := db.Exec("UPDATE event_series SET place_points = $2 WHERE urid = $1", urid, pq.Array(placePoints)); err != nil {
+			return huma.Error422UnprocessableEntity(err.Error())
+		}
+	}
+	if nominationPoints != nil {
+		if _, err := db.Exec("UPDATE event_series SET nomination_points = $2 WHERE urid = $1", urid, *nominationPoints); err != nil {
+			return huma.Error422UnprocessableEntity(err.Error())
+		}
+	}
+
+	return nil
+}
+
+func checkPoints(placePoints []int64, nominationPoints *int) error {
+	for _, points := range placePoints {
+		if points < 0 {
+			return huma.Error422UnprocessableEntity("Очки не могут быть отрицательными")
+		}
+	}
+	if nominationPoints != nil && *nominationPoints < 0 {
+		return huma.Error422UnprocessableEntity("Очки не могут быть отрицательными")
+	}
+
+	return nil
+}
+
+func checkSeriesOwner(user *utils.UserEmail, urid string, db *sql.DB) error {
+	var owner string
+	if err := db.QueryRow("SELECT owner_email FROM event_series WHERE urid = $1", urid).Scan(&owner); err != nil {
+		if err == sql.ErrNoRows {
+			return huma.Error404NotFound("Такой серии нет")
+		}
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	if owner != user.Email && user.Perms != 10 {
+		return huma.Error403Forbidden("Это не твоя серия :/")
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+var ErrNotFound = errors.New("файл не найден")
+
+// Сведения о сохраненном файле
+type Object struct {
+	ContentType  string
+	Size         int64
+	LastModified time.Time
+}
+
+// Хранилище загруженных файлов. Ключ - имя файла без каталогов
+type Storage interface {
+	Put(key string, data []byte, contentType string) error
+	Get(key string) (io.ReadCloser, *Object, error)
+	Delete(key string) error
+}
+
+// Хранилище выбирается переменной STORAGE (local или s3)
+func New() (Storage, error) {
+	switch os.Getenv("STORAGE") {
+	case "", "local":
+		dir := os.Getenv("STORAGE_DIR")
+		if dir == "" {
+			dir = "uploads"
+		}
+		return NewLocal(dir)
+	case "s3":
+		return NewS3(S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		})
+	}
+	return nil, errors.New("неизвестное хранилище: " + os.Getenv("STORAGE"))
+}
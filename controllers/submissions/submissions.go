@@ -0,0 +1,454 @@
+package submissions
+
+import (
+	"database/sql"
+	"hackaton-jam-back/controllers/utils"
+	"hackaton-jam-back/controllers/webhooks"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/lib/pq"
+)
+
+type SubmissionSaveInput struct {
+	Id   int64 `path:"id" example:"0" doc:"Идентификатор команды"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+
+		Title           string   `json:"title" example:"Супер-игра" doc:"Название проекта"`
+		Description     string   `json:"desc,omitempty" doc:"Описание проекта"`
+		RepoUrl         string   `json:"repo_url,omitempty" example:"https://github.com/example/game" doc:"Ссылка на репозиторий"`
+		DemoUrl         string   `json:"demo_url,omitempty" example:"https://youtu.be/example" doc:"Ссылка на демо/видео"`
+		PresentationUrl string   `json:"presentation_url,omitempty" doc:"Ссылка на презентацию"`
+		Files           []string `json:"files,omitempty" doc:"Ссылки на загруженные файлы"`
+	}
+}
+
+type SubmissionGetInput struct {
+	Id   int64 `path:"id" example:"0" doc:"Идентификатор команды"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type EventSubmissionsInput struct {
+	Urid   string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	CaseId int64  `query:"case_id" example:"1" doc:"Только работы команд, выбравших этот кейс"`
+	Body   struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type SubmissionDeadlineInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token    string    `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+		Deadline time.Time `json:"deadline,omitempty" doc:"Крайний срок сдачи проектов (если не указан - срока нет)"`
+	}
+}
+
+type SubmissionInfo struct {
+	Id              int64     `json:"id" example:"1" doc:"Идентификатор работы"`
+	TeamId          int64     `json:"team_id" example:"2" doc:"Идентификатор команды"`
+	TeamName        string    `json:"team_name" example:"Супер-команда" doc:"Название команды"`
+	CaseId          int64     `json:"case_id,omitempty" example:"1" doc:"Кейс партнера, выбранный командой"`
+	EventUri        string    `json:"event_urid" example:"example_events" doc:"Ссылка на мероприятие"`
+	Title           string    `json:"title" example:"Супер-игра" doc:"Название проекта"`
+	Description     string    `json:"desc" doc:"Описание проекта"`
+	RepoUrl         string    `json:"repo_url" doc:"Ссылка на репозиторий"`
+	DemoUrl         string    `json:"demo_url" doc:"Ссылка на демо/видео"`
+	PresentationUrl string    `json:"presentation_url" doc:"Ссылка на презентацию"`
+	Files           []string  `json:"files" doc:"Ссылки на загруженные файлы"`
+	Version         int       `json:"version" example:"1" doc:"Номер версии"`
+	UpdatedAt       time.Time `json:"updated_at" doc:"Время последнего изменения"`
+	InVoting        bool      `json:"in_voting" doc:"Отобрана в зрительское голосование"`
+}
+
+type SubmissionVersion struct {
+	Version         int       `json:"version" example:"1" doc:"Номер версии"`
+	Author          string    `json:"author" example:"thatmaidguy@ya.ru" doc:"Кто сохранил версию"`
+	Title           string    `json:"title" example:"Супер-игра" doc:"Название проекта"`
+	Description     string    `json:"desc" doc:"Описание проекта"`
+	RepoUrl         string    `json:"repo_url" doc:"Ссылка на репозиторий"`
+	DemoUrl         string    `json:"demo_url" doc:"Ссылка на демо/видео"`
+	PresentationUrl string    `json:"presentation_url" doc:"Ссылка на презентацию"`
+	Files           []string  `json:"files" doc:"Ссылки на загруженные файлы"`
+	CreatedAt       time.Time `json:"created_at" doc:"Время сохранения версии"`
+}
+
+type SubmissionOutput struct {
+	Body SubmissionInfo
+}
+
+type SubmissionHistoryOutput struct {
+	Body struct {
+		History []*SubmissionVersion `json:"history" doc:"Все версии работы (от новых к старым)"`
+	}
+}
+
+type EventSubmissionsOutput struct {
+	Body struct {
+		Submissions []*SubmissionInfo `json:"submissions" doc:"Список работ команд"`
+	}
+}
+
+type SubmissionDeadlineOutput struct {
+	Body struct {
+		Urid     string     `json:"urid" example:"example_events" doc:"Ссылка на мероприятие"`
+		Deadline *time.Time `json:"deadline,omitempty" doc:"Крайний срок сдачи проектов"`
+	}
+}
+
+const submissionColumns = "submissions.id, submissions.team_id, teams.name, teams.case_id, submissions.event_uri, submissions.title, " +
+	"submissions.\"desc\", submissions.repo_url, submissions.demo_url, submissions.presentation_url, " +
+	"submissions.files, submissions.version, submissions.updated_at, submissions.in_voting"
+
+func SaveSubmission(input *SubmissionSaveInput, db *sql.DB) (*SubmissionOutput, error) {
+	if input.Body.Title == "" {
+		return nil, huma.Error422UnprocessableEntity("Название проекта не должно быть пустым")
+	}
+
+	// Проверка ссылок
+	links := []string{input.Body.RepoUrl, input.Body.DemoUrl, input.Body.PresentationUrl}
+	links = append(links, input.Body.Files...)
+	for _, link := range links {
+		if link == "" {
+			continue
+		}
+		if _, err := url.ParseRequestURI(link); err != nil {
+			return nil, huma.Error422UnprocessableEntity("Неверная ссылка: " + link)
+		}
+	}
+
+	// Проверяем, что пользователь тимлид
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil || user.Perms != 0 {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+
+	var eventUri string
+	var teamleader string
+	if err := db.QueryRow("SELECT event_uri, teamleader FROM teams WHERE id = $1", input.Id).Scan(&eventUri, &teamleader); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, huma.Error403Forbidden("Этой команды нет")
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if teamleader != user.Email {
+		return nil, huma.Error403Forbidden("Вы не тимлид команды")
+	}
+
+	// Проверяем срок сдачи
+	if err := checkSubmissionDeadline(eventUri, db); err != nil {
+		return nil, err
+	}
+
+	// Сдать работу может только команда подходящего размера
+	if err := checkTeamSize(input.Id, eventUri, db); err != nil {
+		return nil, err
+	}
+
+	files := input.Body.Files
+	if files == nil {
+		files = []string{}
+	}
+
+	// Работа и ее версия в истории сохраняются вместе, иначе в истории могла бы пропасть версия
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer tx.Rollback()
+
+	// Сохраняем работу, каждое сохранение - новая версия
+	var submissionId int64
+	var version int
+	if err := tx.QueryRow(
+		"INSERT INTO submissions (team_id, event_uri, title, \"desc\", repo_url, demo_url, presentation_url, files) "+
+			"VALUES ($1, $2, $3, $4, $5, $6, $7, $8) "+
+			"ON CONFLICT (team_id) DO UPDATE SET "+
+			"title = EXCLUDED.title, \"desc\" = EXCLUDED.\"desc\", repo_url = EXCLUDED.repo_url, "+
+			"demo_url = EXCLUDED.demo_url, presentation_url = EXCLUDED.presentation_url, files = EXCLUDED.files, "+
+			"version = submissions.version + 1, updated_at = now() "+
+			"RETURNING id, version",
+		input.Id, eventUri, input.Body.Title, input.Body.Description, input.Body.RepoUrl,
+		input.Body.DemoUrl, input.Body.PresentationUrl, pq.Array(files),
+	).Scan(&submissionId, &version); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	// Записываем в историю
+	_, err = tx.Exec(
+		"INSERT INTO submission_history (submission_id, version, author, title, \"desc\", repo_url, demo_url, presentation_url, files) "+
+			"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)",
+		submissionId, version, user.Email, input.Body.Title, input.Body.Description, input.Body.RepoUrl,
+		input.Body.DemoUrl, input.Body.PresentationUrl, pq.Array(files),
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	submission, err := getSubmissionByTeam(input.Id, db)
+	if err != nil {
+		return nil, err
+	}
+	if err := webhooks.Enqueue(eventUri, webhooks.TypeSubmissionSaved, submission.Body, db); err != nil {
+		log.Println("Вебхуки: " + err.Error())
+	}
+
+	return submission, nil
+}
+
+func GetSubmission(input *SubmissionGetInput, db *sql.DB) (*SubmissionOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+
+	if err := checkSubmissionAccess(user, input.Id, db); err != nil {
+		return nil, err
+	}
+
+	return getSubmissionByTeam(input.Id, db)
+}
+
+func GetSubmissionHistory(input *SubmissionGetInput, db *sql.DB) (*SubmissionHistoryOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+
+	if err := checkSubmissionAccess(user, input.Id, db); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(
+		"SELECT submission_history.version, submission_history.author, submission_history.title, "+
+			"submission_history.\"desc\", submission_history.repo_url, submission_history.demo_url, "+
+			"submission_history.presentation_url, submission_history.files, submission_history.created_at "+
+			"FROM submission_history INNER JOIN submissions ON submission_history.submission_id = submissions.id "+
+			"WHERE submissions.team_id = $1 ORDER BY submission_history.version DESC", input.Id,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	result := new(SubmissionHistoryOutput)
+
+	for rows.Next() {
+		version := new(SubmissionVersion)
+		var desc, repoUrl, demoUrl, presentationUrl sql.NullString
+		if err := rows.Scan(
+			&version.Version,
+			&version.Author,
+			&version.Title,
+			&desc,
+			&repoUrl,
+			&demoUrl,
+			&presentationUrl,
+			pq.Array(&version.Files),
+			&version.CreatedAt,
+		); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+
+		version.Description = desc.String
+		version.RepoUrl = repoUrl.String
+		version.DemoUrl = demoUrl.String
+		version.PresentationUrl = presentationUrl.String
+
+		result.Body.History = append(result.Body.History, version)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
+
+func GetEventSubmissions(input *EventSubmissionsInput, db *sql.DB) (*EventSubmissionsOutput, error) {
+	// Проверить наша ли меро? Работы своего кейса видит и жюри партнера
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckCaseAccess(user, input.Urid, input.CaseId, db); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(
+		"SELECT "+submissionColumns+" FROM submissions INNER JOIN teams ON submissions.team_id = teams.id "+
+			"WHERE submissions.event_uri = $1 AND ($2::bigint = 0 OR teams.case_id = $2) "+
+			"ORDER BY submissions.updated_at DESC", input.Urid, input.CaseId,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	result := new(EventSubmissionsOutput)
+
+	for rows.Next() {
+		submission, err := scanSubmission(rows)
+		if err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		result.Body.Submissions = append(result.Body.Submissions, submission)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
+
+func SetSubmissionDeadline(input *SubmissionDeadlineInput, db *sql.DB) (*SubmissionDeadlineOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	var deadline sql.NullTime
+	if !input.Body.Deadline.IsZero() {
+		deadline = sql.NullTime{Time: input.Body.Deadline, Valid: true}
+	}
+
+	res, err := db.Exec("UPDATE events SET submission_deadline = $2 WHERE urid = $1", input.Urid, deadline)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, huma.Error403Forbidden("Этого события нет XP")
+	}
+
+	result := new(SubmissionDeadlineOutput)
+	result.Body.Urid = input.Urid
+	if deadline.Valid {
+		result.Body.Deadline = &deadline.Time
+	}
+
+	return result, nil
+}
+
+func getSubmissionByTeam(teamId int64, db *sql.DB) (*SubmissionOutput, error) {
+	row := db.QueryRow(
+		"SELECT "+submissionColumns+" FROM submissions INNER JOIN teams ON submissions.team_id = teams.id "+
+			"WHERE submissions.team_id = $1", teamId,
+	)
+
+	submission, err := scanSubmission(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, huma.Error404NotFound("Команда еще не сдала работу")
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return &SubmissionOutput{Body: *submission}, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSubmission(row rowScanner) (*SubmissionInfo, error) {
+	submission := new(SubmissionInfo)
+
+	var caseId sql.NullInt64
+	var desc, repoUrl, demoUrl, presentationUrl sql.NullString
+	if err := row.Scan(
+		&submission.Id,
+		&submission.TeamId,
+		&submission.TeamName,
+		&caseId,
+		&submission.EventUri,
+		&submission.Title,
+		&desc,
+		&repoUrl,
+		&demoUrl,
+		&presentationUrl,
+		pq.Array(&submission.Files),
+		&submission.Version,
+		&submission.UpdatedAt,
+		&submission.InVoting,
+	); err != nil {
+		return nil, err
+	}
+
+	submission.CaseId = caseId.Int64
+	submission.Description = desc.String
+	submission.RepoUrl = repoUrl.String
+	submission.DemoUrl = demoUrl.String
+	submission.PresentationUrl = presentationUrl.String
+
+	return submission, nil
+}
+
+// Работу видят участники команды и организаторы события
+func checkSubmissionAccess(user *utils.UserEmail, teamId int64, db *sql.DB) error {
+	var eventUri string
+	if err := db.QueryRow("SELECT event_uri FROM teams WHERE id = $1", teamId).Scan(&eventUri); err != nil {
+		if err == sql.ErrNoRows {
+			return huma.Error403Forbidden("Этой команды нет")
+		}
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	var member_email string
+	err := db.QueryRow(
+		"SELECT member_email FROM teams_members WHERE team_id = $1 AND member_email = $2 AND pending = false",
+		teamId, user.Email).Scan(&member_email)
+	if err == nil {
+		return nil
+	}
+	if err != sql.ErrNoRows {
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return utils.CheckEventOrganizator(user, eventUri, db)
+}
+
+func checkSubmissionDeadline(eventUri string, db *sql.DB) error {
+	var deadline sql.NullTime
+	if err := db.QueryRow("SELECT submission_deadline FROM events WHERE urid = $1", eventUri).Scan(&deadline); err != nil {
+		if err == sql.ErrNoRows {
+			return huma.Error403Forbidden("Этого события нет XP")
+		}
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	if deadline.Valid && time.Now().After(deadline.Time) {
+		return huma.Error403Forbidden("Срок сдачи проектов истек")
+	}
+
+	return nil
+}
+
+func checkTeamSize(teamId int64, eventUri string, db *sql.DB) error {
+	rule, err := utils.GetTeamSizeRule(eventUri, db)
+	if err != nil {
+		return err
+	}
+
+	var size int
+	if err := db.QueryRow(
+		"SELECT COUNT(*) FROM teams_members WHERE team_id = $1 AND pending = false", teamId,
+	).Scan(&size); err != nil {
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	if !rule.Fits(size) {
+		return huma.Error403Forbidden("Команда не подходит под требование к размеру: должно быть " + rule.String() + " участников")
+	}
+	return nil
+}
@@ -3,6 +3,8 @@ package teams
 import (
 	"database/sql"
 	"hackaton-jam-back/controllers/utils"
+	"hackaton-jam-back/controllers/webhooks"
+	"log"
 
 	"github.com/danielgtaylor/huma/v2"
 )
@@ -44,6 +46,15 @@ type TeamChangeNameInput struct {
 	}
 }
 
+type TeamChangeTrackInput struct {
+	Id   int64 `path:"id" example:"0" doc:"Идентификатор команды"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+
+		Track string `json:"track" example:"Мобильная разработка" doc:"Трек команды (пусто - без трека)"`
+	}
+}
+
 type TeamChangeMemberRoleInput struct {
 	Id   int64 `path:"id" example:"0" doc:"Идентификатор команды"`
 	Body struct {
@@ -80,7 +91,37 @@ type TeamInfoOutput struct {
 		Name       string        `json:"name" example:"Супер-команда" doc:"Название команды"`
 		Urid       string        `json:"urid" example:"example_events" doc:"Ссылка на событие, привязанного к команде"`
 		Teamleader string        `json:"teamleader" example:"thatmaidguy@ya.ru" doc:"Тимлид (участник, который может собирать людей)"`
+		Track      string        `json:"track" example:"Мобильная разработка" doc:"Трек команды"`
+		CaseId     int64         `json:"case_id,omitempty" example:"1" doc:"Выбранный кейс партнера"`
 		Members    []*MemberInfo `json:"members" doc:"Список участников"`
+		Size       int           `json:"size" example:"3" doc:"Участников в команде (без неотвеченных приглашений)"`
+		SizeRule   string        `json:"size_rule" example:"не больше 5" doc:"Требование мероприятия к размеру команды"`
+		IsValid    bool          `json:"is_valid" doc:"Подходит ли команда под требование к размеру"`
+	}
+}
+
+type EventTeamsValidityInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type TeamValidity struct {
+	Id         int64  `json:"id" example:"2" doc:"Идентификатор команды"`
+	Name       string `json:"name" example:"Супер-команда" doc:"Название команды"`
+	Teamleader string `json:"teamleader" example:"thatmaidguy@ya.ru" doc:"Тимлид"`
+	Size       int    `json:"size" example:"3" doc:"Участников в команде"`
+	Pending    int    `json:"pending" example:"1" doc:"Неотвеченных приглашений"`
+	IsValid    bool   `json:"is_valid" doc:"Подходит ли команда под требование к размеру"`
+}
+
+type EventTeamsValidityOutput struct {
+	Body struct {
+		SizeRule string          `json:"size_rule" example:"не больше 5" doc:"Требование мероприятия к размеру команды"`
+		Valid    int             `json:"valid" example:"7" doc:"Команд, подходящих под требование"`
+		Invalid  int             `json:"invalid" example:"3" doc:"Команд, не подходящих под требование"`
+		Teams    []*TeamValidity `json:"teams" doc:"Команды мероприятия (сначала неподходящие)"`
 	}
 }
 
@@ -112,38 +153,54 @@ func CreateTeam(input *TeamCreationInput, db *sql.DB) (*TeamInfoOutput, error) {
 		"INSERT INTO teams_members (team_id, member_email, role, pending) VALUES ($1, $2, $3, false)",
 		teamId, user.Email, "Тимлидер").Scan()
 
-	return GetTeamInfo(teamId, db)
+	info, err := GetTeamInfo(teamId, db)
+	if err != nil {
+		return nil, err
+	}
+	if err := webhooks.Enqueue(info.Body.Urid, webhooks.TypeTeamCreated, info.Body, db); err != nil {
+		log.Println("Вебхуки: " + err.Error())
+	}
+
+	return info, nil
 }
 
 func GetTeamInfo(teamId int64, db *sql.DB) (*TeamInfoOutput, error) {
 	info := new(TeamInfoOutput)
 
+	var caseId sql.NullInt64
 	if err := db.QueryRow(
-		"SELECT * FROM teams WHERE id = $1", teamId).Scan(
+		"SELECT id, event_uri, name, teamleader, track, case_id FROM teams WHERE id = $1", teamId).Scan(
 		&info.Body.Id,
 		&info.Body.Urid,
 		&info.Body.Name,
 		&info.Body.Teamleader,
+		&info.Body.Track,
+		&caseId,
 	); err != nil {
 		return nil, huma.Error422UnprocessableEntity(err.Error())
 	}
+	info.Body.CaseId = caseId.Int64
 
-	rows, err := db.Query("SELECT member_email, role FROM teams_members WHERE team_id = $1", teamId)
+	rows, err := db.Query("SELECT member_email, role, pending FROM teams_members WHERE team_id = $1", teamId)
 	if err != nil {
 		return nil, huma.Error422UnprocessableEntity(err.Error())
 	}
 	defer rows.Close()
 
 	for rows.Next() {
+		var email string
 		memberInfo := new(MemberInfo)
-		if err := rows.Scan(&memberInfo.User.Email, &memberInfo.Role); err != nil {
+		if err := rows.Scan(&email, &memberInfo.Role, &memberInfo.Pending); err != nil {
 			return nil, huma.Error422UnprocessableEntity(err.Error())
 		}
 
-		memberInfo.User, err = utils.GetUserShortInfo(memberInfo.User.Email, db)
+		memberInfo.User, err = utils.GetUserShortInfo(email, db)
 		if err != nil {
 			return nil, err
 		}
+		if !memberInfo.Pending {
+			info.Body.Size++
+		}
 
 		info.Body.Members = append(info.Body.Members, memberInfo)
 	}
@@ -151,9 +208,72 @@ func GetTeamInfo(teamId int64, db *sql.DB) (*TeamInfoOutput, error) {
 		return nil, huma.Error422UnprocessableEntity(err.Error())
 	}
 
+	rule, err := utils.GetTeamSizeRule(info.Body.Urid, db)
+	if err != nil {
+		return nil, err
+	}
+	info.Body.SizeRule = rule.String()
+	info.Body.IsValid = rule.Fits(info.Body.Size)
+
 	return info, nil
 }
 
+// Команды мероприятия с проверкой размера - для организаторов перед закрытием регистрации и сдачи
+func GetEventTeamsValidity(input *EventTeamsValidityInput, db *sql.DB) (*EventTeamsValidityOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	rule, err := utils.GetTeamSizeRule(input.Urid, db)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(
+		"SELECT teams.id, teams.name, teams.teamleader, "+
+			"COUNT(teams_members.member_email) FILTER (WHERE teams_members.pending = false), "+
+			"COUNT(teams_members.member_email) FILTER (WHERE teams_members.pending = true) "+
+			"FROM teams LEFT JOIN teams_members ON teams_members.team_id = teams.id "+
+			"WHERE teams.event_uri = $1 GROUP BY teams.id ORDER BY teams.name",
+		input.Urid,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	result := new(EventTeamsValidityOutput)
+	result.Body.SizeRule = rule.String()
+
+	var valid []*TeamValidity
+	for rows.Next() {
+		team := new(TeamValidity)
+		if err := rows.Scan(&team.Id, &team.Name, &team.Teamleader, &team.Size, &team.Pending); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		team.IsValid = rule.Fits(team.Size)
+
+		if team.IsValid {
+			result.Body.Valid++
+			valid = append(valid, team)
+		} else {
+			result.Body.Invalid++
+			result.Body.Teams = append(result.Body.Teams, team)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	result.Body.Teams = append(result.Body.Teams, valid...)
+
+	return result, nil
+}
+
 func InviteUser(input *TeamInviteInput, db *sql.DB) (*TeamInfoOutput, error) {
 	// Проверяем, что пользователь тимлид
 	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
@@ -178,6 +298,15 @@ func InviteUser(input *TeamInviteInput, db *sql.DB) (*TeamInfoOutput, error) {
 		return nil, err
 	}
 
+	// Неотвеченные приглашения тоже занимают места, чтобы команду нельзя было переполнить
+	rule, err := utils.GetTeamSizeRule(result.Body.Urid, db)
+	if err != nil {
+		return nil, err
+	}
+	if !rule.HasPlace(len(result.Body.Members)) {
+		return nil, huma.Error422UnprocessableEntity("В команде нет мест (с учетом приглашений): размер команды должен быть " + rule.String())
+	}
+
 	// Кидаем приглашение
 	db.QueryRow(
 		"INSERT INTO notifications (user, team_id, type, from, event_uri) VALUES ($1, $2, 0, $3, $4)",
@@ -203,11 +332,45 @@ func AcceptInvite(input *TeamInviteAcceptCancelInput, db *sql.DB) (*TeamInfoOutp
 		return nil, err
 	}
 
+	rule, err := utils.GetTeamSizeRule(result.Body.Urid, db)
+	if err != nil {
+		return nil, err
+	}
+
+	// Строка команды блокируется, чтобы два приглашенных не заняли одно последнее место
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer tx.Rollback()
+
+	var size int
+	if err := tx.QueryRow(
+		"SELECT (SELECT COUNT(*) FROM teams_members WHERE team_id = teams.id AND pending = false) FROM teams WHERE id = $1 FOR UPDATE",
+		result.Body.Id,
+	).Scan(&size); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if !rule.HasPlace(size) {
+		return nil, huma.Error422UnprocessableEntity("Команда уже заполнена: размер команды должен быть " + rule.String())
+	}
+
 	// Удаляем приглашение
-	db.QueryRow("DELETE FROM notifications WHERE user=$1 AND team_id=$2 AND type=0", user.Email, result.Body.Id).Scan()
+	if _, err := tx.Exec("DELETE FROM notifications WHERE user=$1 AND team_id=$2 AND type=0", user.Email, result.Body.Id); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
 
 	// Записываем "ручкой"
-	db.QueryRow("UPDATE teams_members SET pending = false WHERE member_email=$1 AND team_id=$2", user.Email, result.Body.Id).Scan()
+	res, err := tx.Exec("UPDATE teams_members SET pending = false WHERE member_email=$1 AND team_id=$2 AND pending = true", user.Email, result.Body.Id)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, huma.Error404NotFound("Приглашения в эту команду нет")
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
 
 	// Кидаем уведомление о принятии (2)
 	db.QueryRow(
@@ -271,13 +434,36 @@ func KickUser(input *TeamKickInput, db *sql.DB) (*TeamInfoOutput, error) {
 		return nil, err
 	}
 
+	// После начала мероприятия регистрация закрыта: команду нельзя сделать меньше требования
+	var started, pending bool
+	if err := db.QueryRow(
+		"SELECT events.start_time <= now(), teams_members.pending FROM teams_members "+
+			"INNER JOIN teams ON teams.id = teams_members.team_id INNER JOIN events ON events.urid = teams.event_uri "+
+			"WHERE teams_members.team_id = $1 AND teams_members.member_email = $2",
+		input.Id, input.Body.Email,
+	).Scan(&started, &pending); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, huma.Error404NotFound("Такого участника в команде нет")
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if started && !pending {
+		rule, err := utils.GetTeamSizeRule(result.Body.Urid, db)
+		if err != nil {
+			return nil, err
+		}
+		if !rule.CanLeave(result.Body.Size) {
+			return nil, huma.Error422UnprocessableEntity("Мероприятие уже началось, а размер команды должен быть " + rule.String())
+		}
+	}
+
 	// Кидаем уведомление о сливе олуха
 	db.QueryRow(
 		"INSERT INTO notifications (user, team_id, type, from, event_uri) VALUES ($1, $2, 3, $3, $4)",
 		input.Body.Email, input.Id, user.Email, result.Body.Urid).Scan()
 
 	// Вычеркиваем
-	db.QueryRow("DELETE FROM teams_members WHERE member_email=$1 AND team_id=$2", user.Email, result.Body.Id).Scan()
+	db.QueryRow("DELETE FROM teams_members WHERE member_email=$1 AND team_id=$2", input.Body.Email, result.Body.Id).Scan()
 
 	return GetTeamInfo(input.Id, db)
 
@@ -314,6 +500,31 @@ func ChangeTeamName(input *TeamChangeNameInput, db *sql.DB) (*TeamInfoOutput, er
 	return GetTeamInfo(input.Id, db)
 }
 
+func ChangeTrack(input *TeamChangeTrackInput, db *sql.DB) (*TeamInfoOutput, error) {
+	// Проверяем, что пользователь тимлид
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil || user.Perms != 0 {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+
+	var teamleader string
+	if err := db.QueryRow("SELECT teamleader FROM teams WHERE id = $1", input.Id).Scan(&teamleader); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, huma.Error403Forbidden("Этой команды нет")
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if teamleader != user.Email {
+		return nil, huma.Error403Forbidden("Вы не тимлид команды")
+	}
+
+	if _, err := db.Exec("UPDATE teams SET track = $2 WHERE id = $1", input.Id, input.Body.Track); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return GetTeamInfo(input.Id, db)
+}
+
 func ChangeRole(input *TeamChangeMemberRoleInput, db *sql.DB) (*TeamInfoOutput, error) {
 	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
 	if err != nil || user.Perms != 0 {
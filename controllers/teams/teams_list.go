@@ -0,0 +1,98 @@
+package teams
+
+import (
+	"database/sql"
+	"hackaton-jam-back/controllers/utils"
+	"strconv"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+type EventTeamsInput struct {
+	Urid   string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Count  int    `query:"count" default:"20" minimum:"1" maximum:"100" example:"20" doc:"Количество команд на страницу"`
+	Cursor string `query:"cursor" doc:"Курсор следующей страницы из прошлого ответа (пусто - первая страница)"`
+	Sort   string `query:"sort" enum:"name,created" default:"name" doc:"Сортировка: по названию или новые сверху"`
+	utils.ViewerInput
+}
+
+type TeamShortInfo struct {
+	Id         int64  `json:"id" example:"2" doc:"Идентификатор команды"`
+	Name       string `json:"name" example:"Супер-команда" doc:"Название команды"`
+	Teamleader string `json:"teamleader" example:"thatmaidguy@ya.ru" doc:"Тимлид"`
+	Track      string `json:"track" example:"Мобильная разработка" doc:"Трек команды"`
+	Size       int    `json:"size" example:"3" doc:"Участников в команде (без неотвеченных приглашений)"`
+}
+
+type EventTeamsOutput struct {
+	Body struct {
+		Teams      []*TeamShortInfo `json:"teams" doc:"Команды мероприятия"`
+		NextCursor string           `json:"next_cursor,omitempty" doc:"Курсор следующей страницы (пусто - это последняя)"`
+	}
+}
+
+// Список команд мероприятия, страницы выдаются по курсору
+func GetEventTeams(input *EventTeamsInput, db *sql.DB) (*EventTeamsOutput, error) {
+	if err := utils.CheckEventVisible(input.Token, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	sortName := input.Sort
+	if sortName == "" {
+		sortName = "name"
+	}
+	page, err := utils.NewPage(input.Cursor, sortName, input.Count)
+	if err != nil {
+		return nil, err
+	}
+
+	query := "SELECT teams.id, teams.name, teams.teamleader, teams.track, lower(teams.name), " +
+		"(SELECT COUNT(*) FROM teams_members WHERE teams_members.team_id = teams.id AND teams_members.pending = false) " +
+		"FROM teams WHERE teams.event_uri = $1"
+	args := []any{input.Urid, page.Limit()}
+	switch sortName {
+	case "name":
+		if page.After != nil {
+			query += " AND (lower(teams.name), teams.id) > ($3, $4::bigint)"
+			args = append(args, page.After.Value, page.After.Key)
+		}
+		query += " ORDER BY lower(teams.name), teams.id"
+	case "created":
+		if page.After != nil {
+			query += " AND teams.id < $3::bigint"
+			args = append(args, page.After.Key)
+		}
+		query += " ORDER BY teams.id DESC"
+	default:
+		return nil, huma.Error422UnprocessableEntity("Неизвестная сортировка")
+	}
+	query += " LIMIT $2"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	result := new(EventTeamsOutput)
+
+	for rows.Next() {
+		team := new(TeamShortInfo)
+		var sortValue string
+		if err := rows.Scan(&team.Id, &team.Name, &team.Teamleader, &team.Track, &sortValue, &team.Size); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+
+		if !page.Add(sortValue, strconv.FormatInt(team.Id, 10)) {
+			break
+		}
+
+		result.Body.Teams = append(result.Body.Teams, team)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	result.Body.NextCursor = page.NextCursor()
+
+	return result, nil
+}
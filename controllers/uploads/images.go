@@ -0,0 +1,79 @@
+package uploads
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Больше не декодируем, чтобы маленький файл не съел всю память
+const maxImagePixels = 40_000_000
+
+type thumbnail struct {
+	size        int
+	data        []byte
+	contentType string
+	ext         string
+}
+
+func checkImage(data []byte) error {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return errors.New("Не удалось прочитать изображение")
+	}
+	if config.Width*config.Height > maxImagePixels {
+		return errors.New("Слишком большое разрешение изображения")
+	}
+	return nil
+}
+
+// Уменьшает изображение, чтобы оно влезло в квадрат size x size (не увеличивая).
+// Прозрачность есть только в PNG и GIF, поэтому они остаются PNG, остальное - JPEG
+func makeThumbnails(data []byte, contentType string, sizes []int) ([]*thumbnail, error) {
+	source, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("Не удалось прочитать изображение")
+	}
+
+	transparent := contentType == "image/png" || contentType == "image/gif"
+
+	var thumbnails []*thumbnail
+	for _, size := range sizes {
+		bounds := source.Bounds()
+		width, height := bounds.Dx(), bounds.Dy()
+		if width > size || height > size {
+			if width >= height {
+				width, height = size, max(1, height*size/width)
+			} else {
+				width, height = max(1, width*size/height), size
+			}
+		}
+
+		resized := image.NewRGBA(image.Rect(0, 0, width, height))
+		draw.CatmullRom.Scale(resized, resized.Bounds(), source, bounds, draw.Src, nil)
+
+		var buf bytes.Buffer
+		thumb := &thumbnail{size: size}
+		if transparent {
+			err = png.Encode(&buf, resized)
+			thumb.contentType, thumb.ext = "image/png", ".png"
+		} else {
+			err = jpeg.Encode(&buf, resized, &jpeg.Options{Quality: 85})
+			thumb.contentType, thumb.ext = "image/jpeg", ".jpg"
+		}
+		if err != nil {
+			return nil, err
+		}
+		thumb.data = buf.Bytes()
+
+		thumbnails = append(thumbnails, thumb)
+	}
+
+	return thumbnails, nil
+}
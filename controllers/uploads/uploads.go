@@ -0,0 +1,366 @@
+package uploads
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"hackaton-jam-back/controllers/storage"
+	"hackaton-jam-back/controllers/utils"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/lib/pq"
+)
+
+const fileCacheControl = "public, max-age=31536000, immutable"
+
+var imageTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+var attachmentTypes = map[string]string{
+	"image/png":                 ".png",
+	"image/jpeg":                ".jpg",
+	"image/gif":                 ".gif",
+	"image/webp":                ".webp",
+	"application/pdf":           ".pdf",
+	"application/zip":           ".zip", // В том числе docx и pptx
+	"text/plain; charset=utf-8": ".txt",
+}
+
+type uploadKind struct {
+	maxSize    int64
+	types      map[string]string
+	thumbnails []int
+}
+
+// Что можно загружать: размеры миниатюр - сторона квадрата, в который вписывается картинка
+var uploadKinds = map[string]uploadKind{
+	"avatar":       {maxSize: 5 << 20, types: imageTypes, thumbnails: []int{64, 256}},
+	"event_icon":   {maxSize: 5 << 20, types: imageTypes, thumbnails: []int{128, 512}},
+	"partner_logo": {maxSize: 2 << 20, types: imageTypes, thumbnails: []int{128, 256}},
+	"attachment":   {maxSize: 20 << 20, types: attachmentTypes},
+}
+
+// Пределы запроса на загрузку: самое большое вложение плюс место под остальные поля формы.
+// Медленному клиенту дается время дослать файл
+const (
+	MaxUploadBodyBytes = 20<<20 + 1<<20
+	UploadReadTimeout  = 2 * time.Minute
+)
+
+type UploadInput struct {
+	RawBody multipart.Form
+}
+
+type FileInput struct {
+	Name        string `path:"name" example:"3f2a9c0d1b7e4a8f_256.jpg" doc:"Имя файла"`
+	IfNoneMatch string `header:"If-None-Match" doc:"ETag закешированной версии"`
+}
+
+type FileDeleteInput struct {
+	Id   string `path:"id" example:"3f2a9c0d1b7e4a8f" doc:"Идентификатор загрузки"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type UploadInfo struct {
+	Id          string            `json:"id" example:"3f2a9c0d1b7e4a8f" doc:"Идентификатор загрузки"`
+	Kind        string            `json:"kind" example:"avatar" doc:"Тип (avatar, event_icon, partner_logo, attachment)"`
+	Url         string            `json:"url" example:"http://localhost/api/files/3f2a9c0d1b7e4a8f.jpg" doc:"Ссылка на оригинал"`
+	Thumbnails  map[string]string `json:"thumbnails,omitempty" doc:"Ссылки на миниатюры по размеру"`
+	ContentType string            `json:"content_type" example:"image/jpeg" doc:"Тип файла"`
+	Size        int64             `json:"size" example:"102400" doc:"Размер в байтах"`
+	Name        string            `json:"name" example:"photo.jpg" doc:"Исходное имя файла"`
+	CreatedAt   time.Time         `json:"created_at" doc:"Время загрузки"`
+}
+
+type UploadOutput struct {
+	Body UploadInfo
+}
+
+type FileDeleteOutput struct {
+	Body struct {
+		Success bool `json:"success" doc:"Успешно выполнено!"`
+	}
+}
+
+// Ожидается файл в поле file, токен в access_token и тип в kind.
+// Для event_icon и partner_logo можно передать urid - тогда картинка сразу станет иконкой или логотипом партнера
+func Upload(input *UploadInput, db *sql.DB, store storage.Storage) (*UploadOutput, error) {
+	user, err := utils.GetUserEmailByToken(formValue(&input.RawBody, "access_token"), db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+
+	kindName := formValue(&input.RawBody, "kind")
+	kind, ok := uploadKinds[kindName]
+	if !ok {
+		return nil, huma.Error422UnprocessableEntity("Неизвестный тип загрузки (avatar, event_icon, partner_logo, attachment)")
+	}
+
+	urid := formValue(&input.RawBody, "urid")
+	if urid != "" && (kindName == "event_icon" || kindName == "partner_logo") {
+		if err := utils.CheckEventOrganizator(user, urid, db); err != nil {
+			return nil, err
+		}
+	}
+
+	files := input.RawBody.File["file"]
+	if len(files) == 0 {
+		return nil, huma.Error422UnprocessableEntity("Нужно приложить файл в поле file")
+	}
+	header := files[0]
+	if header.Size > kind.maxSize {
+		return nil, huma.Error422UnprocessableEntity("Файл слишком большой (максимум " + strconv.FormatInt(kind.maxSize>>20, 10) + " МБ)")
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, kind.maxSize+1))
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if int64(len(data)) > kind.maxSize {
+		return nil, huma.Error422UnprocessableEntity("Файл слишком большой (максимум " + strconv.FormatInt(kind.maxSize>>20, 10) + " МБ)")
+	}
+
+	// Тип определяем по содержимому, а не по тому, что прислал браузер
+	contentType := http.DetectContentType(data)
+	ext, ok := kind.types[contentType]
+	if !ok {
+		return nil, huma.Error422UnprocessableEntity("Недопустимый тип файла: " + contentType)
+	}
+
+	var thumbnails []*thumbnail
+	if len(kind.thumbnails) > 0 {
+		if err := checkImage(data); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		if thumbnails, err = makeThumbnails(data, contentType, kind.thumbnails); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+	}
+
+	id, err := newUploadId()
+	if err != nil {
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+
+	keys := []string{id + ext}
+	if err := store.Put(id+ext, data, contentType); err != nil {
+		return nil, huma.Error500InternalServerError("Не удалось сохранить файл: " + err.Error())
+	}
+	for _, thumb := range thumbnails {
+		key := thumbnailKey(id, thumb.size, thumb.ext)
+		if err := store.Put(key, thumb.data, thumb.contentType); err != nil {
+			deleteKeys(store, keys)
+			return nil, huma.Error500InternalServerError("Не удалось сохранить файл: " + err.Error())
+		}
+		keys = append(keys, key)
+	}
+
+	_, err = db.Exec(
+		"INSERT INTO uploads (id, owner_email, kind, content_type, size, name, keys) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		id, user.Email, kindName, contentType, len(data), header.Filename, pq.Array(keys),
+	)
+	if err != nil {
+		deleteKeys(store, keys)
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	result, err := getUpload(id, db)
+	if err != nil {
+		return nil, err
+	}
+
+	// Сразу ставим картинку туда, куда ее загружали (берем самую большую миниатюру)
+	if len(thumbnails) > 0 {
+		largest := fileUrl(thumbnailKey(id, thumbnails[len(thumbnails)-1].size, thumbnails[len(thumbnails)-1].ext))
+
+		switch {
+		case kindName == "avatar":
+			_, err = db.Exec("UPDATE users SET avatar = $2 WHERE email = $1", user.Email, largest)
+		case kindName == "event_icon" && urid != "":
+			_, err = db.Exec("UPDATE events SET icon = $2 WHERE urid = $1", urid, largest)
+		case kindName == "partner_logo" && urid != "":
+			_, err = db.Exec("INSERT INTO event_partners (event_uri, logo_url) VALUES ($1, $2)", urid, largest)
+		}
+		if err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+	}
+
+	return result, nil
+}
+
+func GetFile(input *FileInput, store storage.Storage) (*huma.StreamResponse, error) {
+	etag := `"` + input.Name + `"`
+
+	// Файлы не перезаписываются, поэтому имя годится как ETag
+	if input.IfNoneMatch == etag {
+		return &huma.StreamResponse{
+			Body: func(ctx huma.Context) {
+				ctx.SetHeader("ETag", etag)
+				ctx.SetHeader("Cache-Control", fileCacheControl)
+				ctx.SetStatus(http.StatusNotModified)
+			},
+		}, nil
+	}
+
+	reader, object, err := store.Get(input.Name)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return nil, huma.Error404NotFound("Файл не найден")
+		}
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+
+	return &huma.StreamResponse{
+		Body: func(ctx huma.Context) {
+			defer reader.Close()
+
+			ctx.SetHeader("Content-Type", object.ContentType)
+			if object.Size >= 0 {
+				ctx.SetHeader("Content-Length", strconv.FormatInt(object.Size, 10))
+			}
+			if !object.LastModified.IsZero() {
+				ctx.SetHeader("Last-Modified", object.LastModified.UTC().Format(http.TimeFormat))
+			}
+			ctx.SetHeader("ETag", etag)
+			ctx.SetHeader("Cache-Control", fileCacheControl)
+			ctx.SetHeader("X-Content-Type-Options", "nosniff")
+
+			if _, err := io.Copy(ctx.BodyWriter(), reader); err != nil {
+				log.Println("Отдача файла " + input.Name + ": " + err.Error())
+			}
+		},
+	}, nil
+}
+
+func DeleteFile(input *FileDeleteInput, db *sql.DB, store storage.Storage) (*FileDeleteOutput, error) {
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+
+	var owner string
+	var keys []string
+	if err := db.QueryRow("SELECT owner_email, keys FROM uploads WHERE id = $1", input.Id).Scan(&owner, pq.Array(&keys)); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, huma.Error404NotFound("Файл не найден")
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if owner != user.Email && user.Perms != 10 {
+		return nil, huma.Error403Forbidden("Это не твой файл")
+	}
+
+	// Запись и ссылки на файл убираются вместе, иначе остались бы аватары и логотипы,
+	// которые ведут в никуда. Сами файлы удаляются только после этого
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer tx.Rollback()
+
+	pattern := "%/api/files/" + input.Id + "%"
+	queries := []string{
+		"UPDATE users SET avatar = DEFAULT WHERE avatar LIKE $1",
+		"UPDATE events SET icon = NULL WHERE icon LIKE $1",
+		"DELETE FROM event_partners WHERE logo_url LIKE $1",
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(query, pattern); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM uploads WHERE id = $1", input.Id); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	deleteKeys(store, keys)
+
+	result := new(FileDeleteOutput)
+	result.Body.Success = true
+	return result, nil
+}
+
+func getUpload(id string, db *sql.DB) (*UploadOutput, error) {
+	result := new(UploadOutput)
+	var keys []string
+	if err := db.QueryRow(
+		"SELECT id, kind, content_type, size, name, keys, created_at FROM uploads WHERE id = $1", id,
+	).Scan(
+		&result.Body.Id,
+		&result.Body.Kind,
+		&result.Body.ContentType,
+		&result.Body.Size,
+		&result.Body.Name,
+		pq.Array(&keys),
+		&result.Body.CreatedAt,
+	); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	// Первый ключ - оригинал, остальные - миниатюры вида <id>_<размер>.<ext>
+	result.Body.Url = fileUrl(keys[0])
+	if len(keys) > 1 {
+		result.Body.Thumbnails = map[string]string{}
+		for _, key := range keys[1:] {
+			size := strings.TrimPrefix(key, id+"_")
+			size = size[:strings.IndexByte(size, '.')]
+			result.Body.Thumbnails[size] = fileUrl(key)
+		}
+	}
+
+	return result, nil
+}
+
+func deleteKeys(store storage.Storage, keys []string) {
+	for _, key := range keys {
+		if err := store.Delete(key); err != nil {
+			log.Println("Не удалось удалить файл " + key + ": " + err.Error())
+		}
+	}
+}
+
+func thumbnailKey(id string, size int, ext string) string {
+	return id + "_" + strconv.Itoa(size) + ext
+}
+
+func fileUrl(key string) string {
+	return os.Getenv("PUBLIC_URL") + "/api/files/" + key
+}
+
+func newUploadId() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func formValue(form *multipart.Form, name string) string {
+	if values := form.Value[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+// Ограничение тела для multipart-операций. huma применяет MaxBodyBytes только к обычному телу,
+// а форму с файлами читает целиком, поэтому размер проверяется по Content-Length до чтения.
+// Больше объявленной длины сервер все равно не прочитает
+func LimitBody(api huma.API, limit int64) func(ctx huma.Context, next func(huma.Context)) {
+	return func(ctx huma.Context, next func(huma.Context)) {
+		length, err := strconv.ParseInt(ctx.Header("Content-Length"), 10, 64)
+		if err != nil {
+			huma.WriteErr(api, ctx, http.StatusLengthRequired, "Нужен заголовок Content-Length")
+			return
+		}
+		if length > limit {
+			huma.WriteErr(api, ctx, http.StatusRequestEntityTooLarge,
+				"Запрос слишком большой, максимум "+strconv.FormatInt(limit>>20, 10)+" МБ")
+			return
+		}
+		next(ctx)
+	}
+}
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// Позиция в списке для постраничного вывода: значение поля сортировки и ключ
+// последней выданной записи. Клиенту отдается непрозрачной строкой
+type Cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	Key   string `json:"k"`
+}
+
+func EncodeCursor(cursor Cursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Пустая строка - первая страница (nil). Курсор от другой сортировки не подходит
+func DecodeCursor(value string, sort string) (*Cursor, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity("Неверный курсор")
+	}
+	cursor := new(Cursor)
+	if err := json.Unmarshal(data, cursor); err != nil || cursor.Sort != sort {
+		return nil, huma.Error422UnprocessableEntity("Неверный курсор")
+	}
+	return cursor, nil
+}
+
+func PageSize(count int) int {
+	if count <= 0 {
+		return DefaultPageSize
+	}
+	if count > MaxPageSize {
+		return MaxPageSize
+	}
+	return count
+}
+
+// Страница списка по курсору. У базы запрашивается на одну запись больше (Limit):
+// лишняя только показывает, что есть следующая страница
+type Page struct {
+	After *Cursor // После какой записи начинается страница (nil - первая)
+	Size  int
+
+	sort  string
+	last  Cursor
+	taken int
+	next  string
+}
+
+func NewPage(cursor string, sort string, count int) (*Page, error) {
+	after, err := DecodeCursor(cursor, sort)
+	if err != nil {
+		return nil, err
+	}
+	return &Page{After: after, Size: PageSize(count), sort: sort}, nil
+}
+
+// Сколько записей запрашивать у базы
+func (p *Page) Limit() int {
+	return p.Size + 1
+}
+
+// Учитывает очередную запись по порядку выдачи. false - запись лишняя, ее не выводим и заканчиваем
+func (p *Page) Add(value string, key string) bool {
+	if p.taken == p.Size {
+		p.next = EncodeCursor(p.last)
+		return false
+	}
+	p.taken++
+	p.last = Cursor{Sort: p.sort, Value: value, Key: key}
+	return true
+}
+
+// Курсор следующей страницы (пусто - это последняя)
+func (p *Page) NextCursor() string {
+	return p.next
+}
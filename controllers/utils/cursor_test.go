@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursors := []Cursor{
+		{Sort: "created", Value: "42", Key: "42"},
+		{Sort: "name", Value: "Супер-команда", Key: "7"},
+		{Sort: "start", Value: "2026-10-19 12:00:00+05", Key: "example_events"},
+		{Sort: "joined", Value: "", Key: "thatmaidguy@ya.ru"},
+	}
+
+	for _, cursor := range cursors {
+		t.Run(cursor.Sort, func(t *testing.T) {
+			decoded, err := DecodeCursor(EncodeCursor(cursor), cursor.Sort)
+			if err != nil {
+				t.Fatalf("DecodeCursor: %v", err)
+			}
+			if *decoded != cursor {
+				t.Errorf("DecodeCursor = %+v, ожидалось %+v", *decoded, cursor)
+			}
+		})
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		sort   string
+		ok     bool
+		isNull bool
+	}{
+		{"пустой - первая страница", "", "name", true, true},
+		{"своя сортировка", EncodeCursor(Cursor{Sort: "name", Value: "a", Key: "1"}), "name", true, false},
+		{"чужая сортировка", EncodeCursor(Cursor{Sort: "created", Value: "1", Key: "1"}), "name", false, false},
+		{"не base64", "не курсор!", "name", false, false},
+		{"не json", "bm90IGpzb24", "name", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cursor, err := DecodeCursor(tt.value, tt.sort)
+			if (err == nil) != tt.ok {
+				t.Fatalf("DecodeCursor = %v, ожидался успех: %v", err, tt.ok)
+			}
+			if tt.ok && (cursor == nil) != tt.isNull {
+				t.Errorf("DecodeCursor = %+v", cursor)
+			}
+		})
+	}
+}
+
+func TestPageSize(t *testing.T) {
+	tests := []struct {
+		count int
+		want  int
+	}{
+		{0, DefaultPageSize},
+		{-5, DefaultPageSize},
+		{1, 1},
+		{MaxPageSize, MaxPageSize},
+		{MaxPageSize + 1, MaxPageSize},
+	}
+
+	for _, tt := range tests {
+		if got := PageSize(tt.count); got != tt.want {
+			t.Errorf("PageSize(%d) = %d, ожидалось %d", tt.count, got, tt.want)
+		}
+	}
+}
+
+func TestPage(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     int
+		rows     int // Сколько записей вернула база (не больше Limit)
+		wantRows int
+		hasNext  bool
+	}{
+		{"пустой список", 3, 0, 0, false},
+		{"неполная страница", 3, 2, 2, false},
+		{"ровно страница", 3, 3, 3, false},
+		{"есть следующая", 3, 4, 3, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := NewPage("", "created", tt.size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if page.Limit() != tt.size+1 {
+				t.Fatalf("Limit = %d, ожидалось %d", page.Limit(), tt.size+1)
+			}
+
+			var taken []int
+			for i := 0; i < tt.rows; i++ {
+				if !page.Add(strconv.Itoa(100-i), strconv.Itoa(i)) {
+					break
+				}
+				taken = append(taken, i)
+			}
+			if len(taken) != tt.wantRows {
+				t.Errorf("на странице %d записей, ожидалось %d", len(taken), tt.wantRows)
+			}
+
+			next := page.NextCursor()
+			if (next != "") != tt.hasNext {
+				t.Fatalf("NextCursor = %q, ожидалась следующая страница: %v", next, tt.hasNext)
+			}
+			if !tt.hasNext {
+				return
+			}
+
+			// Следующая страница начинается после последней выданной записи
+			after, err := NewPage(next, "created", tt.size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			last := taken[len(taken)-1]
+			want := Cursor{Sort: "created", Value: strconv.Itoa(100 - last), Key: strconv.Itoa(last)}
+			if after.After == nil || *after.After != want {
+				t.Errorf("курсор следующей страницы %+v, ожидалось %+v", after.After, want)
+			}
+		})
+	}
+}
+
+func TestNewPageOtherSort(t *testing.T) {
+	page, _ := NewPage("", "name", 1)
+	page.Add("a", "1")
+	page.Add("b", "2")
+
+	if _, err := NewPage(page.NextCursor(), "created", 1); err == nil {
+		t.Error("курсор от сортировки по названию подошел к сортировке по дате")
+	}
+}
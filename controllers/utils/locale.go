@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"database/sql"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/lib/pq"
+)
+
+// Ответ зависит от этого заголовка, кеши должны хранить языки раздельно
+const VaryLanguage = "Accept-Language"
+
+// Язык ответа: параметр lang важнее заголовка Accept-Language.
+// Если перевода нет, тексты отдаются на основном языке мероприятия
+type LocaleInput struct {
+	Lang           string `query:"lang" maxLength:"16" example:"en" doc:"Язык текстов (важнее заголовка Accept-Language)"`
+	AcceptLanguage string `header:"Accept-Language" example:"en-US,en;q=0.9" doc:"Предпочитаемые языки"`
+}
+
+// Названия мероприятий на языках перевода: ссылка -> язык -> название (пусто - не переведено)
+type NameTranslations map[string]map[string]string
+
+// Языки из параметра lang и заголовка Accept-Language по убыванию предпочтения
+func PreferredLocales(input LocaleInput) []string {
+	type weighted struct {
+		locale string
+		q      float64
+	}
+
+	var prefs []weighted
+	for _, part := range strings.Split(input.AcceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q <= 0 {
+			continue
+		}
+
+		prefs = append(prefs, weighted{locale: tag, q: q})
+	}
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+
+	var locales []string
+	if input.Lang != "" {
+		locales = append(locales, strings.ToLower(input.Lang))
+	}
+	for _, pref := range prefs {
+		locales = append(locales, pref.locale)
+	}
+
+	return locales
+}
+
+// Выбирает лучший из доступных языков: сначала точное совпадение, потом по основному
+// языку (en-us подходит к en и наоборот). Ничего не подошло - основной язык мероприятия
+func MatchLocale(preferred []string, available []string, fallback string) string {
+	for _, want := range preferred {
+		for _, have := range available {
+			if want == have {
+				return have
+			}
+		}
+
+		base, _, _ := strings.Cut(want, "-")
+		for _, have := range available {
+			if haveBase, _, _ := strings.Cut(have, "-"); haveBase == base {
+				return have
+			}
+		}
+	}
+
+	return fallback
+}
+
+// Переводы названий сразу для всей страницы списка одним запросом.
+// Если клиент не просил язык, в базу не ходим
+func GetNameTranslations(urids []string, preferred []string, db *sql.DB) (NameTranslations, error) {
+	translations := NameTranslations{}
+	if len(preferred) == 0 || len(urids) == 0 {
+		return translations, nil
+	}
+
+	rows, err := db.Query("SELECT event_uri, locale, name FROM event_translations WHERE event_uri = ANY($1)", pq.Array(urids))
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var urid, locale string
+		var name sql.NullString
+		if err := rows.Scan(&urid, &locale, &name); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		if translations[urid] == nil {
+			translations[urid] = map[string]string{}
+		}
+		translations[urid][locale] = name.String
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return translations, nil
+}
+
+// Название карточки на лучшем доступном языке и сам язык. Если в переводе
+// название не заполнено, остается название на основном языке
+func (t NameTranslations) Localize(urid string, name string, locale string, preferred []string) (string, string) {
+	available := []string{locale}
+	for have := range t[urid] {
+		available = append(available, have)
+	}
+	sort.Strings(available[1:])
+
+	match := MatchLocale(preferred, available, locale)
+	if match == locale {
+		return name, locale
+	}
+	if translated := t[urid][match]; translated != "" {
+		name = translated
+	}
+	return name, match
+}
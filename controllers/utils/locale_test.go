@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPreferredLocales(t *testing.T) {
+	tests := []struct {
+		name  string
+		input LocaleInput
+		want  []string
+	}{
+		{"ничего", LocaleInput{}, nil},
+		{"только lang", LocaleInput{Lang: "EN"}, []string{"en"}},
+		{"порядок по q", LocaleInput{AcceptLanguage: "ru;q=0.5, en-US, en;q=0.9"}, []string{"en-us", "en", "ru"}},
+		{"lang важнее заголовка", LocaleInput{Lang: "de", AcceptLanguage: "en-US,en;q=0.9"}, []string{"de", "en-us", "en"}},
+		{"звездочка и q=0 пропускаются", LocaleInput{AcceptLanguage: "fr;q=0, *;q=0.1, pt-BR"}, []string{"pt-br"}},
+		{"битый q пропускается", LocaleInput{AcceptLanguage: "en;q=abc, ru"}, []string{"ru"}},
+		{"равные q в исходном порядке", LocaleInput{AcceptLanguage: "kk, ru, en"}, []string{"kk", "ru", "en"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PreferredLocales(tt.input); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PreferredLocales = %q, ожидалось %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchLocale(t *testing.T) {
+	tests := []struct {
+		name      string
+		preferred []string
+		available []string
+		want      string
+	}{
+		{"ничего не просили", nil, []string{"ru", "en"}, "ru"},
+		{"точное совпадение", []string{"en"}, []string{"ru", "en"}, "en"},
+		{"региональный к основному", []string{"en-us"}, []string{"ru", "en"}, "en"},
+		{"основной к региональному", []string{"pt"}, []string{"ru", "pt-br"}, "pt-br"},
+		{"точное важнее основного", []string{"pt-br"}, []string{"ru", "pt", "pt-br"}, "pt-br"},
+		{"первый подходящий по предпочтению", []string{"de", "en", "ru"}, []string{"ru", "en"}, "en"},
+		{"ничего не подошло", []string{"de", "fr"}, []string{"kk", "en"}, "ru"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchLocale(tt.preferred, tt.available, "ru"); got != tt.want {
+				t.Errorf("MatchLocale = %q, ожидалось %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNameTranslationsLocalize(t *testing.T) {
+	translations := NameTranslations{
+		"autumn_jam": {"en": "Autumn Jam", "kk": ""},
+	}
+
+	tests := []struct {
+		name       string
+		urid       string
+		preferred  []string
+		wantName   string
+		wantLocale string
+	}{
+		{"есть перевод", "autumn_jam", []string{"en-gb"}, "Autumn Jam", "en"},
+		{"название не переведено", "autumn_jam", []string{"kk"}, "Осенний джем", "kk"},
+		{"основной язык", "autumn_jam", []string{"ru", "en"}, "Осенний джем", "ru"},
+		{"нет переводов", "spring_jam", []string{"en"}, "Осенний джем", "ru"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, locale := translations.Localize(tt.urid, "Осенний джем", "ru", tt.preferred)
+			if name != tt.wantName || locale != tt.wantLocale {
+				t.Errorf("Localize = %q, %q, ожидалось %q, %q", name, locale, tt.wantName, tt.wantLocale)
+			}
+		})
+	}
+}
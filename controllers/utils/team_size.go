@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"database/sql"
+	"strconv"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+// Типы требования к размеру команды (events.team_requirements_type)
+const (
+	TeamSizeEqual = iota
+	TeamSizeLessOrEqual
+	TeamSizeLess
+	TeamSizeGreaterOrEqual
+	TeamSizeGreater
+)
+
+type TeamSizeRule struct {
+	Type  int
+	Value int
+}
+
+// Правило размера команды для мероприятия
+func GetTeamSizeRule(urid string, db *sql.DB) (*TeamSizeRule, error) {
+	rule := new(TeamSizeRule)
+	if err := db.QueryRow(
+		"SELECT team_requirements_type, team_requirements_value FROM events WHERE urid = $1", urid,
+	).Scan(&rule.Type, &rule.Value); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, huma.Error404NotFound("Этого события нет XP")
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	return rule, nil
+}
+
+// Подходит ли команда из size человек
+func (r *TeamSizeRule) Fits(size int) bool {
+	switch r.Type {
+	case TeamSizeEqual:
+		return size == r.Value
+	case TeamSizeLessOrEqual:
+		return size <= r.Value
+	case TeamSizeLess:
+		return size < r.Value
+	case TeamSizeGreaterOrEqual:
+		return size >= r.Value
+	case TeamSizeGreater:
+		return size > r.Value
+	}
+	return false
+}
+
+// Максимальный размер команды (-1, если сверху не ограничен)
+func (r *TeamSizeRule) Max() int {
+	switch r.Type {
+	case TeamSizeEqual, TeamSizeLessOrEqual:
+		return r.Value
+	case TeamSizeLess:
+		return r.Value - 1
+	}
+	return -1
+}
+
+// Минимальный размер команды
+func (r *TeamSizeRule) Min() int {
+	switch r.Type {
+	case TeamSizeEqual, TeamSizeGreaterOrEqual:
+		return r.Value
+	case TeamSizeGreater:
+		return r.Value + 1
+	}
+	return 1
+}
+
+// Можно ли убрать одного человека из команды из size человек
+func (r *TeamSizeRule) CanLeave(size int) bool {
+	return size-1 >= r.Min()
+}
+
+// Можно ли добавить еще одного человека в команду из size человек
+func (r *TeamSizeRule) HasPlace(size int) bool {
+	max := r.Max()
+	return max == -1 || size < max
+}
+
+func (r *TeamSizeRule) String() string {
+	value := strconv.Itoa(r.Value)
+	switch r.Type {
+	case TeamSizeEqual:
+		return "ровно " + value
+	case TeamSizeLessOrEqual:
+		return "не больше " + value
+	case TeamSizeLess:
+		return "меньше " + value
+	case TeamSizeGreaterOrEqual:
+		return "не меньше " + value
+	case TeamSizeGreater:
+		return "больше " + value
+	}
+	return "неизвестное правило"
+}
+
+// То же правило для SQL: size - выражение с размером команды, в запросе должна быть таблица events
+func TeamSizeCondition(size string) string {
+	return "CASE events.team_requirements_type " +
+		"WHEN 0 THEN " + size + " = events.team_requirements_value " +
+		"WHEN 1 THEN " + size + " <= events.team_requirements_value " +
+		"WHEN 2 THEN " + size + " < events.team_requirements_value " +
+		"WHEN 3 THEN " + size + " >= events.team_requirements_value " +
+		"WHEN 4 THEN " + size + " > events.team_requirements_value " +
+		"ELSE false END"
+}
@@ -0,0 +1,48 @@
+package utils
+
+import "testing"
+
+func TestTeamSizeRule(t *testing.T) {
+	tests := []struct {
+		rule TeamSizeRule
+		min  int
+		max  int
+		fits []int
+		not  []int
+	}{
+		{TeamSizeRule{TeamSizeEqual, 3}, 3, 3, []int{3}, []int{2, 4}},
+		{TeamSizeRule{TeamSizeLessOrEqual, 5}, 1, 5, []int{1, 5}, []int{6}},
+		{TeamSizeRule{TeamSizeLess, 5}, 1, 4, []int{1, 4}, []int{5}},
+		{TeamSizeRule{TeamSizeGreaterOrEqual, 2}, 2, -1, []int{2, 10}, []int{1}},
+		{TeamSizeRule{TeamSizeGreater, 2}, 3, -1, []int{3, 10}, []int{2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule.String(), func(t *testing.T) {
+			if got := tt.rule.Min(); got != tt.min {
+				t.Errorf("Min = %d, ожидалось %d", got, tt.min)
+			}
+			if got := tt.rule.Max(); got != tt.max {
+				t.Errorf("Max = %d, ожидалось %d", got, tt.max)
+			}
+			for _, size := range tt.fits {
+				if !tt.rule.Fits(size) {
+					t.Errorf("команда из %d не подошла", size)
+				}
+			}
+			for _, size := range tt.not {
+				if tt.rule.Fits(size) {
+					t.Errorf("команда из %d подошла", size)
+				}
+			}
+
+			// На границах: в полную команду никого не добавить, из минимальной никого не убрать
+			if tt.max != -1 && (tt.rule.HasPlace(tt.max) || !tt.rule.HasPlace(tt.max-1)) {
+				t.Errorf("HasPlace на границе %d", tt.max)
+			}
+			if tt.rule.CanLeave(tt.min) || !tt.rule.CanLeave(tt.min+1) {
+				t.Errorf("CanLeave на границе %d", tt.min)
+			}
+		})
+	}
+}
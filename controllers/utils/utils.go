@@ -120,3 +120,48 @@ func GetUserShortInfo(email string, db *sql.DB) (*UserShortInfo, error) {
 
 	return result, nil
 }
+
+// Проверяет, что пользователь организатор события, админ организации,
+// которой принадлежит событие (или админ сайта)
+func CheckEventOrganizator(user *UserEmail, urid string, db *sql.DB) error {
+	if user.Perms == 10 {
+		return nil
+	}
+
+	var allowed bool
+	if err := db.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM event_orgs WHERE organizator_email = $1 AND event_uri = $2) "+
+			"OR EXISTS (SELECT 1 FROM events INNER JOIN organization_members ON organization_members.org_urid = events.org_urid "+
+			"WHERE events.urid = $2 AND organization_members.member_email = $1 AND organization_members.role = 'admin')",
+		user.Email, urid,
+	).Scan(&allowed); err != nil {
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+	if !allowed {
+		return huma.Error403Forbidden("Это не твое мероприятие :/")
+	}
+
+	return nil
+}
+
+// Данные кейса доступны организаторам события и жюри партнера этого кейса
+func CheckCaseAccess(user *UserEmail, urid string, caseId int64, db *sql.DB) error {
+	err := CheckEventOrganizator(user, urid, db)
+	if err == nil || caseId == 0 {
+		return err
+	}
+
+	var exists bool
+	if err := db.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM case_judges INNER JOIN event_cases ON case_judges.case_id = event_cases.id "+
+			"WHERE case_judges.case_id = $1 AND case_judges.judge_email = $2 AND event_cases.event_uri = $3)",
+		caseId, user.Email, urid,
+	).Scan(&exists); err != nil {
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+	if !exists {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"database/sql"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+// Токен для GET-запросов передается заголовком, а не в адресе, чтобы не оседать
+// в логах прокси и истории браузера
+type ViewerInput struct {
+	Token string `header:"X-Access-Token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя (необязательно, для закрытых мероприятий)"`
+}
+
+// Может ли пользователь видеть закрытое мероприятие (участник, организатор, сотрудник организации или админ)
+func CanSeeEvent(user *UserEmail, urid string, db *sql.DB) (bool, error) {
+	if user == nil {
+		return false, nil
+	}
+	if user.Perms == 10 {
+		return true, nil
+	}
+
+	var visible bool
+	err := db.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM event_members WHERE event_uri = $1 AND member_email = $2) "+
+			"OR EXISTS (SELECT 1 FROM event_orgs WHERE event_uri = $1 AND organizator_email = $2) "+
+			"OR EXISTS (SELECT 1 FROM events INNER JOIN organization_members ON organization_members.org_urid = events.org_urid "+
+			"WHERE events.urid = $1 AND organization_members.member_email = $2)",
+		urid, user.Email,
+	).Scan(&visible)
+	if err != nil {
+		return false, huma.Error422UnprocessableEntity(err.Error())
+	}
+	return visible, nil
+}
+
+// Проверка для вложенных данных мероприятия (команды, участники, итоги, голосование):
+// у закрытого, не прошедшего модерацию или еще не опубликованного мероприятия их видят только свои
+func CheckEventVisible(token string, urid string, db *sql.DB) error {
+	var open bool
+	if err := db.QueryRow(
+		"SELECT visibility <> 'private' AND moderation_status = 'approved' AND NOT is_draft FROM events WHERE urid = $1", urid,
+	).Scan(&open); err != nil {
+		if err == sql.ErrNoRows {
+			return huma.Error404NotFound("Этого события нет XP")
+		}
+		return huma.Error422UnprocessableEntity(err.Error())
+	}
+	if open {
+		return nil
+	}
+
+	var user *UserEmail
+	if token != "" {
+		var err error
+		if user, err = GetUserEmailByToken(token, db); err != nil {
+			return huma.Error403Forbidden("Пользователь не найден")
+		}
+	}
+	visible, err := CanSeeEvent(user, urid, db)
+	if err != nil {
+		return err
+	}
+	if !visible {
+		return huma.Error403Forbidden("Это закрытое мероприятие")
+	}
+	return nil
+}
@@ -0,0 +1,453 @@
+package voting
+
+import (
+	"database/sql"
+	"hackaton-jam-back/controllers/utils"
+	"sort"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/lib/pq"
+)
+
+// Состояния голосования
+const (
+	StatusOff      = "off"
+	StatusUpcoming = "upcoming"
+	StatusOpen     = "open"
+	StatusClosed   = "closed"
+)
+
+type VotingSettings struct {
+	StartsAt *time.Time `json:"starts_at,omitempty" doc:"Начало голосования"`
+	EndsAt   *time.Time `json:"ends_at,omitempty" doc:"Конец голосования"`
+	Limit    int        `json:"limit" example:"3" doc:"Сколько голосов у одного пользователя"`
+	Live     bool       `json:"live" doc:"Показывать ли счет во время голосования"`
+}
+
+type Candidate struct {
+	SubmissionId int64  `json:"submission_id" example:"1" doc:"Идентификатор работы"`
+	TeamId       int64  `json:"team_id" example:"2" doc:"Идентификатор команды"`
+	TeamName     string `json:"team_name" example:"Супер-команда" doc:"Название команды"`
+	Title        string `json:"title" example:"Супер-игра" doc:"Название проекта"`
+	Votes        *int   `json:"votes,omitempty" example:"42" doc:"Голосов (скрыто, пока счет не открыт)"`
+	MyVote       bool   `json:"my_vote" doc:"Голосовал ли за работу текущий пользователь"`
+	Own          bool   `json:"own" doc:"Работа команды текущего пользователя (голосовать нельзя)"`
+}
+
+type VotingInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token string `json:"access_token,omitempty" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя (необязательно)"`
+	}
+}
+
+type VotingSettingsInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token    string    `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+		StartsAt time.Time `json:"starts_at" doc:"Начало голосования"`
+		EndsAt   time.Time `json:"ends_at" doc:"Конец голосования"`
+		Limit    int       `json:"limit" minimum:"1" maximum:"20" example:"3" doc:"Сколько голосов у одного пользователя"`
+		Live     bool      `json:"live,omitempty" doc:"Показывать ли счет во время голосования"`
+	}
+}
+
+type VotingCandidatesInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token         string  `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+		SubmissionIds []int64 `json:"submission_ids" maxItems:"500" doc:"Работы, которые участвуют в голосовании (остальные убираются)"`
+	}
+}
+
+type VoteInput struct {
+	Id   int64 `path:"id" example:"1" doc:"Идентификатор работы"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type VotingOutput struct {
+	Body struct {
+		Settings     VotingSettings `json:"settings" doc:"Правила голосования"`
+		Status       string         `json:"status" enum:"off,upcoming,open,closed" example:"open" doc:"Состояние голосования"`
+		TallyVisible bool           `json:"tally_visible" doc:"Виден ли счет"`
+		VotesLeft    int            `json:"votes_left" example:"2" doc:"Сколько голосов осталось у текущего пользователя"`
+		Candidates   []*Candidate   `json:"candidates" doc:"Отобранные организаторами работы, за которые можно голосовать"`
+	}
+}
+
+func GetVoting(input *VotingInput, db *sql.DB) (*VotingOutput, error) {
+	if err := utils.CheckEventVisible(input.Body.Token, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	var user *utils.UserEmail
+	if input.Body.Token != "" {
+		var err error
+		if user, err = utils.GetUserEmailByToken(input.Body.Token, db); err != nil {
+			return nil, huma.Error403Forbidden("Пользователь не найден")
+		}
+	}
+
+	return getVoting(input.Urid, user, db)
+}
+
+func EditSettings(input *VotingSettingsInput, db *sql.DB) (*VotingOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	if !input.Body.EndsAt.After(input.Body.StartsAt) {
+		return nil, huma.Error422UnprocessableEntity("Голосование должно заканчиваться позже, чем начинается")
+	}
+
+	_, err = db.Exec(
+		"UPDATE events SET voting_starts_at = $2, voting_ends_at = $3, voting_limit = $4, voting_live = $5 WHERE urid = $1",
+		input.Urid, input.Body.StartsAt, input.Body.EndsAt, input.Body.Limit, input.Body.Live,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return getVoting(input.Urid, user, db)
+}
+
+// Организаторы отбирают работы в голосование, пока оно не началось
+func SelectCandidates(input *VotingCandidatesInput, db *sql.DB) (*VotingOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	settings, err := getSettings(input.Urid, db)
+	if err != nil {
+		return nil, err
+	}
+	if current := status(settings); current == StatusOpen || current == StatusClosed {
+		return nil, huma.Error422UnprocessableEntity("Голосование уже началось, список работ менять нельзя")
+	}
+
+	ids := input.Body.SubmissionIds
+	if ids == nil {
+		ids = []int64{}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer tx.Rollback()
+
+	var foreign bool
+	if err := tx.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM unnest($2::bigint[]) AS selected (id) "+
+			"WHERE NOT EXISTS (SELECT 1 FROM submissions WHERE submissions.id = selected.id AND submissions.event_uri = $1))",
+		input.Urid, pq.Array(ids),
+	).Scan(&foreign); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if foreign {
+		return nil, huma.Error422UnprocessableEntity("Такой работы нет в этом событии")
+	}
+
+	if _, err := tx.Exec(
+		"UPDATE submissions SET in_voting = (id = ANY($2)) WHERE event_uri = $1", input.Urid, pq.Array(ids),
+	); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return getVoting(input.Urid, user, db)
+}
+
+// Голосовать могут пользователи с подтвержденной почтой, только за отобранные работы, не более
+// одного раза за работу, не за свою команду и не больше лимита голосов события
+func Vote(input *VoteInput, db *sql.DB) (*VotingOutput, error) {
+	user, urid, err := checkVoter(input.Body.Token, input.Id, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var verified bool
+	if err := db.QueryRow("SELECT email_verified FROM users WHERE email = $1", user.Email).Scan(&verified); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if !verified {
+		return nil, huma.Error403Forbidden("Голосовать можно только с подтвержденной почтой")
+	}
+
+	var inVoting, own bool
+	if err := db.QueryRow(
+		"SELECT in_voting, EXISTS (SELECT 1 FROM teams_members WHERE teams_members.team_id = submissions.team_id "+
+			"AND teams_members.member_email = $2 AND teams_members.pending = false) "+
+			"FROM submissions WHERE id = $1",
+		input.Id, user.Email,
+	).Scan(&inVoting, &own); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if !inVoting {
+		return nil, huma.Error422UnprocessableEntity("Эта работа не участвует в голосовании")
+	}
+	if own {
+		return nil, huma.Error403Forbidden("Нельзя голосовать за свою команду")
+	}
+
+	// Параллельные запросы одного зрителя иначе видят одинаковое число голосов и вместе превышают лимит,
+	// поэтому проверка и запись идут в одной транзакции под блокировкой на пару зритель-событие
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1))", "vote:"+urid+":"+user.Email); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	var voted bool
+	var used, limit int
+	if err := tx.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM audience_votes WHERE submission_id = $1 AND voter_email = $2), "+
+			"(SELECT COUNT(*) FROM audience_votes WHERE event_uri = $3 AND voter_email = $2), "+
+			"(SELECT voting_limit FROM events WHERE urid = $3)",
+		input.Id, user.Email, urid,
+	).Scan(&voted, &used, &limit); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if voted {
+		return nil, huma.Error422UnprocessableEntity("Вы уже голосовали за эту работу")
+	}
+	if used >= limit {
+		return nil, huma.Error422UnprocessableEntity("У вас не осталось голосов")
+	}
+
+	if _, err := tx.Exec(
+		"INSERT INTO audience_votes (submission_id, voter_email, event_uri) VALUES ($1, $2, $3)",
+		input.Id, user.Email, urid,
+	); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return getVoting(urid, user, db)
+}
+
+// Голос можно забрать, пока голосование идет
+func Unvote(input *VoteInput, db *sql.DB) (*VotingOutput, error) {
+	user, urid, err := checkVoter(input.Body.Token, input.Id, db)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := db.Exec("DELETE FROM audience_votes WHERE submission_id = $1 AND voter_email = $2", input.Id, user.Email)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, huma.Error404NotFound("Вы не голосовали за эту работу")
+	}
+
+	return getVoting(urid, user, db)
+}
+
+// Победители зрительского голосования: работы с наибольшим числом голосов (несколько при равенстве).
+// Доступны только после окончания голосования
+func GetWinners(urid string, db *sql.DB) ([]*Candidate, error) {
+	settings, err := getSettings(urid, db)
+	if err != nil {
+		return nil, err
+	}
+	if status(settings) != StatusClosed {
+		return nil, huma.Error422UnprocessableEntity("Голосование еще не закончилось")
+	}
+
+	candidates, err := getCandidates(urid, "", db)
+	if err != nil {
+		return nil, err
+	}
+
+	var winners []*Candidate
+	for _, candidate := range candidates {
+		if *candidate.Votes == 0 || (len(winners) > 0 && *candidate.Votes < *winners[0].Votes) {
+			break
+		}
+		winners = append(winners, candidate)
+	}
+
+	return winners, nil
+}
+
+// Проверяет, что пользователь может голосовать за работу прямо сейчас, и возвращает событие работы
+func checkVoter(token string, submissionId int64, db *sql.DB) (*utils.UserEmail, string, error) {
+	user, err := utils.GetUserEmailByToken(token, db)
+	if err != nil {
+		return nil, "", huma.Error403Forbidden("Пользователь не найден")
+	}
+
+	var urid string
+	if err := db.QueryRow("SELECT event_uri FROM submissions WHERE id = $1", submissionId).Scan(&urid); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, "", huma.Error404NotFound("Такой работы нет")
+		}
+		return nil, "", huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	settings, err := getSettings(urid, db)
+	if err != nil {
+		return nil, "", err
+	}
+	switch status(settings) {
+	case StatusOff:
+		return nil, "", huma.Error422UnprocessableEntity("Зрительского голосования на этом событии нет")
+	case StatusUpcoming:
+		return nil, "", huma.Error422UnprocessableEntity("Голосование еще не началось")
+	case StatusClosed:
+		return nil, "", huma.Error422UnprocessableEntity("Голосование уже закончилось")
+	}
+
+	if utils.CheckEventOrganizator(user, urid, db) == nil {
+		return nil, "", huma.Error403Forbidden("Организаторы не участвуют в зрительском голосовании")
+	}
+
+	return user, urid, nil
+}
+
+func status(settings *VotingSettings) string {
+	now := time.Now()
+	switch {
+	case settings.StartsAt == nil || settings.EndsAt == nil:
+		return StatusOff
+	case now.Before(*settings.StartsAt):
+		return StatusUpcoming
+	case now.Before(*settings.EndsAt):
+		return StatusOpen
+	default:
+		return StatusClosed
+	}
+}
+
+func getSettings(urid string, db *sql.DB) (*VotingSettings, error) {
+	settings := new(VotingSettings)
+	var startsAt, endsAt sql.NullTime
+	if err := db.QueryRow(
+		"SELECT voting_starts_at, voting_ends_at, voting_limit, voting_live FROM events WHERE urid = $1", urid,
+	).Scan(&startsAt, &endsAt, &settings.Limit, &settings.Live); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, huma.Error404NotFound("Этого события нет XP")
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if startsAt.Valid {
+		settings.StartsAt = &startsAt.Time
+	}
+	if endsAt.Valid {
+		settings.EndsAt = &endsAt.Time
+	}
+
+	return settings, nil
+}
+
+// Отобранные в голосование работы события по убыванию голосов. Если voter не пустой - отмечаются его голоса и его команда
+func getCandidates(urid string, voter string, db *sql.DB) ([]*Candidate, error) {
+	rows, err := db.Query(
+		"SELECT submissions.id, submissions.team_id, teams.name, submissions.title, "+
+			"(SELECT COUNT(*) FROM audience_votes WHERE audience_votes.submission_id = submissions.id) AS votes, "+
+			"EXISTS (SELECT 1 FROM audience_votes WHERE audience_votes.submission_id = submissions.id AND audience_votes.voter_email = $2), "+
+			"EXISTS (SELECT 1 FROM teams_members WHERE teams_members.team_id = submissions.team_id "+
+			"AND teams_members.member_email = $2 AND teams_members.pending = false) "+
+			"FROM submissions INNER JOIN teams ON submissions.team_id = teams.id "+
+			"WHERE submissions.event_uri = $1 AND submissions.in_voting ORDER BY votes DESC, submissions.id",
+		urid, voter,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	candidates := []*Candidate{}
+	for rows.Next() {
+		candidate := new(Candidate)
+		var votes int
+		if err := rows.Scan(
+			&candidate.SubmissionId,
+			&candidate.TeamId,
+			&candidate.TeamName,
+			&candidate.Title,
+			&votes,
+			&candidate.MyVote,
+			&candidate.Own,
+		); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		candidate.Votes = &votes
+		candidates = append(candidates, candidate)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return candidates, nil
+}
+
+func getVoting(urid string, user *utils.UserEmail, db *sql.DB) (*VotingOutput, error) {
+	settings, err := getSettings(urid, db)
+	if err != nil {
+		return nil, err
+	}
+
+	result := new(VotingOutput)
+	result.Body.Settings = *settings
+	result.Body.Status = status(settings)
+
+	// Счет виден всем в живом режиме и после окончания, организаторам - всегда
+	result.Body.TallyVisible = settings.Live || result.Body.Status == StatusClosed
+	voter := ""
+	if user != nil {
+		voter = user.Email
+		if !result.Body.TallyVisible && utils.CheckEventOrganizator(user, urid, db) == nil {
+			result.Body.TallyVisible = true
+		}
+	}
+
+	result.Body.Candidates, err = getCandidates(urid, voter, db)
+	if err != nil {
+		return nil, err
+	}
+
+	if user != nil {
+		used := 0
+		for _, candidate := range result.Body.Candidates {
+			if candidate.MyVote {
+				used++
+			}
+		}
+		result.Body.VotesLeft = max(settings.Limit-used, 0)
+	}
+
+	if !result.Body.TallyVisible {
+		// Без счета порядок не должен подсказывать лидера
+		for _, candidate := range result.Body.Candidates {
+			candidate.Votes = nil
+		}
+		sort.Slice(result.Body.Candidates, func(i, j int) bool {
+			return result.Body.Candidates[i].SubmissionId < result.Body.Candidates[j].SubmissionId
+		})
+	}
+
+	return result, nil
+}
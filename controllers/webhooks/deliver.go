@@ -0,0 +1,297 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+const (
+	// После стольких неудачных попыток отправка помечается как failed
+	MaxAttempts = 10
+	// Пауза перед повтором удваивается с каждой попыткой: 30 секунд, минута, две...
+	retryBase = 30 * time.Second
+	retryMax  = 6 * time.Hour
+	// Сколько отправка считается занятой воркером (чтобы два воркера не слали одно и то же)
+	claimLease  = 5 * time.Minute
+	batchSize   = 20
+	sendTimeout = 10 * time.Second
+)
+
+// Заголовки запроса к получателю
+const (
+	HeaderEvent     = "X-Hjam-Event"
+	HeaderDelivery  = "X-Hjam-Delivery"
+	HeaderTimestamp = "X-Hjam-Timestamp"
+	HeaderSignature = "X-Hjam-Signature"
+)
+
+type Payload struct {
+	Type      string    `json:"type"`
+	EventUri  string    `json:"event_urid"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+var client = &http.Client{
+	Timeout: sendTimeout,
+	Transport: &http.Transport{
+		// Без прокси: иначе адрес проверялся бы у прокси, а не у получателя
+		Proxy: nil,
+		DialContext: (&net.Dialer{
+			Timeout: sendTimeout,
+			Control: dialControl,
+		}).DialContext,
+		TLSHandshakeTimeout: sendTimeout,
+		MaxIdleConnsPerHost: 2,
+		IdleConnTimeout:     time.Minute,
+	},
+	// Редиректы не проходим: получатель должен отвечать сам
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// Кладет событие в очередь всех активных вебхуков мероприятия, подписанных на этот тип.
+// Отправляет воркер, так что запрос пользователя не ждет получателей
+func Enqueue(urid string, eventType string, data any, db *sql.DB) error {
+	payload, err := buildPayload(urid, eventType, data)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(
+		"INSERT INTO webhook_deliveries (webhook_id, type, payload) "+
+			"SELECT id, $2, $3 FROM webhooks WHERE event_uri = $1 AND is_active = true AND $2 = ANY(types)",
+		urid, eventType, payload,
+	)
+	return err
+}
+
+// Подпись тела запроса: HMAC-SHA256 от "<timestamp>.<body>" на секрете вебхука, в hex.
+// Получатель считает ее так же и сравнивает с заголовком X-Hjam-Signature (без префикса sha256=)
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Раз в interval отправляет все отправки, у которых подошло время
+func RunWorker(db *sql.DB, interval time.Duration) {
+	for {
+		if err := DeliverPending(db); err != nil {
+			log.Printf("Вебхуки: %v", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+func DeliverPending(db *sql.DB) error {
+	for {
+		// Забираем пачку в одном запросе, чтобы ее не взял другой воркер
+		rows, err := db.Query(
+			"UPDATE webhook_deliveries SET next_attempt_at = $1 WHERE id IN ("+
+				"SELECT webhook_deliveries.id FROM webhook_deliveries "+
+				"INNER JOIN webhooks ON webhooks.id = webhook_deliveries.webhook_id "+
+				"WHERE webhook_deliveries.status = 'pending' AND webhook_deliveries.next_attempt_at <= now() AND webhooks.is_active = true "+
+				"ORDER BY webhook_deliveries.next_attempt_at LIMIT $2 FOR UPDATE OF webhook_deliveries SKIP LOCKED"+
+				") RETURNING id",
+			time.Now().Add(claimLease), batchSize,
+		)
+		if err != nil {
+			return err
+		}
+
+		var ids []int64
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			if err := attempt(id, db); err != nil {
+				return err
+			}
+		}
+
+		if len(ids) < batchSize {
+			return nil
+		}
+	}
+}
+
+func deliverNow(id int64, db *sql.DB) (*DeliveryOutput, error) {
+	if err := attempt(id, db); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	delivery, err := scanDelivery(db.QueryRow("SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE id = $1", id))
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return &DeliveryOutput{Body: *delivery}, nil
+}
+
+// Одна попытка отправки. Ошибка возвращается только при проблемах с базой,
+// неудачная отправка записывается в журнал и планируется повтор
+func attempt(id int64, db *sql.DB) error {
+	var hookUrl, secret, eventType, payload string
+	var attempts int
+	err := db.QueryRow(
+		"SELECT webhooks.url, webhooks.secret, webhook_deliveries.type, webhook_deliveries.payload, webhook_deliveries.attempts "+
+			"FROM webhook_deliveries INNER JOIN webhooks ON webhooks.id = webhook_deliveries.webhook_id "+
+			"WHERE webhook_deliveries.id = $1 AND webhook_deliveries.status = 'pending'",
+		id,
+	).Scan(&hookUrl, &secret, &eventType, &payload, &attempts)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	code, sendErr := send(hookUrl, secret, id, eventType, []byte(payload))
+	attempts++
+
+	if sendErr == nil {
+		_, err = db.Exec(
+			"UPDATE webhook_deliveries SET status = 'delivered', attempts = $2, response_code = $3, error = NULL, delivered_at = now() "+
+				"WHERE id = $1",
+			id, attempts, code,
+		)
+		return err
+	}
+
+	status := "pending"
+	if attempts >= MaxAttempts {
+		status = "failed"
+	}
+
+	var responseCode sql.NullInt64
+	if code != 0 {
+		responseCode = sql.NullInt64{Int64: int64(code), Valid: true}
+	}
+
+	_, err = db.Exec(
+		"UPDATE webhook_deliveries SET status = $2, attempts = $3, response_code = $4, error = $5, next_attempt_at = $6 WHERE id = $1",
+		id, status, attempts, responseCode, sendErr.Error(), time.Now().Add(backoff(attempts)),
+	)
+	return err
+}
+
+func send(hookUrl string, secret string, id int64, eventType string, body []byte) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, hookUrl, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "HackatonJam-Webhooks/1.0")
+	req.Header.Set(HeaderEvent, eventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(id, 10))
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, "sha256="+Sign(secret, timestamp, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, connectError(err)
+	}
+	defer resp.Body.Close()
+	// Тело ответа не читаем и не храним: иначе вебхуком можно было бы читать чужие ответы
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, errors.New("Получатель ответил " + resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// В журнал пишем только причину, без подробностей сети
+func connectError(err error) error {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, errForbiddenAddress):
+		return errors.New("Адрес получателя во внутренней сети")
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return errors.New("Получатель не ответил вовремя")
+	default:
+		return errors.New("Не удалось подключиться к получателю")
+	}
+}
+
+func backoff(attempts int) time.Duration {
+	delay := retryBase
+	for i := 1; i < attempts && delay < retryMax; i++ {
+		delay *= 2
+	}
+	return min(delay, retryMax)
+}
+
+func buildPayload(urid string, eventType string, data any) (string, error) {
+	payload, err := json.Marshal(Payload{Type: eventType, EventUri: urid, CreatedAt: time.Now(), Data: data})
+	if err != nil {
+		return "", err
+	}
+	return string(payload), nil
+}
+
+const deliveryColumns = "id, webhook_id, type, payload, status, attempts, response_code, error, created_at, next_attempt_at, delivered_at"
+
+func scanDelivery(row interface{ Scan(dest ...any) error }) (*Delivery, error) {
+	delivery := new(Delivery)
+	var responseCode sql.NullInt64
+	var errText sql.NullString
+	var nextAttemptAt time.Time
+	var deliveredAt sql.NullTime
+	if err := row.Scan(
+		&delivery.Id,
+		&delivery.WebhookId,
+		&delivery.Type,
+		&delivery.Payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&responseCode,
+		&errText,
+		&delivery.CreatedAt,
+		&nextAttemptAt,
+		&deliveredAt,
+	); err != nil {
+		return nil, err
+	}
+
+	delivery.ResponseCode = int(responseCode.Int64)
+	delivery.Error = errText.String
+	if delivery.Status == "pending" {
+		delivery.NextAttemptAt = &nextAttemptAt
+	}
+	if deliveredAt.Valid {
+		delivery.DeliveredAt = &deliveredAt.Time
+	}
+
+	return delivery, nil
+}
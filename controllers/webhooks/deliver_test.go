@@ -0,0 +1,110 @@
+package webhooks
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+)
+
+// Ожидаемые подписи посчитаны отдельно: python3 -c "import hmac,hashlib; print(hmac.new(...).hexdigest())"
+func TestSign(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		body      string
+		want      string
+	}{
+		{"обычный", "whsec_test", "1760875200", `{"type":"member.joined"}`, "026c2953ce5eb00fdccb8d9f90e5abda7dc05fbfc8072c4ca7916dc7290b8fd0"},
+		{"пустые", "", "0", "", "b849d5a581847b281957065739df36df2463d1977ea8d6e1e4e6cf33fadc68c3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sign(tt.secret, tt.timestamp, []byte(tt.body)); got != tt.want {
+				t.Errorf("Sign = %s, ожидалось %s", got, tt.want)
+			}
+		})
+	}
+
+	// Время входит в подпись, иначе старый запрос можно повторить
+	if Sign("s", "1", []byte("x")) == Sign("s", "2", []byte("x")) {
+		t.Error("подпись не зависит от времени")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, retryBase},
+		{1, retryBase},
+		{2, 2 * retryBase},
+		{3, 4 * retryBase},
+		{6, 32 * retryBase},
+		{12, retryMax},
+		{1000, retryMax},
+	}
+
+	for _, tt := range tests {
+		if got := backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, ожидалось %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestIsAllowedAddr(t *testing.T) {
+	allowed := parseAllowedNets("172.17.0.1, 10.0.5.0/24, мусор")
+
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"192.168.0.10", false},
+		{"172.16.5.4", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"100.64.0.1", false},
+		{"::ffff:127.0.0.1", false},
+		{"fc00::1", false},
+		{"224.0.0.1", false},
+		// Разрешенные в WEBHOOK_ALLOWED_NETS
+		{"172.17.0.1", true},
+		{"172.17.0.2", false},
+		{"10.0.5.77", true},
+	}
+
+	for _, tt := range tests {
+		if got := isAllowedAddr(netip.MustParseAddr(tt.addr), allowed); got != tt.want {
+			t.Errorf("isAllowedAddr(%s) = %v, ожидалось %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestDialControl(t *testing.T) {
+	tests := []struct {
+		address string
+		ok      bool
+	}{
+		{"93.184.216.34:443", true},
+		{"127.0.0.1:8080", false},
+		{"[::1]:80", false},
+		{"169.254.169.254:80", false},
+		{"localhost:80", false},
+		{"без порта", false},
+	}
+
+	for _, tt := range tests {
+		if err := dialControl("tcp", tt.address, nil); (err == nil) != tt.ok {
+			t.Errorf("dialControl(%s) = %v, ожидалось разрешение: %v", tt.address, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Вебхуки задают организаторы, поэтому сервер не должен ходить по их ссылкам во внутреннюю сеть:
+// к себе, в локальные подсети, к метаданным облака. Проверка стоит в самом подключении,
+// уже после DNS, так что подмена адреса между проверкой и отправкой ничего не дает
+var errForbiddenAddress = errors.New("адрес получателя во внутренней сети")
+
+// Служебные диапазоны, которых нет среди методов netip.Addr
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+}
+
+// Подсети, куда все же можно отправлять (например, локальный получатель при разработке).
+// Задаются в WEBHOOK_ALLOWED_NETS через запятую: 172.17.0.1/32,10.0.5.0/24
+var allowedPrefixes = parseAllowedNets(os.Getenv("WEBHOOK_ALLOWED_NETS"))
+
+func parseAllowedNets(value string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if !strings.Contains(part, "/") {
+			if addr, err := netip.ParseAddr(part); err == nil {
+				prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			}
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(part); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+		}
+	}
+	return prefixes
+}
+
+// Можно ли отправлять на этот адрес
+func isAllowedAddr(addr netip.Addr, allowed []netip.Prefix) bool {
+	addr = addr.Unmap()
+	for _, prefix := range allowed {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// Вызывается перед каждым подключением с уже разрешенным адресом
+func dialControl(network string, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !isAllowedAddr(addr, allowedPrefixes) {
+		return errForbiddenAddress
+	}
+	return nil
+}
+
+// Проверка при сохранении ссылки, чтобы сразу сказать организатору, что адрес не подойдет.
+// Защищает не она, а dialControl
+func checkHost(host string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return errors.New("не удалось найти адрес " + host)
+	}
+	for _, addr := range addrs {
+		if !isAllowedAddr(addr, allowedPrefixes) {
+			return errForbiddenAddress
+		}
+	}
+	return nil
+}
@@ -0,0 +1,459 @@
+package webhooks
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"hackaton-jam-back/controllers/utils"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/lib/pq"
+)
+
+// Типы событий, на которые можно подписаться
+const (
+	TypeMemberJoined     = "member.joined"
+	TypeTeamCreated      = "team.created"
+	TypeSubmissionSaved  = "submission.saved"
+	TypeResultsPublished = "results.published"
+	// Тестовая отправка, подписываться на нее не нужно
+	TypePing = "ping"
+)
+
+var Types = []string{TypeMemberJoined, TypeTeamCreated, TypeSubmissionSaved, TypeResultsPublished}
+
+// Данные события member.joined
+type MemberData struct {
+	Email    string `json:"email" example:"thatmaidguy@ya.ru" doc:"E-mail участника"`
+	Username string `json:"username,omitempty" example:"ThatMaidGuy" doc:"Никнейм (пусто, если аккаунта еще нет)"`
+	Source   string `json:"source" enum:"join,invitation,import" example:"join" doc:"Как участник попал на мероприятие"`
+}
+
+type Webhook struct {
+	Id        int64     `json:"id" example:"1" doc:"Идентификатор вебхука"`
+	EventUri  string    `json:"event_urid" example:"example_events" doc:"Ссылка на мероприятие"`
+	Url       string    `json:"url" example:"https://example.com/hooks/hjam" doc:"Куда отправлять события"`
+	Secret    string    `json:"secret,omitempty" example:"9f86d081884c7d659a2feaa0c55ad015" doc:"Секрет для проверки подписи (показывается только при создании)"`
+	Types     []string  `json:"types" doc:"На какие события подписан"`
+	Active    bool      `json:"active" doc:"Включен ли вебхук"`
+	CreatedBy string    `json:"created_by" example:"thatmaidguy@ya.ru" doc:"Кто создал"`
+	CreatedAt time.Time `json:"created_at" doc:"Время создания"`
+	Pending   int       `json:"pending" example:"0" doc:"Сколько отправок ждут очереди"`
+	Failed    int       `json:"failed" example:"0" doc:"Сколько отправок не удалось доставить"`
+}
+
+type Delivery struct {
+	Id            int64      `json:"id" example:"1" doc:"Идентификатор отправки"`
+	WebhookId     int64      `json:"webhook_id" example:"1" doc:"Идентификатор вебхука"`
+	Type          string     `json:"type" example:"team.created" doc:"Тип события"`
+	Payload       string     `json:"payload" doc:"Отправленное тело запроса"`
+	Status        string     `json:"status" enum:"pending,delivered,failed" example:"delivered" doc:"Состояние отправки"`
+	Attempts      int        `json:"attempts" example:"1" doc:"Сколько было попыток"`
+	ResponseCode  int        `json:"response_code,omitempty" example:"200" doc:"HTTP-код последнего ответа"`
+	Error         string     `json:"error,omitempty" doc:"Ошибка последней попытки"`
+	CreatedAt     time.Time  `json:"created_at" doc:"Когда событие попало в очередь"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty" doc:"Когда будет следующая попытка"`
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty" doc:"Когда доставлено"`
+}
+
+type WebhooksInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type WebhookAddInput struct {
+	Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+
+		Url    string   `json:"url" maxLength:"1024" example:"https://example.com/hooks/hjam" doc:"Куда отправлять события (http или https)"`
+		Secret string   `json:"secret,omitempty" minLength:"16" maxLength:"128" doc:"Секрет для подписи (если не указан - сгенерируется)"`
+		Types  []string `json:"types" minItems:"1" doc:"На какие события подписаться (member.joined, team.created, submission.saved, results.published)"`
+	}
+}
+
+type WebhookEditInput struct {
+	Id   int64 `path:"id" example:"1" doc:"Идентификатор вебхука"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+
+		Url    string   `json:"url,omitempty" maxLength:"1024" example:"https://example.com/hooks/hjam" doc:"Новый адрес (если не указан - не меняется)"`
+		Types  []string `json:"types,omitempty" doc:"Новый список событий (если не указан - не меняется)"`
+		Active *bool    `json:"active,omitempty" doc:"Включить или выключить"`
+	}
+}
+
+type WebhookIdInput struct {
+	Id   int64 `path:"id" example:"1" doc:"Идентификатор вебхука"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type DeliveriesInput struct {
+	Id     int64  `path:"id" example:"1" doc:"Идентификатор вебхука"`
+	Status string `query:"status" enum:"pending,delivered,failed" doc:"Только отправки в этом состоянии"`
+	Body   struct {
+		Token  string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+		Cursor string `json:"cursor,omitempty" doc:"Курсор следующей страницы из прошлого ответа (пусто - первая страница)"`
+		Count  int    `json:"count,omitempty" minimum:"0" maximum:"100" example:"20" doc:"Количество отправок на страницу"`
+	}
+}
+
+type DeliveryRetryInput struct {
+	Id   int64 `path:"id" example:"1" doc:"Идентификатор отправки"`
+	Body struct {
+		Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+	}
+}
+
+type WebhooksOutput struct {
+	Body struct {
+		Types    []string   `json:"types" doc:"Доступные типы событий"`
+		Webhooks []*Webhook `json:"webhooks" doc:"Вебхуки мероприятия"`
+	}
+}
+
+type WebhookOutput struct {
+	Body Webhook
+}
+
+type DeliveriesOutput struct {
+	Body struct {
+		Deliveries []*Delivery `json:"deliveries" doc:"Журнал отправок (новые сверху)"`
+		NextCursor string      `json:"next_cursor,omitempty" doc:"Курсор следующей страницы (пусто - это последняя)"`
+	}
+}
+
+type DeliveryOutput struct {
+	Body Delivery
+}
+
+func GetWebhooks(input *WebhooksInput, db *sql.DB) (*WebhooksOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	return getWebhooks(input.Urid, db)
+}
+
+func AddWebhook(input *WebhookAddInput, db *sql.DB) (*WebhookOutput, error) {
+	// Проверить наша ли меро?
+	user, err := utils.GetUserEmailByToken(input.Body.Token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+	if err := utils.CheckEventOrganizator(user, input.Urid, db); err != nil {
+		return nil, err
+	}
+
+	if err := checkUrl(input.Body.Url); err != nil {
+		return nil, err
+	}
+	if err := checkTypes(input.Body.Types); err != nil {
+		return nil, err
+	}
+
+	secret := input.Body.Secret
+	if secret == "" {
+		buf := make([]byte, 24)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, huma.Error500InternalServerError(err.Error())
+		}
+		secret = hex.EncodeToString(buf)
+	}
+
+	var id int64
+	if err := db.QueryRow(
+		"INSERT INTO webhooks (event_uri, url, secret, types, created_by) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+		input.Urid, input.Body.Url, secret, pq.Array(input.Body.Types), user.Email,
+	).Scan(&id); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	hook, err := getWebhook(id, db)
+	if err != nil {
+		return nil, err
+	}
+	// Секрет видно только сейчас
+	hook.Secret = secret
+
+	return &WebhookOutput{Body: *hook}, nil
+}
+
+func EditWebhook(input *WebhookEditInput, db *sql.DB) (*WebhookOutput, error) {
+	if _, err := checkWebhookOrganizator(input.Body.Token, input.Id, db); err != nil {
+		return nil, err
+	}
+
+	if input.Body.Url != "" {
+		if err := checkUrl(input.Body.Url); err != nil {
+			return nil, err
+		}
+	}
+	if input.Body.Types != nil {
+		if err := checkTypes(input.Body.Types); err != nil {
+			return nil, err
+		}
+	}
+
+	var types any
+	if input.Body.Types != nil {
+		types = pq.Array(input.Body.Types)
+	}
+
+	_, err := db.Exec(
+		"UPDATE webhooks SET url = COALESCE(NULLIF($2, ''), url), types = COALESCE($3, types), "+
+			"is_active = COALESCE($4, is_active) WHERE id = $1",
+		input.Id, input.Body.Url, types, input.Body.Active,
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	hook, err := getWebhook(input.Id, db)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WebhookOutput{Body: *hook}, nil
+}
+
+// Удаляет вебхук вместе с журналом отправок
+func DelWebhook(input *WebhookIdInput, db *sql.DB) (*WebhooksOutput, error) {
+	hook, err := checkWebhookOrganizator(input.Body.Token, input.Id, db)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec("DELETE FROM webhook_deliveries WHERE webhook_id = $1", input.Id)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	_, err = db.Exec("DELETE FROM webhooks WHERE id = $1", input.Id)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return getWebhooks(hook.EventUri, db)
+}
+
+// Отправляет тестовое событие сразу, не дожидаясь очереди. Неудачная попытка
+// дальше повторяется как обычная отправка
+func TestWebhook(input *WebhookIdInput, db *sql.DB) (*DeliveryOutput, error) {
+	hook, err := checkWebhookOrganizator(input.Body.Token, input.Id, db)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := buildPayload(hook.EventUri, TypePing, map[string]any{"webhook_id": hook.Id})
+	if err != nil {
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+
+	var id int64
+	if err := db.QueryRow(
+		"INSERT INTO webhook_deliveries (webhook_id, type, payload, next_attempt_at) "+
+			"VALUES ($1, $2, $3, $4) RETURNING id",
+		hook.Id, TypePing, payload, time.Now().Add(claimLease),
+	).Scan(&id); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return deliverNow(id, db)
+}
+
+func GetDeliveries(input *DeliveriesInput, db *sql.DB) (*DeliveriesOutput, error) {
+	if _, err := checkWebhookOrganizator(input.Body.Token, input.Id, db); err != nil {
+		return nil, err
+	}
+
+	page, err := utils.NewPage(input.Body.Cursor, "created", input.Body.Count)
+	if err != nil {
+		return nil, err
+	}
+
+	query := "SELECT " + deliveryColumns + " FROM webhook_deliveries WHERE webhook_id = $1 AND ($2::text = '' OR status = $2)"
+	args := []any{input.Id, input.Status, page.Limit()}
+	if page.After != nil {
+		query += " AND (created_at, id) < ($4::timestamptz, $5::bigint)"
+		args = append(args, page.After.Value, page.After.Key)
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT $3"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	result := new(DeliveriesOutput)
+	result.Body.Deliveries = []*Delivery{}
+
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+
+		if !page.Add(delivery.CreatedAt.Format(time.RFC3339Nano), strconv.FormatInt(delivery.Id, 10)) {
+			break
+		}
+
+		result.Body.Deliveries = append(result.Body.Deliveries, delivery)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	result.Body.NextCursor = page.NextCursor()
+
+	return result, nil
+}
+
+// Повторяет отправку сразу, счетчик попыток начинается заново
+func RetryDelivery(input *DeliveryRetryInput, db *sql.DB) (*DeliveryOutput, error) {
+	var webhookId int64
+	if err := db.QueryRow("SELECT webhook_id FROM webhook_deliveries WHERE id = $1", input.Id).Scan(&webhookId); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, huma.Error404NotFound("Такой отправки нет")
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	if _, err := checkWebhookOrganizator(input.Body.Token, webhookId, db); err != nil {
+		return nil, err
+	}
+
+	res, err := db.Exec(
+		"UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = $2 "+
+			"WHERE id = $1 AND status <> 'delivered'",
+		input.Id, time.Now().Add(claimLease),
+	)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, huma.Error422UnprocessableEntity("Эта отправка уже доставлена")
+	}
+
+	return deliverNow(input.Id, db)
+}
+
+func checkUrl(link string) error {
+	parsed, err := url.ParseRequestURI(link)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return huma.Error422UnprocessableEntity("Неверная ссылка: " + link)
+	}
+	if err := checkHost(parsed.Hostname()); err != nil {
+		return huma.Error422UnprocessableEntity("Нельзя отправлять на " + parsed.Hostname() + ": " + err.Error())
+	}
+	return nil
+}
+
+func checkTypes(types []string) error {
+	if len(types) == 0 {
+		return huma.Error422UnprocessableEntity("Нужно выбрать хотя бы одно событие")
+	}
+	for _, t := range types {
+		known := false
+		for _, k := range Types {
+			if t == k {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return huma.Error422UnprocessableEntity("Неизвестный тип события: " + t)
+		}
+	}
+	return nil
+}
+
+// Возвращает вебхук, если пользователь организатор его события
+func checkWebhookOrganizator(token string, id int64, db *sql.DB) (*Webhook, error) {
+	user, err := utils.GetUserEmailByToken(token, db)
+	if err != nil {
+		return nil, huma.Error403Forbidden("Пользователь не найден")
+	}
+
+	hook, err := getWebhook(id, db)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := utils.CheckEventOrganizator(user, hook.EventUri, db); err != nil {
+		return nil, err
+	}
+
+	return hook, nil
+}
+
+const webhookColumns = "webhooks.id, webhooks.event_uri, webhooks.url, webhooks.types, webhooks.is_active, " +
+	"webhooks.created_by, webhooks.created_at, " +
+	"(SELECT COUNT(*) FROM webhook_deliveries WHERE webhook_id = webhooks.id AND status = 'pending'), " +
+	"(SELECT COUNT(*) FROM webhook_deliveries WHERE webhook_id = webhooks.id AND status = 'failed')"
+
+func scanWebhook(row interface{ Scan(dest ...any) error }) (*Webhook, error) {
+	hook := new(Webhook)
+	if err := row.Scan(
+		&hook.Id,
+		&hook.EventUri,
+		&hook.Url,
+		pq.Array(&hook.Types),
+		&hook.Active,
+		&hook.CreatedBy,
+		&hook.CreatedAt,
+		&hook.Pending,
+		&hook.Failed,
+	); err != nil {
+		return nil, err
+	}
+	return hook, nil
+}
+
+func getWebhook(id int64, db *sql.DB) (*Webhook, error) {
+	hook, err := scanWebhook(db.QueryRow("SELECT "+webhookColumns+" FROM webhooks WHERE id = $1", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, huma.Error404NotFound("Такого вебхука нет")
+		}
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	return hook, nil
+}
+
+func getWebhooks(urid string, db *sql.DB) (*WebhooksOutput, error) {
+	rows, err := db.Query("SELECT "+webhookColumns+" FROM webhooks WHERE event_uri = $1 ORDER BY id", urid)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	defer rows.Close()
+
+	result := new(WebhooksOutput)
+	result.Body.Types = Types
+	result.Body.Webhooks = []*Webhook{}
+
+	for rows.Next() {
+		hook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		result.Body.Webhooks = append(result.Body.Webhooks, hook)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	return result, nil
+}
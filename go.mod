@@ -2,22 +2,29 @@ module hackaton-jam-back
 
 go 1.22
 
+require (
+	github.com/danielgtaylor/huma/v2 v2.14.0
+	github.com/go-pdf/fpdf v0.9.0
+	github.com/go-pdf/fpdf v0.9.0
+	github.com/lib/pq v1.10.9
+	github.com/rs/cors v1.10.1
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
+	github.com/tanimutomo/sqlfile v1.0.0
+	golang.org/x/crypto v0.22.0
+	golang.org/x/image v0.18.0
+)
+
 require (
 	github.com/andybalholm/brotli v1.1.0 // indirect
-	github.com/danielgtaylor/huma/v2 v2.14.0 // indirect
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/klauspost/compress v1.17.8 // indirect
-	github.com/lib/pq v1.10.9 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
-	github.com/rs/cors v1.10.1 // indirect
 	github.com/spf13/cobra v1.8.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
-	github.com/tanimutomo/sqlfile v1.0.0 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
 	github.com/valyala/fasthttp v1.52.0 // indirect
 	github.com/valyala/tcplisten v1.0.0 // indirect
-	golang.org/x/crypto v0.22.0 // indirect
 	golang.org/x/sys v0.19.0 // indirect
 )
@@ -3,10 +3,14 @@ package main
 import (
 	"database/sql"
 	"fmt"
+	"hackaton-jam-back/controllers/feedback"
+	"hackaton-jam-back/controllers/mentors"
+	"hackaton-jam-back/controllers/webhooks"
 	"hackaton-jam-back/routes"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/rs/cors"
@@ -43,6 +47,10 @@ func main() {
 				defer db.Close()
 				fmt.Println("Migration ended!")
 			} else {
+				go feedback.RunScheduler(db, time.Minute)
+				go mentors.RunReminders(db, time.Minute)
+				go webhooks.RunWorker(db, 10*time.Second)
+
 				if err := http.ListenAndServe(fmt.Sprintf("%s:%d", options.Ip, options.Port), handler); err != nil {
 					log.Fatalf("HTTP server error: %v", err)
 				}
@@ -0,0 +1,23 @@
+package analytics
+
+import (
+	"context"
+	"database/sql"
+	"hackaton-jam-back/controllers/analytics"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+func Route(api huma.API, db *sql.DB) {
+	huma.Register(api, huma.Operation{
+		OperationID: "get-event-analytics",
+		Method:      http.MethodPost,
+		Path:        "/api/event/{urid}/analytics",
+		Summary:     "Аналитика мероприятия (только для организаторов)",
+		Description: "Регистрации и выходы по времени, сбор команд, участники без команды, навыки, география и сдача проектов",
+		Tags:        []string{"Аналитика"},
+	}, func(ctx context.Context, input *analytics.AnalyticsInput) (*analytics.AnalyticsOutput, error) {
+		return analytics.GetEventAnalytics(input, db)
+	})
+}
@@ -0,0 +1,33 @@
+package announcements
+
+import (
+	"context"
+	"database/sql"
+	"hackaton-jam-back/controllers/announcements"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+func Route(api huma.API, db *sql.DB) {
+	huma.Register(api, huma.Operation{
+		OperationID: "send-announcement",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/announcements",
+		Summary:     "Отправить объявление участникам",
+		Description: "Приходит уведомлением, а при настроенной почте - еще и письмом",
+		Tags:        []string{"Объявления"},
+	}, func(ctx context.Context, input *announcements.AnnouncementSendInput) (*announcements.AnnouncementsOutput, error) {
+		return announcements.SendAnnouncement(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-announcements",
+		Method:      http.MethodPost,
+		Path:        "/api/event/{urid}/announcements",
+		Summary:     "История отправленных объявлений",
+		Tags:        []string{"Объявления"},
+	}, func(ctx context.Context, input *announcements.AnnouncementsEventInput) (*announcements.AnnouncementsOutput, error) {
+		return announcements.GetAnnouncements(input, db)
+	})
+}
@@ -41,4 +41,25 @@ func Route(api huma.API, db *sql.DB) {
 	}, func(ctx context.Context, input *utils.JustAccessTokenInput) (*struct{}, error) {
 		return auth.Logout(input, db)
 	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "send-email-verification",
+		Method:      http.MethodPost,
+		Path:        "/api/verify/send",
+		Summary:     "Отправить письмо для подтверждения почты",
+		Description: "Ссылка действует сутки. Повторный запрос заменяет старую ссылку",
+		Tags:        []string{"Авторизация"},
+	}, func(ctx context.Context, input *utils.JustAccessTokenInput) (*auth.VerificationOutput, error) {
+		return auth.SendVerification(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "verify-email",
+		Method:      http.MethodGet,
+		Path:        "/api/verify/{code}",
+		Summary:     "Подтвердить почту по ссылке из письма",
+		Tags:        []string{"Авторизация"},
+	}, func(ctx context.Context, input *auth.VerifyEmailInput) (*auth.VerificationOutput, error) {
+		return auth.VerifyEmail(input, db)
+	})
 }
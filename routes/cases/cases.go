@@ -0,0 +1,97 @@
+package cases
+
+import (
+	"context"
+	"database/sql"
+	"hackaton-jam-back/controllers/cases"
+	"hackaton-jam-back/controllers/teams"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+func Route(api huma.API, db *sql.DB) {
+	huma.Register(api, huma.Operation{
+		OperationID: "get-cases",
+		Method:      http.MethodGet,
+		Path:        "/api/event/{urid}/cases",
+		Summary:     "Получить кейсы партнеров",
+		Tags:        []string{"Кейсы партнеров"},
+	}, func(ctx context.Context, input *cases.CasesInput) (*cases.CasesOutput, error) {
+		return cases.GetCases(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "add-case",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/cases",
+		Summary:     "Добавить кейс партнера",
+		Tags:        []string{"Кейсы партнеров"},
+	}, func(ctx context.Context, input *cases.CaseAddInput) (*cases.CaseOutput, error) {
+		return cases.AddCase(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "set-case-deadline",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/cases/deadline",
+		Summary:     "Установить срок выбора кейса",
+		Description: "После срока команды не могут выбрать, сменить или отменить кейс",
+		Tags:        []string{"Кейсы партнеров"},
+	}, func(ctx context.Context, input *cases.CaseDeadlineInput) (*cases.CasesOutput, error) {
+		return cases.SetCaseDeadline(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "edit-case",
+		Method:      http.MethodPatch,
+		Path:        "/api/cases/{id}",
+		Summary:     "Изменить кейс партнера",
+		Tags:        []string{"Кейсы партнеров"},
+	}, func(ctx context.Context, input *cases.CaseEditInput) (*cases.CaseOutput, error) {
+		return cases.EditCase(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "del-case",
+		Method:      http.MethodDelete,
+		Path:        "/api/cases/{id}",
+		Summary:     "Удалить кейс партнера",
+		Description: "Только если кейс еще никто не выбрал и по нему нет итогов",
+		Tags:        []string{"Кейсы партнеров"},
+	}, func(ctx context.Context, input *cases.CaseDelInput) (*cases.CasesOutput, error) {
+		return cases.DelCase(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "add-case-judges",
+		Method:      http.MethodPut,
+		Path:        "/api/cases/{id}/judges",
+		Summary:     "Добавить жюри партнера",
+		Description: "Жюри партнера оценивает работы команд, выбравших кейс, по критериям события",
+		Tags:        []string{"Кейсы партнеров"},
+	}, func(ctx context.Context, input *cases.CaseJudgesInput) (*cases.CaseOutput, error) {
+		return cases.AddCaseJudges(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "del-case-judges",
+		Method:      http.MethodDelete,
+		Path:        "/api/cases/{id}/judges",
+		Summary:     "Убрать жюри партнера",
+		Tags:        []string{"Кейсы партнеров"},
+	}, func(ctx context.Context, input *cases.CaseJudgesInput) (*cases.CaseOutput, error) {
+		return cases.DelCaseJudges(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "team-choose-case",
+		Method:      http.MethodPatch,
+		Path:        "/api/team/{id}/case",
+		Summary:     "Выбрать кейс команды",
+		Description: "Только для тимлида и только до срока выбора кейса",
+		Tags:        []string{"Кейсы партнеров"},
+	}, func(ctx context.Context, input *cases.TeamCaseInput) (*teams.TeamInfoOutput, error) {
+		return cases.ChooseCase(input, db)
+	})
+}
@@ -0,0 +1,88 @@
+package certificates
+
+import (
+	"context"
+	"database/sql"
+	"hackaton-jam-back/controllers/certificates"
+	"hackaton-jam-back/controllers/utils"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+func Route(api huma.API, db *sql.DB) {
+	huma.Register(api, huma.Operation{
+		OperationID: "get-certificate-templates",
+		Method:      http.MethodPost,
+		Path:        "/api/event/{urid}/certificates/templates",
+		Summary:     "Шаблоны сертификатов события (только для организаторов)",
+		Tags:        []string{"Сертификаты"},
+	}, func(ctx context.Context, input *certificates.CertificatesEventInput) (*certificates.TemplatesOutput, error) {
+		return certificates.GetTemplates(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "set-certificate-template",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/certificates/templates",
+		Summary:     "Настроить шаблон сертификата",
+		Tags:        []string{"Сертификаты"},
+	}, func(ctx context.Context, input *certificates.TemplateSetInput) (*certificates.TemplatesOutput, error) {
+		return certificates.SetTemplate(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "issue-certificates",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/certificates/issue",
+		Summary:     "Выдать сертификаты",
+		Description: "Выдает сертификаты выбранного типа всем, кому они положены (или только указанным)",
+		Tags:        []string{"Сертификаты"},
+	}, func(ctx context.Context, input *certificates.CertificatesIssueInput) (*certificates.CertificatesOutput, error) {
+		return certificates.IssueCertificates(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-event-certificates",
+		Method:      http.MethodPost,
+		Path:        "/api/event/{urid}/certificates",
+		Summary:     "Выданные сертификаты события (только для организаторов)",
+		Tags:        []string{"Сертификаты"},
+	}, func(ctx context.Context, input *certificates.CertificatesEventInput) (*certificates.CertificatesOutput, error) {
+		return certificates.GetEventCertificates(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-my-certificates",
+		Method:      http.MethodPost,
+		Path:        "/api/certificates/my",
+		Summary:     "Сертификаты текущего пользователя",
+		Tags:        []string{"Сертификаты"},
+	}, func(ctx context.Context, input *utils.JustAccessTokenInput) (*certificates.CertificatesOutput, error) {
+		return certificates.GetMyCertificates(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "verify-certificate",
+		Method:      http.MethodGet,
+		Path:        "/api/certificate/{code}",
+		Summary:     "Проверить подлинность сертификата",
+		Tags:        []string{"Сертификаты"},
+	}, func(ctx context.Context, input *struct {
+		Code string `path:"code" maxLength:"32" example:"3F9A0C12B7E45D80" doc:"Код проверки"`
+	}) (*certificates.CertificateVerifyOutput, error) {
+		return certificates.VerifyCertificate(input.Code, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-certificate-pdf",
+		Method:      http.MethodGet,
+		Path:        "/api/certificate/{code}/pdf",
+		Summary:     "Скачать сертификат в PDF",
+		Tags:        []string{"Сертификаты"},
+	}, func(ctx context.Context, input *struct {
+		Code string `path:"code" maxLength:"32" example:"3F9A0C12B7E45D80" doc:"Код проверки"`
+	}) (*certificates.CertificatePdfOutput, error) {
+		return certificates.GetCertificatePdf(input.Code, db)
+	})
+}
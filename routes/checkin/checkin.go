@@ -0,0 +1,88 @@
+package checkin
+
+import (
+	"context"
+	"database/sql"
+	"hackaton-jam-back/controllers/checkin"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+func Route(api huma.API, db *sql.DB) {
+	huma.Register(api, huma.Operation{
+		OperationID: "get-checkin-code",
+		Method:      http.MethodPost,
+		Path:        "/api/event/{urid}/checkin/code",
+		Summary:     "Получить свой код отметки на входе",
+		Description: "Только для очных мероприятий и зарегистрированных участников",
+		Tags:        []string{"Отметка на входе"},
+	}, func(ctx context.Context, input *checkin.CheckinEventInput) (*checkin.CheckinCodeOutput, error) {
+		return checkin.GetMyCheckinCode(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-checkin-qr",
+		Method:      http.MethodGet,
+		Path:        "/api/checkin/{code}/qr",
+		Summary:     "QR-код для отметки (PNG или SVG)",
+		Tags:        []string{"Отметка на входе"},
+	}, func(ctx context.Context, input *checkin.CheckinQrInput) (*checkin.CheckinQrOutput, error) {
+		return checkin.GetCheckinQr(input)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "scan-checkin",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/checkin/scan",
+		Summary:     "Отметить участника по коду (организаторы и волонтеры)",
+		Description: "При повторном сканировании возвращается первая отметка с duplicate = true",
+		Tags:        []string{"Отметка на входе"},
+	}, func(ctx context.Context, input *checkin.CheckinScanInput) (*checkin.CheckinScanOutput, error) {
+		return checkin.ScanCheckin(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-checkin-stats",
+		Method:      http.MethodPost,
+		Path:        "/api/event/{urid}/checkin/stats",
+		Summary:     "Посещаемость мероприятия и команд",
+		Tags:        []string{"Отметка на входе"},
+	}, func(ctx context.Context, input *checkin.CheckinEventInput) (*checkin.CheckinStatsOutput, error) {
+		return checkin.GetCheckinStats(input, db)
+	})
+
+	/// ====
+	/// Волонтеры
+	/// ====
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-volunteers",
+		Method:      http.MethodPost,
+		Path:        "/api/event/{urid}/volunteers",
+		Summary:     "Получить волонтеров мероприятия",
+		Tags:        []string{"Волонтеры"},
+	}, func(ctx context.Context, input *checkin.CheckinEventInput) (*checkin.VolunteersOutput, error) {
+		return checkin.GetVolunteers(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "add-volunteers",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/volunteers",
+		Summary:     "Добавить волонтеров",
+		Tags:        []string{"Волонтеры"},
+	}, func(ctx context.Context, input *checkin.VolunteersAddDelInput) (*checkin.VolunteersOutput, error) {
+		return checkin.AddVolunteers(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "del-volunteers",
+		Method:      http.MethodDelete,
+		Path:        "/api/event/{urid}/volunteers",
+		Summary:     "Удалить волонтеров",
+		Tags:        []string{"Волонтеры"},
+	}, func(ctx context.Context, input *checkin.VolunteersAddDelInput) (*checkin.VolunteersOutput, error) {
+		return checkin.DelVolunteers(input, db)
+	})
+}
@@ -4,24 +4,28 @@ import (
 	"context"
 	"database/sql"
 	"hackaton-jam-back/controllers/events"
+	"hackaton-jam-back/controllers/geo"
 	"hackaton-jam-back/controllers/utils"
+	"log"
 	"net/http"
 
 	"github.com/danielgtaylor/huma/v2"
 )
 
 func Route(api huma.API, db *sql.DB) {
+	geocoder, err := geo.New()
+	if err != nil {
+		log.Fatalf("Справочник городов: %v", err)
+	}
+
 	huma.Register(api, huma.Operation{
 		OperationID: "get-last-events",
 		Method:      http.MethodGet,
 		Path:        "/api/events/last",
 		Summary:     "Получить последние события",
 		Tags:        []string{"События"},
-	}, func(ctx context.Context, input *struct {
-		Count int `query:"count" default:"20" example:"20" doc:"Количество событий на страницу"`
-		Page  int `query:"page" default:"0" example:"0" doc:"Страница"`
-	}) (*events.GetEventsOutput, error) {
-		return events.GetAllEvents(input.Count, input.Page, db)
+	}, func(ctx context.Context, input *events.EventsListInput) (*events.GetEventsOutput, error) {
+		return events.GetAllEvents(input, db)
 	})
 
 	huma.Register(api, huma.Operation{
@@ -31,9 +35,12 @@ func Route(api huma.API, db *sql.DB) {
 		Summary:     "Получить полную информацию события",
 		Tags:        []string{"События"},
 	}, func(ctx context.Context, input *struct {
-		Urid string `path:"urid" doc:"Urid события"`
+		Urid   string `path:"urid" doc:"Urid события"`
+		Invite string `query:"invite" doc:"Код из ссылки-приглашения (для закрытых мероприятий)"`
+		utils.ViewerInput
+		utils.LocaleInput
 	}) (*events.FullEventOutput, error) {
-		return events.GetFullEventInfo(input.Urid, db)
+		return events.GetFullEventInfo(input.Urid, input.Token, input.Invite, input.LocaleInput, db)
 	})
 
 	huma.Register(api, huma.Operation{
@@ -43,7 +50,7 @@ func Route(api huma.API, db *sql.DB) {
 		Summary:     "Создать событие (только для организаторов)",
 		Tags:        []string{"События"},
 	}, func(ctx context.Context, input *events.EventCreationInput) (*events.FullEventOutput, error) {
-		return events.CreateEvent(input, db)
+		return events.CreateEvent(input, db, geocoder)
 	})
 
 	huma.Register(api, huma.Operation{
@@ -53,7 +60,7 @@ func Route(api huma.API, db *sql.DB) {
 		Summary:     "Редактировать событие",
 		Tags:        []string{"События"},
 	}, func(ctx context.Context, input *events.EventEditInput) (*events.FullEventOutput, error) {
-		return events.EditEvent(input, db)
+		return events.EditEvent(input, db, geocoder)
 	})
 
 	huma.Register(api, huma.Operation{
@@ -72,7 +79,7 @@ func Route(api huma.API, db *sql.DB) {
 		Path:        "/api/event/{urid}/join",
 		Summary:     "Присоединиться к событию",
 		Tags:        []string{"События и пользователи"},
-	}, func(ctx context.Context, input *events.EventJoinExitInput) (*events.EventJoinExitOutput, error) {
+	}, func(ctx context.Context, input *events.EventJoinInput) (*events.EventJoinExitOutput, error) {
 		return events.JoinEvent(input, db)
 	})
 
@@ -94,8 +101,9 @@ func Route(api huma.API, db *sql.DB) {
 		Tags:        []string{"События и пользователи"},
 	}, func(ctx context.Context, input *struct {
 		Email string `path:"email" example:"thatmaidguy@ya.ru" doc:"E-mail пользователя"`
+		utils.LocaleInput
 	}) (*events.UserEventsOutput, error) {
-		return events.GetAllJoinedEvents(input.Email, db)
+		return events.GetAllJoinedEvents(input.Email, input.LocaleInput, db)
 	})
 
 	huma.Register(api, huma.Operation{
@@ -115,14 +123,19 @@ func Route(api huma.API, db *sql.DB) {
 		Path:        "/api/user-events",
 		Summary:     "События текущего пользователя",
 		Tags:        []string{"События и пользователи"},
-	}, func(ctx context.Context, input *utils.JustAccessTokenInput) (*events.UserEventsOutput, error) {
+	}, func(ctx context.Context, input *struct {
+		Body struct {
+			Token string `json:"access_token" example:"82a3682d0d56f40a4d088aee08521663" doc:"Токен пользователя"`
+		}
+		utils.LocaleInput
+	}) (*events.UserEventsOutput, error) {
 		// Проверить можем ли присоединится к меро?
 		user, err := utils.GetUserEmailByToken(input.Body.Token, db)
 		if err != nil {
 			return nil, huma.Error403Forbidden("Пользователь не найден")
 		}
 
-		return events.GetAllJoinedEvents(user.Email, db)
+		return events.GetAllJoinedEvents(user.Email, input.LocaleInput, db)
 	})
 
 	huma.Register(api, huma.Operation{
@@ -164,4 +177,245 @@ func Route(api huma.API, db *sql.DB) {
 	}, func(ctx context.Context, input *events.EventPartnersAddDelInput) (*events.FullEventOutput, error) {
 		return events.DelEventPartners(input, db)
 	})
+
+	/// ====
+	/// Программа и анкета при записи
+	/// ====
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-event-agenda",
+		Method:      http.MethodPost,
+		Path:        "/api/event/{urid}/agenda",
+		Summary:     "Программа мероприятия с переводами (только для организаторов)",
+		Tags:        []string{"Программа и анкета"},
+	}, func(ctx context.Context, input *events.AgendaInput) (*events.AgendaOutput, error) {
+		return events.GetAgenda(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "edit-event-agenda",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/agenda",
+		Summary:     "Изменить программу мероприятия",
+		Description: "Программа заменяется целиком вместе с переводами и показывается в информации о событии на языке читателя. После изменения мероприятие снова уходит на модерацию",
+		Tags:        []string{"Программа и анкета"},
+	}, func(ctx context.Context, input *events.AgendaEditInput) (*events.AgendaOutput, error) {
+		return events.EditAgenda(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "edit-registration-form",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/registration-form",
+		Summary:     "Изменить анкету при записи",
+		Description: "Пока никто не записался по анкете. Ответы передаются в answers при записи на мероприятие",
+		Tags:        []string{"Программа и анкета"},
+	}, func(ctx context.Context, input *events.RegistrationFormEditInput) (*events.RegistrationFormOutput, error) {
+		return events.EditRegistrationForm(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-registration-answers",
+		Method:      http.MethodPost,
+		Path:        "/api/event/{urid}/registration-form/answers",
+		Summary:     "Ответы участников на анкету (только для организаторов)",
+		Tags:        []string{"Программа и анкета"},
+	}, func(ctx context.Context, input *events.RegistrationAnswersInput) (*events.RegistrationAnswersOutput, error) {
+		return events.GetRegistrationAnswers(input, db)
+	})
+
+	/// ====
+	/// Черновики, копии и шаблоны событий
+	/// ====
+
+	huma.Register(api, huma.Operation{
+		OperationID: "duplicate-event",
+		Method:      http.MethodPost,
+		Path:        "/api/event/{urid}/duplicate",
+		Summary:     "Скопировать событие в новый черновик",
+		Description: "Копируются тексты, теги, партнеры, правила команд, критерии, анкета при записи и программа (со сдвигом к новому началу)",
+		Tags:        []string{"Шаблоны событий"},
+	}, func(ctx context.Context, input *events.EventDuplicateInput) (*events.FullEventOutput, error) {
+		return events.DuplicateEvent(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "publish-draft-event",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/publish",
+		Summary:     "Опубликовать черновик события",
+		Tags:        []string{"Шаблоны событий"},
+	}, func(ctx context.Context, input *events.EventPublishInput) (*events.FullEventOutput, error) {
+		return events.PublishDraftEvent(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-event-templates",
+		Method:      http.MethodPost,
+		Path:        "/api/event-templates",
+		Summary:     "Получить свои шаблоны событий",
+		Tags:        []string{"Шаблоны событий"},
+	}, func(ctx context.Context, input *utils.JustAccessTokenInput) (*events.EventTemplatesOutput, error) {
+		return events.GetEventTemplates(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "save-event-template",
+		Method:      http.MethodPut,
+		Path:        "/api/event-templates",
+		Summary:     "Сохранить событие как шаблон",
+		Tags:        []string{"Шаблоны событий"},
+	}, func(ctx context.Context, input *events.EventTemplateSaveInput) (*events.EventTemplatesOutput, error) {
+		return events.SaveEventTemplate(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "del-event-template",
+		Method:      http.MethodDelete,
+		Path:        "/api/event-templates/{id}",
+		Summary:     "Удалить шаблон события",
+		Tags:        []string{"Шаблоны событий"},
+	}, func(ctx context.Context, input *events.EventTemplateDelInput) (*events.EventTemplatesOutput, error) {
+		return events.DelEventTemplate(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "create-event-from-template",
+		Method:      http.MethodPost,
+		Path:        "/api/event-templates/{id}/create",
+		Summary:     "Создать черновик события из шаблона",
+		Tags:        []string{"Шаблоны событий"},
+	}, func(ctx context.Context, input *events.EventFromTemplateInput) (*events.FullEventOutput, error) {
+		return events.CreateEventFromTemplate(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-event-access",
+		Method:      http.MethodPost,
+		Path:        "/api/event/{urid}/access",
+		Summary:     "Настройки доступа к событию",
+		Tags:        []string{"Доступ к событиям"},
+	}, func(ctx context.Context, input *events.EventAccessInput) (*events.EventAccessOutput, error) {
+		return events.GetEventAccess(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "edit-event-access",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/access",
+		Summary:     "Изменить видимость, код доступа и разрешенные домены",
+		Tags:        []string{"Доступ к событиям"},
+	}, func(ctx context.Context, input *events.EventAccessEditInput) (*events.EventAccessOutput, error) {
+		return events.EditEventAccess(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-invite-links",
+		Method:      http.MethodPost,
+		Path:        "/api/event/{urid}/invite-links",
+		Summary:     "Ссылки-приглашения события",
+		Tags:        []string{"Доступ к событиям"},
+	}, func(ctx context.Context, input *events.EventAccessInput) (*events.InviteLinksOutput, error) {
+		return events.GetInviteLinks(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "create-invite-link",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/invite-links",
+		Summary:     "Создать ссылку-приглашение",
+		Description: "По ссылке можно записаться без кода доступа и проверки домена",
+		Tags:        []string{"Доступ к событиям"},
+	}, func(ctx context.Context, input *events.InviteLinkCreateInput) (*events.InviteLinksOutput, error) {
+		return events.CreateInviteLink(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "delete-invite-link",
+		Method:      http.MethodDelete,
+		Path:        "/api/event/{urid}/invite-links/{invite}",
+		Summary:     "Отозвать ссылку-приглашение",
+		Tags:        []string{"Доступ к событиям"},
+	}, func(ctx context.Context, input *events.InviteLinkDeleteInput) (*events.InviteLinksOutput, error) {
+		return events.DelInviteLink(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-nearby-events",
+		Method:      http.MethodGet,
+		Path:        "/api/events/nearby",
+		Summary:     "Найти очные мероприятия рядом",
+		Description: "Предстоящие и идущие очные мероприятия в радиусе от точки, ближайшие сверху",
+		Tags:        []string{"События"},
+	}, func(ctx context.Context, input *events.NearbyEventsInput) (*events.NearbyEventsOutput, error) {
+		return events.GetNearbyEvents(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-event-cities",
+		Method:      http.MethodGet,
+		Path:        "/api/events/cities",
+		Summary:     "Получить города с мероприятиями",
+		Description: "Справочник для фильтра city в списке событий",
+		Tags:        []string{"События"},
+	}, func(ctx context.Context, input *struct{}) (*events.EventCitiesOutput, error) {
+		return events.GetEventCities(db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "edit-event-geo",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/geo",
+		Summary:     "Изменить координаты мероприятия",
+		Description: "Без координат и города они заново определяются по месту проведения",
+		Tags:        []string{"События"},
+	}, func(ctx context.Context, input *events.EventGeoInput) (*events.EventGeoOutput, error) {
+		return events.EditEventGeo(input, db, geocoder)
+	})
+
+	/// ====
+	/// Переводы
+	/// ====
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-event-translations",
+		Method:      http.MethodPost,
+		Path:        "/api/event/{urid}/translations",
+		Summary:     "Переводы текстов мероприятия (только для организаторов)",
+		Tags:        []string{"Переводы"},
+	}, func(ctx context.Context, input *events.EventTranslationsInput) (*events.EventTranslationsOutput, error) {
+		return events.GetEventTranslations(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "save-event-translation",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/translations/{locale}",
+		Summary:     "Сохранить перевод на язык",
+		Description: "Незаполненные поля показываются на основном языке. После изменения мероприятие снова уходит на модерацию",
+		Tags:        []string{"Переводы"},
+	}, func(ctx context.Context, input *events.EventTranslationSaveInput) (*events.EventTranslationsOutput, error) {
+		return events.SaveEventTranslation(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "del-event-translation",
+		Method:      http.MethodDelete,
+		Path:        "/api/event/{urid}/translations/{locale}",
+		Summary:     "Удалить перевод",
+		Tags:        []string{"Переводы"},
+	}, func(ctx context.Context, input *events.EventTranslationDelInput) (*events.EventTranslationsOutput, error) {
+		return events.DelEventTranslation(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "set-event-locale",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/locale",
+		Summary:     "Изменить основной язык мероприятия",
+		Description: "Основной язык - тот, на котором написаны тексты самого мероприятия. На него показываются тексты, если перевода нет",
+		Tags:        []string{"Переводы"},
+	}, func(ctx context.Context, input *events.EventLocaleInput) (*events.EventTranslationsOutput, error) {
+		return events.SetEventLocale(input, db)
+	})
 }
@@ -0,0 +1,23 @@
+package export
+
+import (
+	"context"
+	"database/sql"
+	"hackaton-jam-back/controllers/export"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+func Route(api huma.API, db *sql.DB) {
+	huma.Register(api, huma.Operation{
+		OperationID: "export-event",
+		Method:      http.MethodPost,
+		Path:        "/api/event/{urid}/export/{kind}",
+		Summary:     "Выгрузить участников, команды или итоги в CSV/XLSX",
+		Description: "Только для организаторов мероприятия и админов",
+		Tags:        []string{"Выгрузка"},
+	}, func(ctx context.Context, input *export.ExportInput) (*huma.StreamResponse, error) {
+		return export.ExportEvent(input, db)
+	})
+}
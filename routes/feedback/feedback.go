@@ -0,0 +1,56 @@
+package feedback
+
+import (
+	"context"
+	"database/sql"
+	"hackaton-jam-back/controllers/feedback"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+func Route(api huma.API, db *sql.DB) {
+	huma.Register(api, huma.Operation{
+		OperationID: "get-feedback-survey",
+		Method:      http.MethodPost,
+		Path:        "/api/event/{urid}/feedback",
+		Summary:     "Получить анкету обратной связи",
+		Description: "Анкета открывается после окончания мероприятия, заполнить ее можно один раз",
+		Tags:        []string{"Обратная связь"},
+	}, func(ctx context.Context, input *feedback.SurveyInput) (*feedback.SurveyOutput, error) {
+		return feedback.GetSurvey(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "submit-feedback-survey",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/feedback",
+		Summary:     "Заполнить анкету обратной связи",
+		Description: "Ответы сохраняются анонимно",
+		Tags:        []string{"Обратная связь"},
+	}, func(ctx context.Context, input *feedback.SurveySubmitInput) (*feedback.SurveyOutput, error) {
+		return feedback.SubmitSurvey(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "edit-feedback-questions",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/feedback/questions",
+		Summary:     "Изменить вопросы анкеты",
+		Description: "Пока никто не ответил. Без своих вопросов используются стандартные",
+		Tags:        []string{"Обратная связь"},
+	}, func(ctx context.Context, input *feedback.QuestionsEditInput) (*feedback.QuestionsOutput, error) {
+		return feedback.EditQuestions(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-feedback-results",
+		Method:      http.MethodPost,
+		Path:        "/api/event/{urid}/feedback/results",
+		Summary:     "Сводка по анкетам",
+		Description: "Для организаторов. Показывается без авторов и только при достаточном числе ответов",
+		Tags:        []string{"Обратная связь"},
+	}, func(ctx context.Context, input *feedback.SurveyInput) (*feedback.SurveyResultsOutput, error) {
+		return feedback.GetSurveyResults(input, db)
+	})
+}
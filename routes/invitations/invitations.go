@@ -0,0 +1,39 @@
+package invitations
+
+import (
+	"context"
+	"database/sql"
+	"hackaton-jam-back/controllers/invitations"
+	"hackaton-jam-back/controllers/utils"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+func Route(api huma.API, db *sql.DB) {
+	huma.Register(api, huma.Operation{
+		OperationID: "import-participants",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/import",
+		Summary:     "Массовая запись участников из CSV",
+		Description: "multipart/form-data: файл в поле file, токен в поле access_token. " +
+			"Существующие пользователи записываются на мероприятие, остальным уходит приглашение на почту. " +
+			"С dry_run=true только проверяет файл",
+		Tags:            []string{"Импорт участников"},
+		MaxBodyBytes:    invitations.MaxImportBodyBytes,
+		BodyReadTimeout: invitations.ImportReadTimeout,
+		Middlewares:     huma.Middlewares{utils.LimitBody(api, invitations.MaxImportBodyBytes)},
+	}, func(ctx context.Context, input *invitations.ImportInput) (*invitations.ImportOutput, error) {
+		return invitations.ImportParticipants(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-invitations",
+		Method:      http.MethodPost,
+		Path:        "/api/event/{urid}/invitations",
+		Summary:     "Приглашения незарегистрированных участников",
+		Tags:        []string{"Импорт участников"},
+	}, func(ctx context.Context, input *invitations.InvitationsEventInput) (*invitations.InvitationsOutput, error) {
+		return invitations.GetInvitations(input, db)
+	})
+}
@@ -0,0 +1,202 @@
+package judging
+
+import (
+	"context"
+	"database/sql"
+	"hackaton-jam-back/controllers/judging"
+	"hackaton-jam-back/controllers/utils"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+func Route(api huma.API, db *sql.DB) {
+	/// ======================================
+	/// ======================================
+	/// ======== Критерии оценивания =========
+	/// ======================================
+	/// ======================================
+	huma.Register(api, huma.Operation{
+		OperationID: "get-judging-criteria",
+		Method:      http.MethodGet,
+		Path:        "/api/event/{urid}/criteria",
+		Summary:     "Критерии оценивания события",
+		Tags:        []string{"Жюри и оценивание"},
+	}, func(ctx context.Context, input *struct {
+		Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	}) (*judging.CriteriaOutput, error) {
+		return judging.GetCriteria(input.Urid, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "add-judging-criterion",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/criteria",
+		Summary:     "Добавить критерий оценивания",
+		Tags:        []string{"Жюри и оценивание"},
+	}, func(ctx context.Context, input *judging.CriterionAddInput) (*judging.CriteriaOutput, error) {
+		return judging.AddCriterion(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "del-judging-criterion",
+		Method:      http.MethodDelete,
+		Path:        "/api/event/{urid}/criteria/{id}",
+		Summary:     "Удалить критерий оценивания",
+		Description: "Удаляет критерий вместе со всеми оценками по нему",
+		Tags:        []string{"Жюри и оценивание"},
+	}, func(ctx context.Context, input *judging.CriterionDelInput) (*judging.CriteriaOutput, error) {
+		return judging.DelCriterion(input, db)
+	})
+
+	/// ======================================
+	/// ======================================
+	/// ================ Жюри ================
+	/// ======================================
+	/// ======================================
+	huma.Register(api, huma.Operation{
+		OperationID: "get-judges",
+		Method:      http.MethodGet,
+		Path:        "/api/event/{urid}/judges",
+		Summary:     "Жюри события",
+		Tags:        []string{"Жюри и оценивание"},
+	}, func(ctx context.Context, input *struct {
+		Urid string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+	}) (*judging.JudgesOutput, error) {
+		return judging.GetJudges(input.Urid, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "add-judges",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/judges",
+		Summary:     "Добавить членов жюри",
+		Description: "Только существующих пользователей, связанных с мероприятием (участники, организаторы, волонтеры, менторы или приглашенные). Если кто-то не подходит, не добавляется никто",
+		Tags:        []string{"Жюри и оценивание"},
+	}, func(ctx context.Context, input *judging.JudgesAddDelInput) (*judging.JudgesOutput, error) {
+		return judging.AddJudges(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "del-judges",
+		Method:      http.MethodDelete,
+		Path:        "/api/event/{urid}/judges",
+		Summary:     "Удалить членов жюри",
+		Tags:        []string{"Жюри и оценивание"},
+	}, func(ctx context.Context, input *judging.JudgesAddDelInput) (*judging.JudgesOutput, error) {
+		return judging.DelJudges(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "assign-judge",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/judges/assign",
+		Summary:     "Назначить работы члену жюри",
+		Description: "Работы команд, в которых состоит член жюри, ему не назначаются",
+		Tags:        []string{"Жюри и оценивание"},
+	}, func(ctx context.Context, input *judging.JudgeAssignInput) (*judging.JudgeAssignmentsOutput, error) {
+		return judging.AssignSubmissions(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "unassign-judge",
+		Method:      http.MethodDelete,
+		Path:        "/api/event/{urid}/judges/assign",
+		Summary:     "Снять работы с члена жюри",
+		Tags:        []string{"Жюри и оценивание"},
+	}, func(ctx context.Context, input *judging.JudgeAssignInput) (*judging.JudgeAssignmentsOutput, error) {
+		return judging.UnassignSubmissions(input, db)
+	})
+
+	/// ======================================
+	/// ======================================
+	/// ============== Оценки ================
+	/// ======================================
+	/// ======================================
+	huma.Register(api, huma.Operation{
+		OperationID: "get-judge-assignments",
+		Method:      http.MethodPost,
+		Path:        "/api/judge/assignments",
+		Summary:     "Работы, назначенные текущему члену жюри",
+		Tags:        []string{"Жюри и оценивание"},
+	}, func(ctx context.Context, input *judging.JudgeAssignmentsInput) (*judging.JudgeAssignmentsOutput, error) {
+		return judging.GetMyAssignments(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-my-scores",
+		Method:      http.MethodPost,
+		Path:        "/api/submission/{id}/scores",
+		Summary:     "Мои оценки работы",
+		Tags:        []string{"Жюри и оценивание"},
+	}, func(ctx context.Context, input *judging.ScoresGetInput) (*judging.ScoresOutput, error) {
+		return judging.GetMyScores(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "set-scores",
+		Method:      http.MethodPut,
+		Path:        "/api/submission/{id}/scores",
+		Summary:     "Оценить работу",
+		Description: "Выставляет (или меняет) оценки по критериям с комментариями. Работу своей команды оценить нельзя",
+		Tags:        []string{"Жюри и оценивание"},
+	}, func(ctx context.Context, input *judging.ScoresSetInput) (*judging.ScoresOutput, error) {
+		return judging.SetScores(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-all-scores",
+		Method:      http.MethodPost,
+		Path:        "/api/submission/{id}/scores/all",
+		Summary:     "Все оценки работы (только для организаторов)",
+		Tags:        []string{"Жюри и оценивание"},
+	}, func(ctx context.Context, input *judging.ScoresGetInput) (*judging.ScoresOutput, error) {
+		return judging.GetAllSubmissionScores(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-judging-progress",
+		Method:      http.MethodPost,
+		Path:        "/api/event/{urid}/judging/progress",
+		Summary:     "Прогресс оценивания (только для организаторов)",
+		Tags:        []string{"Жюри и оценивание"},
+	}, func(ctx context.Context, input *judging.JudgingEventInput) (*judging.JudgingProgressOutput, error) {
+		return judging.GetJudgingProgress(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-leaderboard",
+		Method:      http.MethodGet,
+		Path:        "/api/event/{urid}/leaderboard",
+		Summary:     "Таблица лидеров",
+		Description: "Доступна только после публикации результатов",
+		Tags:        []string{"Жюри и оценивание"},
+	}, func(ctx context.Context, input *struct {
+		Urid   string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+		CaseId int64  `query:"case_id" example:"1" doc:"Таблица лидеров кейса партнера (если не указан - общий зачет)"`
+		utils.ViewerInput
+	}) (*judging.LeaderboardOutput, error) {
+		return judging.GetPublicLeaderboard(input.Urid, input.CaseId, input.Token, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-leaderboard-preview",
+		Method:      http.MethodPost,
+		Path:        "/api/event/{urid}/leaderboard",
+		Summary:     "Таблица лидеров до публикации (только для организаторов)",
+		Description: "Таблицу лидеров кейса (case_id) видит также жюри партнера",
+		Tags:        []string{"Жюри и оценивание"},
+	}, func(ctx context.Context, input *judging.LeaderboardPreviewInput) (*judging.LeaderboardOutput, error) {
+		return judging.GetOrganizatorLeaderboard(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "publish-judging",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/judging/publish",
+		Summary:     "Опубликовать или скрыть результаты оценивания",
+		Tags:        []string{"Жюри и оценивание"},
+	}, func(ctx context.Context, input *judging.JudgingPublishInput) (*judging.LeaderboardOutput, error) {
+		return judging.PublishJudging(input, db)
+	})
+}
@@ -3,12 +3,31 @@ package routes
 import (
 	"database/sql"
 
+	"hackaton-jam-back/routes/analytics"
+	"hackaton-jam-back/routes/announcements"
 	"hackaton-jam-back/routes/auth"
+	"hackaton-jam-back/routes/cases"
+	"hackaton-jam-back/routes/certificates"
+	"hackaton-jam-back/routes/checkin"
 	"hackaton-jam-back/routes/events"
 	"hackaton-jam-back/routes/example"
+	"hackaton-jam-back/routes/export"
+	"hackaton-jam-back/routes/feedback"
+	"hackaton-jam-back/routes/invitations"
+	"hackaton-jam-back/routes/judging"
+	"hackaton-jam-back/routes/mentors"
+	"hackaton-jam-back/routes/moderation"
 	"hackaton-jam-back/routes/notifications"
+	"hackaton-jam-back/routes/organizations"
 	"hackaton-jam-back/routes/profile"
+	"hackaton-jam-back/routes/qa"
+	"hackaton-jam-back/routes/results"
+	"hackaton-jam-back/routes/series"
+	"hackaton-jam-back/routes/submissions"
 	"hackaton-jam-back/routes/teams"
+	"hackaton-jam-back/routes/uploads"
+	"hackaton-jam-back/routes/voting"
+	"hackaton-jam-back/routes/webhooks"
 
 	"github.com/danielgtaylor/huma/v2"
 
@@ -22,4 +41,23 @@ func Route(api huma.API, db *sql.DB) {
 	events.Route(api, db)
 	notifications.Route(api, db)
 	teams.Route(api, db)
+	submissions.Route(api, db)
+	judging.Route(api, db)
+	results.Route(api, db)
+	certificates.Route(api, db)
+	series.Route(api, db)
+	checkin.Route(api, db)
+	announcements.Route(api, db)
+	analytics.Route(api, db)
+	export.Route(api, db)
+	invitations.Route(api, db)
+	uploads.Route(api, db)
+	moderation.Route(api, db)
+	feedback.Route(api, db)
+	mentors.Route(api, db)
+	qa.Route(api, db)
+	cases.Route(api, db)
+	voting.Route(api, db)
+	webhooks.Route(api, db)
+	organizations.Route(api, db)
 }
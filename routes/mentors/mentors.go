@@ -0,0 +1,119 @@
+package mentors
+
+import (
+	"context"
+	"database/sql"
+	"hackaton-jam-back/controllers/mentors"
+	"hackaton-jam-back/controllers/utils"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+func Route(api huma.API, db *sql.DB) {
+	huma.Register(api, huma.Operation{
+		OperationID: "get-mentors",
+		Method:      http.MethodGet,
+		Path:        "/api/event/{urid}/mentors",
+		Summary:     "Получить менторов мероприятия",
+		Tags:        []string{"Менторы"},
+	}, func(ctx context.Context, input *mentors.MentorsInput) (*mentors.MentorsOutput, error) {
+		return mentors.GetMentors(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "add-mentor",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/mentors",
+		Summary:     "Добавить ментора",
+		Description: "Для организаторов. Повторный вызов обновляет экспертизу и описание",
+		Tags:        []string{"Менторы"},
+	}, func(ctx context.Context, input *mentors.MentorAddInput) (*mentors.MentorsOutput, error) {
+		return mentors.AddMentor(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "del-mentor",
+		Method:      http.MethodDelete,
+		Path:        "/api/event/{urid}/mentors/{email}",
+		Summary:     "Удалить ментора",
+		Description: "Вместе со слотами. Записавшиеся команды получат уведомление об отмене",
+		Tags:        []string{"Менторы"},
+	}, func(ctx context.Context, input *mentors.MentorDelInput) (*mentors.MentorsOutput, error) {
+		return mentors.DelMentor(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "edit-mentor-settings",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/mentors/settings",
+		Summary:     "Изменить правила записи к менторам",
+		Tags:        []string{"Менторы"},
+	}, func(ctx context.Context, input *mentors.SettingsEditInput) (*mentors.MentorsOutput, error) {
+		return mentors.EditSettings(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-mentor-slots",
+		Method:      http.MethodGet,
+		Path:        "/api/event/{urid}/mentor-slots",
+		Summary:     "Получить слоты менторов",
+		Tags:        []string{"Менторы"},
+	}, func(ctx context.Context, input *mentors.SlotsInput) (*mentors.SlotsOutput, error) {
+		return mentors.GetSlots(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "add-mentor-slot",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/mentor-slots",
+		Summary:     "Добавить слот ментора",
+		Description: "Ментор добавляет себе, организатор - любому ментору мероприятия",
+		Tags:        []string{"Менторы"},
+	}, func(ctx context.Context, input *mentors.SlotAddInput) (*mentors.SlotsOutput, error) {
+		return mentors.AddSlot(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "del-mentor-slot",
+		Method:      http.MethodDelete,
+		Path:        "/api/mentor-slots/{id}",
+		Summary:     "Удалить слот ментора",
+		Description: "Если на слот записана команда, ей придет уведомление об отмене",
+		Tags:        []string{"Менторы"},
+	}, func(ctx context.Context, input *mentors.SlotInput) (*mentors.SlotsOutput, error) {
+		return mentors.DelSlot(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "book-mentor-slot",
+		Method:      http.MethodPut,
+		Path:        "/api/mentor-slots/{id}/book",
+		Summary:     "Записать команду к ментору",
+		Description: "Только для тимлидов, с ограничением числа предстоящих встреч на команду",
+		Tags:        []string{"Менторы"},
+	}, func(ctx context.Context, input *mentors.SlotInput) (*mentors.SlotOutput, error) {
+		return mentors.BookSlot(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "cancel-mentor-booking",
+		Method:      http.MethodPut,
+		Path:        "/api/mentor-slots/{id}/cancel",
+		Summary:     "Отменить запись к ментору",
+		Description: "Тимлид может отменить не позже срока из правил мероприятия, организатор - в любое время",
+		Tags:        []string{"Менторы"},
+	}, func(ctx context.Context, input *mentors.SlotInput) (*mentors.SlotOutput, error) {
+		return mentors.CancelBooking(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-mentor-sessions",
+		Method:      http.MethodPost,
+		Path:        "/api/mentor/sessions",
+		Summary:     "Предстоящие встречи ментора",
+		Tags:        []string{"Менторы"},
+	}, func(ctx context.Context, input *utils.JustAccessTokenInput) (*mentors.SessionsOutput, error) {
+		return mentors.GetMentorSessions(input, db)
+	})
+}
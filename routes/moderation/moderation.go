@@ -0,0 +1,55 @@
+package moderation
+
+import (
+	"context"
+	"database/sql"
+	"hackaton-jam-back/controllers/moderation"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+func Route(api huma.API, db *sql.DB) {
+	huma.Register(api, huma.Operation{
+		OperationID: "get-moderation-queue",
+		Method:      http.MethodPost,
+		Path:        "/api/moderation/events",
+		Summary:     "Очередь мероприятий на модерацию",
+		Description: "Только для администраторов",
+		Tags:        []string{"Модерация"},
+	}, func(ctx context.Context, input *moderation.ModerationQueueInput) (*moderation.ModerationQueueOutput, error) {
+		return moderation.GetQueue(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "approve-event",
+		Method:      http.MethodPut,
+		Path:        "/api/moderation/event/{urid}/approve",
+		Summary:     "Одобрить мероприятие",
+		Tags:        []string{"Модерация"},
+	}, func(ctx context.Context, input *moderation.ModerationDecisionInput) (*moderation.ModerationDecisionOutput, error) {
+		return moderation.ApproveEvent(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "reject-event",
+		Method:      http.MethodPut,
+		Path:        "/api/moderation/event/{urid}/reject",
+		Summary:     "Отклонить мероприятие",
+		Description: "Организаторы получат уведомление с причиной",
+		Tags:        []string{"Модерация"},
+	}, func(ctx context.Context, input *moderation.ModerationDecisionInput) (*moderation.ModerationDecisionOutput, error) {
+		return moderation.RejectEvent(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "set-trusted-organizer",
+		Method:      http.MethodPut,
+		Path:        "/api/moderation/organizer/{email}/trusted",
+		Summary:     "Сделать организатора доверенным",
+		Description: "Мероприятия доверенных организаторов публикуются без модерации",
+		Tags:        []string{"Модерация"},
+	}, func(ctx context.Context, input *moderation.TrustedOrganizerInput) (*moderation.TrustedOrganizerOutput, error) {
+		return moderation.SetTrustedOrganizer(input, db)
+	})
+}
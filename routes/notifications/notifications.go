@@ -17,7 +17,7 @@ func Route(api huma.API, db *sql.DB) {
 		Path:        "/api/notifications",
 		Summary:     "Получить уведомления",
 		Tags:        []string{"Уведомления"},
-	}, func(ctx context.Context, input *utils.JustAccessTokenInput) (*notifications.NotificationsOutput, error) {
+	}, func(ctx context.Context, input *notifications.NotificationsInput) (*notifications.NotificationsOutput, error) {
 		return notifications.GetNotifications(input, db)
 	})
 
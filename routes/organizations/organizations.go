@@ -0,0 +1,121 @@
+package organizations
+
+import (
+	"context"
+	"database/sql"
+	"hackaton-jam-back/controllers/organizations"
+	"hackaton-jam-back/controllers/utils"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+func Route(api huma.API, db *sql.DB) {
+	huma.Register(api, huma.Operation{
+		OperationID: "get-all-organizations",
+		Method:      http.MethodGet,
+		Path:        "/api/organizations",
+		Summary:     "Получить все организации",
+		Tags:        []string{"Организации"},
+	}, func(ctx context.Context, input *organizations.OrganizationsListInput) (*organizations.OrganizationsOutput, error) {
+		return organizations.GetAllOrganizations(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-organization",
+		Method:      http.MethodGet,
+		Path:        "/api/organizations/{urid}",
+		Summary:     "Страница организации с сотрудниками, прошедшими и будущими мероприятиями",
+		Tags:        []string{"Организации"},
+	}, func(ctx context.Context, input *struct {
+		Urid string `path:"urid" maxLength:"30" example:"urfu" doc:"Ссылка на организацию"`
+		utils.LocaleInput
+	}) (*organizations.OrganizationOutput, error) {
+		return organizations.GetOrganization(input.Urid, input.LocaleInput, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-my-organizations",
+		Method:      http.MethodPost,
+		Path:        "/api/organizations/my",
+		Summary:     "Организации, в которых я состою",
+		Tags:        []string{"Организации"},
+	}, func(ctx context.Context, input *utils.JustAccessTokenInput) (*organizations.OrganizationsOutput, error) {
+		return organizations.GetMyOrganizations(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "create-organization",
+		Method:      http.MethodPost,
+		Path:        "/api/organizations/create",
+		Summary:     "Создать организацию (только для организаторов)",
+		Description: "Создатель становится админом организации",
+		Tags:        []string{"Организации"},
+	}, func(ctx context.Context, input *organizations.OrganizationCreateInput) (*organizations.OrganizationOutput, error) {
+		return organizations.CreateOrganization(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "edit-organization",
+		Method:      http.MethodPatch,
+		Path:        "/api/organizations/{urid}",
+		Summary:     "Редактировать организацию",
+		Tags:        []string{"Организации"},
+	}, func(ctx context.Context, input *organizations.OrganizationEditInput) (*organizations.OrganizationOutput, error) {
+		return organizations.EditOrganization(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "del-organization",
+		Method:      http.MethodDelete,
+		Path:        "/api/organizations/{urid}",
+		Summary:     "Удалить организацию",
+		Description: "Удалить можно только организацию без мероприятий",
+		Tags:        []string{"Организации"},
+	}, func(ctx context.Context, input *organizations.OrganizationTokenInput) (*organizations.OrganizationsOutput, error) {
+		return organizations.DeleteOrganization(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "set-organization-member",
+		Method:      http.MethodPut,
+		Path:        "/api/organizations/{urid}/members",
+		Summary:     "Добавить сотрудника или сменить его роль",
+		Description: "Админы организации управляют всеми ее мероприятиями, сотрудники могут создавать мероприятия от ее имени",
+		Tags:        []string{"Организации"},
+	}, func(ctx context.Context, input *organizations.OrganizationMemberInput) (*organizations.OrganizationOutput, error) {
+		return organizations.SetMember(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "del-organization-member",
+		Method:      http.MethodDelete,
+		Path:        "/api/organizations/{urid}/members",
+		Summary:     "Убрать сотрудника или выйти из организации",
+		Description: "Сотрудник перестает быть организатором мероприятий организации",
+		Tags:        []string{"Организации"},
+	}, func(ctx context.Context, input *organizations.OrganizationMemberDelInput) (*organizations.OrganizationOutput, error) {
+		return organizations.DelMember(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "add-organization-event",
+		Method:      http.MethodPut,
+		Path:        "/api/organizations/{urid}/events",
+		Summary:     "Передать мероприятие организации",
+		Tags:        []string{"Организации"},
+	}, func(ctx context.Context, input *organizations.OrganizationEventInput) (*organizations.OrganizationOutput, error) {
+		return organizations.AddOrganizationEvent(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "del-organization-event",
+		Method:      http.MethodDelete,
+		Path:        "/api/organizations/{urid}/events",
+		Summary:     "Забрать мероприятие у организации",
+		Description: "Мероприятие остается у его организаторов",
+		Tags:        []string{"Организации"},
+	}, func(ctx context.Context, input *organizations.OrganizationEventInput) (*organizations.OrganizationOutput, error) {
+		return organizations.DelOrganizationEvent(input, db)
+	})
+}
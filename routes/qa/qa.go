@@ -0,0 +1,76 @@
+package qa
+
+import (
+	"context"
+	"database/sql"
+	"hackaton-jam-back/controllers/qa"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+func Route(api huma.API, db *sql.DB) {
+	huma.Register(api, huma.Operation{
+		OperationID: "get-event-questions",
+		Method:      http.MethodPost,
+		Path:        "/api/event/{urid}/questions",
+		Summary:     "Получить вопросы мероприятия",
+		Description: "Закрепленные сверху. Организаторы видят и скрытые вопросы",
+		Tags:        []string{"Вопросы"},
+	}, func(ctx context.Context, input *qa.EventQuestionsInput) (*qa.EventQuestionsOutput, error) {
+		return qa.GetQuestions(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "ask-event-question",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/questions",
+		Summary:     "Задать вопрос организаторам",
+		Tags:        []string{"Вопросы"},
+	}, func(ctx context.Context, input *qa.QuestionAskInput) (*qa.EventQuestionOutput, error) {
+		return qa.AskQuestion(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "vote-event-question",
+		Method:      http.MethodPut,
+		Path:        "/api/questions/{id}/vote",
+		Summary:     "Поддержать вопрос",
+		Tags:        []string{"Вопросы"},
+	}, func(ctx context.Context, input *qa.QuestionVoteInput) (*qa.EventQuestionOutput, error) {
+		return qa.VoteQuestion(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "answer-event-question",
+		Method:      http.MethodPut,
+		Path:        "/api/questions/{id}/answer",
+		Summary:     "Ответить на вопрос",
+		Description: "Для организаторов. Автору приходит уведомление",
+		Tags:        []string{"Вопросы"},
+	}, func(ctx context.Context, input *qa.QuestionAnswerInput) (*qa.EventQuestionOutput, error) {
+		return qa.AnswerQuestion(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "moderate-event-question",
+		Method:      http.MethodPatch,
+		Path:        "/api/questions/{id}",
+		Summary:     "Закрепить, вынести в частые вопросы или скрыть",
+		Description: "Для организаторов. Меняются только переданные поля",
+		Tags:        []string{"Вопросы"},
+	}, func(ctx context.Context, input *qa.QuestionModerateInput) (*qa.EventQuestionOutput, error) {
+		return qa.ModerateQuestion(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "del-event-question",
+		Method:      http.MethodDelete,
+		Path:        "/api/questions/{id}",
+		Summary:     "Удалить вопрос",
+		Description: "Организатор удаляет любой вопрос, автор - свой, пока на него не ответили",
+		Tags:        []string{"Вопросы"},
+	}, func(ctx context.Context, input *qa.EventQuestionInput) (*qa.QuestionDelOutput, error) {
+		return qa.DelQuestion(input, db)
+	})
+}
@@ -0,0 +1,111 @@
+package results
+
+import (
+	"context"
+	"database/sql"
+	"hackaton-jam-back/controllers/results"
+	"hackaton-jam-back/controllers/utils"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+func Route(api huma.API, db *sql.DB) {
+	huma.Register(api, huma.Operation{
+		OperationID: "get-results",
+		Method:      http.MethodGet,
+		Path:        "/api/event/{urid}/results",
+		Summary:     "Итоги мероприятия",
+		Description: "Доступны только после публикации",
+		Tags:        []string{"Итоги и награды"},
+	}, func(ctx context.Context, input *struct {
+		Urid   string `path:"urid" maxLength:"30" example:"example_events" doc:"Ссылка на мероприятие"`
+		CaseId int64  `query:"case_id" example:"1" doc:"Только результаты по этому кейсу"`
+		utils.ViewerInput
+	}) (*results.EventResultsOutput, error) {
+		return results.GetPublicResults(input.Urid, input.CaseId, input.Token, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-results-preview",
+		Method:      http.MethodPost,
+		Path:        "/api/event/{urid}/results",
+		Summary:     "Итоги мероприятия до публикации (только для организаторов)",
+		Tags:        []string{"Итоги и награды"},
+	}, func(ctx context.Context, input *results.ResultsPreviewInput) (*results.EventResultsOutput, error) {
+		return results.GetOrganizatorResults(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "add-result",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/results",
+		Summary:     "Добавить место или номинацию команде",
+		Tags:        []string{"Итоги и награды"},
+	}, func(ctx context.Context, input *results.ResultAddInput) (*results.EventResultsOutput, error) {
+		return results.AddResult(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "del-result",
+		Method:      http.MethodDelete,
+		Path:        "/api/event/{urid}/results/{id}",
+		Summary:     "Удалить место или номинацию",
+		Tags:        []string{"Итоги и награды"},
+	}, func(ctx context.Context, input *results.ResultDelInput) (*results.EventResultsOutput, error) {
+		return results.DelResult(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "add-results-from-leaderboard",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/results/from-leaderboard",
+		Summary:     "Заполнить призовые места по таблице лидеров жюри",
+		Tags:        []string{"Итоги и награды"},
+	}, func(ctx context.Context, input *results.ResultsFromLeaderboardInput) (*results.EventResultsOutput, error) {
+		return results.AddResultsFromLeaderboard(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "add-results-from-audience-voting",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/results/audience-choice",
+		Summary:     "Наградить победителя зрительского голосования",
+		Description: "Доступно после окончания голосования. При равенстве голосов номинацию получают все лидеры",
+		Tags:        []string{"Итоги и награды"},
+	}, func(ctx context.Context, input *results.ResultsAudienceChoiceInput) (*results.EventResultsOutput, error) {
+		return results.AddAudienceChoice(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "publish-results",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/results/publish",
+		Summary:     "Опубликовать и зафиксировать итоги",
+		Description: "Публикует итоги, фиксирует их от изменений и уведомляет всех участников награжденных команд",
+		Tags:        []string{"Итоги и награды"},
+	}, func(ctx context.Context, input *results.ResultsEventInput) (*results.EventResultsOutput, error) {
+		return results.PublishResults(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "unlock-results",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/results/unlock",
+		Summary:     "Снять фиксацию итогов",
+		Description: "Снимает фиксацию итогов (причина записывается в журнал изменений)",
+		Tags:        []string{"Итоги и награды"},
+	}, func(ctx context.Context, input *results.ResultsUnlockInput) (*results.EventResultsOutput, error) {
+		return results.UnlockResults(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-results-audit",
+		Method:      http.MethodPost,
+		Path:        "/api/event/{urid}/results/audit",
+		Summary:     "Журнал изменений итогов (только для организаторов)",
+		Tags:        []string{"Итоги и награды"},
+	}, func(ctx context.Context, input *results.ResultsEventInput) (*results.ResultsAuditOutput, error) {
+		return results.GetResultsAudit(input, db)
+	})
+}
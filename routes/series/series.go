@@ -0,0 +1,136 @@
+package series
+
+import (
+	"context"
+	"database/sql"
+	"hackaton-jam-back/controllers/series"
+	"hackaton-jam-back/controllers/utils"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+func Route(api huma.API, db *sql.DB) {
+	huma.Register(api, huma.Operation{
+		OperationID: "get-all-series",
+		Method:      http.MethodGet,
+		Path:        "/api/series",
+		Summary:     "Получить все серии мероприятий",
+		Tags:        []string{"Серии мероприятий"},
+	}, func(ctx context.Context, input *struct{}) (*series.SeriesListOutput, error) {
+		return series.GetAllSeries(db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-series",
+		Method:      http.MethodGet,
+		Path:        "/api/series/{urid}",
+		Summary:     "Страница серии с прошедшими и будущими выпусками",
+		Tags:        []string{"Серии мероприятий"},
+	}, func(ctx context.Context, input *struct {
+		Urid string `path:"urid" maxLength:"30" example:"autumn_jams" doc:"Ссылка на серию"`
+		utils.LocaleInput
+	}) (*series.SeriesOutput, error) {
+		return series.GetSeries(input.Urid, input.LocaleInput, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "create-series",
+		Method:      http.MethodPost,
+		Path:        "/api/series/create",
+		Summary:     "Создать серию (только для организаторов)",
+		Tags:        []string{"Серии мероприятий"},
+	}, func(ctx context.Context, input *series.SeriesCreateInput) (*series.SeriesOutput, error) {
+		return series.CreateSeries(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "edit-series",
+		Method:      http.MethodPatch,
+		Path:        "/api/series/{urid}",
+		Summary:     "Редактировать серию",
+		Tags:        []string{"Серии мероприятий"},
+	}, func(ctx context.Context, input *series.SeriesEditInput) (*series.SeriesOutput, error) {
+		return series.EditSeries(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "del-series",
+		Method:      http.MethodDelete,
+		Path:        "/api/series/{urid}",
+		Summary:     "Удалить серию",
+		Description: "Мероприятия не удаляются, а только выходят из серии",
+		Tags:        []string{"Серии мероприятий"},
+	}, func(ctx context.Context, input *series.SeriesTokenInput) (*series.SeriesListOutput, error) {
+		return series.DeleteSeries(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "add-series-event",
+		Method:      http.MethodPut,
+		Path:        "/api/series/{urid}/events",
+		Summary:     "Добавить мероприятие в серию",
+		Description: "Подписчики серии получат уведомление, если мероприятие опубликовано и еще не началось",
+		Tags:        []string{"Серии мероприятий"},
+	}, func(ctx context.Context, input *series.SeriesEventInput) (*series.SeriesOutput, error) {
+		return series.AddSeriesEvent(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "del-series-event",
+		Method:      http.MethodDelete,
+		Path:        "/api/series/{urid}/events",
+		Summary:     "Убрать мероприятие из серии",
+		Tags:        []string{"Серии мероприятий"},
+	}, func(ctx context.Context, input *series.SeriesEventInput) (*series.SeriesOutput, error) {
+		return series.DelSeriesEvent(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-season-leaderboard",
+		Method:      http.MethodGet,
+		Path:        "/api/series/{urid}/leaderboard",
+		Summary:     "Турнирная таблица сезона",
+		Description: "Считается по опубликованным итогам мероприятий серии",
+		Tags:        []string{"Серии мероприятий"},
+	}, func(ctx context.Context, input *struct {
+		Urid   string `path:"urid" maxLength:"30" example:"autumn_jams" doc:"Ссылка на серию"`
+		Season string `query:"season" example:"2026" doc:"Сезон (пусто - за все время)"`
+	}) (*series.SeasonLeaderboardOutput, error) {
+		return series.GetSeasonLeaderboard(input.Urid, input.Season, db)
+	})
+
+	/// ====
+	/// Подписки
+	/// ====
+
+	huma.Register(api, huma.Operation{
+		OperationID: "subscribe-series",
+		Method:      http.MethodPut,
+		Path:        "/api/series/{urid}/subscribe",
+		Summary:     "Подписаться на серию",
+		Tags:        []string{"Подписки на серии"},
+	}, func(ctx context.Context, input *series.SeriesTokenInput) (*series.SeriesOutput, error) {
+		return series.Subscribe(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "unsubscribe-series",
+		Method:      http.MethodDelete,
+		Path:        "/api/series/{urid}/subscribe",
+		Summary:     "Отписаться от серии",
+		Tags:        []string{"Подписки на серии"},
+	}, func(ctx context.Context, input *series.SeriesTokenInput) (*series.SeriesOutput, error) {
+		return series.Unsubscribe(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-my-series-subscriptions",
+		Method:      http.MethodPost,
+		Path:        "/api/series/subscriptions",
+		Summary:     "Мои подписки на серии",
+		Tags:        []string{"Подписки на серии"},
+	}, func(ctx context.Context, input *utils.JustAccessTokenInput) (*series.SeriesListOutput, error) {
+		return series.GetMySubscriptions(input, db)
+	})
+}
@@ -0,0 +1,64 @@
+package submissions
+
+import (
+	"context"
+	"database/sql"
+	"hackaton-jam-back/controllers/submissions"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+func Route(api huma.API, db *sql.DB) {
+	huma.Register(api, huma.Operation{
+		OperationID: "save-submission",
+		Method:      http.MethodPut,
+		Path:        "/api/team/{id}/submission",
+		Summary:     "Сдать/обновить работу команды",
+		Description: "Сохраняет новую версию работы (только тимлид, до крайнего срока)",
+		Tags:        []string{"Работы команд"},
+	}, func(ctx context.Context, input *submissions.SubmissionSaveInput) (*submissions.SubmissionOutput, error) {
+		return submissions.SaveSubmission(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-submission",
+		Method:      http.MethodPost,
+		Path:        "/api/team/{id}/submission",
+		Summary:     "Получить работу команды",
+		Tags:        []string{"Работы команд"},
+	}, func(ctx context.Context, input *submissions.SubmissionGetInput) (*submissions.SubmissionOutput, error) {
+		return submissions.GetSubmission(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-submission-history",
+		Method:      http.MethodPost,
+		Path:        "/api/team/{id}/submission/history",
+		Summary:     "История версий работы команды",
+		Tags:        []string{"Работы команд"},
+	}, func(ctx context.Context, input *submissions.SubmissionGetInput) (*submissions.SubmissionHistoryOutput, error) {
+		return submissions.GetSubmissionHistory(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-event-submissions",
+		Method:      http.MethodPost,
+		Path:        "/api/event/{urid}/submissions",
+		Summary:     "Все работы события (только для организаторов)",
+		Description: "С фильтром case_id работы кейса видит также жюри партнера",
+		Tags:        []string{"Работы команд"},
+	}, func(ctx context.Context, input *submissions.EventSubmissionsInput) (*submissions.EventSubmissionsOutput, error) {
+		return submissions.GetEventSubmissions(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "set-submission-deadline",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/submission-deadline",
+		Summary:     "Установить крайний срок сдачи работ",
+		Tags:        []string{"Работы команд"},
+	}, func(ctx context.Context, input *submissions.SubmissionDeadlineInput) (*submissions.SubmissionDeadlineOutput, error) {
+		return submissions.SetSubmissionDeadline(input, db)
+	})
+}
@@ -67,6 +67,7 @@ func Route(api huma.API, db *sql.DB) {
 		Method:      http.MethodPut,
 		Path:        "/api/team/{id}/kick",
 		Summary:     "Выгнать пользователя из команды",
+		Description: "После начала мероприятия нельзя выгнать участника, если команда станет меньше требования",
 		Tags:        []string{"Команды"},
 	}, func(ctx context.Context, input *teams.TeamKickInput) (*teams.TeamInfoOutput, error) {
 		return teams.KickUser(input, db)
@@ -82,6 +83,16 @@ func Route(api huma.API, db *sql.DB) {
 		return teams.ChangeTeamName(input, db)
 	})
 
+	huma.Register(api, huma.Operation{
+		OperationID: "team-change-track",
+		Method:      http.MethodPatch,
+		Path:        "/api/team/{id}/track",
+		Summary:     "Изменить трек команды",
+		Tags:        []string{"Команды"},
+	}, func(ctx context.Context, input *teams.TeamChangeTrackInput) (*teams.TeamInfoOutput, error) {
+		return teams.ChangeTrack(input, db)
+	})
+
 	huma.Register(api, huma.Operation{
 		OperationID: "team-change-role",
 		Method:      http.MethodPatch,
@@ -91,4 +102,25 @@ func Route(api huma.API, db *sql.DB) {
 	}, func(ctx context.Context, input *teams.TeamChangeMemberRoleInput) (*teams.TeamInfoOutput, error) {
 		return teams.ChangeRole(input, db)
 	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "event-teams-validity",
+		Method:      http.MethodPost,
+		Path:        "/api/event/{urid}/teams/validity",
+		Summary:     "Проверить размеры команд мероприятия",
+		Description: "Для организаторов: какие команды не подходят под требование к размеру. Такие команды не могут сдать проект",
+		Tags:        []string{"Команды"},
+	}, func(ctx context.Context, input *teams.EventTeamsValidityInput) (*teams.EventTeamsValidityOutput, error) {
+		return teams.GetEventTeamsValidity(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-event-teams",
+		Method:      http.MethodGet,
+		Path:        "/api/event/{urid}/teams",
+		Summary:     "Получить команды мероприятия",
+		Tags:        []string{"Команды"},
+	}, func(ctx context.Context, input *teams.EventTeamsInput) (*teams.EventTeamsOutput, error) {
+		return teams.GetEventTeams(input, db)
+	})
 }
@@ -0,0 +1,55 @@
+package uploads
+
+import (
+	"context"
+	"database/sql"
+	"hackaton-jam-back/controllers/storage"
+	"hackaton-jam-back/controllers/uploads"
+	"hackaton-jam-back/controllers/utils"
+	"log"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+func Route(api huma.API, db *sql.DB) {
+	store, err := storage.New()
+	if err != nil {
+		log.Fatalf("Хранилище файлов: %v", err)
+	}
+
+	huma.Register(api, huma.Operation{
+		OperationID: "upload-file",
+		Method:      http.MethodPost,
+		Path:        "/api/upload",
+		Summary:     "Загрузить файл",
+		Description: "multipart/form-data: файл в поле file, токен в access_token, тип в kind (avatar, event_icon, partner_logo, attachment). " +
+			"Для картинок создаются миниатюры. Аватар ставится сразу, иконка и логотип партнера - если передан urid мероприятия",
+		Tags:            []string{"Файлы"},
+		MaxBodyBytes:    uploads.MaxUploadBodyBytes,
+		BodyReadTimeout: uploads.UploadReadTimeout,
+		Middlewares:     huma.Middlewares{utils.LimitBody(api, uploads.MaxUploadBodyBytes)},
+	}, func(ctx context.Context, input *uploads.UploadInput) (*uploads.UploadOutput, error) {
+		return uploads.Upload(input, db, store)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-file",
+		Method:      http.MethodGet,
+		Path:        "/api/files/{name}",
+		Summary:     "Получить загруженный файл",
+		Tags:        []string{"Файлы"},
+	}, func(ctx context.Context, input *uploads.FileInput) (*huma.StreamResponse, error) {
+		return uploads.GetFile(input, store)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "delete-file",
+		Method:      http.MethodDelete,
+		Path:        "/api/files/{id}",
+		Summary:     "Удалить загруженный файл вместе с миниатюрами",
+		Tags:        []string{"Файлы"},
+	}, func(ctx context.Context, input *uploads.FileDeleteInput) (*uploads.FileDeleteOutput, error) {
+		return uploads.DeleteFile(input, db, store)
+	})
+}
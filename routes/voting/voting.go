@@ -0,0 +1,66 @@
+package voting
+
+import (
+	"context"
+	"database/sql"
+	"hackaton-jam-back/controllers/voting"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+func Route(api huma.API, db *sql.DB) {
+	huma.Register(api, huma.Operation{
+		OperationID: "get-voting",
+		Method:      http.MethodPost,
+		Path:        "/api/event/{urid}/voting",
+		Summary:     "Зрительское голосование мероприятия",
+		Description: "С токеном дополнительно показывает свои голоса и остаток. Счет виден в живом режиме, после окончания и организаторам",
+		Tags:        []string{"Зрительское голосование"},
+	}, func(ctx context.Context, input *voting.VotingInput) (*voting.VotingOutput, error) {
+		return voting.GetVoting(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "edit-voting-settings",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/voting/settings",
+		Summary:     "Настроить зрительское голосование",
+		Tags:        []string{"Зрительское голосование"},
+	}, func(ctx context.Context, input *voting.VotingSettingsInput) (*voting.VotingOutput, error) {
+		return voting.EditSettings(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "select-voting-candidates",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/voting/candidates",
+		Summary:     "Отобрать работы в зрительское голосование",
+		Description: "Список заменяется целиком и меняется только до начала голосования. Голосовать можно только за отобранные работы",
+		Tags:        []string{"Зрительское голосование"},
+	}, func(ctx context.Context, input *voting.VotingCandidatesInput) (*voting.VotingOutput, error) {
+		return voting.SelectCandidates(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "audience-vote",
+		Method:      http.MethodPut,
+		Path:        "/api/submission/{id}/vote",
+		Summary:     "Проголосовать за работу",
+		Description: "Только за отобранные работы, с подтвержденной почтой, один раз за работу и не за свою команду",
+		Tags:        []string{"Зрительское голосование"},
+	}, func(ctx context.Context, input *voting.VoteInput) (*voting.VotingOutput, error) {
+		return voting.Vote(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "audience-unvote",
+		Method:      http.MethodDelete,
+		Path:        "/api/submission/{id}/vote",
+		Summary:     "Забрать голос",
+		Description: "Пока голосование идет",
+		Tags:        []string{"Зрительское голосование"},
+	}, func(ctx context.Context, input *voting.VoteInput) (*voting.VotingOutput, error) {
+		return voting.Unvote(input, db)
+	})
+}
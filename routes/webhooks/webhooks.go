@@ -0,0 +1,87 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"hackaton-jam-back/controllers/webhooks"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+func Route(api huma.API, db *sql.DB) {
+	huma.Register(api, huma.Operation{
+		OperationID: "get-webhooks",
+		Method:      http.MethodPost,
+		Path:        "/api/event/{urid}/webhooks",
+		Summary:     "Вебхуки мероприятия (только для организаторов)",
+		Tags:        []string{"Вебхуки"},
+	}, func(ctx context.Context, input *webhooks.WebhooksInput) (*webhooks.WebhooksOutput, error) {
+		return webhooks.GetWebhooks(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "add-webhook",
+		Method:      http.MethodPut,
+		Path:        "/api/event/{urid}/webhooks",
+		Summary:     "Добавить вебхук",
+		Description: "События приходят POST-запросом с JSON-телом. Заголовок X-Hjam-Signature содержит " +
+			"sha256=HMAC-SHA256(секрет, X-Hjam-Timestamp + \".\" + тело) в hex. Секрет показывается только в ответе на этот запрос",
+		Tags: []string{"Вебхуки"},
+	}, func(ctx context.Context, input *webhooks.WebhookAddInput) (*webhooks.WebhookOutput, error) {
+		return webhooks.AddWebhook(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "edit-webhook",
+		Method:      http.MethodPatch,
+		Path:        "/api/webhooks/{id}",
+		Summary:     "Изменить вебхук",
+		Description: "Пока вебхук выключен, события копятся в очереди и уходят после включения",
+		Tags:        []string{"Вебхуки"},
+	}, func(ctx context.Context, input *webhooks.WebhookEditInput) (*webhooks.WebhookOutput, error) {
+		return webhooks.EditWebhook(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "del-webhook",
+		Method:      http.MethodDelete,
+		Path:        "/api/webhooks/{id}",
+		Summary:     "Удалить вебхук вместе с журналом отправок",
+		Tags:        []string{"Вебхуки"},
+	}, func(ctx context.Context, input *webhooks.WebhookIdInput) (*webhooks.WebhooksOutput, error) {
+		return webhooks.DelWebhook(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "test-webhook",
+		Method:      http.MethodPut,
+		Path:        "/api/webhooks/{id}/test",
+		Summary:     "Отправить тестовое событие",
+		Description: "Событие ping уходит сразу, в ответе - результат попытки",
+		Tags:        []string{"Вебхуки"},
+	}, func(ctx context.Context, input *webhooks.WebhookIdInput) (*webhooks.DeliveryOutput, error) {
+		return webhooks.TestWebhook(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-webhook-deliveries",
+		Method:      http.MethodPost,
+		Path:        "/api/webhooks/{id}/deliveries",
+		Summary:     "Журнал отправок вебхука",
+		Tags:        []string{"Вебхуки"},
+	}, func(ctx context.Context, input *webhooks.DeliveriesInput) (*webhooks.DeliveriesOutput, error) {
+		return webhooks.GetDeliveries(input, db)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "retry-webhook-delivery",
+		Method:      http.MethodPut,
+		Path:        "/api/webhook-deliveries/{id}/retry",
+		Summary:     "Повторить отправку",
+		Description: "Отправка уходит сразу, счетчик попыток начинается заново",
+		Tags:        []string{"Вебхуки"},
+	}, func(ctx context.Context, input *webhooks.DeliveryRetryInput) (*webhooks.DeliveryOutput, error) {
+		return webhooks.RetryDelivery(input, db)
+	})
+}
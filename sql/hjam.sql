@@ -11,6 +11,8 @@ CREATE TABLE "users" (
 	"work_time" varchar(255),
 	"loc" varchar(255),
 	"perms" int NOT NULL DEFAULT '0',
+	"is_trusted" bool NOT NULL DEFAULT 'false',
+	"email_verified" bool NOT NULL DEFAULT 'false',
 	CONSTRAINT "users_pk" PRIMARY KEY ("email")
 ) WITH (
   OIDS=FALSE
@@ -51,6 +53,8 @@ CREATE TABLE "teams" (
 	"event_uri" varchar(255) NOT NULL,
 	"name" varchar(255) NOT NULL UNIQUE DEFAULT 'Без названия',
 	"teamleader" varchar(255) NOT NULL,
+	"track" varchar(255) NOT NULL DEFAULT '',
+	"case_id" bigint,
 	CONSTRAINT "teams_pk" PRIMARY KEY ("id")
 ) WITH (
   OIDS=FALSE
@@ -70,10 +74,12 @@ CREATE TABLE "teams_members" (
 CREATE TABLE "notifications" (
 	"id" bigserial NOT NULL,
 	"user" varchar(255) NOT NULL,
-	"team_id" bigint NOT NULL,
+	"team_id" bigint,
 	"type" int NOT NULL,
 	"from" varchar(255) NOT NULL,
-	"event_uri" varchar(255) NOT NULL
+	"event_uri" varchar(255) NOT NULL,
+	"text" TEXT,
+	"created_at" timestamp with time zone NOT NULL DEFAULT now()
 ) WITH (
   OIDS=FALSE
 );
@@ -93,6 +99,33 @@ CREATE TABLE "events" (
 	"is_irl" bool NOT NULL DEFAULT 'false',
 	"team_requirements_type" int NOT NULL DEFAULT '0',
 	"team_requirements_value" int NOT NULL DEFAULT '5',
+	"submission_deadline" timestamp with time zone,
+	"judging_published" bool NOT NULL DEFAULT 'false',
+	"results_published" bool NOT NULL DEFAULT 'false',
+	"results_locked" bool NOT NULL DEFAULT 'false',
+	"is_draft" bool NOT NULL DEFAULT 'false',
+	"series_urid" varchar(255),
+	"season" varchar(255),
+	"visibility" varchar(16) NOT NULL DEFAULT 'public',
+	"access_code" varchar(64),
+	"allowed_domains" varchar(255)[] NOT NULL DEFAULT '{}',
+	"moderation_status" varchar(16) NOT NULL DEFAULT 'approved',
+	"moderation_reason" TEXT,
+	"moderated_by" varchar(255),
+	"moderation_requested_at" timestamp with time zone NOT NULL DEFAULT now(),
+	"city" varchar(255),
+	"latitude" double precision,
+	"longitude" double precision,
+	"feedback_opened_at" timestamp with time zone,
+	"mentor_booking_limit" int NOT NULL DEFAULT '2',
+	"mentor_cancel_hours" int NOT NULL DEFAULT '2',
+	"case_deadline" timestamp with time zone,
+	"voting_starts_at" timestamp with time zone,
+	"voting_ends_at" timestamp with time zone,
+	"voting_limit" int NOT NULL DEFAULT '3',
+	"voting_live" bool NOT NULL DEFAULT 'false',
+	"org_urid" varchar(255),
+	"default_locale" varchar(16) NOT NULL DEFAULT 'ru',
 	CONSTRAINT "events_pk" PRIMARY KEY ("urid")
 ) WITH (
   OIDS=FALSE
@@ -111,7 +144,9 @@ CREATE TABLE "event_orgs" (
 
 CREATE TABLE "event_members" (
 	"event_uri" varchar(255) NOT NULL,
-	"member_email" varchar(255) NOT NULL
+	"member_email" varchar(255) NOT NULL,
+	"joined_at" timestamp with time zone NOT NULL DEFAULT now(),
+	"checkin_token" varchar(32) UNIQUE
 ) WITH (
   OIDS=FALSE
 );
@@ -150,6 +185,582 @@ CREATE TABLE "event_partners" (
 
 
 
+CREATE TABLE "submissions" (
+	"id" bigserial NOT NULL,
+	"team_id" bigint NOT NULL UNIQUE,
+	"event_uri" varchar(255) NOT NULL,
+	"title" varchar(255) NOT NULL,
+	"desc" TEXT,
+	"repo_url" varchar(255),
+	"demo_url" varchar(255),
+	"presentation_url" varchar(255),
+	"files" varchar(255)[] NOT NULL DEFAULT '{}',
+	"version" int NOT NULL DEFAULT '1',
+	"updated_at" timestamp with time zone NOT NULL DEFAULT now(),
+	"in_voting" bool NOT NULL DEFAULT 'false',
+	CONSTRAINT "submissions_pk" PRIMARY KEY ("id")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+CREATE TABLE "submission_history" (
+	"submission_id" bigint NOT NULL,
+	"version" int NOT NULL,
+	"author" varchar(255) NOT NULL,
+	"title" varchar(255) NOT NULL,
+	"desc" TEXT,
+	"repo_url" varchar(255),
+	"demo_url" varchar(255),
+	"presentation_url" varchar(255),
+	"files" varchar(255)[] NOT NULL DEFAULT '{}',
+	"created_at" timestamp with time zone NOT NULL DEFAULT now(),
+	CONSTRAINT "submission_history_pk" PRIMARY KEY ("submission_id", "version")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+CREATE TABLE "judging_criteria" (
+	"id" bigserial NOT NULL,
+	"event_uri" varchar(255) NOT NULL,
+	"name" varchar(255) NOT NULL,
+	"desc" TEXT,
+	"weight" double precision NOT NULL DEFAULT '1',
+	"min_score" int NOT NULL DEFAULT '0',
+	"max_score" int NOT NULL DEFAULT '10',
+	CONSTRAINT "judging_criteria_pk" PRIMARY KEY ("id")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+CREATE TABLE "event_judges" (
+	"event_uri" varchar(255) NOT NULL,
+	"judge_email" varchar(255) NOT NULL,
+	CONSTRAINT "event_judges_pk" PRIMARY KEY ("event_uri", "judge_email")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+CREATE TABLE "judge_assignments" (
+	"submission_id" bigint NOT NULL,
+	"judge_email" varchar(255) NOT NULL,
+	CONSTRAINT "judge_assignments_pk" PRIMARY KEY ("submission_id", "judge_email")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+CREATE TABLE "scores" (
+	"submission_id" bigint NOT NULL,
+	"judge_email" varchar(255) NOT NULL,
+	"criterion_id" bigint NOT NULL,
+	"score" int NOT NULL,
+	"comment" TEXT,
+	"updated_at" timestamp with time zone NOT NULL DEFAULT now(),
+	CONSTRAINT "scores_pk" PRIMARY KEY ("submission_id", "judge_email", "criterion_id")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+CREATE TABLE "event_results" (
+	"id" bigserial NOT NULL,
+	"event_uri" varchar(255) NOT NULL,
+	"track" varchar(255) NOT NULL DEFAULT '',
+	"team_id" bigint NOT NULL,
+	"place" int,
+	"nomination" varchar(255),
+	"prize" varchar(255),
+	"case_id" bigint,
+	"notified" boolean NOT NULL DEFAULT false,
+	CONSTRAINT "event_results_pk" PRIMARY KEY ("id"),
+	CONSTRAINT "event_results_uq" UNIQUE NULLS NOT DISTINCT ("event_uri", "team_id", "track", "case_id", "place", "nomination")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+CREATE TABLE "event_results_audit" (
+	"id" bigserial NOT NULL,
+	"event_uri" varchar(255) NOT NULL,
+	"actor" varchar(255) NOT NULL,
+	"action" varchar(255) NOT NULL,
+	"details" TEXT,
+	"reason" TEXT,
+	"created_at" timestamp with time zone NOT NULL DEFAULT now(),
+	CONSTRAINT "event_results_audit_pk" PRIMARY KEY ("id")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+CREATE TABLE "certificate_templates" (
+	"event_uri" varchar(255) NOT NULL,
+	"type" varchar(255) NOT NULL,
+	"title" varchar(255) NOT NULL,
+	"text" TEXT NOT NULL,
+	"signer" varchar(255),
+	CONSTRAINT "certificate_templates_pk" PRIMARY KEY ("event_uri", "type")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+CREATE TABLE "certificates" (
+	"code" varchar(255) NOT NULL,
+	"event_uri" varchar(255) NOT NULL,
+	"user_email" varchar(255) NOT NULL,
+	"type" varchar(255) NOT NULL,
+	"details" varchar(255),
+	"issued_at" timestamp with time zone NOT NULL DEFAULT now(),
+	CONSTRAINT "certificates_pk" PRIMARY KEY ("code"),
+	CONSTRAINT "certificates_uq" UNIQUE ("event_uri", "user_email", "type")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+CREATE TABLE "event_templates" (
+	"id" bigserial NOT NULL,
+	"owner_email" varchar(255) NOT NULL,
+	"name" varchar(255) NOT NULL,
+	"payload" jsonb NOT NULL,
+	"created_at" timestamp with time zone NOT NULL DEFAULT now(),
+	CONSTRAINT "event_templates_pk" PRIMARY KEY ("id")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+
+CREATE TABLE "event_series" (
+	"urid" varchar(255) NOT NULL,
+	"name" varchar(255) NOT NULL,
+	"desc" TEXT,
+	"icon" varchar(255),
+	"owner_email" varchar(255) NOT NULL,
+	"place_points" int[] NOT NULL DEFAULT '{25,18,15,12,10,8,6,4,2,1}',
+	"nomination_points" int NOT NULL DEFAULT '5',
+	"created_at" timestamp with time zone NOT NULL DEFAULT now(),
+	CONSTRAINT "event_series_pk" PRIMARY KEY ("urid")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+CREATE TABLE "series_subscriptions" (
+	"series_urid" varchar(255) NOT NULL,
+	"user_email" varchar(255) NOT NULL,
+	CONSTRAINT "series_subscriptions_pk" PRIMARY KEY ("series_urid", "user_email")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+
+CREATE TABLE "event_volunteers" (
+	"event_uri" varchar(255) NOT NULL,
+	"volunteer_email" varchar(255) NOT NULL,
+	CONSTRAINT "event_volunteers_pk" PRIMARY KEY ("event_uri", "volunteer_email")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+CREATE TABLE "event_checkins" (
+	"event_uri" varchar(255) NOT NULL,
+	"member_email" varchar(255) NOT NULL,
+	"checked_in_at" timestamp with time zone NOT NULL DEFAULT now(),
+	"checked_in_by" varchar(255) NOT NULL,
+	CONSTRAINT "event_checkins_pk" PRIMARY KEY ("event_uri", "member_email")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+
+CREATE TABLE "event_announcements" (
+	"id" bigserial NOT NULL,
+	"event_uri" varchar(255) NOT NULL,
+	"author" varchar(255) NOT NULL,
+	"target" varchar(255) NOT NULL,
+	"track" varchar(255) NOT NULL DEFAULT '',
+	"text" TEXT NOT NULL,
+	"recipients" int NOT NULL DEFAULT '0',
+	"emailed" bool NOT NULL DEFAULT 'false',
+	"created_at" timestamp with time zone NOT NULL DEFAULT now(),
+	CONSTRAINT "event_announcements_pk" PRIMARY KEY ("id")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+
+CREATE TABLE "event_exits" (
+	"id" bigserial NOT NULL,
+	"event_uri" varchar(255) NOT NULL,
+	"member_email" varchar(255) NOT NULL,
+	"joined_at" timestamp with time zone NOT NULL,
+	"exited_at" timestamp with time zone NOT NULL DEFAULT now(),
+	CONSTRAINT "event_exits_pk" PRIMARY KEY ("id")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+
+CREATE TABLE "event_invitations" (
+	"event_uri" varchar(255) NOT NULL,
+	"email" varchar(255) NOT NULL,
+	"invited_by" varchar(255) NOT NULL,
+	"created_at" timestamp with time zone NOT NULL DEFAULT now(),
+	"accepted_at" timestamp with time zone,
+	CONSTRAINT "event_invitations_pk" PRIMARY KEY ("event_uri","email")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+
+
+CREATE TABLE "uploads" (
+	"id" varchar(32) NOT NULL,
+	"owner_email" varchar(255) NOT NULL,
+	"kind" varchar(32) NOT NULL,
+	"content_type" varchar(255) NOT NULL,
+	"size" bigint NOT NULL,
+	"name" varchar(255) NOT NULL DEFAULT '',
+	"keys" varchar(64)[] NOT NULL,
+	"created_at" timestamp with time zone NOT NULL DEFAULT now(),
+	CONSTRAINT "uploads_pk" PRIMARY KEY ("id")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+
+
+CREATE TABLE "event_invite_links" (
+	"invite" varchar(32) NOT NULL,
+	"event_uri" varchar(255) NOT NULL,
+	"created_by" varchar(255) NOT NULL,
+	"max_uses" int,
+	"uses" int NOT NULL DEFAULT '0',
+	"expires_at" timestamp with time zone,
+	"created_at" timestamp with time zone NOT NULL DEFAULT now(),
+	CONSTRAINT "event_invite_links_pk" PRIMARY KEY ("invite")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+
+
+CREATE TABLE "feedback_questions" (
+	"id" bigserial NOT NULL,
+	"event_uri" varchar(255) NOT NULL,
+	"text" varchar(500) NOT NULL,
+	"type" varchar(16) NOT NULL,
+	"required" bool NOT NULL DEFAULT 'false',
+	"position" int NOT NULL DEFAULT '0',
+	CONSTRAINT "feedback_questions_pk" PRIMARY KEY ("id")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+CREATE TABLE "feedback_respondents" (
+	"event_uri" varchar(255) NOT NULL,
+	"member_email" varchar(255) NOT NULL,
+	CONSTRAINT "feedback_respondents_pk" PRIMARY KEY ("event_uri","member_email")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+CREATE TABLE "feedback_answers" (
+	"event_uri" varchar(255) NOT NULL,
+	"response_id" varchar(32) NOT NULL,
+	"question_id" bigint NOT NULL,
+	"rating" int,
+	"text" TEXT
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+
+
+CREATE TABLE "event_mentors" (
+	"event_uri" varchar(255) NOT NULL,
+	"mentor_email" varchar(255) NOT NULL,
+	"expertise" varchar(255)[] NOT NULL DEFAULT '{}',
+	"bio" TEXT,
+	"added_at" timestamp with time zone NOT NULL DEFAULT now(),
+	CONSTRAINT "event_mentors_pk" PRIMARY KEY ("event_uri","mentor_email")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+CREATE TABLE "mentor_slots" (
+	"id" bigserial NOT NULL,
+	"event_uri" varchar(255) NOT NULL,
+	"mentor_email" varchar(255) NOT NULL,
+	"start_time" timestamp with time zone NOT NULL,
+	"end_time" timestamp with time zone NOT NULL,
+	"location" varchar(255),
+	"team_id" bigint,
+	"booked_by" varchar(255),
+	"booked_at" timestamp with time zone,
+	"reminded" bool NOT NULL DEFAULT 'false',
+	CONSTRAINT "mentor_slots_pk" PRIMARY KEY ("id")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+
+
+CREATE TABLE "event_questions" (
+	"id" bigserial NOT NULL,
+	"event_uri" varchar(255) NOT NULL,
+	"author_email" varchar(255) NOT NULL,
+	"text" TEXT NOT NULL,
+	"answer" TEXT,
+	"answered_by" varchar(255),
+	"answered_at" timestamp with time zone,
+	"is_pinned" bool NOT NULL DEFAULT 'false',
+	"in_faq" bool NOT NULL DEFAULT 'false',
+	"is_hidden" bool NOT NULL DEFAULT 'false',
+	"created_at" timestamp with time zone NOT NULL DEFAULT now(),
+	CONSTRAINT "event_questions_pk" PRIMARY KEY ("id")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+CREATE TABLE "event_question_votes" (
+	"question_id" bigint NOT NULL,
+	"user_email" varchar(255) NOT NULL,
+	CONSTRAINT "event_question_votes_pk" PRIMARY KEY ("question_id","user_email")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+CREATE TABLE "event_cases" (
+	"id" bigserial NOT NULL,
+	"event_uri" varchar(255) NOT NULL,
+	"partner" varchar(255) NOT NULL,
+	"partner_logo_url" varchar(255),
+	"title" varchar(255) NOT NULL,
+	"desc" TEXT NOT NULL DEFAULT '',
+	"attachments" varchar(255)[] NOT NULL DEFAULT '{}',
+	"capacity" int NOT NULL DEFAULT '0',
+	"prize" varchar(255),
+	"created_at" timestamp with time zone NOT NULL DEFAULT now(),
+	CONSTRAINT "event_cases_pk" PRIMARY KEY ("id")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+CREATE TABLE "case_judges" (
+	"case_id" bigint NOT NULL,
+	"judge_email" varchar(255) NOT NULL,
+	CONSTRAINT "case_judges_pk" PRIMARY KEY ("case_id","judge_email")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+CREATE TABLE "email_verifications" (
+	"email" varchar(255) NOT NULL,
+	"code" varchar(64) NOT NULL UNIQUE,
+	"expires_at" timestamp with time zone NOT NULL,
+	CONSTRAINT "email_verifications_pk" PRIMARY KEY ("email")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+CREATE TABLE "audience_votes" (
+	"submission_id" bigint NOT NULL,
+	"voter_email" varchar(255) NOT NULL,
+	"event_uri" varchar(255) NOT NULL,
+	"created_at" timestamp with time zone NOT NULL DEFAULT now(),
+	CONSTRAINT "audience_votes_pk" PRIMARY KEY ("submission_id","voter_email")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+CREATE TABLE "webhooks" (
+	"id" bigserial NOT NULL,
+	"event_uri" varchar(255) NOT NULL,
+	"url" varchar(1024) NOT NULL,
+	"secret" varchar(128) NOT NULL,
+	"types" varchar(64)[] NOT NULL DEFAULT '{}',
+	"is_active" bool NOT NULL DEFAULT 'true',
+	"created_by" varchar(255) NOT NULL,
+	"created_at" timestamp with time zone NOT NULL DEFAULT now(),
+	CONSTRAINT "webhooks_pk" PRIMARY KEY ("id")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+CREATE TABLE "webhook_deliveries" (
+	"id" bigserial NOT NULL,
+	"webhook_id" bigint NOT NULL,
+	"type" varchar(64) NOT NULL,
+	"payload" TEXT NOT NULL,
+	"status" varchar(16) NOT NULL DEFAULT 'pending',
+	"attempts" int NOT NULL DEFAULT '0',
+	"next_attempt_at" timestamp with time zone NOT NULL DEFAULT now(),
+	"response_code" int,
+	"error" TEXT,
+	"created_at" timestamp with time zone NOT NULL DEFAULT now(),
+	"delivered_at" timestamp with time zone,
+	CONSTRAINT "webhook_deliveries_pk" PRIMARY KEY ("id")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+CREATE TABLE "organizations" (
+	"urid" varchar(255) NOT NULL,
+	"name" varchar(255) NOT NULL,
+	"desc" TEXT,
+	"logo" varchar(255),
+	"created_by" varchar(255) NOT NULL,
+	"created_at" timestamp with time zone NOT NULL DEFAULT now(),
+	CONSTRAINT "organizations_pk" PRIMARY KEY ("urid")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+CREATE TABLE "organization_members" (
+	"org_urid" varchar(255) NOT NULL,
+	"member_email" varchar(255) NOT NULL,
+	"role" varchar(16) NOT NULL DEFAULT 'member',
+	"added_at" timestamp with time zone NOT NULL DEFAULT now(),
+	CONSTRAINT "organization_members_pk" PRIMARY KEY ("org_urid","member_email")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+CREATE TABLE "event_translations" (
+	"event_uri" varchar(255) NOT NULL,
+	"locale" varchar(16) NOT NULL,
+	"name" varchar(255),
+	"desc" TEXT,
+	"requirements" TEXT,
+	"prize" varchar(255),
+	"updated_at" timestamp with time zone NOT NULL DEFAULT now(),
+	CONSTRAINT "event_translations_pk" PRIMARY KEY ("event_uri","locale")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+CREATE TABLE "event_agenda" (
+	"id" bigserial NOT NULL,
+	"event_uri" varchar(255) NOT NULL,
+	"title" varchar(255) NOT NULL,
+	"desc" TEXT NOT NULL DEFAULT '',
+	"place" varchar(255) NOT NULL DEFAULT '',
+	"starts_at" timestamp with time zone NOT NULL,
+	"ends_at" timestamp with time zone,
+	CONSTRAINT "event_agenda_pk" PRIMARY KEY ("id")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+CREATE TABLE "event_agenda_translations" (
+	"item_id" bigint NOT NULL,
+	"locale" varchar(16) NOT NULL,
+	"title" varchar(255),
+	"desc" TEXT,
+	"place" varchar(255),
+	CONSTRAINT "event_agenda_translations_pk" PRIMARY KEY ("item_id","locale")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+CREATE TABLE "registration_fields" (
+	"id" bigserial NOT NULL,
+	"event_uri" varchar(255) NOT NULL,
+	"label" varchar(500) NOT NULL,
+	"type" varchar(16) NOT NULL,
+	"options" varchar(255)[] NOT NULL DEFAULT '{}',
+	"required" bool NOT NULL DEFAULT 'false',
+	"position" int NOT NULL DEFAULT '0',
+	CONSTRAINT "registration_fields_pk" PRIMARY KEY ("id")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+CREATE TABLE "registration_answers" (
+	"field_id" bigint NOT NULL,
+	"member_email" varchar(255) NOT NULL,
+	"value" TEXT NOT NULL,
+	CONSTRAINT "registration_answers_pk" PRIMARY KEY ("field_id","member_email")
+) WITH (
+  OIDS=FALSE
+);
+
+
+
+
 
 ALTER TABLE "tokens" ADD CONSTRAINT "tokens_fk0" FOREIGN KEY ("user_email") REFERENCES "users"("email");
 
@@ -158,30 +769,149 @@ ALTER TABLE "skills" ADD CONSTRAINT "skills_fk0" FOREIGN KEY ("user_email") REFE
 ALTER TABLE "contacts" ADD CONSTRAINT "contacts_fk0" FOREIGN KEY ("user_email") REFERENCES "users"("email");
 
 
-ALTER TABLE "teams" ADD CONSTRAINT "teams_fk0" FOREIGN KEY ("event_uri") REFERENCES "events"("urid");
+ALTER TABLE "teams" ADD CONSTRAINT "teams_fk0" FOREIGN KEY ("event_uri") REFERENCES "events"("urid") ON DELETE CASCADE;
 ALTER TABLE "teams" ADD CONSTRAINT "teams_fk1" FOREIGN KEY ("teamleader") REFERENCES "users"("email");
 
-ALTER TABLE "teams_members" ADD CONSTRAINT "teams_members_fk0" FOREIGN KEY ("team_id") REFERENCES "teams"("id");
+ALTER TABLE "teams_members" ADD CONSTRAINT "teams_members_fk0" FOREIGN KEY ("team_id") REFERENCES "teams"("id") ON DELETE CASCADE;
 ALTER TABLE "teams_members" ADD CONSTRAINT "teams_members_fk1" FOREIGN KEY ("member_email") REFERENCES "users"("email");
 
 ALTER TABLE "notifications" ADD CONSTRAINT "notifications_fk0" FOREIGN KEY ("user") REFERENCES "users"("email");
 ALTER TABLE "notifications" ADD CONSTRAINT "notifications_fk1" FOREIGN KEY ("from") REFERENCES "users"("email");
-ALTER TABLE "notifications" ADD CONSTRAINT "notifications_fk2" FOREIGN KEY ("team_id") REFERENCES "teams"("id");
-ALTER TABLE "notifications" ADD CONSTRAINT "notifications_fk3" FOREIGN KEY ("event_uri") REFERENCES "events"("urid");
+ALTER TABLE "notifications" ADD CONSTRAINT "notifications_fk2" FOREIGN KEY ("team_id") REFERENCES "teams"("id") ON DELETE CASCADE;
+ALTER TABLE "notifications" ADD CONSTRAINT "notifications_fk3" FOREIGN KEY ("event_uri") REFERENCES "events"("urid") ON DELETE CASCADE;
 
 
-ALTER TABLE "event_orgs" ADD CONSTRAINT "event_orgs_fk0" FOREIGN KEY ("event_uri") REFERENCES "events"("urid");
+ALTER TABLE "event_orgs" ADD CONSTRAINT "event_orgs_fk0" FOREIGN KEY ("event_uri") REFERENCES "events"("urid") ON DELETE CASCADE;
 ALTER TABLE "event_orgs" ADD CONSTRAINT "event_orgs_fk1" FOREIGN KEY ("organizator_email") REFERENCES "users"("email");
 
-ALTER TABLE "event_members" ADD CONSTRAINT "event_members_fk0" FOREIGN KEY ("event_uri") REFERENCES "events"("urid");
+ALTER TABLE "event_members" ADD CONSTRAINT "event_members_fk0" FOREIGN KEY ("event_uri") REFERENCES "events"("urid") ON DELETE CASCADE;
 ALTER TABLE "event_members" ADD CONSTRAINT "event_members_fk1" FOREIGN KEY ("member_email") REFERENCES "users"("email");
 
-ALTER TABLE "event_blog" ADD CONSTRAINT "event_blog_fk0" FOREIGN KEY ("event_uri") REFERENCES "events"("urid");
+ALTER TABLE "event_blog" ADD CONSTRAINT "event_blog_fk0" FOREIGN KEY ("event_uri") REFERENCES "events"("urid") ON DELETE CASCADE;
 ALTER TABLE "event_blog" ADD CONSTRAINT "event_blog_fk1" FOREIGN KEY ("author") REFERENCES "users"("email");
 
-ALTER TABLE "event_tags" ADD CONSTRAINT "event_tags_fk0" FOREIGN KEY ("event_uri") REFERENCES "events"("urid");
+ALTER TABLE "event_tags" ADD CONSTRAINT "event_tags_fk0" FOREIGN KEY ("event_uri") REFERENCES "events"("urid") ON DELETE CASCADE;
+
+ALTER TABLE "event_partners" ADD CONSTRAINT "event_partners_fk0" FOREIGN KEY ("event_uri") REFERENCES "events"("urid") ON DELETE CASCADE;
+
+ALTER TABLE "submissions" ADD CONSTRAINT "submissions_fk0" FOREIGN KEY ("team_id") REFERENCES "teams"("id") ON DELETE CASCADE;
+ALTER TABLE "submissions" ADD CONSTRAINT "submissions_fk1" FOREIGN KEY ("event_uri") REFERENCES "events"("urid") ON DELETE CASCADE;
+
+ALTER TABLE "submission_history" ADD CONSTRAINT "submission_history_fk0" FOREIGN KEY ("submission_id") REFERENCES "submissions"("id") ON DELETE CASCADE;
+ALTER TABLE "submission_history" ADD CONSTRAINT "submission_history_fk1" FOREIGN KEY ("author") REFERENCES "users"("email");
+
+ALTER TABLE "judging_criteria" ADD CONSTRAINT "judging_criteria_fk0" FOREIGN KEY ("event_uri") REFERENCES "events"("urid") ON DELETE CASCADE;
+
+ALTER TABLE "event_judges" ADD CONSTRAINT "event_judges_fk0" FOREIGN KEY ("event_uri") REFERENCES "events"("urid") ON DELETE CASCADE;
+ALTER TABLE "event_judges" ADD CONSTRAINT "event_judges_fk1" FOREIGN KEY ("judge_email") REFERENCES "users"("email");
+
+ALTER TABLE "judge_assignments" ADD CONSTRAINT "judge_assignments_fk0" FOREIGN KEY ("submission_id") REFERENCES "submissions"("id") ON DELETE CASCADE;
+ALTER TABLE "judge_assignments" ADD CONSTRAINT "judge_assignments_fk1" FOREIGN KEY ("judge_email") REFERENCES "users"("email");
+
+ALTER TABLE "scores" ADD CONSTRAINT "scores_fk0" FOREIGN KEY ("submission_id") REFERENCES "submissions"("id") ON DELETE CASCADE;
+ALTER TABLE "scores" ADD CONSTRAINT "scores_fk1" FOREIGN KEY ("judge_email") REFERENCES "users"("email");
+ALTER TABLE "scores" ADD CONSTRAINT "scores_fk2" FOREIGN KEY ("criterion_id") REFERENCES "judging_criteria"("id") ON DELETE CASCADE;
+
+ALTER TABLE "event_results" ADD CONSTRAINT "event_results_fk0" FOREIGN KEY ("event_uri") REFERENCES "events"("urid") ON DELETE CASCADE;
+ALTER TABLE "event_results" ADD CONSTRAINT "event_results_fk1" FOREIGN KEY ("team_id") REFERENCES "teams"("id") ON DELETE CASCADE;
+
+ALTER TABLE "event_results_audit" ADD CONSTRAINT "event_results_audit_fk1" FOREIGN KEY ("actor") REFERENCES "users"("email");
+
+ALTER TABLE "certificate_templates" ADD CONSTRAINT "certificate_templates_fk0" FOREIGN KEY ("event_uri") REFERENCES "events"("urid") ON DELETE CASCADE;
+
+ALTER TABLE "certificates" ADD CONSTRAINT "certificates_fk0" FOREIGN KEY ("event_uri") REFERENCES "events"("urid") ON DELETE CASCADE;
+ALTER TABLE "certificates" ADD CONSTRAINT "certificates_fk1" FOREIGN KEY ("user_email") REFERENCES "users"("email");
+
+ALTER TABLE "event_templates" ADD CONSTRAINT "event_templates_fk0" FOREIGN KEY ("owner_email") REFERENCES "users"("email");
+
+ALTER TABLE "events" ADD CONSTRAINT "events_fk0" FOREIGN KEY ("series_urid") REFERENCES "event_series"("urid");
+
+ALTER TABLE "event_series" ADD CONSTRAINT "event_series_fk0" FOREIGN KEY ("owner_email") REFERENCES "users"("email");
+
+ALTER TABLE "series_subscriptions" ADD CONSTRAINT "series_subscriptions_fk0" FOREIGN KEY ("series_urid") REFERENCES "event_series"("urid");
+ALTER TABLE "series_subscriptions" ADD CONSTRAINT "series_subscriptions_fk1" FOREIGN KEY ("user_email") REFERENCES "users"("email");
+
+ALTER TABLE "event_volunteers" ADD CONSTRAINT "event_volunteers_fk0" FOREIGN KEY ("event_uri") REFERENCES "events"("urid") ON DELETE CASCADE;
+ALTER TABLE "event_volunteers" ADD CONSTRAINT "event_volunteers_fk1" FOREIGN KEY ("volunteer_email") REFERENCES "users"("email");
+
+ALTER TABLE "event_checkins" ADD CONSTRAINT "event_checkins_fk0" FOREIGN KEY ("event_uri") REFERENCES "events"("urid") ON DELETE CASCADE;
+ALTER TABLE "event_checkins" ADD CONSTRAINT "event_checkins_fk1" FOREIGN KEY ("member_email") REFERENCES "users"("email");
+ALTER TABLE "event_checkins" ADD CONSTRAINT "event_checkins_fk2" FOREIGN KEY ("checked_in_by") REFERENCES "users"("email");
+
+ALTER TABLE "event_announcements" ADD CONSTRAINT "event_announcements_fk0" FOREIGN KEY ("event_uri") REFERENCES "events"("urid") ON DELETE CASCADE;
+ALTER TABLE "event_announcements" ADD CONSTRAINT "event_announcements_fk1" FOREIGN KEY ("author") REFERENCES "users"("email");
+
+ALTER TABLE "event_exits" ADD CONSTRAINT "event_exits_fk0" FOREIGN KEY ("event_uri") REFERENCES "events"("urid") ON DELETE CASCADE;
+ALTER TABLE "event_exits" ADD CONSTRAINT "event_exits_fk1" FOREIGN KEY ("member_email") REFERENCES "users"("email");
+
+ALTER TABLE "event_invitations" ADD CONSTRAINT "event_invitations_fk0" FOREIGN KEY ("event_uri") REFERENCES "events"("urid") ON DELETE CASCADE;
+ALTER TABLE "event_invitations" ADD CONSTRAINT "event_invitations_fk1" FOREIGN KEY ("invited_by") REFERENCES "users"("email");
+
+ALTER TABLE "uploads" ADD CONSTRAINT "uploads_fk0" FOREIGN KEY ("owner_email") REFERENCES "users"("email");
+
+ALTER TABLE "event_invite_links" ADD CONSTRAINT "event_invite_links_fk0" FOREIGN KEY ("event_uri") REFERENCES "events"("urid") ON DELETE CASCADE;
+ALTER TABLE "event_invite_links" ADD CONSTRAINT "event_invite_links_fk1" FOREIGN KEY ("created_by") REFERENCES "users"("email");
+
+ALTER TABLE "events" ADD CONSTRAINT "events_fk1" FOREIGN KEY ("moderated_by") REFERENCES "users"("email");
+
+ALTER TABLE "feedback_questions" ADD CONSTRAINT "feedback_questions_fk0" FOREIGN KEY ("event_uri") REFERENCES "events"("urid") ON DELETE CASCADE;
+
+ALTER TABLE "feedback_respondents" ADD CONSTRAINT "feedback_respondents_fk0" FOREIGN KEY ("event_uri") REFERENCES "events"("urid") ON DELETE CASCADE;
+ALTER TABLE "feedback_respondents" ADD CONSTRAINT "feedback_respondents_fk1" FOREIGN KEY ("member_email") REFERENCES "users"("email");
+
+ALTER TABLE "feedback_answers" ADD CONSTRAINT "feedback_answers_fk0" FOREIGN KEY ("event_uri") REFERENCES "events"("urid") ON DELETE CASCADE;
+ALTER TABLE "feedback_answers" ADD CONSTRAINT "feedback_answers_fk1" FOREIGN KEY ("question_id") REFERENCES "feedback_questions"("id") ON DELETE CASCADE;
+
+ALTER TABLE "event_mentors" ADD CONSTRAINT "event_mentors_fk0" FOREIGN KEY ("event_uri") REFERENCES "events"("urid") ON DELETE CASCADE;
+ALTER TABLE "event_mentors" ADD CONSTRAINT "event_mentors_fk1" FOREIGN KEY ("mentor_email") REFERENCES "users"("email");
+
+ALTER TABLE "mentor_slots" ADD CONSTRAINT "mentor_slots_fk0" FOREIGN KEY ("event_uri", "mentor_email") REFERENCES "event_mentors"("event_uri", "mentor_email") ON DELETE CASCADE;
+ALTER TABLE "mentor_slots" ADD CONSTRAINT "mentor_slots_fk1" FOREIGN KEY ("team_id") REFERENCES "teams"("id") ON DELETE SET NULL;
+ALTER TABLE "mentor_slots" ADD CONSTRAINT "mentor_slots_fk2" FOREIGN KEY ("booked_by") REFERENCES "users"("email");
+
+ALTER TABLE "event_questions" ADD CONSTRAINT "event_questions_fk0" FOREIGN KEY ("event_uri") REFERENCES "events"("urid") ON DELETE CASCADE;
+ALTER TABLE "event_questions" ADD CONSTRAINT "event_questions_fk1" FOREIGN KEY ("author_email") REFERENCES "users"("email");
+ALTER TABLE "event_questions" ADD CONSTRAINT "event_questions_fk2" FOREIGN KEY ("answered_by") REFERENCES "users"("email");
+
+ALTER TABLE "event_question_votes" ADD CONSTRAINT "event_question_votes_fk0" FOREIGN KEY ("question_id") REFERENCES "event_questions"("id") ON DELETE CASCADE;
+ALTER TABLE "event_question_votes" ADD CONSTRAINT "event_question_votes_fk1" FOREIGN KEY ("user_email") REFERENCES "users"("email");
+
+ALTER TABLE "event_cases" ADD CONSTRAINT "event_cases_fk0" FOREIGN KEY ("event_uri") REFERENCES "events"("urid") ON DELETE CASCADE;
+
+ALTER TABLE "case_judges" ADD CONSTRAINT "case_judges_fk0" FOREIGN KEY ("case_id") REFERENCES "event_cases"("id") ON DELETE CASCADE;
+ALTER TABLE "case_judges" ADD CONSTRAINT "case_judges_fk1" FOREIGN KEY ("judge_email") REFERENCES "users"("email");
+
+ALTER TABLE "teams" ADD CONSTRAINT "teams_fk2" FOREIGN KEY ("case_id") REFERENCES "event_cases"("id") ON DELETE SET NULL;
+ALTER TABLE "event_results" ADD CONSTRAINT "event_results_fk2" FOREIGN KEY ("case_id") REFERENCES "event_cases"("id") ON DELETE CASCADE;
+
+ALTER TABLE "email_verifications" ADD CONSTRAINT "email_verifications_fk0" FOREIGN KEY ("email") REFERENCES "users"("email");
+
+ALTER TABLE "audience_votes" ADD CONSTRAINT "audience_votes_fk0" FOREIGN KEY ("submission_id") REFERENCES "submissions"("id") ON DELETE CASCADE;
+ALTER TABLE "audience_votes" ADD CONSTRAINT "audience_votes_fk1" FOREIGN KEY ("voter_email") REFERENCES "users"("email");
+ALTER TABLE "audience_votes" ADD CONSTRAINT "audience_votes_fk2" FOREIGN KEY ("event_uri") REFERENCES "events"("urid") ON DELETE CASCADE;
+
+ALTER TABLE "webhooks" ADD CONSTRAINT "webhooks_fk0" FOREIGN KEY ("event_uri") REFERENCES "events"("urid") ON DELETE CASCADE;
+ALTER TABLE "webhooks" ADD CONSTRAINT "webhooks_fk1" FOREIGN KEY ("created_by") REFERENCES "users"("email");
+
+ALTER TABLE "webhook_deliveries" ADD CONSTRAINT "webhook_deliveries_fk0" FOREIGN KEY ("webhook_id") REFERENCES "webhooks"("id") ON DELETE CASCADE;
+
+ALTER TABLE "events" ADD CONSTRAINT "events_fk2" FOREIGN KEY ("org_urid") REFERENCES "organizations"("urid");
+
+ALTER TABLE "organizations" ADD CONSTRAINT "organizations_fk0" FOREIGN KEY ("created_by") REFERENCES "users"("email");
+
+ALTER TABLE "organization_members" ADD CONSTRAINT "organization_members_fk0" FOREIGN KEY ("org_urid") REFERENCES "organizations"("urid");
+ALTER TABLE "organization_members" ADD CONSTRAINT "organization_members_fk1" FOREIGN KEY ("member_email") REFERENCES "users"("email");
+
+ALTER TABLE "event_translations" ADD CONSTRAINT "event_translations_fk0" FOREIGN KEY ("event_uri") REFERENCES "events"("urid") ON DELETE CASCADE;
+
+ALTER TABLE "event_agenda" ADD CONSTRAINT "event_agenda_fk0" FOREIGN KEY ("event_uri") REFERENCES "events"("urid") ON DELETE CASCADE;
+
+ALTER TABLE "event_agenda_translations" ADD CONSTRAINT "event_agenda_translations_fk0" FOREIGN KEY ("item_id") REFERENCES "event_agenda"("id") ON DELETE CASCADE;
+
+ALTER TABLE "registration_fields" ADD CONSTRAINT "registration_fields_fk0" FOREIGN KEY ("event_uri") REFERENCES "events"("urid") ON DELETE CASCADE;
 
-ALTER TABLE "event_partners" ADD CONSTRAINT "event_partners_fk0" FOREIGN KEY ("event_uri") REFERENCES "events"("urid");
+ALTER TABLE "registration_answers" ADD CONSTRAINT "registration_answers_fk0" FOREIGN KEY ("field_id") REFERENCES "registration_fields"("id") ON DELETE CASCADE;
+ALTER TABLE "registration_answers" ADD CONSTRAINT "registration_answers_fk1" FOREIGN KEY ("member_email") REFERENCES "users"("email");
 
 
 -- Пробные данные
@@ -189,12 +919,12 @@ INSERT INTO "users" ("email", "username", "first_name", "last_name", "password",
 INSERT INTO "users" ("email", "username", "first_name", "last_name", "password", "perms") VALUES ('thatmaidguy2@ya.ru', 'organizator', 'Организатор', 'Организаторов', '$2a$10$DmTlEGzS/Ix0JFfTT3hmH.ZLliSvSMRlkTBVoo2F6uBZiQwXP1YVy', 1);
 INSERT INTO "users" ("email", "username", "first_name", "last_name", "password", "perms") VALUES ('thatmaidguy3@ya.ru', 'user', 'Иван', 'Иванов', '$2a$10$DmTlEGzS/Ix0JFfTT3hmH.ZLliSvSMRlkTBVoo2F6uBZiQwXP1YVy', 0);
 
-INSERT INTO "events" ("urid", "name", "start_time", "end_time", "prize", "location", "desc", "requirements", "icon", "is_irl", "team_requirements_type", "team_requirements_value") VALUES ('example_event', 'Example Event 1', '2022-05-20 15:00:10-09', '2022-05-21 15:00:10-09', '200 рублей выплот', 'Екатеринбург', 'Тестовое описание', 'тест', '', false, 0, 5);
+INSERT INTO "events" ("urid", "name", "start_time", "end_time", "prize", "location", "desc", "requirements", "icon", "is_irl", "team_requirements_type", "team_requirements_value", "city", "latitude", "longitude") VALUES ('example_event', 'Example Event 1', '2022-05-20 15:00:10-09', '2022-05-21 15:00:10-09', '200 рублей выплот', 'Екатеринбург', 'Тестовое описание', 'тест', '', false, 0, 5, 'Екатеринбург', 56.8389, 60.6057);
 INSERT INTO "event_orgs" ("event_uri", "organizator_email") VALUES ('example_event', 'thatmaidguy2@ya.ru');
 INSERT INTO "event_tags" ("event_uri", "tag") VALUES ('example_event', 'Тег 1');
 INSERT INTO "event_tags" ("event_uri", "tag") VALUES ('example_event', 'Тег 2');
 
-INSERT INTO "events" ("urid", "name", "start_time", "end_time", "prize", "location", "desc", "requirements", "icon", "is_irl", "team_requirements_type", "team_requirements_value") VALUES ('example_event2', 'Example Event 2', '2022-05-20 15:00:10-09', '2022-05-21 15:00:10-09', '100 рублей выплот', 'Екатеринбург', 'Тестовое описание', 'тест', '', false, 0, 5);
+INSERT INTO "events" ("urid", "name", "start_time", "end_time", "prize", "location", "desc", "requirements", "icon", "is_irl", "team_requirements_type", "team_requirements_value", "city", "latitude", "longitude") VALUES ('example_event2', 'Example Event 2', '2022-05-20 15:00:10-09', '2022-05-21 15:00:10-09', '100 рублей выплот', 'Екатеринбург', 'Тестовое описание', 'тест', '', false, 0, 5, 'Екатеринбург', 56.8389, 60.6057);
 INSERT INTO "event_orgs" ("event_uri", "organizator_email") VALUES ('example_event2', 'thatmaidguy2@ya.ru');
 INSERT INTO "event_tags" ("event_uri", "tag") VALUES ('example_event2', 'Тег 1');
-INSERT INTO "event_tags" ("event_uri", "tag") VALUES ('example_event2', 'Тег 3');
\ No newline at end of file
+INSERT INTO "event_tags" ("event_uri", "tag") VALUES ('example_event2', 'Тег 3');